@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type apiKeyEntry struct {
+	KeySHA256 string   `json:"key_sha256"`
+	Principal string   `json:"principal"`
+	Scopes    []string `json:"scopes"`
+	Wallets   []int    `json:"wallets"`
+}
+
+// APIKeys authenticates callers by the X-API-Key header. Only SHA-256
+// hashes of the keys are kept, so the key file never holds usable secrets.
+type APIKeys struct {
+	entries []apiKeyEntry
+}
+
+// LoadAPIKeys reads a JSON array of
+// {"key_sha256": "...", "principal": "...", "scopes": [...], "wallets": [...]}.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []apiKeyEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i, entry := range entries {
+		if len(entry.KeySHA256) != sha256.Size*2 || entry.Principal == "" {
+			return nil, fmt.Errorf("%s: entry %d needs a hex key_sha256 and a principal", path, i)
+		}
+	}
+
+	return &APIKeys{entries: entries}, nil
+}
+
+func (k *APIKeys) Authenticate(key string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+
+	for _, entry := range k.entries {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(entry.KeySHA256)) == 1 {
+			return &Principal{
+				ID:      entry.Principal,
+				Method:  "api_key",
+				Scopes:  entry.Scopes,
+				Wallets: entry.Wallets,
+			}, nil
+		}
+	}
+
+	return nil, ErrUnauthenticated
+}
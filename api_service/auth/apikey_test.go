@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeAPIKeys(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAPIKeys(t *testing.T) {
+	sum := sha256.Sum256([]byte("merchant-key"))
+	keys, err := LoadAPIKeys(writeAPIKeys(t, `[{"key_sha256": "`+hex.EncodeToString(sum[:])+`", "principal": "merchant", "scopes": ["wallets:read"], "wallets": [3]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := keys.Authenticate("merchant-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.ID != "merchant" || principal.Method != "api_key" || !principal.OwnsWallet(3) {
+		t.Errorf("principal = %+v", principal)
+	}
+
+	for _, key := range []string{"wrong-key", "merchant-key ", "", hex.EncodeToString(sum[:])} {
+		if principal, err := keys.Authenticate(key); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Authenticate(%q) = %+v, %v, want ErrUnauthenticated", key, principal, err)
+		}
+	}
+}
+
+func TestLoadAPIKeysErrors(t *testing.T) {
+	for _, content := range []string{
+		`{`,
+		`{"key_sha256": "00", "principal": "merchant"}`,
+		`[{"key_sha256": "00", "principal": "merchant"}]`,
+		`[{"key_sha256": "` + hex.EncodeToString(make([]byte, sha256.Size)) + `"}]`,
+	} {
+		if _, err := LoadAPIKeys(writeAPIKeys(t, content)); err == nil {
+			t.Errorf("LoadAPIKeys(%s) succeeded", content)
+		}
+	}
+}
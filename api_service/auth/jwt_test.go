@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret   = "test-hs256-secret"
+	testIssuer   = "https://issuer.example"
+	testAudience = "api_service"
+	testKid      = "key-1"
+)
+
+// writeJWKS stores the public half of key in a JWKS file under kid.
+func writeJWKS(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":     "merchant",
+		"iss":     testIssuer,
+		"aud":     testAudience,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"scope":   "wallets:read payments:write",
+		"wallets": []int{1, 2},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})
+
+	jwks := writeJWKS(t, testKid, rsaKey)
+	both, err := NewJWTVerifier(JWTOptions{HS256Secret: testSecret, JWKSFile: jwks, Issuer: testIssuer, Audience: testAudience})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaOnly, err := NewJWTVerifier(JWTOptions{JWKSFile: jwks, Issuer: testIssuer, Audience: testAudience})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		change(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		wantOK   bool
+	}{
+		{"hs256", both, sign(t, jwt.SigningMethodHS256, "", validClaims(), []byte(testSecret)), true},
+		{"rs256", both, sign(t, jwt.SigningMethodRS256, testKid, validClaims(), rsaKey), true},
+		{"rs256 without kid", rsaOnly, sign(t, jwt.SigningMethodRS256, "", validClaims(), rsaKey), true},
+
+		{"alg none", both, sign(t, jwt.SigningMethodNone, "", validClaims(), jwt.UnsafeAllowNoneSignatureType), false},
+		{"hs256 signed with the public key", rsaOnly, sign(t, jwt.SigningMethodHS256, testKid, validClaims(), publicPEM), false},
+		{"hs256 signed with the public key, both configured", both, sign(t, jwt.SigningMethodHS256, testKid, validClaims(), publicPEM), false},
+		{"hs256 with a wrong secret", both, sign(t, jwt.SigningMethodHS256, "", validClaims(), []byte("guess")), false},
+		{"rs384", both, sign(t, jwt.SigningMethodRS384, testKid, validClaims(), rsaKey), false},
+		{"unknown kid", both, sign(t, jwt.SigningMethodRS256, "key-2", validClaims(), rsaKey), false},
+		{"unlisted key", both, sign(t, jwt.SigningMethodRS256, testKid, validClaims(), otherKey), false},
+
+		{"expired", both, sign(t, jwt.SigningMethodHS256, "", with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }), []byte(testSecret)), false},
+		{"missing exp", both, sign(t, jwt.SigningMethodHS256, "", with(func(c jwt.MapClaims) { delete(c, "exp") }), []byte(testSecret)), false},
+		{"not yet valid", both, sign(t, jwt.SigningMethodHS256, "", with(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }), []byte(testSecret)), false},
+		{"wrong issuer", both, sign(t, jwt.SigningMethodHS256, "", with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }), []byte(testSecret)), false},
+		{"missing issuer", both, sign(t, jwt.SigningMethodHS256, "", with(func(c jwt.MapClaims) { delete(c, "iss") }), []byte(testSecret)), false},
+		{"wrong audience", both, sign(t, jwt.SigningMethodHS256, "", with(func(c jwt.MapClaims) { c["aud"] = "other_service" }), []byte(testSecret)), false},
+		{"missing subject", both, sign(t, jwt.SigningMethodHS256, "", with(func(c jwt.MapClaims) { delete(c, "sub") }), []byte(testSecret)), false},
+
+		{"garbage", both, "not.a.token", false},
+		{"empty", both, "", false},
+	}
+	for _, tt := range tests {
+		principal, err := tt.verifier.Authenticate(tt.token)
+		if !tt.wantOK {
+			if err == nil || !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("%s: Authenticate = %+v, %v, want ErrUnauthenticated", tt.name, principal, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Authenticate: %v", tt.name, err)
+			continue
+		}
+		if principal.ID != "merchant" || principal.Method != "jwt" {
+			t.Errorf("%s: principal = %+v", tt.name, principal)
+		}
+	}
+}
+
+func TestJWTScopesAndWallets(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTOptions{HS256Secret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := validClaims()
+	claims["scopes"] = []string{"webhooks:manage"}
+	principal, err := verifier.Authenticate(sign(t, jwt.SigningMethodHS256, "", claims, []byte(testSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"webhooks:manage", "wallets:read", "payments:write"}; !slices.Equal(principal.Scopes, want) {
+		t.Errorf("Scopes = %v, want %v", principal.Scopes, want)
+	}
+	if want := []int{1, 2}; !slices.Equal(principal.Wallets, want) {
+		t.Errorf("Wallets = %v, want %v", principal.Wallets, want)
+	}
+}
+
+func TestNewJWTVerifierErrors(t *testing.T) {
+	if _, err := NewJWTVerifier(JWTOptions{}); err == nil {
+		t.Error("NewJWTVerifier without keys succeeded")
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"malformed.json": `{"keys": [`,
+		"no_rsa.json":    `{"keys": [{"kty": "EC", "kid": "ec"}]}`,
+		"bad_n.json":     `{"keys": [{"kty": "RSA", "kid": "k", "n": "!!", "e": "AQAB"}]}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewJWTVerifier(JWTOptions{JWKSFile: path}); err == nil {
+			t.Errorf("NewJWTVerifier(%s) succeeded", name)
+		}
+	}
+	if _, err := NewJWTVerifier(JWTOptions{JWKSFile: filepath.Join(dir, "missing.json")}); err == nil {
+		t.Error("NewJWTVerifier with a missing JWKS file succeeded")
+	}
+}
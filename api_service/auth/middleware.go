@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"api_service/respond"
+	"common/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+const principalKey = "auth.principal"
+
+// Authenticator checks the credentials of incoming requests. Either method
+// may be nil when it is not configured.
+type Authenticator struct {
+	APIKeys *APIKeys
+	JWT     *JWTVerifier
+}
+
+func (a *Authenticator) authenticate(c *gin.Context) (*Principal, error) {
+	if key := c.GetHeader("X-API-Key"); key != "" && a.APIKeys != nil {
+		return a.APIKeys.Authenticate(key)
+	}
+
+	header := c.GetHeader("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok && a.JWT != nil {
+		return a.JWT.Authenticate(strings.TrimSpace(token))
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+// Middleware rejects requests without valid credentials and stores the
+// principal in the gin context.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := a.authenticate(c)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api_service"`)
+			respond.Abort(c, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "principal", principal.ID))
+		c.Next()
+	}
+}
+
+// RequireScope rejects principals lacking scope. It must run after Middleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFrom(c)
+		if principal == nil || !principal.HasScope(scope) {
+			respond.Abort(c, http.StatusForbidden, "Missing scope "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+func PrincipalFrom(c *gin.Context) *Principal {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*Principal)
+	return principal
+}
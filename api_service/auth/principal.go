@@ -0,0 +1,29 @@
+package auth
+
+import "slices"
+
+// Scopes understood by the API. ScopeAdmin implies every other scope and
+// access to every wallet.
+const (
+	ScopeWalletsRead   = "wallets:read"
+	ScopePaymentsWrite = "payments:write"
+	ScopeWebhooks      = "webhooks:manage"
+	ScopeAdmin         = "admin"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID      string
+	Method  string // "api_key" or "jwt"
+	Scopes  []string
+	Wallets []int
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// OwnsWallet reports whether the principal may act on walletID.
+func (p *Principal) OwnsWallet(walletID int) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Wallets, walletID)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import "testing"
+
+func TestPrincipal(t *testing.T) {
+	merchant := &Principal{ID: "merchant", Scopes: []string{ScopeWalletsRead}, Wallets: []int{1, 2}}
+	admin := &Principal{ID: "admin", Scopes: []string{ScopeAdmin}}
+	nobody := &Principal{ID: "nobody"}
+
+	scopes := []struct {
+		principal *Principal
+		scope     string
+		want      bool
+	}{
+		{merchant, ScopeWalletsRead, true},
+		{merchant, ScopePaymentsWrite, false},
+		{merchant, ScopeAdmin, false},
+		{admin, ScopePaymentsWrite, true},
+		{admin, ScopeWebhooks, true},
+		{nobody, ScopeWalletsRead, false},
+	}
+	for _, tt := range scopes {
+		if got := tt.principal.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%s.HasScope(%q) = %v, want %v", tt.principal.ID, tt.scope, got, tt.want)
+		}
+	}
+
+	wallets := []struct {
+		principal *Principal
+		walletID  int
+		want      bool
+	}{
+		{merchant, 1, true},
+		{merchant, 2, true},
+		{merchant, 3, false},
+		{admin, 3, true},
+		{nobody, 1, false},
+	}
+	for _, tt := range wallets {
+		if got := tt.principal.OwnsWallet(tt.walletID); got != tt.want {
+			t.Errorf("%s.OwnsWallet(%d) = %v, want %v", tt.principal.ID, tt.walletID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api_service/auth"
+	"api_service/respond"
+	"common/logging"
+	"sqlapi/amount"
+	pb "sqlapi/pb"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	batchQueue = "batch_requests"
+	// maxBatchBodySize bounds the JSON or CSV body of POST /batches; the
+	// router enforces it.
+	maxBatchBodySize = 1 << 20
+)
+
+type CreateBatchRequest struct {
+	Mode  string      `json:"mode"`
+	Items []BatchItem `json:"items"`
+}
+
+type BatchItem struct {
+	Index         int     `json:"index"`
+	WalletID      int     `json:"wallet_id"`
+	Type          string  `json:"type"`
+	Amount        float64 `json:"amount"`
+	Reference     string  `json:"reference,omitempty"`
+	Status        string  `json:"status,omitempty"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+type Batch struct {
+	BatchID     string      `json:"batch_id"`
+	Mode        string      `json:"mode"`
+	Status      string      `json:"status"`
+	Total       int         `json:"total"`
+	Succeeded   int         `json:"succeeded"`
+	Failed      int         `json:"failed"`
+	Pending     int         `json:"pending"`
+	CreatedAt   string      `json:"created_at"`
+	CompletedAt string      `json:"completed_at,omitempty"`
+	Items       []BatchItem `json:"items,omitempty"`
+}
+
+// BatchItemError explains why an item was refused. Index is the position
+// in items, or the data row of a CSV upload, counting from 0.
+type BatchItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// createBatchHandler accepts a JSON body, a text/csv body or a
+// multipart/form-data upload with a "file" field. The mode comes from the
+// JSON body, the "mode" form field or the ?mode= query parameter.
+func (a *Api) createBatchHandler(c *gin.Context) {
+	request, err := parseBatchRequest(c)
+	if err != nil {
+		respond.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	principal := auth.PrincipalFrom(c)
+	problems, err := validateBatch(request, a.Config.BatchMaxItems, principal)
+	if err != nil {
+		respond.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(problems) > 0 {
+		respond.ErrorDetails(c, http.StatusBadRequest, "Invalid batch", gin.H{"items": problems})
+		return
+	}
+
+	items := make([]*pb.BatchItem, 0, len(request.Items))
+	for _, item := range request.Items {
+		items = append(items, &pb.BatchItem{
+			WalletId:  int32(item.WalletID),
+			Type:      item.Type,
+			Amount:    item.Amount,
+			Reference: item.Reference,
+		})
+	}
+
+	ctx := c.Request.Context()
+	batch, err := a.SQL.CreateBatch(ctx, &pb.CreateBatchRequest{
+		Principal: principal.ID,
+		Mode:      request.Mode,
+		Items:     items,
+	})
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to create batch", err)
+		return
+	}
+
+	ctx = logging.With(ctx, "batch_id", batch.BatchId)
+	if err := a.publishBatch(ctx, batch.BatchId); err != nil {
+		logging.FromContext(ctx).Error("Error publishBatch", "error", err)
+		respond.ErrorDetails(c, http.StatusInternalServerError, "Failed to publish batch request", gin.H{"batch_id": batch.BatchId})
+		return
+	}
+
+	response := batchFromProto(batch)
+	response.Items = nil
+	respond.Data(c, http.StatusAccepted, response)
+}
+
+func (a *Api) getBatchHandler(c *gin.Context) {
+	ctx := logging.With(c.Request.Context(), "batch_id", c.Param("id"))
+	batch, err := a.SQL.GetBatch(ctx, &pb.BatchRef{BatchId: c.Param("id")})
+	principal := auth.PrincipalFrom(c)
+	if status.Code(err) == codes.NotFound || err == nil && batch.Principal != principal.ID && !principal.HasScope(auth.ScopeAdmin) {
+		respond.Error(c, http.StatusNotFound, "Batch not found")
+		return
+	}
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to get batch", err)
+		return
+	}
+
+	respond.Data(c, http.StatusOK, batchFromProto(batch))
+}
+
+func (a *Api) publishBatch(ctx context.Context, batchID string) error {
+	ch, err := a.RabbitConn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclare(
+		batchQueue,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(gin.H{"batch_id": batchID})
+	if err != nil {
+		return err
+	}
+
+	return publishJSON(ctx, ch, q.Name, body)
+}
+
+// parseBatchRequest reads the batch in whichever format it was sent.
+// all_or_nothing is the default mode.
+func parseBatchRequest(c *gin.Context) (CreateBatchRequest, error) {
+	request := CreateBatchRequest{Mode: c.DefaultQuery("mode", "all_or_nothing")}
+
+	switch c.ContentType() {
+	case "text/csv":
+		items, err := parseBatchCSV(c.Request.Body)
+		request.Items = items
+		return request, err
+	case "multipart/form-data":
+		header, err := c.FormFile("file")
+		if err != nil {
+			return request, fmt.Errorf("a CSV file is expected in the \"file\" field")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return request, err
+		}
+		defer file.Close()
+		if mode := c.PostForm("mode"); mode != "" {
+			request.Mode = mode
+		}
+		items, err := parseBatchCSV(file)
+		request.Items = items
+		return request, err
+	default:
+		err := c.ShouldBindJSON(&request)
+		return request, err
+	}
+}
+
+// parseBatchCSV reads items from a CSV file with a header row naming the
+// wallet_id, type and amount columns and, optionally, reference.
+func parseBatchCSV(r io.Reader) ([]BatchItem, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty CSV file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"wallet_id", "type", "amount"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header must name the %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var items []BatchItem
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+
+		row := len(items)
+		walletID, err := strconv.Atoi(field(record, "wallet_id"))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid wallet_id %q", row, field(record, "wallet_id"))
+		}
+		amount, err := strconv.ParseFloat(field(record, "amount"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid amount %q", row, field(record, "amount"))
+		}
+		items = append(items, BatchItem{
+			WalletID:  walletID,
+			Type:      strings.ToLower(field(record, "type")),
+			Amount:    amount,
+			Reference: field(record, "reference"),
+		})
+	}
+}
+
+// validateBatch checks the whole batch before anything is stored, so a
+// batch is either accepted as a whole or refused with every problem listed.
+// err is set when the batch itself is unusable.
+func validateBatch(request CreateBatchRequest, maxItems int, principal *auth.Principal) (problems []BatchItemError, err error) {
+	switch request.Mode {
+	case "all_or_nothing", "best_effort":
+	default:
+		return nil, errors.New("mode must be all_or_nothing or best_effort")
+	}
+	if len(request.Items) == 0 {
+		return nil, errors.New("a batch needs at least one item")
+	}
+	if len(request.Items) > maxItems {
+		return nil, fmt.Errorf("a batch holds at most %d items, got %d", maxItems, len(request.Items))
+	}
+
+	for i, item := range request.Items {
+		var err error
+		switch {
+		case item.Type != "deposit" && item.Type != "withdraw":
+			err = fmt.Errorf("type must be deposit or withdraw")
+		case checkAmount(item.Amount) != nil:
+			err = checkAmount(item.Amount)
+		case len(item.Reference) > 255:
+			err = fmt.Errorf("reference is longer than 255 characters")
+		case !principal.OwnsWallet(item.WalletID):
+			err = fmt.Errorf("access to wallet %d is not allowed", item.WalletID)
+		}
+		if err != nil {
+			problems = append(problems, BatchItemError{Index: i, Error: err.Error()})
+		}
+	}
+
+	return problems, nil
+}
+
+// checkAmount reports why a payment amount cannot be stored in a
+// DECIMAL(10,2) balance column, or nil if it can.
+func checkAmount(value float64) error {
+	switch {
+	case value <= 0 || math.IsInf(value, 0) || math.IsNaN(value):
+		return errors.New("amount must be greater than 0")
+	case value > amount.Max:
+		return fmt.Errorf("amount exceeds the limit of %.2f", amount.Max)
+	case math.Abs(value*100-math.Round(value*100)) > 1e-6:
+		return errors.New("amount has more than 2 decimal places")
+	}
+	return nil
+}
+
+func batchFromProto(b *pb.Batch) Batch {
+	batch := Batch{
+		BatchID:   b.BatchId,
+		Mode:      b.Mode,
+		Status:    b.Status,
+		Total:     int(b.Total),
+		Succeeded: int(b.Succeeded),
+		Failed:    int(b.Failed),
+		Pending:   int(b.Pending),
+	}
+	batch.CreatedAt, _ = ProtoTimestampToFormattedTime(b.CreatedAt)
+	if b.CompletedAt != nil {
+		batch.CompletedAt, _ = ProtoTimestampToFormattedTime(b.CompletedAt)
+	}
+	for _, item := range b.Items {
+		batch.Items = append(batch.Items, BatchItem{
+			Index:         int(item.Index),
+			WalletID:      int(item.WalletId),
+			Type:          item.Type,
+			Amount:        item.Amount,
+			Reference:     item.Reference,
+			Status:        item.Status,
+			TransactionID: item.TransactionId,
+			Error:         item.Error,
+		})
+	}
+	return batch
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendTime(t *testing.T) {
+	// 100 rps reached after 2s: 100 requests during the ramp, then one
+	// every 10ms.
+	tests := []struct {
+		k    int
+		want time.Duration
+	}{
+		{0, 0},
+		{25, time.Second},
+		{100, 2 * time.Second},
+		{150, 2500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := sendTime(tt.k, 100, 2*time.Second); got.Round(time.Millisecond) != tt.want {
+			t.Errorf("request %d due at %v, want %v", tt.k, got, tt.want)
+		}
+	}
+	if got := sendTime(10, 100, 0); got != 100*time.Millisecond {
+		t.Errorf("without a ramp request 10 is due at %v, want 100ms", got)
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	ops, err := parseMix("deposit=5, lookup=0,withdraw=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 || ops[0] != (weightedOp{opDeposit, 5}) || ops[2] != (weightedOp{opWithdraw, 3}) {
+		t.Errorf("got %v", ops)
+	}
+
+	for _, mix := range []string{"deposit", "refund=1", "deposit=-1", "deposit=0,withdraw=0"} {
+		if _, err := parseMix(mix); err == nil {
+			t.Errorf("%q accepted", mix)
+		}
+	}
+}
+
+func TestZipfPrefersFirstWallets(t *testing.T) {
+	pick, err := newWalletPicker(rand.New(rand.NewSource(1)), "zipf", 10, 100, 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[int]int)
+	for i := 0; i < 10000; i++ {
+		id := pick()
+		if id < 10 || id >= 110 {
+			t.Fatalf("picked wallet %d outside 10..109", id)
+		}
+		counts[id]++
+	}
+	if counts[10] <= counts[11] || counts[11] <= counts[50] {
+		t.Errorf("wallet 10 picked %d times, 11 %d times, 50 %d times", counts[10], counts[11], counts[50])
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	want := Percentiles{P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}
+	if got := percentiles(durations); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReplayRequestLog(t *testing.T) {
+	log := `{"time":"2026-10-19T10:00:00Z","level":"INFO","msg":"Connected to RabbitMQ"}
+{"time":"2026-10-19T10:00:00Z","msg":"http request","method":"POST","path":"/v1/wallets/:id/deposits","status":202}
+
+{"time":"2026-10-19T10:00:01Z","msg":"http request","method":"GET","path":"/v1/transactions/:id","status":200}
+{"time":"2026-10-19T10:00:01Z","msg":"http request","method":"DELETE","path":"/v1/wallets/:id/webhooks/:webhook_id","status":204}
+{"method":"POST","path":"/withdraw","body":{"wallet_id":2,"amount":3.5}}
+{"time":"2026-10-19T10:00:03Z","msg":"http request","method":"POST","path":"/deposit","status":200}
+{"time":"2026-10-19T10:00:04Z","msg":"http request","method":"GET","path":"","status":404}
+`
+	rec, err := readLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.entries) != 5 || rec.skipped != 2 {
+		t.Fatalf("read %d requests and skipped %d lines, want 5 and 2", len(rec.entries), rec.skipped)
+	}
+
+	known := newTransactionIDs(10)
+	known.add("0b5e8f0c-5f4b-4d0c-9d7e-2f1c3a4b5c6d")
+	g := &generator{rng: rand.New(rand.NewSource(1)), wallet: func() int { return 7 }, maxAmount: 10, known: known}
+	next := g.replay(rec, false, 1, 0, 2, 0)
+
+	type sent struct {
+		op, method, path string
+		due              time.Duration
+	}
+	want := []sent{
+		{opDeposit, "POST", "/v1/wallets/7/deposits", 0},
+		{opLookup, "GET", "/v1/transactions/0b5e8f0c-5f4b-4d0c-9d7e-2f1c3a4b5c6d", 500 * time.Millisecond},
+		{opWithdraw, "POST", "/withdraw", 500 * time.Millisecond},
+		{opDeposit, "POST", "/deposit", 1500 * time.Millisecond},
+	}
+	for k, w := range want {
+		req, due, ok := next(k)
+		if !ok {
+			t.Fatalf("replay ended after %d requests", k)
+		}
+		if got := (sent{req.op, req.method, req.path, due}); got != w {
+			t.Errorf("request %d: got %+v, want %+v", k, got, w)
+		}
+		if req.method == "POST" && !strings.Contains(string(req.body), `"amount"`) {
+			t.Errorf("request %d: body %s has no amount", k, req.body)
+		}
+		if req.path == "/deposit" && !strings.Contains(string(req.body), `"wallet_id":7`) {
+			t.Errorf("legacy deposit body %s has no wallet", req.body)
+		}
+	}
+	if _, _, ok := next(len(want)); ok {
+		t.Error("replay did not end with the log")
+	}
+	if g.skipped != 1 {
+		t.Errorf("skipped %d entries, want the webhook deletion", g.skipped)
+	}
+}
@@ -0,0 +1,135 @@
+// Command loadgen sends a mix of deposits, withdrawals and transaction
+// lookups to api-service at a target rate and reports latency percentiles,
+// error rates and how long the queued payments took to be recorded.
+//
+//	loadgen [-rps N] [-ramp D] [-duration D] [-mix deposit=5,withdraw=3,lookup=2]
+//	        [-wallets N] [-first-wallet ID] [-dist uniform|zipf] [-zipf-s S] [-max-amount A]
+//	loadgen -replay FILE [-speed X | -rps N]
+//
+// The rate grows linearly from zero to -rps over -ramp. Requests are sent
+// on schedule whether or not the earlier ones were answered; once
+// -concurrency are in flight, the next ones are dropped and counted. Every
+// payment api-service queues is polled at GET /v1/transactions/{id} until
+// it is recorded or -poll-timeout passes.
+//
+// With -replay, the requests come from a JSON-lines log instead, one
+// object per line with method, path and optionally time and body; lines
+// without a method and path are skipped. api-service's own request log has
+// this shape: its route patterns get a wallet from -wallets and -dist, a
+// transaction id queued earlier in the run, and a random amount.
+//
+// The report is printed to stdout as JSON, progress to stderr.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"common/logging"
+)
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "api-service address")
+	apiKey := flag.String("key", "dev-admin-key", "X-API-Key sent with every request; it must own the wallets")
+	rps := flag.Float64("rps", 50, "target requests per second")
+	ramp := flag.Duration("ramp", 10*time.Second, "time to reach -rps from zero")
+	duration := flag.Duration("duration", time.Minute, "how long to send requests, ramp included; a replay runs to the end of the log unless it is set")
+	mixFlag := flag.String("mix", "deposit=5,withdraw=3,lookup=2", "relative weights of deposit, withdraw and lookup")
+	wallets := flag.Int("wallets", 3, "number of wallets to pay into, starting at -first-wallet")
+	firstWallet := flag.Int("first-wallet", 1, "lowest wallet id")
+	dist := flag.String("dist", "uniform", "wallet distribution: uniform or zipf")
+	zipfS := flag.Float64("zipf-s", 1.1, "exponent of the zipf distribution, greater than 1")
+	maxAmount := flag.Float64("max-amount", 100, "payments are of a random amount up to this")
+	concurrency := flag.Int("concurrency", 256, "requests in flight at most")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of one request")
+	pollInterval := flag.Duration("poll-interval", 250*time.Millisecond, "interval between lookups of a queued payment")
+	pollTimeout := flag.Duration("poll-timeout", 30*time.Second, "give up on a queued payment after this")
+	replay := flag.String("replay", "", "replay the requests of this JSON-lines log")
+	speed := flag.Float64("speed", 1, "replay this many times faster than recorded")
+	seed := flag.Int64("seed", 0, "random seed; 0 picks one")
+	progress := flag.Duration("progress", 5*time.Second, "interval between progress lines; 0 disables them")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	picker, err := newWalletPicker(rng, *dist, *firstWallet, *wallets, *zipfS)
+	if err != nil {
+		logging.Fatal("Invalid wallet distribution", "error", err)
+	}
+	if *rps <= 0 || *maxAmount < 0.01 || *concurrency < 1 {
+		logging.Fatal("-rps, -max-amount and -concurrency must be positive")
+	}
+
+	known := newTransactionIDs(1000)
+	g := &generator{rng: rng, wallet: picker, maxAmount: *maxAmount, known: known}
+
+	var next source
+	if *replay != "" {
+		rec, err := readLogFile(*replay)
+		if err != nil {
+			logging.Fatal("Failed to read request log", "file", *replay, "error", err)
+		}
+		if len(rec.entries) == 0 {
+			logging.Fatal("No requests in the log", "file", *replay, "skipped_lines", rec.skipped)
+		}
+		paced := set["rps"] || !rec.timed()
+		if !set["duration"] {
+			*duration = 0
+		}
+		next = g.replay(rec, paced, *rps, *ramp, *speed, *duration)
+		slog.Info("Replaying request log", "file", *replay, "requests", len(rec.entries), "skipped_lines", rec.skipped, "paced", paced)
+	} else {
+		if g.ops, err = parseMix(*mixFlag); err != nil {
+			logging.Fatal("Invalid -mix", "error", err)
+		}
+		next = g.synthetic(*rps, *ramp, *duration)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	r := &runner{
+		client:       &http.Client{Timeout: *timeout},
+		baseURL:      *baseURL,
+		apiKey:       *apiKey,
+		stats:        newStats(),
+		known:        known,
+		inflight:     make(chan struct{}, *concurrency),
+		pollInterval: *pollInterval,
+		pollTimeout:  *pollTimeout,
+	}
+	if *progress > 0 {
+		go r.logProgress(ctx, *progress)
+	}
+	slog.Info("Starting", "url", *baseURL, "seed", *seed)
+
+	elapsed := r.run(ctx, next)
+	report := r.stats.report(elapsed)
+	if *replay != "" {
+		report.Skipped = g.skipped
+	}
+	printJSON(report)
+}
+
+func printJSON(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		logging.Fatal("Failed to write the report", "error", err)
+	}
+}
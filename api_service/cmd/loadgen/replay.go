@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// logEntry is one line of a recorded request log. The field names are
+// those of api-service's request log, whose time key comes from slog.
+type logEntry struct {
+	Time   time.Time       `json:"time"`
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// recording is a request log read for replay.
+type recording struct {
+	entries []logEntry
+	// skipped counts the lines that are not requests, such as the other
+	// records of a service log.
+	skipped int
+}
+
+// timed reports whether the log says when its requests were made.
+func (r recording) timed() bool {
+	for _, e := range r.entries {
+		if !e.Time.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func readLogFile(name string) (recording, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return recording{}, err
+	}
+	defer f.Close()
+	return readLog(f)
+}
+
+func readLog(r io.Reader) (recording, error) {
+	var rec recording
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var e logEntry
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			return recording{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		// A request log records unmatched routes with an empty path.
+		if e.Method == "" || !strings.HasPrefix(e.Path, "/") {
+			rec.skipped++
+			continue
+		}
+		rec.entries = append(rec.entries, e)
+	}
+	return rec, scanner.Err()
+}
+
+// replay returns the requests of rec in order. Paced requests are due at
+// rps after the ramp like synthetic ones; the others keep their recorded
+// spacing, sped up speed times. A non-zero duration ends the replay early.
+// Entries that cannot be sent are left out and counted in g.skipped.
+func (g *generator) replay(rec recording, paced bool, rps float64, ramp time.Duration, speed float64, duration time.Duration) source {
+	var start time.Time
+	for _, e := range rec.entries {
+		if !e.Time.IsZero() {
+			start = e.Time
+			break
+		}
+	}
+
+	i := 0
+	var last time.Duration
+	return func(k int) (request, time.Duration, bool) {
+		for ; i < len(rec.entries); i++ {
+			e := rec.entries[i]
+			req, ok := g.fromEntry(e)
+			if !ok {
+				g.skipped++
+				continue
+			}
+			i++
+
+			due := sendTime(k, rps, ramp)
+			if !paced {
+				// Entries without a time go with the one before.
+				if !e.Time.IsZero() && e.Time.After(start) {
+					last = time.Duration(float64(e.Time.Sub(start)) / speed)
+				}
+				due = last
+			}
+			if duration > 0 && due >= duration {
+				return request{}, 0, false
+			}
+			return req, due, true
+		}
+		return request{}, 0, false
+	}
+}
+
+// fromEntry turns a log entry into a request. The route patterns of
+// api-service's log are filled in: a wallet :id from the wallet picker, a
+// transaction :id from the ids queued so far. Payments logged without a
+// body get a random amount. Other parameters cannot be made up, and event
+// streams never end, so those entries are not sent.
+func (g *generator) fromEntry(e logEntry) (request, bool) {
+	if strings.HasSuffix(e.Path, "/events") {
+		return request{}, false
+	}
+	method := strings.ToUpper(e.Method)
+	segments := strings.Split(e.Path, "/")
+	walletID := 0
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		switch {
+		case segment == ":id" && i > 0 && segments[i-1] == "wallets":
+			walletID = g.wallet()
+			segments[i] = fmt.Sprint(walletID)
+		case segment == ":id" && i > 0 && (segments[i-1] == "transactions" || segments[i-1] == "get-transaction"):
+			segments[i] = g.known.random(g.rng)
+		default:
+			return request{}, false
+		}
+	}
+	path := strings.Join(segments, "/")
+
+	op := classify(method, path)
+	if len(e.Body) > 0 && string(e.Body) != "null" {
+		return request{op: op, method: method, path: path, body: e.Body}, true
+	}
+	if op == opDeposit || op == opWithdraw {
+		if walletID == 0 {
+			// The legacy /deposit and /withdraw take the wallet in the body.
+			return g.payment(op, path, map[string]any{"wallet_id": g.wallet()}), true
+		}
+		return g.payment(op, path, nil), true
+	}
+	return request{op: op, method: method, path: path}, true
+}
+
+// classify names the operation of a request for the report; the legacy
+// routes count with their /v1 successors.
+func classify(method, path string) string {
+	switch {
+	case method == http.MethodPost && (strings.HasSuffix(path, "/deposits") || path == "/deposit"):
+		return opDeposit
+	case method == http.MethodPost && (strings.HasSuffix(path, "/withdrawals") || path == "/withdraw"):
+		return opWithdraw
+	case method == http.MethodGet && (strings.Contains(path, "/transactions/") || strings.HasPrefix(path, "/get-transaction/")):
+		return opLookup
+	}
+	return opOther
+}
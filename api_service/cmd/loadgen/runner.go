@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// runner sends the requests of a source on schedule and follows the
+// payments they queue until their transactions are recorded.
+type runner struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	stats   *stats
+	known   *transactionIDs
+	// inflight holds a token for every request being sent.
+	inflight     chan struct{}
+	pollInterval time.Duration
+	pollTimeout  time.Duration
+
+	requests sync.WaitGroup
+	polls    sync.WaitGroup
+}
+
+// run sends the requests of next until it has no more or ctx is done,
+// waits for the answers and the polls, and returns how long sending took.
+func (r *runner) run(ctx context.Context, next source) time.Duration {
+	start := time.Now()
+	timer := time.NewTimer(0)
+	<-timer.C
+
+schedule:
+	for k := 0; ; k++ {
+		req, due, ok := next(k)
+		if !ok {
+			break
+		}
+		if wait := time.Until(start.Add(due)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				break schedule
+			case <-timer.C:
+			}
+		}
+		r.dispatch(ctx, req)
+	}
+	elapsed := time.Since(start)
+
+	r.requests.Wait()
+	r.polls.Wait()
+	return elapsed
+}
+
+// dispatch sends req in the background. When the server falls behind and
+// every token is taken, req is dropped rather than delayed, so that the
+// requests after it stay on schedule.
+func (r *runner) dispatch(ctx context.Context, req request) {
+	select {
+	case r.inflight <- struct{}{}:
+	default:
+		r.stats.drop(req.op)
+		return
+	}
+
+	r.requests.Add(1)
+	go func() {
+		defer r.requests.Done()
+		defer func() { <-r.inflight }()
+		r.send(ctx, req)
+	}()
+}
+
+// send makes the request and starts polling the transaction of a queued
+// payment. An interrupted run lets the requests already sent finish; only
+// the polls stop.
+func (r *runner) send(ctx context.Context, req request) {
+	sent := time.Now()
+	status, body, err := r.do(context.Background(), req.method, req.path, req.body)
+	r.stats.record(req.op, status, time.Since(sent), err)
+
+	if err != nil || status != http.StatusAccepted || (req.op != opDeposit && req.op != opWithdraw) {
+		return
+	}
+	var queued struct {
+		Data struct {
+			TransactionID string `json:"transaction_id"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &queued) != nil || queued.Data.TransactionID == "" {
+		return
+	}
+	r.known.add(queued.Data.TransactionID)
+
+	r.polls.Add(1)
+	go func() {
+		defer r.polls.Done()
+		r.poll(ctx, queued.Data.TransactionID, sent)
+	}()
+}
+
+// poll looks the transaction up until it is recorded and counts the time
+// from its request to the answer that found it, so the completion time is
+// up to one poll interval late.
+func (r *runner) poll(ctx context.Context, id string, sent time.Time) {
+	r.stats.queue()
+	timer := time.NewTimer(r.pollInterval)
+	defer timer.Stop()
+
+	for time.Since(sent) < r.pollTimeout {
+		select {
+		case <-ctx.Done():
+			r.stats.giveUp()
+			return
+		case <-timer.C:
+		}
+
+		status, body, err := r.do(ctx, http.MethodGet, "/v1/transactions/"+id, nil)
+		r.stats.poll(err == nil && status != http.StatusOK && status != http.StatusNotFound)
+		if err == nil && status == http.StatusOK {
+			var transaction struct {
+				Data struct {
+					Status string `json:"status"`
+				} `json:"data"`
+			}
+			_ = json.Unmarshal(body, &transaction)
+			r.stats.complete(transaction.Data.Status, time.Since(sent))
+			return
+		}
+		timer.Reset(r.pollInterval)
+	}
+	r.stats.giveUp()
+}
+
+func (r *runner) do(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.apiKey != "" {
+		req.Header.Set("X-API-Key", r.apiKey)
+	}
+
+	response, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	return response.StatusCode, data, err
+}
+
+// logProgress logs the totals so far every interval until ctx is done.
+func (r *runner) logProgress(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p := r.stats.progress()
+			slog.Info("Progress", "requests", p.requests, "failed", p.failed, "dropped", p.dropped,
+				"in_flight", len(r.inflight), "completed", p.completed, "pending", p.pending)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// stats collects the results of a run. It is safe for concurrent use.
+type stats struct {
+	mu         sync.Mutex
+	ops        map[string]*opStats
+	queued     int
+	pending    int
+	completed  map[string]int
+	completion []time.Duration
+	unfinished int
+	polls      int
+	pollErrors int
+}
+
+type opStats struct {
+	latencies []time.Duration
+	// statuses counts the answers by HTTP status; "error" counts the
+	// requests that got none.
+	statuses map[string]int
+	failed   int
+	dropped  int
+}
+
+func newStats() *stats {
+	return &stats{ops: make(map[string]*opStats), completed: make(map[string]int)}
+}
+
+func (s *stats) op(name string) *opStats {
+	o, ok := s.ops[name]
+	if !ok {
+		o = &opStats{statuses: make(map[string]int)}
+		s.ops[name] = o
+	}
+	return o
+}
+
+// record counts an answered request, or one that failed with err. Any
+// status other than 2xx is a failure.
+func (s *stats) record(op string, status int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := s.op(op)
+	if err != nil {
+		o.statuses["error"]++
+		o.failed++
+		return
+	}
+	o.latencies = append(o.latencies, latency)
+	o.statuses[strconv.Itoa(status)]++
+	if status < 200 || status > 299 {
+		o.failed++
+	}
+}
+
+func (s *stats) drop(op string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.op(op).dropped++
+}
+
+// queue counts a payment whose transaction is being polled for.
+func (s *stats) queue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued++
+	s.pending++
+}
+
+func (s *stats) poll(failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls++
+	if failed {
+		s.pollErrors++
+	}
+}
+
+// complete counts a payment recorded with the given transaction status
+// after it was requested.
+func (s *stats) complete(transactionStatus string, after time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	s.completed[transactionStatus]++
+	s.completion = append(s.completion, after)
+}
+
+// giveUp counts a payment whose transaction was not found in time.
+func (s *stats) giveUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	s.unfinished++
+}
+
+type progress struct {
+	requests, failed, dropped, completed, pending int
+}
+
+func (s *stats) progress() progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var p progress
+	for _, o := range s.ops {
+		p.requests += len(o.latencies) + o.statuses["error"]
+		p.failed += o.failed
+		p.dropped += o.dropped
+	}
+	p.completed = len(s.completion)
+	p.pending = s.pending
+	return p
+}
+
+// Report is the outcome of a run. Latencies and times are in milliseconds.
+type Report struct {
+	Duration string `json:"duration"`
+	Requests int    `json:"requests"`
+	// RPS is the rate the requests were sent at, dropped ones excluded.
+	RPS        float64                    `json:"rps"`
+	ErrorRate  float64                    `json:"error_rate"`
+	Operations map[string]OperationReport `json:"operations"`
+	Completion CompletionReport           `json:"completion"`
+	// Skipped counts the replayed log lines that were not sent.
+	Skipped int `json:"skipped,omitempty"`
+}
+
+type OperationReport struct {
+	Requests  int            `json:"requests"`
+	Failed    int            `json:"failed"`
+	ErrorRate float64        `json:"error_rate"`
+	Dropped   int            `json:"dropped,omitempty"`
+	Statuses  map[string]int `json:"statuses"`
+	Latency   Percentiles    `json:"latency_ms"`
+}
+
+// CompletionReport follows the queued payments to their transactions.
+type CompletionReport struct {
+	Queued int `json:"queued"`
+	// Completed counts the recorded transactions by status.
+	Completed  map[string]int `json:"completed"`
+	Unfinished int            `json:"unfinished"`
+	Time       Percentiles    `json:"time_ms"`
+	Polls      int            `json:"polls"`
+	PollErrors int            `json:"poll_errors"`
+}
+
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+func (s *stats) report(elapsed time.Duration) Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := Report{
+		Duration:   elapsed.Round(time.Millisecond).String(),
+		Operations: make(map[string]OperationReport),
+		Completion: CompletionReport{
+			Queued:     s.queued,
+			Completed:  s.completed,
+			Unfinished: s.unfinished,
+			Time:       percentiles(s.completion),
+			Polls:      s.polls,
+			PollErrors: s.pollErrors,
+		},
+	}
+	failed := 0
+	for name, o := range s.ops {
+		requests := len(o.latencies) + o.statuses["error"]
+		r.Operations[name] = OperationReport{
+			Requests:  requests,
+			Failed:    o.failed,
+			ErrorRate: rate(o.failed, requests),
+			Dropped:   o.dropped,
+			Statuses:  o.statuses,
+			Latency:   percentiles(o.latencies),
+		}
+		r.Requests += requests
+		failed += o.failed
+	}
+	r.ErrorRate = rate(failed, r.Requests)
+	if elapsed > 0 {
+		r.RPS = round(float64(r.Requests) / elapsed.Seconds())
+	}
+	return r
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round(float64(n) / float64(total))
+}
+
+// percentiles sorts durations and returns their nearest-rank percentiles.
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	at := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(durations)))) - 1
+		return round(float64(durations[max(i, 0)]) / float64(time.Millisecond))
+	}
+	return Percentiles{P50: at(0.5), P90: at(0.9), P95: at(0.95), P99: at(0.99), Max: at(1)}
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Operations the report is broken down by.
+const (
+	opDeposit  = "deposit"
+	opWithdraw = "withdraw"
+	opLookup   = "lookup"
+	// opOther is a replayed request that is none of the above.
+	opOther = "other"
+)
+
+// request is one HTTP request to send.
+type request struct {
+	op     string
+	method string
+	path   string
+	body   []byte
+}
+
+// source returns the k-th request of a run (counting from 0) and when it
+// is due after the start; ok is false once there are no more. It is called
+// from one goroutine, in order.
+type source func(k int) (req request, due time.Duration, ok bool)
+
+type weightedOp struct {
+	op     string
+	weight int
+}
+
+// parseMix reads operation weights such as "deposit=5,withdraw=3,lookup=2".
+func parseMix(s string) ([]weightedOp, error) {
+	var ops []weightedOp
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		op, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not operation=weight", part)
+		}
+		if op != opDeposit && op != opWithdraw && op != opLookup {
+			return nil, fmt.Errorf("unknown operation %q", op)
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("weight of %s must be a non-negative integer", op)
+		}
+		ops = append(ops, weightedOp{op, weight})
+		total += weight
+	}
+	if total == 0 {
+		return nil, errors.New("all weights are zero")
+	}
+	return ops, nil
+}
+
+// newWalletPicker returns a function choosing among n wallets from first:
+// every one equally often, or with zipf the first most often and the k-th
+// about k^s times less.
+func newWalletPicker(rng *rand.Rand, dist string, first, n int, s float64) (func() int, error) {
+	if n < 1 || first < 1 {
+		return nil, errors.New("need at least one wallet with a positive id")
+	}
+	switch dist {
+	case "uniform":
+		return func() int { return first + rng.Intn(n) }, nil
+	case "zipf":
+		if s <= 1 {
+			return nil, errors.New("the zipf exponent must be greater than 1")
+		}
+		zipf := rand.NewZipf(rng, s, 1, uint64(n-1))
+		return func() int { return first + int(zipf.Uint64()) }, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %q", dist)
+}
+
+// transactionIDs keeps the most recent transaction ids api-service
+// returned, for lookups to ask for. It is safe for concurrent use.
+type transactionIDs struct {
+	mu   sync.Mutex
+	ids  []string
+	next int
+}
+
+func newTransactionIDs(size int) *transactionIDs {
+	return &transactionIDs{ids: make([]string, 0, size)}
+}
+
+func (t *transactionIDs) add(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.ids) < cap(t.ids) {
+		t.ids = append(t.ids, id)
+		return
+	}
+	t.ids[t.next] = id
+	t.next = (t.next + 1) % len(t.ids)
+}
+
+// random returns one of the ids, or a made-up one before any was added:
+// looking it up is answered with 404.
+func (t *transactionIDs) random(rng *rand.Rand) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.ids) == 0 {
+		return uuid.New().String()
+	}
+	return t.ids[rng.Intn(len(t.ids))]
+}
+
+// sendTime is when the k-th request is due: the rate grows linearly from
+// zero to rps over ramp, so k requests are due by rps·t²/(2·ramp), then
+// stays at rps.
+func sendTime(k int, rps float64, ramp time.Duration) time.Duration {
+	rampRequests := rps * ramp.Seconds() / 2
+	if float64(k) < rampRequests {
+		return seconds(math.Sqrt(2 * float64(k) * ramp.Seconds() / rps))
+	}
+	return ramp + seconds((float64(k)-rampRequests)/rps)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// generator makes the requests of a run. It is used from the goroutine
+// calling the source only.
+type generator struct {
+	rng       *rand.Rand
+	ops       []weightedOp
+	wallet    func() int
+	maxAmount float64
+	known     *transactionIDs
+	// skipped counts the replayed entries that could not be sent.
+	skipped int
+}
+
+// synthetic returns the requests of the -mix workload, due at rps after
+// the ramp, until duration has passed.
+func (g *generator) synthetic(rps float64, ramp, duration time.Duration) source {
+	return func(k int) (request, time.Duration, bool) {
+		due := sendTime(k, rps, ramp)
+		if due >= duration {
+			return request{}, 0, false
+		}
+		return g.pick(), due, true
+	}
+}
+
+func (g *generator) pick() request {
+	total := 0
+	for _, o := range g.ops {
+		total += o.weight
+	}
+	n := g.rng.Intn(total)
+	op := g.ops[len(g.ops)-1].op
+	for _, o := range g.ops {
+		if n < o.weight {
+			op = o.op
+			break
+		}
+		n -= o.weight
+	}
+
+	switch op {
+	case opDeposit:
+		return g.payment(op, fmt.Sprintf("/v1/wallets/%d/deposits", g.wallet()), nil)
+	case opWithdraw:
+		return g.payment(op, fmt.Sprintf("/v1/wallets/%d/withdrawals", g.wallet()), nil)
+	default:
+		return request{op: opLookup, method: http.MethodGet, path: "/v1/transactions/" + g.known.random(g.rng)}
+	}
+}
+
+// payment returns a POST of a random amount to path; fields are added to
+// the body, for the legacy routes that take the wallet there.
+func (g *generator) payment(op, path string, fields map[string]any) request {
+	body := map[string]any{"amount": g.amount()}
+	for key, value := range fields {
+		body[key] = value
+	}
+	data, _ := json.Marshal(body)
+	return request{op: op, method: http.MethodPost, path: path, body: data}
+}
+
+// amount is a random amount of whole cents from 0.01 to maxAmount.
+func (g *generator) amount() float64 {
+	return float64(g.rng.Int63n(int64(g.maxAmount*100))+1) / 100
+}
@@ -0,0 +1,41 @@
+package main
+
+import "time"
+
+// Config is the api-service configuration, see config.Load for the tag syntax.
+type Config struct {
+	LogLevel          string        `env:"LOG_LEVEL" flag:"log-level" default:"info" validate:"oneof=debug info warn error"`
+	HTTPPort          string        `env:"HTTP_PORT" flag:"http-port" default:":8080" validate:"hostport"`
+	HTTPReadTimeout   time.Duration `env:"HTTP_READ_TIMEOUT" default:"10s" validate:"positive"`
+	HTTPWriteTimeout  time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"10s" validate:"positive"`
+	SQLServiceAddress string        `env:"SQL_SERVICE_ADDRESS" flag:"sql-service-address" required:"true" validate:"hostport"`
+	SQLServiceTimeout time.Duration `env:"SQL_SERVICE_TIMEOUT" default:"5s" validate:"positive"`
+	SQLServiceRetries int           `env:"SQL_SERVICE_RETRIES" default:"2" usage:"retries of read-only sql-service calls when it is unavailable"`
+	SQLServiceBackoff time.Duration `env:"SQL_SERVICE_RETRY_BACKOFF" default:"100ms" validate:"positive"`
+	RabbitMQAddress   string        `env:"RABBITMQ_ADDRESS" flag:"rabbitmq-address" required:"true" validate:"url"`
+	SQLServiceName    string        `env:"SQL_SERVICE_SERVER_NAME" usage:"expected name in the sql-service certificate, defaults to the address host"`
+	TLSCertFile       string        `env:"TLS_CERT_FILE" flag:"tls-cert"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE" flag:"tls-key" secret:"true"`
+	TLSCAFile         string        `env:"TLS_CA_FILE" flag:"tls-ca"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" default:"30s" validate:"positive"`
+	InsecureDev       bool          `env:"INSECURE_DEV" default:"false" usage:"allow plaintext gRPC without certificates, for development only"`
+	RetryInterval     time.Duration `env:"RETRY_INTERVAL" default:"5s" validate:"positive"`
+	APIKeysFile       string        `env:"API_KEYS_FILE" flag:"api-keys-file" usage:"JSON file with hashed API keys"`
+	JWTHS256Secret    string        `env:"JWT_HS256_SECRET" secret:"true"`
+	JWTJWKSFile       string        `env:"JWT_JWKS_FILE" usage:"local JWKS file with RS256 public keys"`
+	JWTIssuer         string        `env:"JWT_ISSUER"`
+	JWTAudience       string        `env:"JWT_AUDIENCE"`
+	TrustedProxies    []string      `env:"TRUSTED_PROXIES" usage:"comma-separated IPs or CIDRs of proxies whose X-Forwarded-For is used as the client IP; none by default"`
+	RateLimitBackend  string        `env:"RATE_LIMIT_BACKEND" default:"memory" validate:"oneof=memory redis"`
+	RateLimitRedis    string        `env:"RATE_LIMIT_REDIS_ADDRESS" validate:"hostport" usage:"Redis-protocol server shared by replicas"`
+	RateLimitRedisPwd string        `env:"RATE_LIMIT_REDIS_PASSWORD" secret:"true"`
+	RateLimitDefault  string        `env:"RATE_LIMIT_DEFAULT" default:"20/s:40" usage:"<count>/<s|m|h>[:<burst>]"`
+	RateLimits        []string      `env:"RATE_LIMITS" default:"POST /v1/wallets/:id/deposits=5/s:10,POST /v1/wallets/:id/withdrawals=5/s:10,POST /v1/batches=1/s:5,POST /deposit=5/s:10,POST /withdraw=5/s:10,POST /batches=1/s:5" usage:"comma-separated METHOD /route=<rule>"`
+	LegacySunset      time.Time     `env:"LEGACY_API_SUNSET" default:"2027-04-30" usage:"date (YYYY-MM-DD) announced in the Sunset header of the unversioned routes"`
+	BatchMaxItems     int           `env:"BATCH_MAX_ITEMS" default:"1000" validate:"positive" usage:"items accepted in one POST /v1/batches"`
+	EventHistorySize  int           `env:"EVENT_HISTORY_SIZE" default:"100" validate:"positive" usage:"wallet events kept per wallet for Last-Event-ID resume"`
+	EventHistoryAge   time.Duration `env:"EVENT_HISTORY_AGE" default:"5m" validate:"positive" usage:"how long wallet events are kept for resume"`
+	SSEHeartbeat      time.Duration `env:"SSE_HEARTBEAT" default:"15s" validate:"positive"`
+	TracingExporter   string        `env:"TRACING_EXPORTER" default:"none" validate:"oneof=none stdout otlp"`
+	OTLPEndpoint      string        `env:"OTLP_ENDPOINT" default:"localhost:4317" validate:"hostport"`
+}
@@ -3,28 +3,75 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	pb "api_service/grpc/proto"
+	"api_service/auth"
+	"api_service/openapi"
+	"api_service/ratelimit"
+	"api_service/respond"
+	"api_service/stream"
+	"common/config"
+	"common/logging"
+	"common/tlsconfig"
+	"common/tracing"
+	pb "sqlapi/pb"
+	"sqlapi/sqlclient"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang/protobuf/ptypes/timestamp"
-	"github.com/joho/godotenv"
+	"github.com/google/uuid"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+const serviceName = "api_service"
+
+var tracer = otel.Tracer(serviceName)
+
 type DepositRequest struct {
-	WalletID int     `json:"wallet_id"`
-	Amount   float64 `json:"amount"`
+	// TransactionID is assigned here and recorded by transaction-service,
+	// so that the client can look the transaction up.
+	TransactionID string  `json:"transaction_id,omitempty"`
+	WalletID      int     `json:"wallet_id" binding:"required,min=1"`
+	Amount        float64 `json:"amount" binding:"required,gt=0"`
+	Principal     string  `json:"principal,omitempty"`
 }
 
 type WithdrawRequest struct {
-	WalletID int     `json:"wallet_id"`
-	Amount   float64 `json:"amount"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+	WalletID      int     `json:"wallet_id" binding:"required,min=1"`
+	Amount        float64 `json:"amount" binding:"required,gt=0"`
+	Principal     string  `json:"principal,omitempty"`
+}
+
+// PaymentRequest is the body of POST /v1/wallets/:id/deposits and
+// /v1/wallets/:id/withdrawals; the wallet comes from the path.
+type PaymentRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+}
+
+type QueuedPayment struct {
+	TransactionID string  `json:"transaction_id"`
+	WalletID      int     `json:"wallet_id"`
+	Type          string  `json:"type"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status"`
 }
 
 type Transaction struct {
@@ -34,80 +81,210 @@ type Transaction struct {
 	Type            string  `json:"type"`
 	Status          string  `json:"status"`
 	TransactionTime string  `json:"transaction_time"`
+	Principal       string  `json:"principal,omitempty"`
+}
+
+type AuditEntry struct {
+	ID            int64   `json:"id"`
+	WalletID      int     `json:"wallet_id"`
+	BeforeBalance float64 `json:"before_balance"`
+	AfterBalance  float64 `json:"after_balance"`
+	CallerService string  `json:"caller_service"`
+	Principal     string  `json:"principal,omitempty"`
+	RequestID     string  `json:"request_id,omitempty"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+	CreatedAt     string  `json:"created_at"`
+	PrevHash      string  `json:"prev_hash"`
+	Hash          string  `json:"hash"`
 }
 
 type Api struct {
-	SQLServiceConn *grpc.ClientConn
-	RabbitConn     *amqp.Connection
+	Config     Config
+	SQL        *sqlclient.Client
+	RabbitConn *amqp.Connection
+	Events     *stream.Hub
 }
 
 func main() {
-	InitConfig()
+	var cfg Config
+	if err := config.Load(&cfg, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New(cfg.LogLevel))
+	config.Print(&cfg)
 
-	api, err := NewApi(os.Getenv("SQL_SERVICE_ADDRESS"), os.Getenv("RABBITMQ_ADDRESS"))
+	shutdownTracing, err := tracing.Init(context.Background(), serviceName, cfg.TracingExporter, cfg.OTLPEndpoint)
 	if err != nil {
-		log.Fatalf("Failed to init api-service: %v", err)
-		return
+		logging.Fatal("Failed to init tracing", "error", err)
 	}
-	defer api.Close()
+	defer shutdownTracing(context.Background())
 
-	r := gin.Default()
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		logging.Fatal("Failed to init authentication", "error", err)
+	}
 
-	r.GET("/get-transaction/:id", api.getTransactionHandler)
-	r.POST("/deposit", api.depositHandler)
-	r.POST("/withdraw", api.withdrawHandler)
+	rateLimits, err := newRateLimits(cfg)
+	if err != nil {
+		logging.Fatal("Failed to init rate limiting", "error", err)
+	}
 
-	httpPort := os.Getenv("HTTP_PORT")
-	err = r.Run(httpPort)
+	spec, err := openapi.Load()
 	if err != nil {
-		log.Fatal("HTTP server failed to start")
+		logging.Fatal("Failed to load the OpenAPI document", "error", err)
+	}
+
+	api, err := NewApi(cfg)
+	if err != nil {
+		logging.Fatal("Failed to init api-service", "error", err)
+	}
+	defer api.Close()
+
+	router := newRouter(api, authenticator, rateLimits, spec, cfg.LegacySunset)
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logging.Fatal("Invalid TRUSTED_PROXIES", "error", err)
+	}
+
+	server := &http.Server{
+		Addr:         cfg.HTTPPort,
+		Handler:      router,
+		ReadTimeout:  cfg.HTTPReadTimeout,
+		WriteTimeout: cfg.HTTPWriteTimeout,
+	}
+	err = server.ListenAndServe()
+	if err != nil {
+		logging.Fatal("HTTP server failed to start", "error", err)
+	}
+}
+
+// newAuthenticator enables every authentication method that is configured
+// and refuses to start when none is.
+func newAuthenticator(cfg Config) (*auth.Authenticator, error) {
+	authenticator := &auth.Authenticator{}
+
+	if cfg.APIKeysFile != "" {
+		keys, err := auth.LoadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.APIKeys = keys
+	}
+
+	if cfg.JWTHS256Secret != "" || cfg.JWTJWKSFile != "" {
+		verifier, err := auth.NewJWTVerifier(auth.JWTOptions{
+			HS256Secret: cfg.JWTHS256Secret,
+			JWKSFile:    cfg.JWTJWKSFile,
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticator.JWT = verifier
+	}
+
+	if authenticator.APIKeys == nil && authenticator.JWT == nil {
+		return nil, fmt.Errorf("no authentication method configured: set API_KEYS_FILE, JWT_HS256_SECRET or JWT_JWKS_FILE")
 	}
+
+	return authenticator, nil
 }
 
-func InitConfig() {
-	err := godotenv.Load(".env")
+// newRateLimits builds the per-route policy and the configured limiter backend.
+func newRateLimits(cfg Config) (*ratelimit.Middleware, error) {
+	defaultRule, err := ratelimit.ParseRule(cfg.RateLimitDefault)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		return nil, err
 	}
+
+	policy := ratelimit.Policy{Default: defaultRule, Routes: make(map[string]ratelimit.Rule)}
+	for _, entry := range cfg.RateLimits {
+		route, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q: expected METHOD /route=<rule>", entry)
+		}
+		rule, err := ratelimit.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		policy.Routes[strings.TrimSpace(route)] = rule
+	}
+
+	var limiter ratelimit.Limiter
+	switch cfg.RateLimitBackend {
+	case "redis":
+		if cfg.RateLimitRedis == "" {
+			return nil, fmt.Errorf("RATE_LIMIT_REDIS_ADDRESS is required for the redis backend")
+		}
+		limiter = ratelimit.NewRedisLimiter(cfg.RateLimitRedis, cfg.RateLimitRedisPwd, 16, time.Second)
+	default:
+		memory := ratelimit.NewMemoryLimiter()
+		go memory.RunCleanup(context.Background(), time.Minute)
+		limiter = memory
+	}
+
+	return &ratelimit.Middleware{Limiter: limiter, Policy: policy}, nil
 }
 
-func NewApi(sqlServiceConnString, rabbitConnString string) (*Api, error) {
+func NewApi(cfg Config) (*Api, error) {
 	var api *Api
 
+	transportCredentials, err := sqlServiceCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	for {
-		sqlServiceConn, err := grpc.Dial(sqlServiceConnString, grpc.WithInsecure())
+		sqlClient, err := sqlclient.Dial(cfg.SQLServiceAddress, sqlclient.Options{
+			Timeout:      cfg.SQLServiceTimeout,
+			Retries:      cfg.SQLServiceRetries,
+			RetryBackoff: cfg.SQLServiceBackoff,
+			Interceptors: []grpc.UnaryClientInterceptor{logging.UnaryClientInterceptor()},
+			DialOptions:  []grpc.DialOption{transportCredentials, grpc.WithStatsHandler(otelgrpc.NewClientHandler())},
+		})
 		if err != nil {
-			log.Printf("Failed to connect to sql-service: %v. Retrying...", err)
-			time.Sleep(5 * time.Second)
+			slog.Warn("Failed to connect to sql-service. Retrying...", "error", err)
+			time.Sleep(cfg.RetryInterval)
 			continue
 		}
 
-		rabbitConn, err := connectRabbitMQ(rabbitConnString)
+		rabbitConn, err := connectRabbitMQ(cfg.RabbitMQAddress, cfg.RetryInterval)
 		if err != nil {
-			sqlServiceConn.Close()
-			log.Printf("Failed to connect to rabbit: %v. Retrying...", err)
-			time.Sleep(5 * time.Second)
+			sqlClient.Close()
+			slog.Warn("Failed to connect to rabbit. Retrying...", "error", err)
+			time.Sleep(cfg.RetryInterval)
 			continue
 		}
 
 		api = &Api{
-			SQLServiceConn: sqlServiceConn,
-			RabbitConn:     rabbitConn,
+			Config:     cfg,
+			SQL:        sqlClient,
+			RabbitConn: rabbitConn,
+			Events:     stream.NewHub(cfg.EventHistorySize, cfg.EventHistoryAge),
+		}
+
+		if err := stream.Consume(context.Background(), stream.Dial(cfg.RabbitMQAddress), api.Events, cfg.RetryInterval); err != nil {
+			sqlClient.Close()
+			rabbitConn.Close()
+			slog.Warn("Failed to subscribe to wallet events. Retrying...", "error", err)
+			time.Sleep(cfg.RetryInterval)
+			continue
 		}
+		go api.Events.RunCleanup(context.Background(), cfg.EventHistoryAge)
 
-		log.Println("sql-service and rabbit Connected")
+		slog.Info("sql-service and rabbit Connected")
 		break
 	}
 
 	return api, nil
 }
 
-func connectRabbitMQ(rabbitConnString string) (*amqp.Connection, error) {
+func connectRabbitMQ(rabbitConnString string, retryInterval time.Duration) (*amqp.Connection, error) {
 	for {
 		conn, err := amqp.Dial(rabbitConnString)
 		if err != nil {
-			log.Printf("Failed to connect to RabbitMQ: %v. Retrying...", err)
-			time.Sleep(5 * time.Second)
+			slog.Warn("Failed to connect to RabbitMQ. Retrying...", "error", err)
+			time.Sleep(retryInterval)
 			continue
 		}
 		return conn, nil
@@ -115,41 +292,45 @@ func connectRabbitMQ(rabbitConnString string) (*amqp.Connection, error) {
 }
 
 func (a *Api) Close() {
-	a.SQLServiceConn.Close()
+	a.SQL.Close()
 	a.RabbitConn.Close()
 }
 
 // API
 func (a *Api) getTransactionHandler(c *gin.Context) {
 	id := c.Param("id")
-	log.Printf("Received ID: %s\n", id)
-
-	transaction, err := a.getTransactionFromService(id)
+	ctx := logging.With(c.Request.Context(), "transaction_id", id)
+	logging.FromContext(ctx).Debug("Received transaction lookup")
+
+	// A transaction on a wallet the caller does not own is answered like a
+	// missing one, so its existence is not revealed.
+	transaction, err := a.getTransactionFromService(ctx, id)
+	if status.Code(err) == codes.NotFound || err == nil && !auth.PrincipalFrom(c).OwnsWallet(transaction.WalletID) {
+		respond.Error(c, http.StatusNotFound, "Transaction not found")
+		return
+	}
 	if err != nil {
-		log.Printf("Error getting transaction: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transaction"})
+		sqlServiceError(ctx, c, "Failed to get transaction", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	respond.Data(c, http.StatusOK, transaction)
 }
 
-func (a *Api) getTransactionFromService(id string) (Transaction, error) {
-	sqlServiceClient := pb.NewSQLServiceClient(a.SQLServiceConn)
-	ctx := context.Background()
+func (a *Api) getTransactionFromService(ctx context.Context, id string) (Transaction, error) {
 	request := &pb.TransactionId{
 		TransactionId: id,
 	}
 
-	response, err := sqlServiceClient.GetTransactionID(ctx, request)
+	response, err := a.SQL.GetTransactionID(ctx, request)
 	if err != nil {
-		log.Println("Error request GetTransactionID:", err)
+		logging.FromContext(ctx).Error("Error request GetTransactionID", "error", err)
 		return Transaction{}, err
 	}
 
 	formattedTime, err := ProtoTimestampToFormattedTime(response.GetRequestTime())
 	if err != nil {
-		log.Println("Error FormattedTime:", err)
+		logging.FromContext(ctx).Error("Error FormattedTime", "error", err)
 		return Transaction{}, err
 	}
 
@@ -160,29 +341,104 @@ func (a *Api) getTransactionFromService(id string) (Transaction, error) {
 		Type:            response.Type,
 		Status:          response.Status,
 		TransactionTime: formattedTime,
+		Principal:       response.Principal,
 	}
 
 	return result, nil
 }
 
-func (a *Api) depositHandler(c *gin.Context) {
-	var depositRequest DepositRequest
+// walletEventsHandler streams the wallet's activity as Server-Sent Events.
+// Clients resume with the Last-Event-ID header (or ?last_event_id=).
+func (a *Api) walletEventsHandler(c *gin.Context) {
+	walletID, ok := walletParam(c)
+	if !ok {
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	logging.FromContext(c.Request.Context()).Info("Wallet event stream opened", "wallet_id", walletID, "last_event_id", lastEventID)
+	a.Events.Serve(c.Writer, c.Request, walletID, lastEventID, a.Config.SSEHeartbeat)
+}
+
+func (a *Api) auditLogHandler(c *gin.Context) {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respond.Error(c, http.StatusBadRequest, "Invalid wallet id")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		respond.Error(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
 
-	if err := c.ShouldBindJSON(&depositRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	ctx := logging.With(c.Request.Context(), "wallet_id", walletID)
+	entries, err := a.getAuditLogFromService(ctx, walletID, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting audit log", "error", err)
+		respond.Error(c, http.StatusInternalServerError, "Failed to get audit log")
 		return
 	}
 
-	if err := a.publishDeposit(depositRequest); err != nil {
-		log.Println("Error publishDeposit:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish deposit request"})
+	respond.Data(c, http.StatusOK, gin.H{"wallet_id": walletID, "entries": entries})
+}
+
+func (a *Api) getAuditLogFromService(ctx context.Context, walletID, limit int) ([]AuditEntry, error) {
+
+	response, err := a.SQL.GetAuditLog(ctx, &pb.AuditLogRequest{
+		WalletId: int32(walletID),
+		Limit:    int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]AuditEntry, 0, len(response.Entries))
+	for _, e := range response.Entries {
+		createdAt, err := ProtoTimestampToFormattedTime(e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, AuditEntry{
+			ID:            e.Id,
+			WalletID:      int(e.WalletId),
+			BeforeBalance: e.BeforeBalance,
+			AfterBalance:  e.AfterBalance,
+			CallerService: e.CallerService,
+			Principal:     e.Principal,
+			RequestID:     e.RequestId,
+			TransactionID: e.TransactionId,
+			CreatedAt:     createdAt,
+			PrevHash:      e.PrevHash,
+			Hash:          e.Hash,
+		})
+	}
+
+	return entries, nil
+}
+
+func (a *Api) depositHandler(c *gin.Context) {
+	walletID, amount, ok := paymentParams(c)
+	if !ok {
+		return
+	}
+	depositRequest := DepositRequest{TransactionID: uuid.New().String(), WalletID: walletID, Amount: amount, Principal: auth.PrincipalFrom(c).ID}
+
+	ctx := logging.With(c.Request.Context(), "wallet_id", depositRequest.WalletID, "transaction_id", depositRequest.TransactionID)
+	if err := a.publishDeposit(ctx, depositRequest); err != nil {
+		logging.FromContext(ctx).Error("Error publishDeposit", "error", err)
+		respond.Error(c, http.StatusInternalServerError, "Failed to publish deposit request")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Deposit request sent to RabbitMQ"})
+	queued(c, "deposit", depositRequest.TransactionID, walletID, amount, "Deposit request sent to RabbitMQ")
 }
 
-func (a *Api) publishDeposit(depositRequest DepositRequest) error {
+func (a *Api) publishDeposit(ctx context.Context, depositRequest DepositRequest) error {
 	ch, err := a.RabbitConn.Channel()
 	if err != nil {
 		return err
@@ -206,39 +462,81 @@ func (a *Api) publishDeposit(depositRequest DepositRequest) error {
 		return err
 	}
 
-	err = ch.Publish(
-		"",
-		q.Name,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		})
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return publishJSON(ctx, ch, q.Name, body)
 }
 
 func (a *Api) withdrawHandler(c *gin.Context) {
-	var withdrawRequest WithdrawRequest
-
-	if err := c.ShouldBindJSON(&withdrawRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	walletID, amount, ok := paymentParams(c)
+	if !ok {
 		return
 	}
+	withdrawRequest := WithdrawRequest{TransactionID: uuid.New().String(), WalletID: walletID, Amount: amount, Principal: auth.PrincipalFrom(c).ID}
 
-	if err := a.publishWithdraw(withdrawRequest); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish withdraw request"})
+	ctx := logging.With(c.Request.Context(), "wallet_id", withdrawRequest.WalletID, "transaction_id", withdrawRequest.TransactionID)
+	if err := a.publishWithdraw(ctx, withdrawRequest); err != nil {
+		logging.FromContext(ctx).Error("Error publishWithdraw", "error", err)
+		respond.Error(c, http.StatusInternalServerError, "Failed to publish withdraw request")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Withdraw request sent to RabbitMQ"})
+	queued(c, "withdraw", withdrawRequest.TransactionID, walletID, amount, "Withdraw request sent to RabbitMQ")
+}
+
+// paymentParams reads the wallet and amount of a deposit or withdrawal:
+// /v1 names the wallet in the path, the legacy routes in the body. Amounts
+// that sql-service could not store are refused with 400 here rather than
+// failing later in transaction-service. It writes the error response itself.
+func paymentParams(c *gin.Context) (walletID int, amount float64, ok bool) {
+	if c.Param("id") != "" {
+		if walletID, ok = walletParam(c); !ok {
+			return 0, 0, false
+		}
+		var request PaymentRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respond.Error(c, http.StatusBadRequest, err.Error())
+			return 0, 0, false
+		}
+		if err := checkAmount(request.Amount); err != nil {
+			respond.Error(c, http.StatusBadRequest, err.Error())
+			return 0, 0, false
+		}
+		return walletID, request.Amount, true
+	}
+
+	var request DepositRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respond.Error(c, http.StatusBadRequest, err.Error())
+		return 0, 0, false
+	}
+	if !auth.PrincipalFrom(c).OwnsWallet(request.WalletID) {
+		respond.Error(c, http.StatusForbidden, "Access to this wallet is not allowed")
+		return 0, 0, false
+	}
+	if err := checkAmount(request.Amount); err != nil {
+		respond.Error(c, http.StatusBadRequest, err.Error())
+		return 0, 0, false
+	}
+	return request.WalletID, request.Amount, true
+}
+
+// queued answers a payment handed over to transaction-service with 202 and
+// the queued request, whose transaction id can be polled until the
+// transaction is recorded. The legacy routes keep their 200 and message.
+func queued(c *gin.Context, paymentType, transactionID string, walletID int, amount float64, legacyMessage string) {
+	if respond.FormatOf(c) == respond.Legacy {
+		respond.Data(c, http.StatusOK, gin.H{"message": legacyMessage})
+		return
+	}
+	respond.Data(c, http.StatusAccepted, QueuedPayment{
+		TransactionID: transactionID,
+		WalletID:      walletID,
+		Type:          paymentType,
+		Amount:        amount,
+		Status:        "queued",
+	})
 }
 
-func (a *Api) publishWithdraw(withdrawRequest WithdrawRequest) error {
+func (a *Api) publishWithdraw(ctx context.Context, withdrawRequest WithdrawRequest) error {
 	ch, err := a.RabbitConn.Channel()
 	if err != nil {
 		return err
@@ -262,24 +560,42 @@ func (a *Api) publishWithdraw(withdrawRequest WithdrawRequest) error {
 		return err
 	}
 
-	err = ch.Publish(
+	return publishJSON(ctx, ch, q.Name, body)
+}
+
+// publishJSON publishes body to queue inside a producer span and injects the
+// span context into the message headers for the consumer to pick up.
+func publishJSON(ctx context.Context, ch *amqp.Channel, queue string, body []byte) error {
+	ctx, span := tracer.Start(ctx, queue+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", queue),
+		),
+	)
+	defer span.End()
+
+	err := ch.Publish(
 		"",
-		q.Name,
+		queue,
 		false,
 		false,
 		amqp.Publishing{
 			ContentType: "application/json",
+			Headers:     tracing.AMQPHeaders(ctx),
 			Body:        body,
 		})
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 
+	logging.FromContext(ctx).Info("Request published", "queue", queue)
 	return nil
 }
 
 // utils
-func ProtoTimestampToFormattedTime(ts *timestamp.Timestamp) (string, error) {
+func ProtoTimestampToFormattedTime(ts *timestamppb.Timestamp) (string, error) {
 	seconds := int64(ts.GetSeconds())
 	nanos := int64(ts.GetNanos())
 
@@ -289,3 +605,34 @@ func ProtoTimestampToFormattedTime(ts *timestamp.Timestamp) (string, error) {
 
 	return formattedTime, nil
 }
+
+// sqlServiceCredentials returns mTLS transport credentials for the sql-service
+// connection, or plaintext when no certificates are configured and
+// INSECURE_DEV allows it.
+func sqlServiceCredentials(cfg Config) (grpc.DialOption, error) {
+	files := tlsconfig.Files{
+		CertFile: cfg.TLSCertFile,
+		KeyFile:  cfg.TLSKeyFile,
+		CAFile:   cfg.TLSCAFile,
+	}
+	plaintext, err := files.Plaintext(cfg.InsecureDev)
+	if err != nil {
+		return nil, err
+	}
+	if plaintext {
+		slog.Warn("INSECURE_DEV is set: connecting to sql-service over plaintext")
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+
+	reloader, err := tlsconfig.NewReloader(context.Background(), files, cfg.TLSReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	serverName := cfg.SQLServiceName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(cfg.SQLServiceAddress)
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(reloader.ClientConfig(serverName))), nil
+}
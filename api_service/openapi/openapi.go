@@ -0,0 +1,156 @@
+// Package openapi embeds the OpenAPI document of the HTTP API, serves it
+// with a Swagger UI page and validates incoming requests against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"api_service/respond"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.yaml
+var document []byte
+
+var uuidFormat = regexp.MustCompile(openapi3.FormatOfStringForUUIDOfRFC4122)
+
+func init() {
+	openapi3.DefineStringFormatCallback("uuid", func(s string) error {
+		if !uuidFormat.MatchString(s) {
+			return errors.New("not a UUID")
+		}
+		return nil
+	})
+}
+
+// Spec is the parsed document together with the router used to match
+// requests to its operations.
+type Spec struct {
+	Doc    *openapi3.T
+	router routers.Router
+	json   []byte
+}
+
+// Load parses and validates the embedded document.
+func Load() (*Spec, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("openapi: invalid document: %v", err)
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %v", err)
+	}
+	json, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %v", err)
+	}
+
+	return &Spec{Doc: doc, router: router, json: json}, nil
+}
+
+// Validator rejects requests whose parameters or body do not match the
+// operation they are routed to, with 400 and the first mismatch. Requests
+// for paths the document does not describe are passed on unchanged.
+// Credentials are checked by the auth middleware, not here.
+func (s *Spec) Validator() gin.HandlerFunc {
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		MultiError:         false,
+	}
+
+	return func(c *gin.Context) {
+		route, pathParams, err := s.router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		err = openapi3filter.ValidateRequest(c.Request.Context(), &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		})
+		if err != nil {
+			respond.Abort(c, http.StatusBadRequest, validationMessage(err))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// validationMessage shortens kin-openapi errors to what a client needs:
+// which parameter or body field is wrong and why.
+func validationMessage(err error) string {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return err.Error()
+	}
+
+	where := "body"
+	if requestErr.Parameter != nil {
+		where = "parameter " + requestErr.Parameter.Name
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if field := strings.Join(schemaErr.JSONPointer(), "."); field != "" {
+			where += ": " + field
+		}
+		return where + ": " + schemaErr.Reason
+	}
+
+	var parseErr *openapi3filter.ParseError
+	if errors.As(err, &parseErr) {
+		return where + ": " + parseErr.Reason
+	}
+	if requestErr.Err != nil {
+		return where + ": " + requestErr.Err.Error()
+	}
+	return where + ": " + requestErr.Reason
+}
+
+// JSONHandler serves the document as JSON.
+func (s *Spec) JSONHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", s.json)
+}
+
+// UIHandler serves a Swagger UI page for the document. The UI assets are
+// loaded from a CDN.
+func (s *Spec) UIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
+
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Wallet API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
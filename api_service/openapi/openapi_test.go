@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(spec.Validator())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/deposit", ok)
+	r.POST("/v1/wallets/:id/deposits", ok)
+	r.GET("/get-transaction/:id", ok)
+	r.GET("/wallets/:id/webhooks/:webhook_id/deliveries", ok)
+	r.POST("/batches", ok)
+	r.GET("/undocumented", ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		message     string
+	}{
+		{"valid deposit", "POST", "/deposit", "application/json", `{"wallet_id": 1, "amount": 10.5}`, 200, ""},
+		{"missing wallet_id", "POST", "/deposit", "application/json", `{"amount": 10}`, 400, "wallet_id"},
+		{"zero amount", "POST", "/deposit", "application/json", `{"wallet_id": 1, "amount": 0}`, 400, "amount"},
+		{"negative amount", "POST", "/deposit", "application/json", `{"wallet_id": 1, "amount": -5}`, 400, "amount"},
+		{"string amount", "POST", "/deposit", "application/json", `{"wallet_id": 1, "amount": "5"}`, 400, "amount"},
+		{"no body", "POST", "/deposit", "application/json", ``, 400, "body"},
+		{"v1 deposit", "POST", "/v1/wallets/1/deposits", "application/json", `{"amount": 10.5}`, 200, ""},
+		{"v1 zero amount", "POST", "/v1/wallets/1/deposits", "application/json", `{"amount": 0}`, 400, "amount"},
+		{"v1 bad wallet path", "POST", "/v1/wallets/0/deposits", "application/json", `{"amount": 5}`, 400, "parameter id"},
+		{"uuid path", "GET", "/get-transaction/f47cbde3-98d8-47cb-a30b-1046b1f70b75", "", "", 200, ""},
+		{"bad uuid path", "GET", "/get-transaction/42", "", "", 400, "parameter id"},
+		{"bad wallet path", "GET", "/wallets/x/webhooks/f47cbde3-98d8-47cb-a30b-1046b1f70b75/deliveries", "", "", 400, "parameter id"},
+		{"limit out of range", "GET", "/wallets/1/webhooks/f47cbde3-98d8-47cb-a30b-1046b1f70b75/deliveries?limit=0", "", "", 400, "parameter limit"},
+		{"batch json", "POST", "/batches", "application/json", `{"mode": "best_effort", "items": [{"wallet_id": 1, "type": "withdraw", "amount": 5}]}`, 200, ""},
+		{"batch bad type", "POST", "/batches", "application/json", `{"items": [{"wallet_id": 1, "type": "refund", "amount": 5}]}`, 400, "type"},
+		{"batch bad mode", "POST", "/batches?mode=sometimes", "text/csv", "wallet_id,type,amount\n1,withdraw,5\n", 400, "parameter mode"},
+		{"batch csv", "POST", "/batches", "text/csv", "wallet_id,type,amount\n1,withdraw,5\n", 200, ""},
+		{"undocumented path", "GET", "/undocumented", "", "", 200, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.message != "" && !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("error %s does not mention %q", w.Body, tt.message)
+			}
+			if true {
+				t.Log(w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rule   Rule
+}
+
+// MemoryLimiter keeps buckets in process memory. It is exact for a single
+// replica; use RedisLimiter when several replicas share the limits.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryLimiter) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	var result Result
+	b.tokens, result = take(b.tokens, b.last, now, rule)
+	b.last = now
+	b.rule = rule
+
+	return result, nil
+}
+
+// Cleanup drops buckets that have refilled since their last request. A
+// dropped bucket comes back full, so only those can go: dropping a bucket
+// still refilling, say one of an hourly rule, would hand out its tokens
+// early.
+func (m *MemoryLimiter) Cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for key, b := range m.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rule.Rate >= float64(b.rule.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// RunCleanup calls Cleanup every interval until ctx is done.
+func (m *MemoryLimiter) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Cleanup()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api_service/auth"
+	"api_service/respond"
+	"common/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	checks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_ratelimit_checks_total",
+		Help: "Rate limit checks by route and key dimension.",
+	}, []string{"route", "dimension"})
+
+	rejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_ratelimit_rejections_total",
+		Help: "Requests rejected with 429 by route and key dimension.",
+	}, []string{"route", "dimension"})
+
+	errorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "api_ratelimit_errors_total",
+		Help: "Rate limit backend errors; requests are let through when they happen.",
+	})
+)
+
+// Policy maps "METHOD /route" (the gin route pattern) to its rule; routes
+// without an entry use Default.
+type Policy struct {
+	Default Rule
+	Routes  map[string]Rule
+}
+
+func (p Policy) rule(c *gin.Context) Rule {
+	if rule, ok := p.Routes[c.Request.Method+" "+c.FullPath()]; ok {
+		return rule
+	}
+	return p.Default
+}
+
+// Middleware throttles requests per client IP, per authenticated principal
+// (the API key or token subject) and per wallet, named by a /wallets/:id
+// path or the wallet_id of the request body.
+type Middleware struct {
+	Limiter Limiter
+	Policy  Policy
+}
+
+// ByIP limits by client address. It runs before authentication so that
+// credential guessing is throttled as well.
+func (m *Middleware) ByIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.check(c, "ip", c.ClientIP()) {
+			c.Next()
+		}
+	}
+}
+
+// ByPrincipalAndWallet limits authenticated requests by caller and by the
+// wallet they act on.
+func (m *Middleware) ByPrincipalAndWallet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal := auth.PrincipalFrom(c); principal != nil {
+			if !m.check(c, "principal", principal.ID) {
+				return
+			}
+		}
+		walletID, ok := pathWalletID(c)
+		if !ok {
+			walletID, ok = bodyWalletID(c)
+		}
+		if ok {
+			if !m.check(c, "wallet", strconv.Itoa(walletID)) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// check counts the request against the bucket for dimension/value, sets the
+// X-RateLimit-* headers and aborts with 429 when the bucket is empty.
+func (m *Middleware) check(c *gin.Context, dimension, value string) bool {
+	route := c.FullPath()
+	rule := m.Policy.rule(c)
+	key := dimension + ":" + c.Request.Method + " " + route + ":" + value
+
+	result, err := m.Limiter.Allow(c.Request.Context(), key, rule)
+	if err != nil {
+		errorsTotal.Inc()
+		logging.FromContext(c.Request.Context()).Error("Rate limiter unavailable, letting request through", "error", err)
+		return true
+	}
+	checks.WithLabelValues(route, dimension).Inc()
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(rule.Burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+	if !result.Allowed {
+		rejections.WithLabelValues(route, dimension).Inc()
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		respond.Abort(c, http.StatusTooManyRequests, "Rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// pathWalletID reads the wallet of /wallets/:id routes.
+func pathWalletID(c *gin.Context) (int, bool) {
+	if !strings.Contains(c.FullPath(), "/wallets/:id") {
+		return 0, false
+	}
+	walletID, err := strconv.Atoi(c.Param("id"))
+	return walletID, err == nil
+}
+
+// maxPeekSize bounds how much of a body bodyWalletID reads. Bodies naming a
+// single wallet are far smaller; larger ones are not counted per wallet.
+const maxPeekSize = 64 << 10
+
+// bodyWalletID peeks at the JSON body for a wallet_id and restores the body
+// for the handler.
+func bodyWalletID(c *gin.Context) (int, bool) {
+	if c.Request.Body == nil || c.ContentType() != "application/json" && c.ContentType() != "" {
+		return 0, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekSize+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+	if err != nil || len(body) == 0 || len(body) > maxPeekSize {
+		return 0, false
+	}
+
+	var payload struct {
+		WalletID *int `json:"wallet_id"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.WalletID == nil {
+		return 0, false
+	}
+	return *payload.WalletID, true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
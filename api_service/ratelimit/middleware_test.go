@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyWalletID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		ok          bool
+	}{
+		{"json", "application/json", `{"wallet_id": 7, "amount": 5}`, 7, true},
+		{"no content type", "", `{"wallet_id": 7}`, 7, true},
+		{"no wallet", "application/json", `{"amount": 5}`, 0, false},
+		{"not json", "application/json", `wallet_id=7`, 0, false},
+		{"csv", "text/csv", "wallet_id\n7\n", 0, false},
+		{"empty", "application/json", "", 0, false},
+		{"larger than the peek", "application/json",
+			`{"wallet_id": 7, "note": "` + strings.Repeat("x", maxPeekSize) + `"}`, 0, false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/deposit", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			c.Request.Header.Set("Content-Type", tt.contentType)
+		}
+
+		walletID, ok := bodyWalletID(c)
+		if walletID != tt.want || ok != tt.ok {
+			t.Errorf("%s: bodyWalletID = %d, %v, want %d, %v", tt.name, walletID, ok, tt.want, tt.ok)
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil || string(body) != tt.body {
+			t.Errorf("%s: the handler reads %d bytes (%v), want the whole body of %d", tt.name, len(body), err, len(tt.body))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule is a token bucket: Burst tokens at most, refilled at Rate per second.
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRule parses "<count>/<s|m|h>[:<burst>]", e.g. "10/s:20" or "100/m".
+// The burst defaults to the count.
+func ParseRule(s string) (Rule, error) {
+	spec, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	countSpec, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q: expected <count>/<unit>[:<burst>]", s)
+	}
+
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: bad count", s)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Rule{}, fmt.Errorf("rate limit %q: unit must be s, m or h", s)
+	}
+
+	burst := count
+	if hasBurst {
+		burst, err = strconv.Atoi(burstSpec)
+		if err != nil || burst <= 0 {
+			return Rule{}, fmt.Errorf("rate limit %q: bad burst", s)
+		}
+	}
+
+	return Rule{Rate: float64(count) / per.Seconds(), Burst: burst}, nil
+}
+
+// Result describes the bucket after a request has been counted.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // zero when allowed
+	ResetAfter time.Duration // time until the bucket is full again
+}
+
+// Limiter takes one token from the bucket identified by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// take applies the token bucket algorithm to a bucket holding tokens at last.
+// Memory and Redis implementations share it (the Lua script mirrors it).
+func take(tokens float64, last, now time.Time, rule Rule) (float64, Result) {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens = math.Min(float64(rule.Burst), tokens+elapsed*rule.Rate)
+
+	result := Result{}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rule.Rate)
+	}
+	result.Remaining = int(tokens)
+	result.ResetAfter = seconds((float64(rule.Burst) - tokens) / rule.Rate)
+
+	return tokens, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Rule
+	}{
+		{"10/s", Rule{Rate: 10, Burst: 10}},
+		{"10/s:20", Rule{Rate: 10, Burst: 20}},
+		{"120/m", Rule{Rate: 2, Burst: 120}},
+		{" 3600/h:5 ", Rule{Rate: 1, Burst: 5}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRule(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "10", "10/d", "0/s", "-1/s", "x/s", "10/s:0", "10/s:x"} {
+		if rule, err := ParseRule(spec); err == nil {
+			t.Errorf("ParseRule(%q) = %+v, want an error", spec, rule)
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	rule := Rule{Rate: 2, Burst: 4}
+	start := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		want       Result
+	}{
+		{"full", 4, 0, 3, Result{Allowed: true, Remaining: 3, ResetAfter: 500 * time.Millisecond}},
+		{"last token", 1, 0, 0, Result{Allowed: true, Remaining: 0, ResetAfter: 2 * time.Second}},
+		{"empty", 0, 0, 0, Result{RetryAfter: 500 * time.Millisecond, ResetAfter: 2 * time.Second}},
+		{"half refilled", 0, 250 * time.Millisecond, 0.5, Result{RetryAfter: 250 * time.Millisecond, ResetAfter: 1750 * time.Millisecond}},
+		{"refilled", 0, 500 * time.Millisecond, 0, Result{Allowed: true, ResetAfter: 2 * time.Second}},
+		{"capped at burst", 1, time.Hour, 3, Result{Allowed: true, Remaining: 3, ResetAfter: 500 * time.Millisecond}},
+		{"clock went back", 0, -time.Second, 0, Result{RetryAfter: 500 * time.Millisecond, ResetAfter: 2 * time.Second}},
+	}
+	for _, tt := range tests {
+		tokens, got := take(tt.tokens, start, start.Add(tt.elapsed), rule)
+		if tokens != tt.wantTokens || got != tt.want {
+			t.Errorf("%s: take = %v, %+v, want %v, %+v", tt.name, tokens, got, tt.wantTokens, tt.want)
+		}
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+	rule := Rule{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	allow := func(key string) Result {
+		t.Helper()
+		result, err := limiter.Allow(ctx, key, rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if !allow("a").Allowed || !allow("a").Allowed {
+		t.Fatal("a full bucket refused a request")
+	}
+	if result := allow("a"); result.Allowed || result.RetryAfter != time.Second {
+		t.Fatalf("an empty bucket answered %+v", result)
+	}
+	if !allow("b").Allowed {
+		t.Fatal("buckets are shared between keys")
+	}
+
+	now = now.Add(time.Second)
+	if !allow("a").Allowed {
+		t.Fatal("the bucket did not refill")
+	}
+
+	now = now.Add(time.Minute)
+	limiter.Cleanup()
+	if len(limiter.buckets) != 0 {
+		t.Errorf("Cleanup kept %d refilled buckets", len(limiter.buckets))
+	}
+	if result := allow("a"); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("a dropped bucket came back as %+v, want full", result)
+	}
+}
+
+// TestMemoryLimiterCleanupSlowRule checks that Cleanup keeps a bucket of an
+// hourly rule until it has refilled, however long it has been idle.
+func TestMemoryLimiterCleanupSlowRule(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+	rule, err := ParseRule("2/h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if result, err := limiter.Allow(ctx, "a", rule); err != nil || !result.Allowed {
+			t.Fatalf("request %d: %+v, %v", i, result, err)
+		}
+	}
+
+	now = now.Add(45 * time.Minute)
+	limiter.Cleanup()
+	if len(limiter.buckets) != 1 {
+		t.Fatal("Cleanup dropped a bucket that had not refilled")
+	}
+	if result, err := limiter.Allow(ctx, "a", rule); err != nil || !result.Allowed || result.Remaining != 0 {
+		t.Errorf("after 45 minutes: %+v, %v, want one token", result, err)
+	}
+	if result, _ := limiter.Allow(ctx, "a", rule); result.Allowed {
+		t.Error("the bucket handed out a token it had not refilled")
+	}
+
+	now = now.Add(time.Hour)
+	limiter.Cleanup()
+	if len(limiter.buckets) != 0 {
+		t.Errorf("Cleanup kept a refilled bucket")
+	}
+}
@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenBucketScript is take() in Lua, run atomically by the server and
+// using the server clock so replicas with skewed clocks agree.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + tonumber(t[2]) / 1000
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+var tokenBucketSHA = func() string {
+	sum := sha1.Sum([]byte(tokenBucketScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+// RedisLimiter keeps buckets in any server speaking the Redis protocol
+// (Redis, Valkey, KeyDB, Dragonfly) so that all api-service replicas share
+// the same limits.
+type RedisLimiter struct {
+	addr     string
+	password string
+	prefix   string
+	timeout  time.Duration
+	pool     chan *redisConn
+}
+
+func NewRedisLimiter(addr, password string, poolSize int, timeout time.Duration) *RedisLimiter {
+	return &RedisLimiter{
+		addr:     addr,
+		password: password,
+		prefix:   "ratelimit:",
+		timeout:  timeout,
+		pool:     make(chan *redisConn, poolSize),
+	}
+}
+
+func (r *RedisLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	rate := strconv.FormatFloat(rule.Rate, 'f', -1, 64)
+	burst := strconv.Itoa(rule.Burst)
+
+	reply, err := r.do(ctx, "EVALSHA", tokenBucketSHA, "1", r.prefix+key, rate, burst)
+	var redisErr redisError
+	if errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "NOSCRIPT") {
+		reply, err = r.do(ctx, "EVAL", tokenBucketScript, "1", r.prefix+key, rate, burst)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected script reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected token count %q", tokensStr)
+	}
+
+	result := Result{
+		Allowed:    allowed == 1,
+		Remaining:  int(tokens),
+		ResetAfter: seconds((float64(rule.Burst) - tokens) / rule.Rate),
+	}
+	if !result.Allowed {
+		result.RetryAfter = seconds((1 - tokens) / rule.Rate)
+	}
+	return result, nil
+}
+
+func (r *RedisLimiter) Close() {
+	for {
+		select {
+		case conn := <-r.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (r *RedisLimiter) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-r.pool:
+		return conn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: r.timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if r.password != "" {
+		if _, err := conn.command(time.Now().Add(r.timeout), "AUTH", r.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (r *RedisLimiter) put(conn *redisConn) {
+	select {
+	case r.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (r *RedisLimiter) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	reply, err := conn.command(deadline, args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection state is unknown after an I/O error.
+		conn.Close()
+		return nil, err
+	}
+	r.put(conn)
+	return reply, err
+}
+
+func (c *redisConn) command(deadline time.Time, args ...string) (interface{}, error) {
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c, b.String()); err != nil {
+		return nil, err
+	}
+
+	return readReply(c.reader)
+}
+
+// readReply decodes one RESP2 reply.
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readReply(reader); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", line[0])
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  any
+		err   error
+	}{
+		{"simple string", "+OK\r\n", "OK", nil},
+		{"error", "-ERR wrong number of arguments\r\n", nil, redisError("ERR wrong number of arguments")},
+		{"noscript", "-NOSCRIPT No matching script.\r\n", nil, redisError("NOSCRIPT No matching script.")},
+		{"integer", ":42\r\n", int64(42), nil},
+		{"bulk string", "$5\r\nhe\r\no\r\n", "he\r\no", nil},
+		{"empty bulk string", "$0\r\n\r\n", "", nil},
+		{"null bulk string", "$-1\r\n", nil, nil},
+		{"script reply", "*2\r\n:1\r\n$3\r\n1.5\r\n", []any{int64(1), "1.5"}, nil},
+		{"nested array", "*2\r\n*2\r\n+a\r\n$-1\r\n:7\r\n", []any{[]any{"a", nil}, int64(7)}, nil},
+		{"null array", "*-1\r\n", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := readReply(bufio.NewReader(strings.NewReader(tt.reply)))
+		if !reflect.DeepEqual(got, tt.want) || err != tt.err {
+			t.Errorf("%s: readReply = %#v, %v, want %#v, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+
+	for _, reply := range []string{"", "\r\n", "?x\r\n", "$5\r\nab\r\n", "*2\r\n:1\r\n", ":x\r\n"} {
+		if got, err := readReply(bufio.NewReader(strings.NewReader(reply))); err == nil {
+			t.Errorf("readReply(%q) = %#v, want an error", reply, got)
+		}
+	}
+}
+
+// TestRedisLimiterLoadsScript runs Allow against a server that has not
+// cached the script yet: EVALSHA fails with NOSCRIPT and the limiter falls
+// back to EVAL on the same connection.
+func TestRedisLimiterLoadsScript(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	commands := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for _, reply := range []string{"-NOSCRIPT No matching script.\r\n", "*2\r\n:0\r\n$4\r\n0.25\r\n"} {
+			request, err := readReply(reader)
+			if err != nil {
+				return
+			}
+			commands <- request.([]any)[0].(string)
+			conn.Write([]byte(reply))
+		}
+	}()
+
+	limiter := NewRedisLimiter(listener.Addr().String(), "", 1, time.Second)
+	defer limiter.Close()
+	result, err := limiter.Allow(context.Background(), "ip:1.2.3.4", Rule{Rate: 1, Burst: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{RetryAfter: 750 * time.Millisecond, ResetAfter: 1750 * time.Millisecond}); result != want {
+		t.Errorf("Allow = %+v, want %+v", result, want)
+	}
+	if got := []string{<-commands, <-commands}; got[0] != "EVALSHA" || got[1] != "EVAL" {
+		t.Errorf("commands %v, want EVALSHA then EVAL", got)
+	}
+
+	var redisErr redisError
+	if _, err := limiter.Allow(context.Background(), "ip:1.2.3.4", Rule{Rate: 1, Burst: 2}); err == nil || errors.As(err, &redisErr) {
+		t.Errorf("Allow after the server hung up returned %v, want an I/O error", err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	"common/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLogger accepts the X-Request-ID header (or generates one), echoes
+// it back, attaches a request-scoped logger to the request context and logs
+// every request once it has been served.
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		ctx := logging.WithRequestID(c.Request.Context(), c.GetHeader(logging.RequestIDHeader))
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(logging.RequestIDHeader, logging.RequestID(ctx))
+
+		c.Next()
+
+		logging.FromContext(ctx).Info("http request",
+			"method", c.Request.Method,
+			"path", c.FullPath(),
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
@@ -0,0 +1,124 @@
+// Package respond writes HTTP responses in the format of the API version a
+// route belongs to. Handlers are shared between versions: they hand their
+// payload or error to respond, and the Format installed on the router group
+// decides how it looks on the wire.
+package respond
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const formatKey = "respond.format"
+
+// Format renders successful payloads and errors. details holds extra error
+// fields, such as the refused items of a batch; it may be nil.
+type Format interface {
+	Data(c *gin.Context, status int, data any)
+	Error(c *gin.Context, status int, message string, details gin.H)
+}
+
+var (
+	// Legacy is the format of the unversioned routes: the payload as is
+	// and {"error": message} with the details merged in.
+	Legacy Format = legacy{}
+	// V1 wraps payloads in {"data": ...} and errors in
+	// {"error": {"code": ..., "message": ..., "details": ...}}.
+	V1 Format = envelope{}
+)
+
+// Use makes f the format of every response written further down the chain,
+// including errors from authentication and rate limiting. It must come
+// first in the group.
+func Use(f Format) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(formatKey, f)
+		c.Next()
+	}
+}
+
+// FormatOf returns the format of the request's route, Legacy when none was
+// installed.
+func FormatOf(c *gin.Context) Format {
+	if value, ok := c.Get(formatKey); ok {
+		if f, ok := value.(Format); ok {
+			return f
+		}
+	}
+	return Legacy
+}
+
+func Data(c *gin.Context, status int, data any) {
+	FormatOf(c).Data(c, status, data)
+}
+
+func Error(c *gin.Context, status int, message string) {
+	FormatOf(c).Error(c, status, message, nil)
+}
+
+func ErrorDetails(c *gin.Context, status int, message string, details gin.H) {
+	FormatOf(c).Error(c, status, message, details)
+}
+
+// Abort writes the error and stops the handler chain; for middleware.
+func Abort(c *gin.Context, status int, message string) {
+	c.Abort()
+	Error(c, status, message)
+}
+
+type legacy struct{}
+
+func (legacy) Data(c *gin.Context, status int, data any) {
+	c.JSON(status, data)
+}
+
+func (legacy) Error(c *gin.Context, status int, message string, details gin.H) {
+	body := gin.H{}
+	for key, value := range details {
+		body[key] = value
+	}
+	body["error"] = message
+	c.JSON(status, body)
+}
+
+type envelope struct{}
+
+func (envelope) Data(c *gin.Context, status int, data any) {
+	c.JSON(status, gin.H{"data": data})
+}
+
+func (envelope) Error(c *gin.Context, status int, message string, details gin.H) {
+	problem := gin.H{"code": Code(status), "message": message}
+	if len(details) > 0 {
+		problem["details"] = details
+	}
+	c.JSON(status, gin.H{"error": problem})
+}
+
+// Code is the machine-readable error code for an HTTP status.
+func Code(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthenticated"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	if status >= 500 {
+		return "internal"
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api_service/auth"
+	"api_service/openapi"
+	"api_service/ratelimit"
+	"api_service/respond"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// legacyDeprecatedAt is when /v1 replaced the unversioned routes; it is
+// sent in their Deprecation header.
+var legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+var legacyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "api_legacy_requests_total",
+	Help: "Requests served on the deprecated unversioned routes, by route.",
+}, []string{"route"})
+
+// route is one operation of the HTTP API. Handlers do not know which
+// version serves them: the version group picks the path and, through
+// respond, the response format. A /v2 gets its own route list and format
+// and reuses whichever handlers did not change.
+type route struct {
+	method  string
+	path    string
+	scope   string
+	handler gin.HandlerFunc
+	// legacy is the unversioned path served before /v1. It is kept as a
+	// deprecated alias until the sunset date.
+	legacy string
+}
+
+// maxBodySize bounds the body of every route but those in bodyLimits.
+const maxBodySize = 64 << 10
+
+// bodyLimits raises the body limit of the routes that take uploads, keyed
+// by method and path.
+var bodyLimits = map[string]int64{
+	"POST /batches": maxBatchBodySize,
+}
+
+func (rt route) maxBody() int64 {
+	if limit, ok := bodyLimits[rt.method+" "+rt.path]; ok {
+		return limit
+	}
+	return maxBodySize
+}
+
+func (a *Api) v1Routes() []route {
+	return []route{
+		{"POST", "/wallets/:id/deposits", auth.ScopePaymentsWrite, a.depositHandler, "/deposit"},
+		{"POST", "/wallets/:id/withdrawals", auth.ScopePaymentsWrite, a.withdrawHandler, "/withdraw"},
+		{"GET", "/transactions/:id", auth.ScopeWalletsRead, a.getTransactionHandler, "/get-transaction/:id"},
+		{"GET", "/wallets/:id/events", auth.ScopeWalletsRead, a.walletEventsHandler, "/wallets/:id/events"},
+		{"POST", "/batches", auth.ScopePaymentsWrite, a.createBatchHandler, "/batches"},
+		{"GET", "/batches/:id", auth.ScopeWalletsRead, a.getBatchHandler, "/batches/:id"},
+		{"GET", "/admin/wallets/:id/audit", auth.ScopeAdmin, a.auditLogHandler, "/admin/wallets/:id/audit"},
+
+		{"POST", "/wallets/:id/webhooks", auth.ScopeWebhooks, a.createWebhookHandler, "/wallets/:id/webhooks"},
+		{"GET", "/wallets/:id/webhooks", auth.ScopeWebhooks, a.listWebhooksHandler, "/wallets/:id/webhooks"},
+		{"DELETE", "/wallets/:id/webhooks/:webhook_id", auth.ScopeWebhooks, a.deleteWebhookHandler, "/wallets/:id/webhooks/:webhook_id"},
+		{"POST", "/wallets/:id/webhooks/:webhook_id/enable", auth.ScopeWebhooks, a.enableWebhookHandler, "/wallets/:id/webhooks/:webhook_id/enable"},
+		{"GET", "/wallets/:id/webhooks/:webhook_id/deliveries", auth.ScopeWebhooks, a.listWebhookDeliveriesHandler, "/wallets/:id/webhooks/:webhook_id/deliveries"},
+		{"POST", "/wallets/:id/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", auth.ScopeWebhooks, a.redeliverWebhookHandler, "/wallets/:id/webhooks/:webhook_id/deliveries/:delivery_id/redeliver"},
+	}
+}
+
+// newRouter registers every route of the API. Each one must be described
+// in the OpenAPI document; TestRoutesMatchSpec keeps the two in step.
+func newRouter(api *Api, authenticator *auth.Authenticator, rateLimits *ratelimit.Middleware, spec *openapi.Spec, sunset time.Time) *gin.Engine {
+	r := gin.New()
+	// gin trusts X-Forwarded-For from every peer by default, which would let
+	// a client pick its own IP rate limit bucket. main trusts the configured
+	// proxies only.
+	r.SetTrustedProxies(nil)
+	r.Use(gin.Recovery(), otelgin.Middleware(serviceName), requestLogger())
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/openapi.json", spec.JSONHandler)
+	r.GET("/docs", spec.UIHandler)
+
+	// The format comes first so that authentication, rate limit and
+	// validation errors are written in it too. The body is limited before
+	// anything reads it: the wallet rate limit and the validator do.
+	protected := func(format respond.Format, rt route, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+		return append([]gin.HandlerFunc{respond.Use(format), limitBody(rt.maxBody()), rateLimits.ByIP(),
+			authenticator.Middleware(), rateLimits.ByPrincipalAndWallet(), spec.Validator()}, handlers...)
+	}
+	v1 := r.Group("/v1")
+	legacy := r.Group("/")
+
+	for _, rt := range api.v1Routes() {
+		v1.Handle(rt.method, rt.path, protected(respond.V1, rt, auth.RequireScope(rt.scope), rt.handler)...)
+		if rt.legacy != "" {
+			legacy.Handle(rt.method, rt.legacy,
+				protected(respond.Legacy, rt, deprecated("/v1"+rt.path, sunset), auth.RequireScope(rt.scope), rt.handler)...)
+		}
+	}
+
+	return r
+}
+
+// limitBody reads the body up to limit bytes and answers 413 beyond it, so
+// that the middleware and handler after it get a body already in memory.
+func limitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			respond.Abort(c, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respond.Abort(c, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if err != nil {
+			respond.Abort(c, http.StatusBadRequest, "Unable to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+// deprecated marks the responses of a legacy alias with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers and links its /v1 successor when
+// the path parameters allow building it.
+func deprecated(successor string, sunset time.Time) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(legacyDeprecatedAt.Unix(), 10)
+	sunsetHeader := sunset.UTC().Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		legacyRequests.WithLabelValues(c.Request.Method + " " + c.FullPath()).Inc()
+
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunsetHeader)
+		links := []string{`</docs>; rel="deprecation"`}
+		if path, ok := expandPath(successor, c.Params); ok {
+			links = append(links, "<"+path+`>; rel="successor-version"`)
+		}
+		c.Header("Link", strings.Join(links, ", "))
+		c.Next()
+	}
+}
+
+// expandPath fills the :name segments of a route pattern from params.
+func expandPath(pattern string, params gin.Params) (string, bool) {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+		value, ok := params.Get(name)
+		if !ok {
+			return "", false
+		}
+		segments[i] = value
+	}
+	return strings.Join(segments, "/"), true
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"api_service/auth"
+	"api_service/openapi"
+	"api_service/ratelimit"
+	pb "sqlapi/pb"
+	"sqlapi/sqlclient"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+// TestRoutesMatchSpec fails when a gin route is missing from the OpenAPI
+// document or the document describes an operation no route serves.
+func TestRoutesMatchSpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := newRouter(&Api{}, &auth.Authenticator{}, &ratelimit.Middleware{}, spec, time.Time{})
+
+	routes := make(map[string]bool)
+	for _, route := range router.Routes() {
+		routes[route.Method+" "+ginParam.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range spec.Doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	for _, missing := range difference(routes, documented) {
+		t.Errorf("route %s is not in openapi.yaml", missing)
+	}
+	for _, missing := range difference(documented, routes) {
+		t.Errorf("openapi.yaml describes %s but no route serves it", missing)
+	}
+}
+
+func difference(a, b map[string]bool) []string {
+	var keys []string
+	for key := range a {
+		if !b[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestVersionedResponses checks that one handler answers in the envelope on
+// /v1 and in the original format, with deprecation headers, on its alias.
+func TestVersionedResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("test-key"))
+	keysFile := filepath.Join(t.TempDir(), "api_keys.json")
+	keys := `[{"key_sha256": "` + hex.EncodeToString(sum[:]) + `", "principal": "merchant", "scopes": ["payments:write", "webhooks:manage"], "wallets": [1]}]`
+	if err := os.WriteFile(keysFile, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	apiKeys, err := auth.LoadAPIKeys(keysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := ratelimit.ParseRule("100/s:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sunset := time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+	router := newRouter(&Api{}, &auth.Authenticator{APIKeys: apiKeys},
+		&ratelimit.Middleware{Limiter: ratelimit.NewMemoryLimiter(), Policy: ratelimit.Policy{Default: rule}}, spec, sunset)
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		key       string
+		status    int
+		error     any
+		successor string
+	}{
+		{"v1", "GET", "/v1/wallets/2/webhooks", "", "test-key", 403,
+			map[string]any{"code": "forbidden", "message": "Access to this wallet is not allowed"}, ""},
+		{"v1 unauthenticated", "GET", "/v1/wallets/1/webhooks", "", "", 401,
+			map[string]any{"code": "unauthenticated", "message": "Authentication required"}, ""},
+		{"v1 invalid", "POST", "/v1/wallets/1/deposits", `{"amount": -1}`, "test-key", 400,
+			map[string]any{"code": "invalid_request", "message": "body: amount: number must be more than 0"}, ""},
+		{"legacy", "GET", "/wallets/2/webhooks", "", "test-key", 403,
+			"Access to this wallet is not allowed", "</v1/wallets/2/webhooks>"},
+		{"legacy body wallet", "POST", "/deposit", `{"wallet_id": 2, "amount": 5}`, "test-key", 403,
+			"Access to this wallet is not allowed", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := json.Marshal(body["error"]); string(got) != mustJSON(t, tt.error) {
+				t.Errorf("error %s, want %s", got, mustJSON(t, tt.error))
+			}
+
+			legacy := !strings.HasPrefix(tt.target, "/v1/")
+			if got := w.Header().Get("Deprecation") != ""; got != legacy {
+				t.Errorf("Deprecation header present: %v, want %v", got, legacy)
+			}
+			if legacy && w.Header().Get("Sunset") != "Fri, 30 Apr 2027 00:00:00 GMT" {
+				t.Errorf("Sunset %q", w.Header().Get("Sunset"))
+			}
+			if got := strings.Contains(w.Header().Get("Link"), "successor-version"); got != (tt.successor != "") ||
+				tt.successor != "" && !strings.Contains(w.Header().Get("Link"), tt.successor) {
+				t.Errorf("Link %q, want successor %q", w.Header().Get("Link"), tt.successor)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// TestBodyLimit checks that oversized bodies are refused with 413 whether
+// or not they announce their length, and before the middleware that reads
+// them.
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := newRouter(&Api{}, &auth.Authenticator{}, &ratelimit.Middleware{}, spec, time.Time{})
+
+	oversized := `{"wallet_id": 1, "amount": 5, "note": "` + strings.Repeat("x", maxBodySize) + `"}`
+	for _, chunked := range []bool{false, true} {
+		req := httptest.NewRequest("POST", "/v1/wallets/1/deposits", strings.NewReader(oversized))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 413 || !strings.Contains(w.Body.String(), `"too_large"`) {
+			t.Errorf("chunked %v: status %d: %s", chunked, w.Code, w.Body)
+		}
+	}
+
+	limits := make(map[string]int64)
+	for _, rt := range (&Api{}).v1Routes() {
+		limits[rt.method+" "+rt.path] = rt.maxBody()
+	}
+	if limits["POST /batches"] != maxBatchBodySize || limits["POST /wallets/:id/deposits"] != maxBodySize {
+		t.Errorf("body limits %v", limits)
+	}
+}
+
+// TestPaymentAmountLimits checks that deposits and withdrawals a
+// DECIMAL(10,2) balance cannot hold are refused before they are queued.
+func TestPaymentAmountLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("payer-key"))
+	keysFile := filepath.Join(t.TempDir(), "api_keys.json")
+	keys := `[{"key_sha256": "` + hex.EncodeToString(sum[:]) + `", "principal": "payer", "scopes": ["payments:write"], "wallets": [1]}]`
+	if err := os.WriteFile(keysFile, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	apiKeys, err := auth.LoadAPIKeys(keysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := ratelimit.ParseRule("100/s:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := newRouter(&Api{}, &auth.Authenticator{APIKeys: apiKeys},
+		&ratelimit.Middleware{Limiter: ratelimit.NewMemoryLimiter(), Policy: ratelimit.Policy{Default: rule}}, spec, time.Time{})
+
+	for _, amount := range []string{"100000000", "99999999.999", "0.001", "10.125"} {
+		for path, body := range map[string]string{
+			"/v1/wallets/1/deposits":    `{"amount": ` + amount + `}`,
+			"/v1/wallets/1/withdrawals": `{"amount": ` + amount + `}`,
+			"/deposit":                  `{"wallet_id": 1, "amount": ` + amount + `}`,
+			"/withdraw":                 `{"wallet_id": 1, "amount": ` + amount + `}`,
+		} {
+			req := httptest.NewRequest("POST", path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", "payer-key")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != 400 {
+				t.Errorf("%s with amount %s: status %d: %s", path, amount, w.Code, w.Body)
+			}
+		}
+	}
+}
+
+// TestClientIPNotSpoofable checks that X-Forwarded-For only picks the IP
+// rate limit bucket when the peer is a trusted proxy.
+func TestClientIPNotSpoofable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := ratelimit.ParseRule("1/h:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newLimitedRouter := func() *gin.Engine {
+		return newRouter(&Api{}, &auth.Authenticator{},
+			&ratelimit.Middleware{Limiter: ratelimit.NewMemoryLimiter(), Policy: ratelimit.Policy{Default: rule}}, spec, time.Time{})
+	}
+	get := func(router *gin.Engine, forwardedFor string) int {
+		req := httptest.NewRequest("GET", "/v1/transactions/"+uuid.NewString(), nil)
+		req.RemoteAddr = "10.0.0.1:40000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	router := newLimitedRouter()
+	get(router, "203.0.113.1")
+	if code := get(router, "203.0.113.2"); code != 429 {
+		t.Errorf("spoofed X-Forwarded-For: status %d, want 429", code)
+	}
+
+	router = newLimitedRouter()
+	if err := router.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	get(router, "203.0.113.1")
+	if code := get(router, "203.0.113.2"); code == 429 {
+		t.Error("X-Forwarded-For from a trusted proxy: another client was limited")
+	}
+}
+
+type fakeSQL struct {
+	pb.SQLServiceClient
+	transactions map[string]*pb.Transaction
+}
+
+func (f *fakeSQL) GetTransactionID(_ context.Context, in *pb.TransactionId, _ ...grpc.CallOption) (*pb.Transaction, error) {
+	if transaction, ok := f.transactions[in.TransactionId]; ok {
+		return transaction, nil
+	}
+	return nil, status.Error(codes.NotFound, "transaction "+in.TransactionId+" not found")
+}
+
+// TestTransactionOwnership checks that a transaction on another principal's
+// wallet cannot be told apart from a missing one, and that another wallet's
+// events cannot be streamed.
+func TestTransactionOwnership(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("reader-key"))
+	keysFile := filepath.Join(t.TempDir(), "api_keys.json")
+	keys := `[{"key_sha256": "` + hex.EncodeToString(sum[:]) + `", "principal": "reader", "scopes": ["wallets:read"], "wallets": [1]}]`
+	if err := os.WriteFile(keysFile, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	apiKeys, err := auth.LoadAPIKeys(keysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err := ratelimit.ParseRule("100/s:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	own, other := uuid.NewString(), uuid.NewString()
+	sql := &fakeSQL{transactions: map[string]*pb.Transaction{
+		own:   {TransactionId: own, WalletId: 1, Amount: 5, Type: "deposit", Status: "completed", RequestTime: timestamppb.Now()},
+		other: {TransactionId: other, WalletId: 2, Amount: 5, Type: "deposit", Status: "completed", RequestTime: timestamppb.Now()},
+	}}
+	router := newRouter(&Api{SQL: &sqlclient.Client{SQLServiceClient: sql}}, &auth.Authenticator{APIKeys: apiKeys},
+		&ratelimit.Middleware{Limiter: ratelimit.NewMemoryLimiter(), Policy: ratelimit.Policy{Default: rule}}, spec, time.Time{})
+
+	get := func(path string) (int, string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-API-Key", "reader-key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	if code, body := get("/v1/transactions/" + own); code != 200 {
+		t.Fatalf("own transaction: status %d: %s", code, body)
+	}
+	otherCode, otherBody := get("/v1/transactions/" + other)
+	missingCode, missingBody := get("/v1/transactions/" + uuid.NewString())
+	if otherCode != 404 || otherCode != missingCode || otherBody != missingBody {
+		t.Errorf("other wallet: %d %s, missing: %d %s", otherCode, otherBody, missingCode, missingBody)
+	}
+
+	// The event stream of another wallet is refused before it is opened.
+	if code, body := get("/v1/wallets/2/events"); code != 403 {
+		t.Errorf("other wallet's events: status %d: %s", code, body)
+	}
+}
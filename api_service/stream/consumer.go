@@ -0,0 +1,148 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Exchange is the fanout exchange transaction_service publishes to.
+const Exchange = "wallet_events"
+
+// Channel is the part of an AMQP channel Consume uses.
+type Channel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Dial returns an open function for Consume that gives each channel a
+// connection of its own, so that a lost connection is dialled again rather
+// than reused. Closing the channel closes its connection.
+func Dial(address string) func() (Channel, error) {
+	return func() (Channel, error) {
+		conn, err := amqp.Dial(address)
+		if err != nil {
+			return nil, err
+		}
+		ch, err := conn.Channel()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &connChannel{Channel: ch, conn: conn}, nil
+	}
+}
+
+type connChannel struct {
+	*amqp.Channel
+	conn *amqp.Connection
+}
+
+func (c *connChannel) Close() error {
+	c.Channel.Close()
+	return c.conn.Close()
+}
+
+// Consume binds a private queue to the exchange on a channel from open and
+// publishes every event to hub until ctx is done. Each replica gets its own
+// copy. When the channel closes, the queue is declared and consumed again
+// on a new one every retryInterval until that succeeds; events published
+// in between are not seen. Only the first subscription's error is
+// returned.
+func Consume(ctx context.Context, open func() (Channel, error), hub *Hub, retryInterval time.Duration) error {
+	ch, messages, err := subscribe(open)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+			deliver(ctx, messages, hub)
+			ch.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Wallet events consumer stopped. Resubscribing...", "error", <-closed)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(retryInterval):
+				}
+				if ch, messages, err = subscribe(open); err == nil {
+					break
+				}
+				slog.Warn("Failed to resubscribe to wallet events. Retrying...", "error", err)
+			}
+			slog.Info("Wallet events consumer resubscribed")
+		}
+	}()
+
+	return nil
+}
+
+// subscribe opens a channel and starts consuming a private queue bound to
+// the exchange on it.
+func subscribe(open func() (Channel, error)) (Channel, <-chan amqp.Delivery, error) {
+	ch, err := open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ch.ExchangeDeclare(Exchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+	if err := ch.QueueBind(q.Name, "", Exchange, false, nil); err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+	messages, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+	return ch, messages, nil
+}
+
+// deliver publishes the events from messages to hub until the deliveries
+// end or ctx is done.
+func deliver(ctx context.Context, messages <-chan amqp.Delivery, hub *Hub) {
+	for {
+		var msg amqp.Delivery
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok = <-messages:
+			if !ok {
+				return
+			}
+		}
+
+		var header struct {
+			ID       string `json:"id"`
+			Type     string `json:"type"`
+			WalletID int    `json:"wallet_id"`
+		}
+		if err := json.Unmarshal(msg.Body, &header); err != nil || header.ID == "" {
+			slog.Warn("Dropping malformed wallet event", "error", err)
+			continue
+		}
+		hub.Publish(Event{ID: header.ID, Type: header.Type, WalletID: header.WalletID, Data: msg.Body})
+	}
+}
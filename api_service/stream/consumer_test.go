@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeChannel hands out the deliveries a test sends on it. Dropping it
+// closes the deliveries and notifies like a broker closing the channel.
+type fakeChannel struct {
+	deliveries chan amqp.Delivery
+	mu         sync.Mutex
+	notify     []chan *amqp.Error
+	closed     bool
+}
+
+func newFakeChannel() *fakeChannel {
+	return &fakeChannel{deliveries: make(chan amqp.Delivery)}
+}
+
+func (f *fakeChannel) ExchangeDeclare(string, string, bool, bool, bool, bool, amqp.Table) error {
+	return nil
+}
+
+func (f *fakeChannel) QueueDeclare(string, bool, bool, bool, bool, amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: "amq.gen-test"}, nil
+}
+
+func (f *fakeChannel) QueueBind(string, string, string, bool, amqp.Table) error {
+	return nil
+}
+
+func (f *fakeChannel) Consume(string, string, bool, bool, bool, bool, amqp.Table) (<-chan amqp.Delivery, error) {
+	return f.deliveries, nil
+}
+
+func (f *fakeChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notify = append(f.notify, receiver)
+	return receiver
+}
+
+func (f *fakeChannel) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		for _, receiver := range f.notify {
+			close(receiver)
+		}
+	}
+	return nil
+}
+
+// drop closes the channel the way a lost connection does.
+func (f *fakeChannel) drop() {
+	f.mu.Lock()
+	for _, receiver := range f.notify {
+		receiver <- amqp.ErrClosed
+	}
+	f.mu.Unlock()
+	close(f.deliveries)
+	f.Close()
+}
+
+func TestConsumeResubscribes(t *testing.T) {
+	hub := NewHub(10, time.Minute)
+	sub, _, _ := hub.Subscribe(1, "", 10)
+	defer sub.Close()
+
+	// The first open succeeds, the next one fails as if the broker were
+	// still down, and the third succeeds again.
+	first, second := newFakeChannel(), newFakeChannel()
+	opens := make(chan struct{}, 3)
+	var calls int
+	open := func() (Channel, error) {
+		calls++
+		opens <- struct{}{}
+		switch calls {
+		case 1:
+			return first, nil
+		case 2:
+			return nil, errors.New("connection refused")
+		default:
+			return second, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := Consume(ctx, open, hub, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func(want string) {
+		t.Helper()
+		select {
+		case e := <-sub.Events:
+			if e.ID != want {
+				t.Errorf("event %s, want %s", e.ID, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %s not delivered", want)
+		}
+	}
+
+	first.deliveries <- amqp.Delivery{Body: []byte(`{"id": "e1", "type": "deposit", "wallet_id": 1}`)}
+	receive("e1")
+
+	first.drop()
+	second.deliveries <- amqp.Delivery{Body: []byte(`{"id": "e2", "type": "deposit", "wallet_id": 1}`)}
+	receive("e2")
+	if len(opens) != 3 {
+		t.Errorf("%d opens, want 3", len(opens))
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		second.mu.Lock()
+		closed := second.closed
+		second.mu.Unlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the channel was not closed when ctx was done")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConsumeFirstSubscribeFails(t *testing.T) {
+	open := func() (Channel, error) { return nil, errors.New("connection refused") }
+	if err := Consume(context.Background(), open, NewHub(10, time.Minute), time.Millisecond); err == nil {
+		t.Error("Consume succeeded without a channel")
+	}
+}
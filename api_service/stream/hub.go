@@ -0,0 +1,158 @@
+// Package stream relays wallet events from the wallet_events exchange to
+// subscribers, keeping a short per-wallet history so clients can resume.
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event is one wallet event as published by transaction_service. Data is
+// the original JSON body.
+type Event struct {
+	ID       string
+	Type     string
+	WalletID int
+	Data     []byte
+	received time.Time
+}
+
+// Hub fans events out to the subscribers of their wallet.
+type Hub struct {
+	mu          sync.Mutex
+	historySize int
+	historyAge  time.Duration
+	history     map[int][]Event
+	subscribers map[int]map[*Subscription]struct{}
+	now         func() time.Time
+}
+
+// NewHub keeps up to historySize events per wallet, none older than
+// historyAge.
+func NewHub(historySize int, historyAge time.Duration) *Hub {
+	return &Hub{
+		historySize: historySize,
+		historyAge:  historyAge,
+		history:     make(map[int][]Event),
+		subscribers: make(map[int]map[*Subscription]struct{}),
+		now:         time.Now,
+	}
+}
+
+// Subscription receives the events of one wallet on Events. The channel is
+// closed when the subscriber falls too far behind; it should reconnect with
+// the last event id it saw.
+type Subscription struct {
+	Events   <-chan Event
+	events   chan Event
+	hub      *Hub
+	walletID int
+	once     sync.Once
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Publish records e in the history of its wallet and sends it to every
+// subscriber of the wallet.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e.received = h.now()
+	history := append(h.history[e.WalletID], e)
+	if len(history) > h.historySize {
+		history = history[len(history)-h.historySize:]
+	}
+	h.history[e.WalletID] = h.trim(history)
+
+	for sub := range h.subscribers[e.WalletID] {
+		select {
+		case sub.events <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe starts delivering the events of walletID. When lastEventID is
+// set, backlog holds the retained events after it; if that event is no
+// longer retained, backlog holds the whole history and resumed is false.
+func (h *Hub) Subscribe(walletID int, lastEventID string, buffer int) (sub *Subscription, backlog []Event, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if lastEventID != "" {
+		history := h.trim(h.history[walletID])
+		backlog = history
+		for i, e := range history {
+			if e.ID == lastEventID {
+				backlog, resumed = history[i+1:], true
+				break
+			}
+		}
+		backlog = append([]Event(nil), backlog...)
+	}
+
+	events := make(chan Event, buffer)
+	sub = &Subscription{Events: events, events: events, hub: h, walletID: walletID}
+	if h.subscribers[walletID] == nil {
+		h.subscribers[walletID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[walletID][sub] = struct{}{}
+
+	return sub, backlog, resumed
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(h.subscribers[sub.walletID], sub)
+		if len(h.subscribers[sub.walletID]) == 0 {
+			delete(h.subscribers, sub.walletID)
+		}
+		close(sub.events)
+	})
+}
+
+// trim drops events older than historyAge from the front of history.
+func (h *Hub) trim(history []Event) []Event {
+	cutoff := h.now().Add(-h.historyAge)
+	for len(history) > 0 && history[0].received.Before(cutoff) {
+		history = history[1:]
+	}
+	return history
+}
+
+// Cleanup forgets wallets whose whole history has expired.
+func (h *Hub) Cleanup() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for walletID, history := range h.history {
+		if history = h.trim(history); len(history) == 0 {
+			delete(h.history, walletID)
+		} else {
+			h.history[walletID] = history
+		}
+	}
+}
+
+// RunCleanup calls Cleanup every interval until ctx is done.
+func (h *Hub) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Cleanup()
+		}
+	}
+}
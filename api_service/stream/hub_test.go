@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeClock replaces the hub clock so history expiry can be tested.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestHub(size int, age time.Duration) (*Hub, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	hub := NewHub(size, age)
+	hub.now = clock.now
+	return hub, clock
+}
+
+func ids(events []Event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestHubHistory(t *testing.T) {
+	hub, clock := newTestHub(3, time.Minute)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		hub.Publish(Event{ID: id, WalletID: 1})
+		clock.t = clock.t.Add(10 * time.Second)
+	}
+	hub.Publish(Event{ID: "x", WalletID: 2})
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []string
+		wantResumed bool
+	}{
+		{"no last event", "", nil, false},
+		{"resume", "b", []string{"c", "d"}, true},
+		{"up to date", "d", nil, true},
+		{"dropped by size", "a", []string{"b", "c", "d"}, false},
+		{"other wallet", "x", []string{"b", "c", "d"}, false},
+	}
+	for _, tt := range tests {
+		sub, backlog, resumed := hub.Subscribe(1, tt.lastEventID, 1)
+		sub.Close()
+		if !slices.Equal(ids(backlog), tt.want) || resumed != tt.wantResumed {
+			t.Errorf("%s: backlog %v, resumed %v, want %v, %v", tt.name, ids(backlog), resumed, tt.want, tt.wantResumed)
+		}
+	}
+
+	// b was received 30s ago; 35 seconds later it has expired and a client
+	// resuming after it gets the whole remaining history.
+	clock.t = clock.t.Add(35 * time.Second)
+	if _, backlog, resumed := hub.Subscribe(1, "b", 1); !slices.Equal(ids(backlog), []string{"c", "d"}) || resumed {
+		t.Errorf("after expiry: backlog %v, resumed %v", ids(backlog), resumed)
+	}
+
+	clock.t = clock.t.Add(time.Hour)
+	hub.Cleanup()
+	if len(hub.history) != 0 {
+		t.Errorf("history after Cleanup: %v", hub.history)
+	}
+}
+
+func TestHubDelivery(t *testing.T) {
+	hub, _ := newTestHub(10, time.Minute)
+	wallet1, _, _ := hub.Subscribe(1, "", 1)
+	wallet2, _, _ := hub.Subscribe(2, "", 1)
+	defer wallet2.Close()
+
+	hub.Publish(Event{ID: "a", WalletID: 1})
+	if e := <-wallet1.Events; e.ID != "a" {
+		t.Errorf("wallet 1 got %q", e.ID)
+	}
+	select {
+	case e := <-wallet2.Events:
+		t.Errorf("wallet 2 got the event %q of wallet 1", e.ID)
+	default:
+	}
+
+	// A subscriber that does not keep up is dropped, and its channel closed.
+	hub.Publish(Event{ID: "b", WalletID: 1})
+	hub.Publish(Event{ID: "c", WalletID: 1})
+	if e := <-wallet1.Events; e.ID != "b" {
+		t.Errorf("wallet 1 got %q, want b", e.ID)
+	}
+	if _, ok := <-wallet1.Events; ok {
+		t.Error("slow subscriber was not closed")
+	}
+	if _, ok := hub.subscribers[1]; ok {
+		t.Error("slow subscriber is still registered")
+	}
+	wallet1.Close()
+}
@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Serve streams the events of walletID to w as Server-Sent Events until the
+// client goes away or falls behind. lastEventID is the Last-Event-ID the
+// client reconnected with, if any.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, walletID int, lastEventID string, heartbeat time.Duration) {
+	// The stream outlives the server write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, backlog, resumed := h.Subscribe(walletID, lastEventID, 64)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if lastEventID != "" && !resumed {
+		// Tell the client it may have missed events and should refetch state.
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range backlog {
+		writeEvent(w, e)
+	}
+	flush(w)
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			writeEvent(w, e)
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flush(w)
+	}
+}
+
+func writeEvent(w io.Writer, e Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\n", e.ID, e.Type)
+	for _, line := range bytes.Split(e.Data, []byte("\n")) {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readUntil returns the stream lines up to and including the first one
+// equal to want.
+func readUntil(t *testing.T, lines *bufio.Scanner, want string) []string {
+	t.Helper()
+	var got []string
+	for lines.Scan() {
+		got = append(got, lines.Text())
+		if lines.Text() == want {
+			return got
+		}
+	}
+	t.Fatalf("stream ended before %q; read %q (%v)", want, got, lines.Err())
+	return nil
+}
+
+func openStream(t *testing.T, hub *Hub, walletID int, lastEventID string, heartbeat time.Duration) *bufio.Scanner {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Serve(w, r, walletID, r.Header.Get("Last-Event-ID"), heartbeat)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewScanner(resp.Body)
+}
+
+func TestServeResume(t *testing.T) {
+	hub := NewHub(10, time.Minute)
+	hub.Publish(Event{ID: "a", Type: "transaction.completed", WalletID: 1, Data: []byte(`{"id":"a"}`)})
+	hub.Publish(Event{ID: "b", Type: "transaction.completed", WalletID: 1, Data: []byte("{\n\"id\":\"b\"}")})
+
+	lines := openStream(t, hub, 1, "a", time.Hour)
+	got := readUntil(t, lines, "data: \"id\":\"b\"}")
+	want := []string{"retry: 3000", "", "id: b", "event: transaction.completed", "data: {", `data: "id":"b"}`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stream %q, want %q", got, want)
+	}
+
+	// Events published after the backlog follow it; other wallets' do not.
+	waitSubscribed(t, hub, 1)
+	hub.Publish(Event{ID: "x", Type: "transaction.completed", WalletID: 2, Data: []byte(`{}`)})
+	hub.Publish(Event{ID: "c", Type: "wallet.frozen", WalletID: 1, Data: []byte(`{}`)})
+	if got := readUntil(t, lines, "event: wallet.frozen"); strings.Contains(strings.Join(got, "\n"), "id: x") {
+		t.Errorf("stream carried another wallet's event: %q", got)
+	}
+}
+
+func TestServeReset(t *testing.T) {
+	hub := NewHub(10, time.Minute)
+	hub.Publish(Event{ID: "a", Type: "transaction.completed", WalletID: 1, Data: []byte(`{}`)})
+
+	lines := openStream(t, hub, 1, "expired", time.Hour)
+	got := readUntil(t, lines, "id: a")
+	if !strings.Contains(strings.Join(got, "\n"), "event: reset\ndata: {}") {
+		t.Errorf("no reset before the backlog: %q", got)
+	}
+}
+
+func TestServeHeartbeat(t *testing.T) {
+	hub := NewHub(10, time.Minute)
+	lines := openStream(t, hub, 1, "", 10*time.Millisecond)
+	readUntil(t, lines, ": ping")
+}
+
+// waitSubscribed waits for the stream handler to subscribe to walletID.
+func waitSubscribed(t *testing.T, hub *Hub, walletID int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		hub.mu.Lock()
+		n := len(hub.subscribers[walletID])
+		hub.mu.Unlock()
+		if n > 0 {
+			return
+		}
+	}
+	t.Fatal("stream did not subscribe")
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"api_service/auth"
+	"api_service/respond"
+	"common/logging"
+	pb "sqlapi/pb"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+type Webhook struct {
+	WebhookID           string `json:"webhook_id"`
+	WalletID            int    `json:"wallet_id"`
+	URL                 string `json:"url"`
+	Secret              string `json:"secret,omitempty"`
+	Enabled             bool   `json:"enabled"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	DisabledReason      string `json:"disabled_reason,omitempty"`
+	CreatedAt           string `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	DeliveryID     string `json:"delivery_id"`
+	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type"`
+	Payload        string `json:"payload"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  string `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  string `json:"last_attempt_at,omitempty"`
+	LastStatusCode int    `json:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// walletParam reads the :id wallet of a /wallets/:id route and checks the
+// principal may act on it. It writes the error response itself.
+func walletParam(c *gin.Context) (int, bool) {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respond.Error(c, http.StatusBadRequest, "Invalid wallet id")
+		return 0, false
+	}
+	if !auth.PrincipalFrom(c).OwnsWallet(walletID) {
+		respond.Error(c, http.StatusForbidden, "Access to this wallet is not allowed")
+		return 0, false
+	}
+	return walletID, true
+}
+
+// sqlServiceError answers with the HTTP status matching a sql-service error.
+func sqlServiceError(ctx context.Context, c *gin.Context, msg string, err error) {
+	switch status.Code(err) {
+	case codes.NotFound:
+		respond.Error(c, http.StatusNotFound, status.Convert(err).Message())
+	case codes.InvalidArgument:
+		respond.Error(c, http.StatusBadRequest, status.Convert(err).Message())
+	default:
+		logging.FromContext(ctx).Error(msg, "error", err)
+		respond.Error(c, http.StatusInternalServerError, msg)
+	}
+}
+
+func (a *Api) createWebhookHandler(c *gin.Context) {
+	walletID, ok := walletParam(c)
+	if !ok {
+		return
+	}
+	var request CreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respond.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := logging.With(c.Request.Context(), "wallet_id", walletID)
+	webhook, err := a.SQL.CreateWebhook(ctx, &pb.CreateWebhookRequest{
+		WalletId:  int32(walletID),
+		Url:       request.URL,
+		Principal: auth.PrincipalFrom(c).ID,
+	})
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to create webhook", err)
+		return
+	}
+
+	respond.Data(c, http.StatusCreated, webhookFromProto(webhook))
+}
+
+func (a *Api) listWebhooksHandler(c *gin.Context) {
+	walletID, ok := walletParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := logging.With(c.Request.Context(), "wallet_id", walletID)
+	response, err := a.SQL.ListWebhooks(ctx, &pb.WalletIdRequest{WalletId: int32(walletID)})
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to list webhooks", err)
+		return
+	}
+
+	webhooks := make([]Webhook, 0, len(response.Webhooks))
+	for _, webhook := range response.Webhooks {
+		webhooks = append(webhooks, webhookFromProto(webhook))
+	}
+	respond.Data(c, http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+func (a *Api) deleteWebhookHandler(c *gin.Context) {
+	walletID, ok := walletParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := logging.With(c.Request.Context(), "wallet_id", walletID)
+	_, err := a.SQL.DeleteWebhook(ctx, &pb.WebhookRef{WalletId: int32(walletID), WebhookId: c.Param("webhook_id")})
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to delete webhook", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (a *Api) enableWebhookHandler(c *gin.Context) {
+	walletID, ok := walletParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := logging.With(c.Request.Context(), "wallet_id", walletID)
+	webhook, err := a.SQL.EnableWebhook(ctx, &pb.WebhookRef{WalletId: int32(walletID), WebhookId: c.Param("webhook_id")})
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to enable webhook", err)
+		return
+	}
+
+	respond.Data(c, http.StatusOK, webhookFromProto(webhook))
+}
+
+func (a *Api) listWebhookDeliveriesHandler(c *gin.Context) {
+	walletID, ok := walletParam(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		respond.Error(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	ctx := logging.With(c.Request.Context(), "wallet_id", walletID)
+	response, err := a.SQL.ListWebhookDeliveries(ctx, &pb.WebhookDeliveriesRequest{
+		WalletId:  int32(walletID),
+		WebhookId: c.Param("webhook_id"),
+		Limit:     int32(limit),
+	})
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to list webhook deliveries", err)
+		return
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(response.Deliveries))
+	for _, delivery := range response.Deliveries {
+		deliveries = append(deliveries, deliveryFromProto(delivery))
+	}
+	respond.Data(c, http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func (a *Api) redeliverWebhookHandler(c *gin.Context) {
+	walletID, ok := walletParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := logging.With(c.Request.Context(), "wallet_id", walletID)
+	delivery, err := a.SQL.RedeliverWebhook(ctx, &pb.RedeliverRequest{
+		WalletId:   int32(walletID),
+		WebhookId:  c.Param("webhook_id"),
+		DeliveryId: c.Param("delivery_id"),
+	})
+	if err != nil {
+		sqlServiceError(ctx, c, "Failed to redeliver webhook", err)
+		return
+	}
+
+	respond.Data(c, http.StatusAccepted, deliveryFromProto(delivery))
+}
+
+func webhookFromProto(w *pb.Webhook) Webhook {
+	createdAt, _ := ProtoTimestampToFormattedTime(w.CreatedAt)
+	return Webhook{
+		WebhookID:           w.WebhookId,
+		WalletID:            int(w.WalletId),
+		URL:                 w.Url,
+		Secret:              w.Secret,
+		Enabled:             w.Enabled,
+		ConsecutiveFailures: int(w.ConsecutiveFailures),
+		DisabledReason:      w.DisabledReason,
+		CreatedAt:           createdAt,
+	}
+}
+
+func deliveryFromProto(d *pb.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		DeliveryID:     d.DeliveryId,
+		EventID:        d.EventId,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       int(d.Attempts),
+		LastStatusCode: int(d.LastStatusCode),
+		LastError:      d.LastError,
+	}
+	delivery.CreatedAt, _ = ProtoTimestampToFormattedTime(d.CreatedAt)
+	if d.Status == "pending" || d.Status == "in_progress" {
+		delivery.NextAttemptAt, _ = ProtoTimestampToFormattedTime(d.NextAttemptAt)
+	}
+	if d.LastAttemptAt != nil {
+		delivery.LastAttemptAt, _ = ProtoTimestampToFormattedTime(d.LastAttemptAt)
+	}
+	return delivery
+}
@@ -0,0 +1,19 @@
+#!/bin/sh
+# Generates a development CA and one certificate per service for the gRPC
+# mTLS links. The common name of each certificate is the service identity
+# sql_service authorizes RPCs against (GRPC_READERS, GRPC_WRITERS,
+# GRPC_MANAGERS, GRPC_OPERATORS); walletctl is the operator CLI.
+set -e
+cd "$(dirname "$0")"
+
+openssl req -x509 -newkey rsa:2048 -nodes -days 365 \
+  -subj "/CN=service_pay dev CA" -keyout ca.key -out ca.pem
+
+for service in sql_service api_service transaction_service walletctl; do
+  openssl req -newkey rsa:2048 -nodes -subj "/CN=$service" \
+    -keyout "$service.key" -out "$service.csr"
+  printf "subjectAltName=DNS:%s,DNS:localhost,IP:127.0.0.1\nextendedKeyUsage=serverAuth,clientAuth\n" "$service" > "$service.ext"
+  openssl x509 -req -in "$service.csr" -CA ca.pem -CAkey ca.key -CAcreateserial \
+    -days 90 -extfile "$service.ext" -out "$service.pem"
+  rm "$service.csr" "$service.ext"
+done
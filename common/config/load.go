@@ -0,0 +1,207 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Load fills the struct pointed to by cfg. Every exported field is described
+// by struct tags:
+//
+//	env:"NAME[,LEGACY_NAME...]"  environment variable(s), first one set wins
+//	flag:"name"                  command-line flag overriding the environment
+//	default:"value"              used when neither is set
+//	required:"true"              the value must not be empty
+//	validate:"url|hostport|positive|oneof=a b c"
+//	secret:"true"                never printed by Print
+//	usage:"text"                 flag help text
+//
+// Values come from, in increasing precedence: defaults, the optional .env
+// file, the process environment and args. All problems are reported at once.
+func Load(cfg any, args []string) error {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("loading .env: %w", err)
+	}
+
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	fset := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flagValues := make(map[string]*string)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := field.Tag.Get("flag"); name != "" {
+			flagValues[name] = fset.String(name, field.Tag.Get("default"), field.Tag.Get("usage"))
+		}
+	}
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	setFlags := make(map[string]bool)
+	fset.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	var problems ValidationError
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, raw := lookup(field)
+		if flagName := field.Tag.Get("flag"); setFlags[flagName] {
+			name, raw = "-"+flagName, *flagValues[flagName]
+		}
+
+		if raw == "" {
+			if field.Tag.Get("required") == "true" {
+				problems = append(problems, fmt.Sprintf("%s is required", name))
+			}
+			continue
+		}
+
+		if err := setValue(v.Field(i), raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if err := validate(v.Field(i), field.Tag.Get("validate")); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// ValidationError lists every invalid or missing setting.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Print logs the effective configuration with secrets and URL passwords
+// redacted.
+func Print(cfg any) {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	attrs := make([]any, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := fmt.Sprint(v.Field(i).Interface())
+		switch {
+		case field.Tag.Get("secret") == "true" && value != "":
+			value = "[REDACTED]"
+		case strings.Contains(value, "://"):
+			if u, err := url.Parse(value); err == nil {
+				value = u.Redacted()
+			}
+		}
+		attrs = append(attrs, slog.String(field.Name, value))
+	}
+
+	slog.Info("Effective configuration", slog.Group("config", attrs...))
+}
+
+func lookup(field reflect.StructField) (string, string) {
+	names := strings.Split(field.Tag.Get("env"), ",")
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok && name != "" {
+			return name, value
+		}
+	}
+	return names[0], field.Tag.Get("default")
+}
+
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Type() == reflect.TypeOf(time.Time{}):
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a date (YYYY-MM-DD)", raw)
+		}
+		v.Set(reflect.ValueOf(t))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+func validate(v reflect.Value, rule string) error {
+	switch {
+	case rule == "":
+		return nil
+	case rule == "url":
+		u, err := url.Parse(v.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not a valid URL", v.String())
+		}
+	case rule == "hostport":
+		if _, _, err := net.SplitHostPort(v.String()); err != nil {
+			return fmt.Errorf("%q is not a valid host:port", v.String())
+		}
+	case rule == "positive":
+		if v.Int() <= 0 {
+			return fmt.Errorf("must be positive")
+		}
+	case strings.HasPrefix(rule, "oneof="):
+		allowed := strings.Fields(strings.TrimPrefix(rule, "oneof="))
+		for _, option := range allowed {
+			if v.String() == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q must be one of %s", v.String(), strings.Join(allowed, ", "))
+	}
+	return nil
+}
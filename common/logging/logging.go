@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey is the name under which the request id travels in AMQP
+// headers and gRPC metadata. HTTP clients use the RequestIDHeader.
+const (
+	RequestIDKey    = "x-request-id"
+	RequestIDHeader = "X-Request-ID"
+)
+
+const redacted = "[REDACTED]"
+
+// defaultSensitiveKeys are always redacted, whatever the caller passes to New.
+var defaultSensitiveKeys = []string{"password", "secret", "token", "authorization", "api_key", "dsn"}
+
+type loggerKey struct{}
+type requestIDKey struct{}
+
+// New builds a JSON logger writing to stdout at the given level
+// ("debug", "info", "warn", "error"; anything else means info). Values of
+// attributes named in redactKeys, or in the built-in sensitive list, are
+// replaced by "[REDACTED]".
+func New(level string, redactKeys ...string) *slog.Logger {
+	sensitive := make(map[string]bool)
+	for _, key := range append(defaultSensitiveKeys, redactKeys...) {
+		sensitive[strings.ToLower(key)] = true
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: ParseLevel(level),
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if sensitive[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, redacted)
+			}
+			return a
+		},
+	})
+
+	return slog.New(handler)
+}
+
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// FromContext returns the logger stored in ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a context whose logger carries the given attributes, e.g.
+// logging.With(ctx, "wallet_id", id).
+func With(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey{}, FromContext(ctx).With(args...))
+}
+
+// WithRequestID stores id in ctx and tags the context logger with it.
+// An empty id is replaced by a freshly generated one.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		id = uuid.New().String()
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return With(ctx, "request_id", id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// UnaryClientInterceptor forwards the request id of the call context to the
+// server in the gRPC metadata.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerInterceptor picks the request id up from the incoming gRPC
+// metadata (generating one if the caller sent none) and logs failed calls.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(RequestIDKey); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		ctx = WithRequestID(ctx, requestID)
+		ctx = With(ctx, "grpc_method", info.FullMethod)
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			FromContext(ctx).Error("RPC failed", "error", err)
+		} else {
+			FromContext(ctx).Debug("RPC served")
+		}
+		return resp, err
+	}
+}
+
+// Fatal logs msg at error level with the default logger and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
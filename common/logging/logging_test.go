@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestRequestIDOverGRPC passes the request id from the client interceptor
+// to the server interceptor through the call metadata.
+func TestRequestIDOverGRPC(t *testing.T) {
+	client, server := UnaryClientInterceptor(), UnaryServerInterceptor()
+
+	for _, sent := range []string{"request-1", ""} {
+		var md metadata.MD
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		}
+		ctx := context.Background()
+		if sent != "" {
+			ctx = WithRequestID(ctx, sent)
+		}
+		if err := client(ctx, "/test/Call", nil, nil, nil, invoker); err != nil {
+			t.Fatal(err)
+		}
+
+		var received string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			received = RequestID(ctx)
+			return nil, nil
+		}
+		incoming := metadata.NewIncomingContext(context.Background(), md)
+		if _, err := server(incoming, nil, &grpc.UnaryServerInfo{FullMethod: "/test/Call"}, handler); err != nil {
+			t.Fatal(err)
+		}
+
+		if sent != "" && received != sent || sent == "" && received == "" {
+			t.Errorf("sent %q, server saw %q", sent, received)
+		}
+	}
+}
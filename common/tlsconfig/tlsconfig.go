@@ -0,0 +1,202 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files are the PEM files of the service certificate, its private key and
+// the CA bundle peers are verified against.
+type Files struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+func (f Files) Enabled() bool {
+	return f.CertFile != "" || f.KeyFile != "" || f.CAFile != ""
+}
+
+func (f Files) Validate() error {
+	if f.CertFile == "" || f.KeyFile == "" || f.CAFile == "" {
+		return errors.New("TLS needs a certificate, a key and a CA file")
+	}
+	return nil
+}
+
+// ErrNotConfigured is returned by Plaintext when no file is configured and
+// plaintext is not explicitly allowed.
+var ErrNotConfigured = errors.New("TLS is not configured: set TLS_CERT_FILE, TLS_KEY_FILE and TLS_CA_FILE, or INSECURE_DEV=true to run without it in development")
+
+// Plaintext reports whether gRPC runs without TLS: only when no file is
+// configured and insecureDev allows it. A missing setting must not turn
+// mutual authentication, and with it the caller checks, off by accident.
+func (f Files) Plaintext(insecureDev bool) (bool, error) {
+	if f.Enabled() {
+		return false, f.Validate()
+	}
+	if !insecureDev {
+		return false, ErrNotConfigured
+	}
+	return true, nil
+}
+
+// Reloader holds the current certificate and CA pool and reloads them when
+// any of the files changes, so certificates can be rotated without a restart.
+type Reloader struct {
+	files Files
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+// NewReloader loads the files and polls them every interval until ctx is done.
+func NewReloader(ctx context.Context, files Files, interval time.Duration) (*Reloader, error) {
+	if err := files.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Reloader{files: files}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	go r.watch(ctx, interval)
+	return r, nil
+}
+
+func (r *Reloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime, err := r.latestModTime()
+			if err != nil {
+				slog.Warn("Failed to stat TLS files", "error", err)
+				continue
+			}
+			r.mu.RLock()
+			changed := modTime.After(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				slog.Error("Failed to reload TLS files, keeping the previous ones", "error", err)
+				continue
+			}
+			slog.Info("TLS certificates reloaded")
+		}
+	}
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *Reloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading key pair: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(r.files.CAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("no certificates found in %s", r.files.CAFile)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTime = modTime
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerConfig requires and verifies client certificates. Every handshake
+// uses the most recently loaded certificate and CA pool.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig presents the service certificate and verifies that the server
+// certificate is valid for serverName under the current CA pool.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		// The standard verification would pin the CA pool at dial time;
+		// VerifyConnection checks the chain against the reloadable pool instead.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			_, pool := r.current()
+			opts := x509.VerifyOptions{
+				Roots:         pool,
+				DNSName:       serverName,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}
+}
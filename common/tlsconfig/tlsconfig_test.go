@@ -0,0 +1,180 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issue creates a certificate for name signed by parent, or a self-signed
+// CA when parent is nil, and returns it with its key in PEM.
+func issue(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (certPEM, keyPEM []byte, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), cert, key
+}
+
+// write replaces a file and moves its modification time forward, so the
+// change is seen even when the file system keeps coarse timestamps.
+func write(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// served returns the certificate the server configuration hands out.
+func served(t *testing.T, r *Reloader) []byte {
+	t.Helper()
+	config, err := r.ServerConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.Certificates[0].Certificate[0]
+}
+
+func TestReloaderRotation(t *testing.T) {
+	caPEM, _, ca, caKey := issue(t, "test-ca", nil, nil)
+	oldCert, oldKey, old, _ := issue(t, "sql-service", ca, caKey)
+	newCert, newKey, rotated, _ := issue(t, "sql-service", ca, caKey)
+
+	dir := t.TempDir()
+	files := Files{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key"), CAFile: filepath.Join(dir, "ca.crt")}
+	modTime := time.Now().Add(-time.Hour)
+	write(t, files.CertFile, oldCert, modTime)
+	write(t, files.KeyFile, oldKey, modTime)
+	write(t, files.CAFile, caPEM, modTime)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := NewReloader(ctx, files, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served(t, r), old.Raw) {
+		t.Fatal("the loaded certificate is not served")
+	}
+
+	waitFor := func(want *x509.Certificate) bool {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if bytes.Equal(served(t, r), want.Raw) {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	// A key that does not match the certificate is refused, and the
+	// previous pair stays in use.
+	modTime = modTime.Add(time.Minute)
+	write(t, files.CertFile, newCert, modTime)
+	if waitFor(rotated) {
+		t.Fatal("a certificate without its key was loaded")
+	}
+	if !bytes.Equal(served(t, r), old.Raw) {
+		t.Fatal("the previous certificate was dropped")
+	}
+
+	modTime = modTime.Add(time.Minute)
+	write(t, files.KeyFile, newKey, modTime)
+	if !waitFor(rotated) {
+		t.Fatal("the rotated certificate is not served")
+	}
+}
+
+func TestNewReloaderInvalidFiles(t *testing.T) {
+	caPEM, _, ca, caKey := issue(t, "test-ca", nil, nil)
+	cert, key, _, _ := issue(t, "sql-service", ca, caKey)
+
+	dir := t.TempDir()
+	files := Files{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key"), CAFile: filepath.Join(dir, "ca.crt")}
+	write(t, files.CertFile, cert, time.Now())
+	write(t, files.KeyFile, key, time.Now())
+	write(t, files.CAFile, []byte("not a certificate"), time.Now())
+
+	if _, err := NewReloader(context.Background(), files, time.Hour); err == nil {
+		t.Error("NewReloader accepted a CA file without certificates")
+	}
+	write(t, files.CAFile, caPEM, time.Now())
+	if _, err := NewReloader(context.Background(), Files{CertFile: files.CertFile, KeyFile: files.KeyFile}, time.Hour); err == nil {
+		t.Error("NewReloader accepted files without a CA")
+	}
+}
+
+func TestPlaintext(t *testing.T) {
+	all := Files{CertFile: "tls.crt", KeyFile: "tls.key", CAFile: "ca.crt"}
+	tests := []struct {
+		name          string
+		files         Files
+		insecureDev   bool
+		wantPlaintext bool
+		wantErr       bool
+	}{
+		{"nothing configured", Files{}, false, false, true},
+		{"nothing configured, INSECURE_DEV", Files{}, true, true, false},
+		{"all files", all, false, false, false},
+		{"all files, INSECURE_DEV", all, true, false, false},
+		{"no CA", Files{CertFile: "tls.crt", KeyFile: "tls.key"}, false, false, true},
+		{"no CA, INSECURE_DEV", Files{CertFile: "tls.crt", KeyFile: "tls.key"}, true, false, true},
+	}
+	for _, tt := range tests {
+		plaintext, err := tt.files.Plaintext(tt.insecureDev)
+		if plaintext != tt.wantPlaintext || (err != nil) != tt.wantErr {
+			t.Errorf("%s: Plaintext = %v, %v", tt.name, plaintext, err)
+		}
+	}
+	if _, err := (Files{}).Plaintext(false); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Plaintext without files = %v, want ErrNotConfigured", err)
+	}
+}
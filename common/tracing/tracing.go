@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"common/logging"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Init installs the global tracer provider and the W3C trace context propagator.
+// exporter is one of "otlp", "stdout" or "none"; endpoint is the host:port of the
+// OTLP gRPC collector and is only used by the "otlp" exporter.
+// The returned function flushes and stops the provider.
+func Init(ctx context.Context, serviceName, exporter, endpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		spanExporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpoint(endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// AMQPHeadersCarrier adapts amqp.Table to propagation.TextMapCarrier so trace
+// context can travel in RabbitMQ message headers.
+type AMQPHeadersCarrier amqp.Table
+
+func (c AMQPHeadersCarrier) Get(key string) string {
+	value, ok := c[key].(string)
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+func (c AMQPHeadersCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c AMQPHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// AMQPHeaders returns message headers carrying the trace context and the
+// request id of ctx.
+func AMQPHeaders(ctx context.Context) amqp.Table {
+	headers := amqp.Table{logging.RequestIDKey: logging.RequestID(ctx)}
+	otel.GetTextMapPropagator().Inject(ctx, AMQPHeadersCarrier(headers))
+	return headers
+}
+
+// FromAMQPHeaders continues the trace carried in message headers and tags
+// the context logger with their request id, or a new one if they have none.
+func FromAMQPHeaders(ctx context.Context, headers amqp.Table) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, AMQPHeadersCarrier(headers))
+	requestID, _ := headers[logging.RequestIDKey].(string)
+	return logging.WithRequestID(ctx, requestID)
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"common/logging"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan installs the propagator Init installs and starts a sampled span.
+func startSpan(t *testing.T) (context.Context, trace.Span) {
+	t.Helper()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	provider := sdktrace.NewTracerProvider()
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return provider.Tracer("test").Start(context.Background(), "publish")
+}
+
+func TestAMQPHeadersCarrier(t *testing.T) {
+	ctx, span := startSpan(t)
+	defer span.End()
+
+	headers := amqp.Table{"x-other": int32(1)}
+	otel.GetTextMapPropagator().Inject(ctx, AMQPHeadersCarrier(headers))
+	if _, ok := headers["traceparent"].(string); !ok {
+		t.Fatalf("no traceparent header in %v", headers)
+	}
+	if err := headers.Validate(); err != nil {
+		t.Errorf("headers cannot be sent: %v", err)
+	}
+
+	carrier := AMQPHeadersCarrier(headers)
+	if got := carrier.Get("x-other"); got != "" {
+		t.Errorf("Get of a non-string header = %q", got)
+	}
+	keys := carrier.Keys()
+	slices.Sort(keys)
+	if want := []string{"traceparent", "x-other"}; !slices.Equal(keys, want) {
+		t.Errorf("Keys = %v, want %v", keys, want)
+	}
+
+	extracted := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), carrier))
+	want := span.SpanContext()
+	if extracted.TraceID() != want.TraceID() || extracted.SpanID() != want.SpanID() || !extracted.IsRemote() || !extracted.IsSampled() {
+		t.Errorf("extracted %+v, want the remote span context %+v", extracted, want)
+	}
+}
+
+func TestAMQPHeaders(t *testing.T) {
+	ctx, span := startSpan(t)
+	defer span.End()
+	ctx = logging.WithRequestID(ctx, "request-1")
+
+	consumed := FromAMQPHeaders(context.Background(), AMQPHeaders(ctx))
+	if got := trace.SpanContextFromContext(consumed).TraceID(); got != span.SpanContext().TraceID() {
+		t.Errorf("trace id %s, want %s", got, span.SpanContext().TraceID())
+	}
+	if got := logging.RequestID(consumed); got != "request-1" {
+		t.Errorf("request id %q, want request-1", got)
+	}
+
+	// A message published without either gets a new request id and starts
+	// a new trace.
+	consumed = FromAMQPHeaders(context.Background(), nil)
+	if logging.RequestID(consumed) == "" {
+		t.Error("no request id generated")
+	}
+	if trace.SpanContextFromContext(consumed).IsValid() {
+		t.Error("span context extracted from no headers")
+	}
+}
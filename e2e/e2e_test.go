@@ -0,0 +1,166 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type queuedResponse struct {
+	Data struct {
+		TransactionID string  `json:"transaction_id"`
+		WalletID      int     `json:"wallet_id"`
+		Type          string  `json:"type"`
+		Amount        float64 `json:"amount"`
+		Status        string  `json:"status"`
+	} `json:"data"`
+}
+
+// pay queues a payment and returns the id its transaction will have.
+func pay(t *testing.T, walletID int, kind string, amount float64) string {
+	t.Helper()
+
+	var queued queuedResponse
+	path := fmt.Sprintf("/v1/wallets/%d/%s", walletID, kind)
+	if code := pipeline.request(t, http.MethodPost, path, map[string]any{"amount": amount}, &queued); code != http.StatusAccepted {
+		t.Fatalf("POST %s: status %d, want 202", path, code)
+	}
+	if queued.Data.WalletID != walletID || queued.Data.Amount != amount {
+		t.Fatalf("POST %s queued %+v", path, queued.Data)
+	}
+	return queued.Data.TransactionID
+}
+
+func waitForTransactions(t *testing.T, walletID, n int) []transactionRow {
+	t.Helper()
+
+	var rows []transactionRow
+	eventually(t, fmt.Sprintf("%d transactions of wallet %d", n, walletID), func() bool {
+		rows = pipeline.transactions(t, walletID)
+		return len(rows) >= n
+	})
+	return rows
+}
+
+func TestDepositAndWithdraw(t *testing.T) {
+	walletID := pipeline.newWallet(t, 100)
+
+	depositID := pay(t, walletID, "deposits", 50.25)
+	waitForTransactions(t, walletID, 1)
+	pay(t, walletID, "withdrawals", 30)
+	rows := waitForTransactions(t, walletID, 2)
+
+	if got, want := rowsString(rows), "deposit Success 50.25, withdraw Success 30.00"; got != want {
+		t.Errorf("transactions: %s, want %s", got, want)
+	}
+	if rows[0].ID != depositID {
+		t.Errorf("deposit recorded as %s, api-service returned %s", rows[0].ID, depositID)
+	}
+	if balance := pipeline.balance(t, walletID); balance != 120.25 {
+		t.Errorf("balance %v, want 120.25", balance)
+	}
+
+	var transaction struct {
+		Data struct {
+			TransactionID string  `json:"transaction_id"`
+			WalletID      int     `json:"wallet_id"`
+			Value         float64 `json:"value"`
+			Type          string  `json:"type"`
+			Status        string  `json:"status"`
+			Principal     string  `json:"principal"`
+		} `json:"data"`
+	}
+	if code := pipeline.request(t, http.MethodGet, "/v1/transactions/"+rows[0].ID, nil, &transaction); code != http.StatusOK {
+		t.Fatalf("GET /v1/transactions/%s: status %d", rows[0].ID, code)
+	}
+	got := transaction.Data
+	if got.TransactionID != rows[0].ID || got.WalletID != walletID || got.Value != 50.25 || got.Type != "deposit" || got.Status != "Success" || got.Principal != "dev-admin" {
+		t.Errorf("GET /v1/transactions/%s returned %+v", rows[0].ID, got)
+	}
+}
+
+func TestLegacyDeposit(t *testing.T) {
+	walletID := pipeline.newWallet(t, 0)
+
+	var response map[string]any
+	code := pipeline.request(t, http.MethodPost, "/deposit", map[string]any{"wallet_id": walletID, "amount": 10}, &response)
+	if code != http.StatusOK {
+		t.Fatalf("POST /deposit: status %d, body %v", code, response)
+	}
+
+	waitForTransactions(t, walletID, 1)
+	if balance := pipeline.balance(t, walletID); balance != 10 {
+		t.Errorf("balance %v, want 10", balance)
+	}
+}
+
+func TestInsufficientFunds(t *testing.T) {
+	walletID := pipeline.newWallet(t, 10)
+
+	pay(t, walletID, "withdrawals", 10.01)
+	rows := waitForTransactions(t, walletID, 1)
+
+	if got, want := rowsString(rows), "withdraw error 10.01"; got != want {
+		t.Errorf("transactions: %s, want %s", got, want)
+	}
+	if balance := pipeline.balance(t, walletID); balance != 10 {
+		t.Errorf("balance %v, want 10", balance)
+	}
+}
+
+func TestDepositLimit(t *testing.T) {
+	walletID := pipeline.newWallet(t, 5)
+
+	// Balances are capped at 99999999.99, the largest DECIMAL(10, 2)
+	// value. A deposit that would take the balance past it is rejected
+	// and recorded as an error transaction.
+	pay(t, walletID, "deposits", 99999999)
+	rows := waitForTransactions(t, walletID, 1)
+	if got, want := rowsString(rows), "deposit error 99999999.00"; got != want {
+		t.Errorf("transactions: %s, want %s", got, want)
+	}
+
+	// A deposit that does not fit the amount column itself is refused by
+	// api-service before it is queued, so nothing more is written.
+	for _, amount := range []float64{1e8, 10.125} {
+		code := pipeline.request(t, http.MethodPost, fmt.Sprintf("/v1/wallets/%d/deposits", walletID), map[string]any{"amount": amount}, nil)
+		if code != http.StatusBadRequest {
+			t.Errorf("deposit of %v: status %d, want 400", amount, code)
+		}
+	}
+	pipeline.settle(t, walletID)
+
+	if rows := pipeline.transactions(t, walletID); len(rows) != 1 {
+		t.Errorf("transactions: %s, want one", rowsString(rows))
+	}
+	if balance := pipeline.balance(t, walletID); balance != 5 {
+		t.Errorf("balance %v, want 5", balance)
+	}
+}
+
+func TestInvalidPaymentsAreRefused(t *testing.T) {
+	walletID := pipeline.newWallet(t, 5)
+
+	for _, body := range []map[string]any{{"amount": -5}, {"amount": 0}, {}} {
+		code := pipeline.request(t, http.MethodPost, fmt.Sprintf("/v1/wallets/%d/withdrawals", walletID), body, nil)
+		if code != http.StatusBadRequest {
+			t.Errorf("withdrawal %v: status %d, want 400", body, code)
+		}
+	}
+
+	var problem struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if code := pipeline.request(t, http.MethodGet, "/v1/transactions/f47cbde3-98d8-47cb-a30b-000000000000", nil, &problem); code != http.StatusNotFound || problem.Error.Code != "not_found" {
+		t.Errorf("unknown transaction: status %d, code %q; want 404 not_found", code, problem.Error.Code)
+	}
+
+	pipeline.settle(t, walletID)
+	if rows := pipeline.transactions(t, walletID); len(rows) != 0 {
+		t.Errorf("transactions: %s, want none", rowsString(rows))
+	}
+}
@@ -0,0 +1,373 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// adminKey is the development API key of dev-admin in
+// api_service/api_keys.json; admin may use every wallet.
+const adminKey = "dev-admin-key"
+
+// stack is one running copy of the pipeline.
+type stack struct {
+	apiURL string
+	db     *pgxpool.Pool
+	logDir string
+}
+
+var pipeline *stack
+
+func TestMain(m *testing.M) {
+	postgres, rabbitMQ := os.Getenv("E2E_POSTGRES"), os.Getenv("E2E_RABBITMQ")
+	if postgres == "" || rabbitMQ == "" {
+		fmt.Println("skipping end-to-end tests: E2E_POSTGRES and E2E_RABBITMQ are not set")
+		os.Exit(0)
+	}
+
+	code, err := run(m, postgres, rabbitMQ)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func run(m *testing.M, postgres, rabbitMQ string) (int, error) {
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp("", "e2e")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(workDir)
+
+	dsn, dropDatabase, err := createDatabase(ctx, postgres)
+	if err != nil {
+		return 0, err
+	}
+	defer dropDatabase()
+
+	sqlAddress, err := freeAddress()
+	if err != nil {
+		return 0, err
+	}
+	httpAddress, err := freeAddress()
+	if err != nil {
+		return 0, err
+	}
+	metricsAddress, err := freeAddress()
+	if err != nil {
+		return 0, err
+	}
+	apiKeys, err := filepath.Abs("../api_service/api_keys.json")
+	if err != nil {
+		return 0, err
+	}
+
+	services := []struct {
+		name string
+		env  []string
+	}{
+		{"sql_service", []string{
+			"POSTGRES_STRING=" + dsn,
+			"SQL_SERVICE_ADDRESS=" + sqlAddress,
+			"METRICS_ADDRESS=" + metricsAddress,
+			"RECONCILE_INTERVAL=0",
+			"DB_MIGRATE=true",
+			"INSECURE_DEV=true",
+		}},
+		{"transaction_service", []string{
+			"SQL_SERVICE_ADDRESS=" + sqlAddress,
+			"RABBITMQ_ADDRESS=" + rabbitMQ,
+			"WEBHOOK_DELIVERY=false",
+			"WORKERS=2",
+			"INSECURE_DEV=true",
+		}},
+		{"api_service", []string{
+			"SQL_SERVICE_ADDRESS=" + sqlAddress,
+			"RABBITMQ_ADDRESS=" + rabbitMQ,
+			"HTTP_PORT=" + httpAddress,
+			"API_KEYS_FILE=" + apiKeys,
+			"RATE_LIMIT_DEFAULT=1000/s:1000",
+			"INSECURE_DEV=true",
+		}},
+	}
+	for _, service := range services {
+		binary := filepath.Join(workDir, service.name)
+		build := exec.Command("go", "build", "-o", binary, ".")
+		build.Dir = filepath.Join("..", service.name)
+		if out, err := build.CombinedOutput(); err != nil {
+			return 0, fmt.Errorf("build %s: %v\n%s", service.name, err, out)
+		}
+
+		stop, err := start(binary, workDir, service.env)
+		if err != nil {
+			return 0, fmt.Errorf("start %s: %v", service.name, err)
+		}
+		defer stop()
+	}
+
+	db, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	pipeline = &stack{apiURL: "http://" + httpAddress, db: db, logDir: workDir}
+	if err := pipeline.waitReady(30 * time.Second); err != nil {
+		pipeline.dumpLogs()
+		return 0, err
+	}
+
+	code := m.Run()
+	if code != 0 {
+		pipeline.dumpLogs()
+	}
+	return code, nil
+}
+
+// createDatabase creates a database for this run next to the one in dsn
+// and returns its connection string.
+func createDatabase(ctx context.Context, dsn string) (string, func(), error) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return "", nil, fmt.Errorf("E2E_POSTGRES must be a postgres:// URL")
+	}
+
+	admin, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		return "", nil, err
+	}
+	name := fmt.Sprintf("e2e_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+		admin.Close()
+		return "", nil, err
+	}
+
+	drop := func() {
+		_, err := admin.Exec(context.Background(), "DROP DATABASE "+name+" WITH (FORCE)")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "drop database %s: %v\n", name, err)
+		}
+		admin.Close()
+	}
+	u.Path = "/" + name
+	return u.String(), drop, nil
+}
+
+// start runs binary with only env and PATH set, so that no .env file or
+// variable of the developer leaks in. Its output goes to <binary>.log.
+func start(binary, dir string, env []string) (func(), error) {
+	logFile, err := os.Create(binary + ".log")
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(binary)
+	cmd.Dir = dir
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH"), "LOG_LEVEL=debug", "RETRY_INTERVAL=200ms"}, env...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return nil, err
+	}
+
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		logFile.Close()
+	}, nil
+}
+
+func freeAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
+
+// waitReady waits for api-service to answer and for sql-service to have
+// created the schema.
+func (s *stack) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		response, err := http.Get(s.apiURL + "/openapi.json")
+		if err == nil {
+			response.Body.Close()
+		}
+		var wallets int
+		dbErr := s.db.QueryRow(context.Background(), `SELECT count(*) FROM wallets`).Scan(&wallets)
+		if err == nil && response.StatusCode == http.StatusOK && dbErr == nil {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("services not ready after %v", timeout)
+}
+
+func (s *stack) dumpLogs() {
+	logs, _ := filepath.Glob(filepath.Join(s.logDir, "*.log"))
+	for _, name := range logs {
+		content, _ := os.ReadFile(name)
+		fmt.Fprintf(os.Stderr, "==> %s <==\n%s\n", filepath.Base(name), content)
+	}
+}
+
+// request sends an authenticated JSON request to api-service and decodes
+// the response into out when it is not nil.
+func (s *stack) request(t *testing.T, method, path string, body any, out any) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, s.apiURL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", adminKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return response.StatusCode
+}
+
+// newWallet creates a wallet straight in the database so that every test
+// starts from a balance of its own.
+func (s *stack) newWallet(t *testing.T, balance float64) int {
+	t.Helper()
+
+	var walletID int
+	err := s.db.QueryRow(context.Background(), `INSERT INTO wallets (balance) VALUES ($1) RETURNING wallet_id`, balance).Scan(&walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return walletID
+}
+
+func (s *stack) balance(t *testing.T, walletID int) float64 {
+	t.Helper()
+
+	var balance float64
+	err := s.db.QueryRow(context.Background(), `SELECT balance FROM wallets WHERE wallet_id = $1`, walletID).Scan(&balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+// transactionRow is a row of the transactions table.
+type transactionRow struct {
+	ID     string
+	Type   string
+	Status string
+	Amount float64
+}
+
+func (r transactionRow) String() string {
+	return fmt.Sprintf("%s %s %.2f", r.Type, r.Status, r.Amount)
+}
+
+func (s *stack) transactions(t *testing.T, walletID int) []transactionRow {
+	t.Helper()
+
+	rows, err := s.db.Query(context.Background(), `
+		SELECT transaction_id::text, type, status, value
+		FROM transactions
+		WHERE wallet_id = $1
+		ORDER BY transaction_time, transaction_id
+	`, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var result []transactionRow
+	for rows.Next() {
+		var row transactionRow
+		if err := rows.Scan(&row.ID, &row.Type, &row.Status, &row.Amount); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// eventually polls check until it returns true or the timeout passes.
+// The pipeline is asynchronous: a 202 only means the request was queued.
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// settle waits until no transaction was written for a while, for checks
+// that something did not happen.
+func (s *stack) settle(t *testing.T, walletID int) {
+	t.Helper()
+
+	count := -1
+	for stable := 0; stable < 10; {
+		n := len(s.transactions(t, walletID))
+		if n == count {
+			stable++
+		} else {
+			count, stable = n, 0
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func rowsString(rows []transactionRow) string {
+	parts := make([]string, len(rows))
+	for i, row := range rows {
+		parts[i] = row.String()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,241 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// A backfill fills existing rows after the migration with its number, in
+// batches that each commit on their own, so no batch holds locks on a
+// large table for long. The next migration only runs once it has finished.
+//
+// Its SQL is one statement run once per batch with the key of the last row
+// of the previous batch as $1 (NULL for the first) and the batch size as
+// $2. It returns one row: the last key of this batch, NULL when there are
+// no rows left, and the number of rows it changed. Keys are compared in
+// the order the statement walks the table; progress is kept in
+// db_backfill, so an interrupted backfill resumes after the last batch
+// that committed.
+type backfill struct {
+	version int32
+	name    string
+	sql     string
+}
+
+const backfillTable = "db_backfill"
+
+//go:embed backfills/*.sql
+var backfillFiles embed.FS
+
+var backfillFile = regexp.MustCompile(`^(\d+)_.+\.sql$`)
+
+func loadBackfills() ([]backfill, error) {
+	entries, err := fs.ReadDir(backfillFiles, "backfills")
+	if err != nil {
+		return nil, err
+	}
+	var backfills []backfill
+	for _, entry := range entries {
+		matches := backfillFile.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(backfillFiles, "backfills/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		backfills = append(backfills, backfill{version: int32(version), name: entry.Name(), sql: string(body)})
+	}
+	sort.Slice(backfills, func(i, j int) bool { return backfills[i].name < backfills[j].name })
+	return backfills, nil
+}
+
+// BackfillOptions control how backfills run.
+type BackfillOptions struct {
+	// BatchSize is passed to the backfill as $2.
+	BatchSize int
+	// Pause is slept between batches to leave the database to other work.
+	Pause time.Duration
+	// Progress, if set, is called after every batch.
+	Progress func(BackfillState)
+}
+
+var defaultBackfillOptions = BackfillOptions{BatchSize: 1000}
+
+// BackfillState is the progress of one backfill.
+type BackfillState struct {
+	Version int32
+	Name    string
+	Rows    int64
+	LastKey string
+	Done    bool
+	// Started is false until the first batch has committed.
+	Started   bool
+	UpdatedAt time.Time
+}
+
+// SetBackfillOptions replaces the options of later backfills.
+func (m Migrator) SetBackfillOptions(o BackfillOptions) {
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultBackfillOptions.BatchSize
+	}
+	*m.backfillOptions = o
+}
+
+// ensureBackfillTableExists creates db_backfill under the migration lock,
+// as tern creates its version table.
+func (m Migrator) ensureBackfillTableExists(ctx context.Context) (err error) {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := m.Unlock(ctx); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	_, err = m.conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS `+backfillTable+` (
+			name TEXT PRIMARY KEY,
+			last_key TEXT,
+			rows BIGINT NOT NULL,
+			done BOOLEAN NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		)`)
+	return err
+}
+
+// Backfills returns the state of every embedded backfill.
+func (m Migrator) Backfills(ctx context.Context) ([]BackfillState, error) {
+	states := make([]BackfillState, 0, len(m.backfills))
+	for _, b := range m.backfills {
+		state, err := m.backfillState(ctx, m.conn, b)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+type queryer interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (m Migrator) backfillState(ctx context.Context, q queryer, b backfill) (BackfillState, error) {
+	state := BackfillState{Version: b.version, Name: b.name}
+	var lastKey *string
+	err := q.QueryRow(ctx, `SELECT last_key, rows, done, updated_at FROM `+backfillTable+` WHERE name = $1`, b.name).
+		Scan(&lastKey, &state.Rows, &state.Done, &state.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return BackfillState{}, err
+	}
+	state.Started = true
+	if lastKey != nil {
+		state.LastKey = *lastKey
+	}
+	return state, nil
+}
+
+// runBackfill runs the batches of b that have not committed yet.
+func (m Migrator) runBackfill(ctx context.Context, b backfill) error {
+	opts := *m.backfillOptions
+	state, err := m.backfillState(ctx, m.conn, b)
+	if err != nil {
+		return err
+	}
+	if opts.Progress != nil {
+		opts.Progress(state)
+	}
+
+	for !state.Done {
+		if err := m.backfillBatch(ctx, b, &state, opts.BatchSize); err != nil {
+			return fmt.Errorf("backfill %s after key %q: %w", b.name, state.LastKey, err)
+		}
+		if opts.Progress != nil {
+			opts.Progress(state)
+		}
+		if state.Done || opts.Pause <= 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.Pause):
+		}
+	}
+	return nil
+}
+
+// backfillBatch runs one batch and records its progress in the same
+// transaction.
+func (m Migrator) backfillBatch(ctx context.Context, b backfill, state *BackfillState, batchSize int) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var after any
+	if state.Started && state.LastKey != "" {
+		after = state.LastKey
+	}
+	var lastKey *string
+	var rows int64
+	if err := tx.QueryRow(ctx, b.sql, after, batchSize).Scan(&lastKey, &rows); err != nil {
+		return err
+	}
+
+	next := *state
+	next.Started = true
+	next.Rows += rows
+	if lastKey == nil {
+		next.Done = true
+	} else {
+		next.LastKey = *lastKey
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO `+backfillTable+` (name, last_key, rows, done, updated_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, now())
+		ON CONFLICT (name) DO UPDATE SET last_key = EXCLUDED.last_key, rows = EXCLUDED.rows,
+			done = EXCLUDED.done, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		b.name, next.LastKey, next.Rows, next.Done).Scan(&next.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	*state = next
+	return nil
+}
+
+// resetBackfills forgets the progress of the backfills of version, whose
+// migration has been rolled back, so they run again when it is reapplied.
+func (m Migrator) resetBackfills(ctx context.Context, version int32) error {
+	for _, b := range m.backfills {
+		if b.version != version {
+			continue
+		}
+		if _, err := m.conn.Exec(ctx, `DELETE FROM `+backfillTable+` WHERE name = $1`, b.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+-- Sets created_at of the transactions written before migration 010 from
+-- transaction_time, local wall clock time read in the session time zone.
+-- Walks the table in transaction_id order; $1 is the last id of the
+-- previous batch, $2 the batch size.
+WITH batch AS (
+    SELECT transaction_id FROM transactions
+    WHERE $1::uuid IS NULL OR transaction_id > $1::uuid
+    ORDER BY transaction_id
+    LIMIT $2
+), filled AS (
+    UPDATE transactions t SET created_at = t.transaction_time::timestamp
+    FROM batch
+    WHERE t.transaction_id = batch.transaction_id AND t.created_at IS NULL
+    RETURNING 1
+)
+SELECT (SELECT max(transaction_id::text) FROM batch), (SELECT count(*) FROM filled)
@@ -0,0 +1,15 @@
+-- Registers the ids of the transactions written before migration 012 in
+-- transaction_ids. Walks the table in transaction_id order; $1 is the last
+-- id of the previous batch, $2 the batch size.
+WITH batch AS (
+    SELECT transaction_id, created_at FROM transactions
+    WHERE $1::uuid IS NULL OR transaction_id > $1::uuid
+    ORDER BY transaction_id
+    LIMIT $2
+), registered AS (
+    INSERT INTO transaction_ids (transaction_id, created_at)
+    SELECT transaction_id, created_at FROM batch
+    ON CONFLICT (transaction_id) DO NOTHING
+    RETURNING 1
+)
+SELECT (SELECT max(transaction_id::text) FROM batch), (SELECT count(*) FROM registered)
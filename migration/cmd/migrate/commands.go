@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"migration"
+)
+
+type command struct {
+	name string
+	// args describes the positional arguments in the usage line.
+	args    string
+	summary string
+	// offline commands do not connect to the database.
+	offline bool
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"status", "", "show the database version, the pending migrations and the backfills; exits 4 if any are pending", false, status},
+	{"up", "", "apply every pending migration", false, up},
+	{"down", "N", "roll back the last N migrations", false, down},
+	{"to", "VERSION", "migrate up or down to VERSION; 0 rolls back everything", false, to},
+	{"redo", "", "roll back the last migration and apply it again", false, redo},
+	{"create", "NAME", "add an empty numbered migration to -dir", true, create},
+	{"lint", "", "check every migration for statements that lock or rewrite large tables; exits 5 if any", true, lint},
+}
+
+func lookup(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// usageError is a mistake in the command line.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+var (
+	// errPending makes status exit with exitPending.
+	errPending = errors.New("migrations are pending")
+	// errUnsafe refuses steps lint found unsafe.
+	errUnsafe = errors.New("unsafe migration")
+	// errFindings makes lint exit with exitUnsafe.
+	errFindings = errors.New("lint found unsafe statements")
+)
+
+func status(c *cli, args []string) error {
+	if len(args) != 0 {
+		return usagef("unexpected arguments %q", args)
+	}
+	s, err := c.m.Status(c.ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "database version %d, latest migration %d\n\n", s.Current, s.Latest())
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tMIGRATION\tSTATE")
+	for _, v := range s.Migrations {
+		state := "applied"
+		if v.Sequence > s.Current {
+			state = "pending"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", v.Sequence, v.Name, state)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	unfinished := 0
+	if len(s.Backfills) > 0 {
+		fmt.Fprintln(c.out)
+		fmt.Fprintln(w, "AFTER\tBACKFILL\tSTATE\tROWS\tLAST KEY\tUPDATED")
+		for _, b := range s.Backfills {
+			state, updated := "pending", ""
+			switch {
+			case b.Done:
+				state = "done"
+			case b.Started:
+				state = "in progress"
+			}
+			if b.Started {
+				updated = b.UpdatedAt.Format(time.RFC3339)
+			}
+			if !b.Done && b.Version <= s.Current {
+				unfinished++
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", b.Version, b.Name, state, b.Rows, b.LastKey, updated)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	pending := len(s.Pending())
+	if pending > 0 || unfinished > 0 {
+		fmt.Fprintf(c.out, "\n%d pending, %d unfinished backfills\n", pending, unfinished)
+		return errPending
+	}
+	return nil
+}
+
+func up(c *cli, args []string) error {
+	if len(args) != 0 {
+		return usagef("unexpected arguments %q", args)
+	}
+	err := c.migrate(func(current, latest int32) ([]int32, error) {
+		return []int32{latest}, nil
+	})
+	if err != nil || !c.opts.seed {
+		return err
+	}
+	if c.opts.dryRun {
+		fmt.Fprintln(c.out, "-- then seed the development data (fixtures/dev.sql)")
+		return nil
+	}
+	if err := c.m.SeedDevData(c.ctx); err != nil {
+		return fmt.Errorf("seed dev data: %w", err)
+	}
+	fmt.Fprintln(c.out, "dev data seeded")
+	return nil
+}
+
+func down(c *cli, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected the number of migrations to roll back")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return usagef("N must be a positive number, got %q", args[0])
+	}
+	return c.migrate(func(current, latest int32) ([]int32, error) {
+		if int32(n) > current {
+			return nil, fmt.Errorf("cannot roll back %d migrations: the database is at version %d", n, current)
+		}
+		return []int32{current - int32(n)}, nil
+	})
+}
+
+func to(c *cli, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected a version")
+	}
+	version, err := strconv.ParseInt(args[0], 10, 32)
+	if err != nil || version < 0 {
+		return usagef("VERSION must be a number of 0 or more, got %q", args[0])
+	}
+	return c.migrate(func(current, latest int32) ([]int32, error) {
+		return []int32{int32(version)}, nil
+	})
+}
+
+func redo(c *cli, args []string) error {
+	if len(args) != 0 {
+		return usagef("unexpected arguments %q", args)
+	}
+	return c.migrate(func(current, latest int32) ([]int32, error) {
+		if current == 0 {
+			return nil, errors.New("no migration has been applied")
+		}
+		return []int32{current - 1, current}, nil
+	})
+}
+
+// migrate takes the migration lock, works out the versions to go through
+// from the current one and runs the steps to each in turn, or with
+// -dry-run prints them. The steps are linted first. Steps run one at a
+// time so that a failure leaves the database at the last version that
+// succeeded, and is reported as such.
+func (c *cli) migrate(targets func(current, latest int32) ([]int32, error)) error {
+	if !c.opts.dryRun {
+		if err := c.m.Lock(c.ctx, c.opts.lockTimeout); err != nil {
+			return err
+		}
+		defer c.m.Unlock(c.ctx)
+	}
+
+	s, err := c.m.Status(c.ctx)
+	if err != nil {
+		return err
+	}
+	versions, err := targets(s.Current, s.Latest())
+	if err != nil {
+		return err
+	}
+
+	from := s.Current
+	var steps []migration.Step
+	for _, version := range versions {
+		planned, err := c.m.Plan(from, version)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, planned...)
+		from = version
+	}
+	steps = skipFinishedBackfills(steps, s.Backfills)
+	if len(steps) == 0 {
+		fmt.Fprintf(c.out, "database is at version %d, nothing to do\n", s.Current)
+		return nil
+	}
+
+	unsafe := false
+	for _, step := range steps {
+		for _, f := range migration.Lint(step) {
+			unsafe = true
+			if c.opts.dryRun {
+				fmt.Fprintf(c.out, "-- lint: %s\n", strings.ReplaceAll(f.String(), "\n", "\n-- "))
+			} else {
+				fmt.Fprintln(c.out, f)
+			}
+		}
+	}
+	if unsafe && !c.opts.dryRun && !c.opts.unsafe {
+		return errUnsafe
+	}
+
+	c.m.SetBackfillOptions(migration.BackfillOptions{
+		BatchSize: c.opts.batchSize,
+		Pause:     c.opts.batchPause,
+		Progress:  c.backfillProgress(),
+	})
+	for _, step := range steps {
+		if c.opts.dryRun {
+			note := ""
+			switch {
+			case step.Direction == "backfill":
+				note = fmt.Sprintf(", in batches of %d", c.opts.batchSize)
+			case !step.Transactional:
+				note = ", without a transaction"
+			}
+			fmt.Fprintf(c.out, "-- %s (%s%s)\n%s\n\n", step.Name, step.Direction, note, step.SQL)
+			continue
+		}
+
+		start := time.Now()
+		fmt.Fprintf(c.out, "%-8s %s ...\n", step.Direction, step.Name)
+		if err := c.m.Apply(c.ctx, step); err != nil {
+			fmt.Fprintf(c.out, "%-8s %s failed\n", step.Direction, step.Name)
+			return fmt.Errorf("%s %s: %w", step.Direction, step.Name, err)
+		}
+		fmt.Fprintf(c.out, "%-8s %s ok (%s)\n", step.Direction, step.Name, time.Since(start).Round(time.Millisecond))
+	}
+	if !c.opts.dryRun {
+		fmt.Fprintf(c.out, "database is at version %d\n", from)
+	}
+	return nil
+}
+
+// skipFinishedBackfills drops the backfills the plan starts with that are
+// already done. Later ones follow a migration of the plan and run anyway;
+// a finished one returns at once.
+func skipFinishedBackfills(steps []migration.Step, states []migration.BackfillState) []migration.Step {
+	done := map[string]bool{}
+	for _, b := range states {
+		done[b.Name] = b.Done
+	}
+	for len(steps) > 0 && steps[0].Direction == "backfill" && done[steps[0].Name] {
+		steps = steps[1:]
+	}
+	return steps
+}
+
+// backfillProgress prints the progress of a backfill at most every few
+// seconds, and when it finishes.
+func (c *cli) backfillProgress() func(migration.BackfillState) {
+	var last time.Time
+	return func(b migration.BackfillState) {
+		if !b.Done && time.Since(last) < 5*time.Second {
+			return
+		}
+		last = time.Now()
+		state := "in progress"
+		switch {
+		case b.Done:
+			state = "done"
+		case !b.Started:
+			state = "starting"
+		}
+		fmt.Fprintf(c.out, "         %s: %d rows, %s, last key %q\n", b.Name, b.Rows, state, b.LastKey)
+	}
+}
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_.+\.sql$`)
+	nameSeparator = regexp.MustCompile(`[\s-]+`)
+	migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+const scaffold = `-- %s
+
+---- create above / drop below ----
+
+`
+
+// create writes the next numbered migration with an empty up and down
+// section. Until the up section has SQL the migrations do not load, so an
+// unfinished file cannot be shipped by accident.
+func create(c *cli, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected a name")
+	}
+	name := nameSeparator.ReplaceAllString(strings.ToLower(strings.TrimSpace(args[0])), "_")
+	if !migrationName.MatchString(name) {
+		return usagef("NAME may only have letters, digits, spaces, - and _, got %q", args[0])
+	}
+
+	entries, err := os.ReadDir(c.opts.dir)
+	if err != nil {
+		return err
+	}
+	next := 1
+	for _, entry := range entries {
+		matches := migrationFile.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(matches[1]); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+
+	path := filepath.Join(c.opts.dir, fmt.Sprintf("%03d_%s.sql", next, name))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, scaffold, name); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, path)
+	return nil
+}
+
+func lint(c *cli, args []string) error {
+	if len(args) != 0 {
+		return usagef("unexpected arguments %q", args)
+	}
+	findings, err := migration.LintEmbedded()
+	if err != nil {
+		return err
+	}
+	for _, f := range findings {
+		fmt.Fprintln(c.out, f)
+	}
+	if len(findings) > 0 {
+		return errFindings
+	}
+	fmt.Fprintln(c.out, "no unsafe statements")
+	return nil
+}
@@ -0,0 +1,195 @@
+// Command migrate manages the schema of the database named by
+// POSTGRES_STRING with the migrations embedded from migration/data.
+//
+//	migrate [flags] status          current version and pending migrations
+//	migrate [flags] up              apply every pending migration (the default)
+//	migrate [flags] down N          roll back the last N migrations
+//	migrate [flags] to VERSION      migrate up or down to VERSION
+//	migrate [flags] redo            roll back the last migration and apply it again
+//	migrate [-dir DIR] create NAME  add an empty numbered migration
+//	migrate lint                    check every migration for unsafe statements
+//
+// With -dry-run the SQL of each step is printed instead of run. Otherwise
+// migrate holds the migration lock for the whole command, so a second
+// migrate or a sql_service replica migrating on startup waits for it;
+// migrate itself gives up after -lock-timeout. -seed-dev-data adds the
+// development wallets after up; never use it against production.
+//
+// Going up, the backfills of a migration run after it in batches of
+// -batch-size, -batch-pause apart; an interrupted backfill resumes on the
+// next up. The steps are linted first and migrate refuses to run one that
+// can lock or rewrite a large table unless given -unsafe.
+//
+// Exit codes: 0 success, 1 failure, 2 bad command line, 3 another
+// migration holds the lock, 4 status found pending migrations or
+// backfills, 5 lint found unsafe statements.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"migration"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitLocked  = 3
+	exitPending = 4
+	exitUnsafe  = 5
+)
+
+func main() {
+	// .env is for running from a checkout; containers pass the environment.
+	_ = godotenv.Load(".env")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr, connect)
+	stop()
+	os.Exit(code)
+}
+
+// migrator is what the commands need of migration.Migrator.
+type migrator interface {
+	Status(ctx context.Context) (migration.Status, error)
+	Plan(from, to int32) ([]migration.Step, error)
+	Apply(ctx context.Context, step migration.Step) error
+	SetBackfillOptions(o migration.BackfillOptions)
+	SeedDevData(ctx context.Context) error
+	Lock(ctx context.Context, wait time.Duration) error
+	Unlock(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+func connect(ctx context.Context) (migrator, error) {
+	connString := os.Getenv("POSTGRES_STRING")
+	if connString == "" {
+		return nil, errors.New("POSTGRES_STRING is not set")
+	}
+	m, err := migration.NewMigrator(ctx, connString)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// options are the flags; they are accepted before and after the command.
+type options struct {
+	dryRun      bool
+	lockTimeout time.Duration
+	seed        bool
+	dir         string
+	unsafe      bool
+	batchSize   int
+	batchPause  time.Duration
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.dryRun, "dry-run", o.dryRun, "print the SQL of each step instead of running it")
+	fs.DurationVar(&o.lockTimeout, "lock-timeout", o.lockTimeout, "how long to wait for another migration to finish")
+	fs.BoolVar(&o.seed, "seed-dev-data", o.seed, "add the development wallets after up")
+	fs.StringVar(&o.dir, "dir", o.dir, "directory create adds the migration to")
+	fs.BoolVar(&o.unsafe, "unsafe", o.unsafe, "run steps lint finds unsafe")
+	fs.IntVar(&o.batchSize, "batch-size", o.batchSize, "rows a backfill changes per transaction")
+	fs.DurationVar(&o.batchPause, "batch-pause", o.batchPause, "pause between backfill batches")
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, connect func(context.Context) (migrator, error)) int {
+	opts := options{lockTimeout: time.Minute, dir: "data", batchSize: 1000}
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts.register(fs)
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"up"}
+	}
+	cmd, ok := lookup(args[0])
+	if !ok {
+		usage(fs)
+		return exitUsage
+	}
+
+	cmdFlags := flag.NewFlagSet("migrate "+cmd.name, flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	opts.register(cmdFlags)
+	if err := cmdFlags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	c := &cli{ctx: ctx, out: stdout, opts: opts}
+	if !cmd.offline {
+		m, err := connect(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, "migrate:", err)
+			return exitFailed
+		}
+		defer m.Close(context.Background())
+		c.m = m
+	}
+
+	err := cmd.run(c, cmdFlags.Args())
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "migrate %s: %v\nusage: migrate %s %s\n", cmd.name, usageErr.err, cmd.name, cmd.args)
+		return exitUsage
+	case errors.Is(err, errPending):
+		return exitPending
+	case errors.Is(err, errFindings):
+		return exitUnsafe
+	case errors.Is(err, errUnsafe):
+		fmt.Fprintln(stderr, "migrate: refusing to run unsafe statements; fix them, mark them -- lint:ignore RULE, or pass -unsafe")
+		return exitUnsafe
+	case errors.Is(err, migration.ErrLocked):
+		fmt.Fprintf(stderr, "migrate: %v: gave up after %s\n", err, opts.lockTimeout)
+		return exitLocked
+	}
+	fmt.Fprintln(stderr, "migrate:", err)
+	return exitFailed
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: migrate [flags] [COMMAND [args]]")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+}
+
+// cli is what the commands share.
+type cli struct {
+	ctx  context.Context
+	m    migrator
+	out  io.Writer
+	opts options
+}
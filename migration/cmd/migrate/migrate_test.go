@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"migration"
+)
+
+// fakeMigrator has five migrations and a backfill after the second, and
+// records what is done to it.
+type fakeMigrator struct {
+	current      int32
+	locked       bool
+	lockHeld     bool
+	backfillDone bool
+	// unsafe is the up SQL of migration 4 instead of a CREATE TABLE.
+	unsafe  string
+	targets []int32
+	filled  int
+}
+
+func (f *fakeMigrator) Status(ctx context.Context) (migration.Status, error) {
+	s := migration.Status{Current: f.current}
+	for i := int32(1); i <= 5; i++ {
+		s.Migrations = append(s.Migrations, migration.Version{Sequence: i, Name: fmt.Sprintf("%03d_step.sql", i)})
+	}
+	s.Backfills = []migration.BackfillState{{Version: 2, Name: "002_fill.sql", Done: f.backfillDone, Started: f.backfillDone}}
+	return s, nil
+}
+
+func (f *fakeMigrator) Plan(from, to int32) ([]migration.Step, error) {
+	if to < 0 || to > 5 {
+		return nil, fmt.Errorf("destination version %d is outside the valid versions of 0 to 5", to)
+	}
+	var steps []migration.Step
+	backfill := migration.Step{Sequence: 2, Name: "002_fill.sql", Direction: "backfill", SQL: "SELECT $1, $2"}
+	if from == 2 && to >= from {
+		steps = append(steps, backfill)
+	}
+	for v := from; v < to; v++ {
+		sql := fmt.Sprintf("CREATE TABLE t%d ()", v+1)
+		if v+1 == 4 && f.unsafe != "" {
+			sql = f.unsafe
+		}
+		steps = append(steps, migration.Step{Sequence: v + 1, Name: fmt.Sprintf("%03d_step.sql", v+1), Direction: "up", SQL: sql, Transactional: true})
+		if v+1 == 2 {
+			steps = append(steps, backfill)
+		}
+	}
+	for v := from; v > to; v-- {
+		steps = append(steps, migration.Step{Sequence: v, Name: fmt.Sprintf("%03d_step.sql", v), Direction: "down", SQL: fmt.Sprintf("DROP TABLE t%d", v), Transactional: true})
+	}
+	return steps, nil
+}
+
+func (f *fakeMigrator) Apply(ctx context.Context, step migration.Step) error {
+	if !f.lockHeld {
+		return fmt.Errorf("applied %s %s without the lock", step.Direction, step.Name)
+	}
+	switch step.Direction {
+	case "up":
+		f.current = step.Sequence
+	case "down":
+		f.current = step.Sequence - 1
+		if step.Sequence == 2 {
+			f.backfillDone = false
+		}
+	case "backfill":
+		if !f.backfillDone {
+			f.filled++
+		}
+		f.backfillDone = true
+		return nil
+	}
+	f.targets = append(f.targets, f.current)
+	return nil
+}
+
+func (f *fakeMigrator) SetBackfillOptions(o migration.BackfillOptions) {}
+
+func (f *fakeMigrator) SeedDevData(ctx context.Context) error { return nil }
+
+func (f *fakeMigrator) Lock(ctx context.Context, wait time.Duration) error {
+	if f.locked {
+		return migration.ErrLocked
+	}
+	f.lockHeld = true
+	return nil
+}
+
+func (f *fakeMigrator) Unlock(ctx context.Context) error {
+	f.lockHeld = false
+	return nil
+}
+
+func (f *fakeMigrator) Close(ctx context.Context) error { return nil }
+
+func runWith(f *fakeMigrator, args ...string) (int, string, string) {
+	var out, errOut bytes.Buffer
+	code := run(context.Background(), args, &out, &errOut, func(context.Context) (migrator, error) { return f, nil })
+	return code, out.String(), errOut.String()
+}
+
+func TestMigrateSteps(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		current int32
+		targets []int32
+		code    int
+	}{
+		{nil, 2, []int32{3, 4, 5}, exitOK},
+		{[]string{"to", "1"}, 0, []int32{1}, exitOK},
+		{[]string{"up"}, 5, nil, exitOK},
+		{[]string{"down", "2"}, 5, []int32{4, 3}, exitOK},
+		{[]string{"down", "3"}, 2, nil, exitFailed},
+		{[]string{"down", "0"}, 2, nil, exitUsage},
+		{[]string{"to", "1"}, 3, []int32{2, 1}, exitOK},
+		{[]string{"to", "6"}, 3, nil, exitFailed},
+		{[]string{"redo"}, 3, []int32{2, 3}, exitOK},
+		{[]string{"redo"}, 0, nil, exitFailed},
+	} {
+		f := &fakeMigrator{current: tc.current, backfillDone: tc.current >= 2}
+		code, _, errOut := runWith(f, tc.args...)
+		if code != tc.code {
+			t.Errorf("%v from %d: exit %d, want %d (%s)", tc.args, tc.current, code, tc.code, errOut)
+		}
+		if !reflect.DeepEqual(f.targets, tc.targets) {
+			t.Errorf("%v from %d: migrated to %v, want %v", tc.args, tc.current, f.targets, tc.targets)
+		}
+		if f.lockHeld {
+			t.Errorf("%v: the lock was not released", tc.args)
+		}
+	}
+}
+
+func TestBackfills(t *testing.T) {
+	// Interrupted after migration 2: up finishes the backfill first.
+	f := &fakeMigrator{current: 2}
+	if code, _, errOut := runWith(f, "up"); code != exitOK || f.filled != 1 || !reflect.DeepEqual(f.targets, []int32{3, 4, 5}) {
+		t.Errorf("resume: exit %d, filled %d times, migrated to %v (%s)", code, f.filled, f.targets, errOut)
+	}
+
+	// Going up past 2 runs it once, and a finished one is not run again.
+	f = &fakeMigrator{current: 1}
+	runWith(f, "to", "3")
+	runWith(f, "up")
+	if f.filled != 1 {
+		t.Errorf("filled %d times", f.filled)
+	}
+
+	// Rolling 2 back forgets it, so redoing 2 runs it again.
+	f = &fakeMigrator{current: 2, backfillDone: true}
+	if code, _, _ := runWith(f, "redo"); code != exitOK || f.filled != 1 {
+		t.Errorf("redo: exit %d, filled %d times", code, f.filled)
+	}
+
+	code, out, _ := runWith(&fakeMigrator{current: 3}, "status")
+	if code != exitPending || !strings.Contains(out, "002_fill.sql") || !strings.Contains(out, "1 unfinished backfills") {
+		t.Errorf("status with an unfinished backfill: exit %d\n%s", code, out)
+	}
+}
+
+func TestUnsafeStepsAreRefused(t *testing.T) {
+	f := &fakeMigrator{current: 3, backfillDone: true, unsafe: "ALTER TABLE t1 ALTER COLUMN a SET NOT NULL"}
+	code, out, _ := runWith(f, "up")
+	if code != exitUnsafe || f.targets != nil || !strings.Contains(out, "004_step.sql (up): set-not-null") {
+		t.Errorf("exit %d, migrated to %v\n%s", code, f.targets, out)
+	}
+
+	code, _, _ = runWith(f, "-unsafe", "up")
+	if code != exitOK || !reflect.DeepEqual(f.targets, []int32{4, 5}) {
+		t.Errorf("-unsafe: exit %d, migrated to %v", code, f.targets)
+	}
+}
+
+func TestDryRunPrintsSQL(t *testing.T) {
+	f := &fakeMigrator{current: 5}
+	code, out, _ := runWith(f, "down", "-dry-run", "2")
+	if code != exitOK {
+		t.Fatalf("exit %d", code)
+	}
+	if f.targets != nil {
+		t.Errorf("dry run migrated to %v", f.targets)
+	}
+	if !strings.Contains(out, "-- 005_step.sql (down)\nDROP TABLE t5") || !strings.Contains(out, "DROP TABLE t4") || strings.Contains(out, "t3") {
+		t.Errorf("printed\n%s", out)
+	}
+}
+
+func TestLocked(t *testing.T) {
+	f := &fakeMigrator{current: 2, locked: true}
+	code, _, errOut := runWith(f, "up")
+	if code != exitLocked || !strings.Contains(errOut, "another migration is running") {
+		t.Errorf("exit %d, %q", code, errOut)
+	}
+	if f.targets != nil {
+		t.Errorf("migrated to %v without the lock", f.targets)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	code, out, _ := runWith(&fakeMigrator{current: 3, backfillDone: true}, "status")
+	if code != exitPending {
+		t.Errorf("exit %d with pending migrations", code)
+	}
+	if !strings.Contains(out, "3        003_step.sql  applied") || !strings.Contains(out, "4        004_step.sql  pending") || !strings.Contains(out, "2 pending") {
+		t.Errorf("printed\n%s", out)
+	}
+
+	if code, _, _ := runWith(&fakeMigrator{current: 5, backfillDone: true}, "status"); code != exitOK {
+		t.Errorf("exit %d when up to date", code)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"001_a.sql", "007_b.sql", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var errBuf bytes.Buffer
+	if code := run(context.Background(), []string{"-dir", dir, "create", "Add wallet-limits"}, &bytes.Buffer{}, &errBuf, nil); code != exitOK {
+		t.Fatalf("exit %d, %q", code, errBuf.String())
+	}
+	body, err := os.ReadFile(filepath.Join(dir, "008_add_wallet_limits.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "---- create above / drop below ----") {
+		t.Errorf("scaffold has no separator:\n%s", body)
+	}
+
+	errBuf.Reset()
+	if code := run(context.Background(), []string{"-dir", dir, "create", "drop;table"}, &bytes.Buffer{}, &errBuf, nil); code != exitUsage {
+		t.Errorf("bad name: exit %d, %q", code, errBuf.String())
+	}
+}
@@ -1,9 +1,8 @@
--- Write your migrate up statements here
 CREATE TABLE IF NOT EXISTS wallets (
-  balance DECIMAL(10, 2) NOT NULL
   wallet_id SERIAL PRIMARY KEY,
+  balance DECIMAL(10, 2) NOT NULL
 );
 
 ---- create above / drop below ----
 
-DROP TABLE wallets
\ No newline at end of file
+DROP TABLE IF EXISTS wallets
@@ -5,9 +5,9 @@ CREATE TABLE IF NOT EXISTS transactions (
   type VARCHAR(255) NOT NULL,
   status VARCHAR(255) NOT NULL,
   transaction_time VARCHAR(255) NOT NULL,
-  FOREIGN KEY (wallet_id) REFERENCES wallets_test (wallet_id)
+  FOREIGN KEY (wallet_id) REFERENCES wallets (wallet_id)
 );
 
 ---- create above / drop below ----
 
-DROP TABLE transactions
\ No newline at end of file
+DROP TABLE IF EXISTS transactions
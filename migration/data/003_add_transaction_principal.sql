@@ -0,0 +1,5 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS principal VARCHAR(255);
+
+---- create above / drop below ----
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS principal
@@ -1,5 +0,0 @@
-INSERT INTO wallets (balance) VALUES (100.00), (200.00), (75.00);
-
----- create above / drop below ----
-
-DROP TABLE wallets
\ No newline at end of file
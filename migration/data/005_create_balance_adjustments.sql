@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+    adjustment_id UUID PRIMARY KEY,
+    wallet_id INT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    through_audit_id BIGINT NOT NULL,
+    transaction_id UUID,
+    created_at TIMESTAMPTZ NOT NULL,
+    decided_at TIMESTAMPTZ,
+    decided_by VARCHAR(255)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS balance_adjustments_pending_idx
+    ON balance_adjustments (wallet_id) WHERE status = 'pending';
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS balance_adjustments
@@ -0,0 +1,7 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS settled_at TIMESTAMPTZ;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS settlement_ref TEXT;
+
+---- create above / drop below ----
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS settlement_ref;
+ALTER TABLE transactions DROP COLUMN IF EXISTS settled_at
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS batches (
+    batch_id UUID PRIMARY KEY,
+    principal VARCHAR(255),
+    mode VARCHAR(16) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    total INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS batch_items (
+    batch_id UUID NOT NULL REFERENCES batches (batch_id) ON DELETE CASCADE,
+    item_index INT NOT NULL,
+    wallet_id INT NOT NULL REFERENCES wallets (wallet_id),
+    type VARCHAR(16) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    reference VARCHAR(255),
+    status VARCHAR(16) NOT NULL,
+    transaction_id UUID,
+    error TEXT,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (batch_id, item_index)
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS batch_items;
+DROP TABLE IF EXISTS batches
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS frozen_at TIMESTAMPTZ;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS frozen_reason TEXT;
+
+---- create above / drop below ----
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_reason;
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_at
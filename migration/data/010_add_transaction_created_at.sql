@@ -0,0 +1,10 @@
+-- created_at is the time of a transaction as a timestamp. New rows get it
+-- from the default; backfills/010_transaction_created_at.sql fills the
+-- older ones from transaction_time before 011 requires it. Neither
+-- statement rewrites the table.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE transactions ALTER COLUMN created_at SET DEFAULT now();
+
+---- create above / drop below ----
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS created_at
@@ -0,0 +1,12 @@
+---- tern: disable-tx ----
+-- A NOT VALID check takes the table lock only for a moment; validating it
+-- in a transaction of its own then scans the table without blocking
+-- writes. Without a transaction a failure is not rolled back, so the
+-- constraint is dropped first to make the migration safe to run again.
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_created_at_not_null;
+ALTER TABLE transactions ADD CONSTRAINT transactions_created_at_not_null CHECK (created_at IS NOT NULL) NOT VALID;
+ALTER TABLE transactions VALIDATE CONSTRAINT transactions_created_at_not_null;
+
+---- create above / drop below ----
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_created_at_not_null
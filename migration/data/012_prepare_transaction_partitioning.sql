@@ -0,0 +1,39 @@
+-- Partitioned by month, transactions can only keep a primary key that
+-- includes created_at, so transaction_ids takes over keeping ids unique
+-- across partitions. The trigger registers every new transaction; a
+-- taken id fails the insert with a unique violation as the primary key
+-- did. Backfill 012 registers the rows written before it.
+CREATE TABLE IF NOT EXISTS transaction_ids (
+    transaction_id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION transactions_register_id() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO transaction_ids (transaction_id, created_at) VALUES (NEW.transaction_id, NEW.created_at);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS transactions_register_id ON transactions;
+CREATE TRIGGER transactions_register_id AFTER INSERT ON transactions
+    FOR EACH ROW EXECUTE FUNCTION transactions_register_id();
+
+-- The existing rows become the first partition, which covers everything
+-- before the start of the month after next. Postgres attaches it without
+-- a scan once this check is validated (migration 013); until migration
+-- 014 it rejects transactions past that bound.
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_legacy_range;
+DO $$
+BEGIN
+    EXECUTE format('ALTER TABLE transactions ADD CONSTRAINT transactions_legacy_range CHECK (created_at < %L) NOT VALID',
+        date_trunc('month', now()) + interval '2 months');
+END;
+$$;
+
+---- create above / drop below ----
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_legacy_range;
+DROP TRIGGER IF EXISTS transactions_register_id ON transactions;
+DROP FUNCTION IF EXISTS transactions_register_id();
+DROP TABLE IF EXISTS transaction_ids
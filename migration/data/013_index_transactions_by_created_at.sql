@@ -0,0 +1,19 @@
+---- tern: disable-tx ----
+-- Indexes the partitioned table needs on every partition, built here
+-- without blocking writes so that attaching the existing rows in
+-- migration 014 only has to adopt them. An index a failed run left
+-- invalid is dropped and built again.
+DROP INDEX CONCURRENTLY IF EXISTS transactions_legacy_id_created_at_idx;
+CREATE UNIQUE INDEX CONCURRENTLY transactions_legacy_id_created_at_idx ON transactions (transaction_id, created_at);
+DROP INDEX CONCURRENTLY IF EXISTS transactions_legacy_wallet_id_created_at_idx;
+CREATE INDEX CONCURRENTLY transactions_legacy_wallet_id_created_at_idx ON transactions (wallet_id, created_at);
+
+-- lint:ignore set-not-null the validated transactions_created_at_not_null check spares the scan
+ALTER TABLE transactions ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE transactions VALIDATE CONSTRAINT transactions_legacy_range;
+
+---- create above / drop below ----
+
+ALTER TABLE transactions ALTER COLUMN created_at DROP NOT NULL;
+DROP INDEX IF EXISTS transactions_legacy_wallet_id_created_at_idx;
+DROP INDEX IF EXISTS transactions_legacy_id_created_at_idx
@@ -0,0 +1,98 @@
+-- transactions becomes partitioned by month of created_at. The existing
+-- table is attached as its first partition, transactions_legacy, which
+-- the check and the indexes of migrations 012 and 013 let Postgres do
+-- without a scan or an index build. sql_service creates the monthly
+-- partitions that follow and archives old ones; transaction_partitions
+-- keeps track of them.
+ALTER TABLE transactions RENAME TO transactions_legacy;
+ALTER INDEX transactions_pkey RENAME TO transactions_legacy_pkey;
+DROP TRIGGER IF EXISTS transactions_register_id ON transactions_legacy;
+
+CREATE TABLE IF NOT EXISTS transactions (
+    transaction_id UUID NOT NULL,
+    wallet_id INT REFERENCES wallets (wallet_id),
+    value DECIMAL(10, 2) NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    transaction_time VARCHAR(255) NOT NULL,
+    principal VARCHAR(255),
+    settled_at TIMESTAMPTZ,
+    settlement_ref TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (transaction_id, created_at)
+) PARTITION BY RANGE (created_at);
+CREATE INDEX IF NOT EXISTS transactions_wallet_id_created_at_idx ON transactions (wallet_id, created_at);
+CREATE TRIGGER transactions_register_id AFTER INSERT ON transactions
+    FOR EACH ROW EXECUTE FUNCTION transactions_register_id();
+
+-- name is the partition table; archive_file and rows are set once it has
+-- been exported and detached.
+CREATE TABLE IF NOT EXISTS transaction_partitions (
+    name TEXT PRIMARY KEY,
+    from_ts TIMESTAMPTZ NOT NULL,
+    to_ts TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ,
+    archive_file TEXT,
+    rows BIGINT
+);
+
+-- The signed sum of the successful transactions of every wallet in an
+-- archived partition, which reconciliation adds to the ones still there.
+CREATE TABLE IF NOT EXISTS transaction_archive_totals (
+    partition_name TEXT NOT NULL REFERENCES transaction_partitions (name),
+    wallet_id INT NOT NULL,
+    total DECIMAL(14, 2) NOT NULL,
+    PRIMARY KEY (partition_name, wallet_id)
+);
+
+DO $$
+DECLARE
+    upper_bound TIMESTAMPTZ;
+BEGIN
+    SELECT substring(pg_get_constraintdef(oid) FROM '''([^'']+)''')::timestamptz INTO STRICT upper_bound
+    FROM pg_constraint
+    WHERE conrelid = 'transactions_legacy'::regclass AND conname = 'transactions_legacy_range';
+
+    EXECUTE format('ALTER TABLE transactions ATTACH PARTITION transactions_legacy FOR VALUES FROM (MINVALUE) TO (%L)', upper_bound);
+    INSERT INTO transaction_partitions (name, from_ts, to_ts) VALUES ('transactions_legacy', '-infinity', upper_bound);
+END;
+$$;
+
+---- create above / drop below ----
+
+-- The rows of the later partitions move back into the table the
+-- partitioning started from, which gets its range check back for the
+-- next attach. The archived rows are gone, so once a partition has been
+-- archived there is no going back.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM transaction_partitions WHERE archived_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'transaction partitions have been archived; their rows cannot be moved back';
+    END IF;
+END;
+$$;
+
+ALTER TABLE transactions DETACH PARTITION transactions_legacy;
+DROP TRIGGER IF EXISTS transactions_register_id ON transactions_legacy;
+ALTER TABLE transactions_legacy DROP CONSTRAINT IF EXISTS transactions_legacy_range;
+INSERT INTO transactions_legacy (transaction_id, wallet_id, value, type, status, transaction_time, principal,
+    settled_at, settlement_ref, created_at)
+SELECT transaction_id, wallet_id, value, type, status, transaction_time, principal,
+    settled_at, settlement_ref, created_at
+FROM transactions;
+DROP TABLE transactions;
+DROP TABLE IF EXISTS transaction_archive_totals;
+DROP TABLE IF EXISTS transaction_partitions;
+
+ALTER TABLE transactions_legacy RENAME TO transactions;
+ALTER INDEX transactions_legacy_pkey RENAME TO transactions_pkey;
+CREATE TRIGGER transactions_register_id AFTER INSERT ON transactions
+    FOR EACH ROW EXECUTE FUNCTION transactions_register_id();
+
+-- Validated right away: the table is locked for the copy above anyway.
+DO $$
+BEGIN
+    EXECUTE format('ALTER TABLE transactions ADD CONSTRAINT transactions_legacy_range CHECK (created_at < %L)',
+        date_trunc('month', now()) + interval '2 months');
+END;
+$$
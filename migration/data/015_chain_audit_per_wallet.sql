@@ -0,0 +1,12 @@
+-- Audit entries link to the previous entry of their wallet instead of the
+-- previous entry overall, so that balance changes of different wallets no
+-- longer wait on one chain lock; the wallet row lock already orders the
+-- entries of a wallet. Entries written before keep their links to the
+-- global chain and are verified along it.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS chain VARCHAR(8) NOT NULL DEFAULT 'global';
+ALTER TABLE audit_log ALTER COLUMN chain SET DEFAULT 'wallet';
+
+---- create above / drop below ----
+
+-- Entries chained per wallet no longer verify once the column is gone.
+ALTER TABLE audit_log DROP COLUMN IF EXISTS chain;
@@ -0,0 +1,16 @@
+-- Development data: three wallets and the deposits that funded them. It
+-- can be applied any number of times.
+INSERT INTO wallets (wallet_id, balance) VALUES (1, 100.00), (2, 200.00), (3, 75.00)
+ON CONFLICT (wallet_id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('wallets', 'wallet_id'), (SELECT MAX(wallet_id) FROM wallets));
+
+-- transactions is partitioned, so its ids are unique in transaction_ids
+-- rather than in a key ON CONFLICT could use.
+INSERT INTO transactions (transaction_id, wallet_id, value, type, status, transaction_time, created_at)
+SELECT v.transaction_id::uuid, v.wallet_id, v.value, v.type, v.status, v.transaction_time, v.created_at::timestamptz
+FROM (VALUES
+    ('f47cbde3-98d8-47cb-a30b-1046b1f70b75', 1, 100.00, 'deposit', 'Success', '2023-11-06 12:00:00', '2023-11-06 12:00:00'),
+    ('5e7e68f0-30d6-4d4b-8411-7f75e3b63f27', 2, 200.00, 'deposit', 'Success', '2023-11-06 12:15:00', '2023-11-06 12:15:00'),
+    ('f4c94427-d2a9-49ac-bb5a-e7a7d2db6d4d', 3, 75.00, 'deposit', 'Success', '2023-11-06 12:30:00', '2023-11-06 12:30:00')
+) AS v (transaction_id, wallet_id, value, type, status, transaction_time, created_at)
+WHERE NOT EXISTS (SELECT 1 FROM transaction_ids i WHERE i.transaction_id = v.transaction_id::uuid);
@@ -0,0 +1,310 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/tern/v2/migrate"
+)
+
+// Finding is a statement of a migration that can lock or rewrite a large
+// table, or fail outright.
+type Finding struct {
+	Migration string
+	Direction string
+	Rule      string
+	Message   string
+	Statement string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s (%s): %s: %s\n    %s", f.Migration, f.Direction, f.Rule, f.Message, f.Statement)
+}
+
+// Lint checks the statements of a migration step against the rules below.
+// Statements on a table the same step creates are exempt: it is still
+// empty. A statement reviewed as safe, say because its table is known to
+// stay small, is let through by a comment before it:
+//
+//	-- lint:ignore set-not-null wallets has a few hundred rows
+//
+// Backfills are not linted; they are batched by design.
+func Lint(step Step) []Finding {
+	if step.Direction == "backfill" {
+		return nil
+	}
+
+	statements := splitStatements(step.SQL)
+	created := map[string]bool{}
+	for _, s := range statements {
+		if m := createTable.FindStringSubmatch(s.normalized); m != nil {
+			created[tableName(m[1])] = true
+		}
+	}
+
+	var findings []Finding
+	for _, s := range statements {
+		for _, v := range lintStatement(s.normalized, step.Transactional, created) {
+			if s.ignores(v.rule) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Migration: step.Name,
+				Direction: step.Direction,
+				Rule:      v.rule,
+				Message:   v.message,
+				Statement: abbreviate(s.normalized),
+			})
+		}
+	}
+	return findings
+}
+
+// LintEmbedded lints both directions of every embedded migration.
+func LintEmbedded() ([]Finding, error) {
+	var migrator migrate.Migrator
+	backfills, err := load(&migrator)
+	if err != nil {
+		return nil, err
+	}
+	last := int32(len(migrator.Migrations))
+	up, err := plan(migrator.Migrations, backfills, 0, last)
+	if err != nil {
+		return nil, err
+	}
+	down, err := plan(migrator.Migrations, backfills, last, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, step := range append(up, down...) {
+		findings = append(findings, Lint(step)...)
+	}
+	return findings, nil
+}
+
+type violation struct {
+	rule, message string
+}
+
+var (
+	createTable = regexp.MustCompile(`^create (?:unlogged )?table (?:if not exists )?([^\s(]+)`)
+	createIndex = regexp.MustCompile(`^create (?:unique )?index (concurrently )?.*? on (?:only )?([^\s(]+)`)
+	alterTable  = regexp.MustCompile(`^alter table (?:if exists )?(?:only )?([^\s]+) (.*)$`)
+	concurrent  = regexp.MustCompile(`^(?:create (?:unique )?index|drop index|reindex .*|refresh materialized view) concurrently\b`)
+	rewrite     = regexp.MustCompile(`^(?:vacuum (?:\(.*\bfull\b.*\)|full)|cluster)\b`)
+	bulkWrite   = regexp.MustCompile(`^(?:update (?:only )?([^\s]+)|delete from (?:only )?([^\s]+))`)
+
+	addColumn     = regexp.MustCompile(`^add (?:column )?(?:if not exists )?[^\s]+ (.*)$`)
+	volatile      = regexp.MustCompile(`\b(?:clock_timestamp|random|gen_random_uuid|uuid_generate_v[14]|nextval|timeofday|statement_timestamp)\s*\(|\b(?:small|big)?serial\b|\bstored\b`)
+	alterType     = regexp.MustCompile(`^alter (?:column )?[^\s]+ (?:set data )?type\b`)
+	setNotNull    = regexp.MustCompile(`^alter (?:column )?[^\s]+ set not null\b`)
+	addConstraint = regexp.MustCompile(`^add (?:constraint [^\s]+ )?(check|foreign key|unique|primary key|exclude)\b`)
+	tableRewrite  = regexp.MustCompile(`^set (?:tablespace|logged|unlogged|without oids)\b`)
+)
+
+func lintStatement(s string, transactional bool, created map[string]bool) []violation {
+	var found []violation
+	if transactional && concurrent.MatchString(s) {
+		found = append(found, violation{"concurrently-in-transaction",
+			"CONCURRENTLY cannot run inside a transaction; mark the migration with ---- tern: disable-tx ----"})
+	}
+	if rewrite.MatchString(s) {
+		found = append(found, violation{"table-rewrite", "rewrites the table under an exclusive lock"})
+	}
+	if m := createIndex.FindStringSubmatch(s); m != nil && m[1] == "" && !created[tableName(m[2])] {
+		found = append(found, violation{"create-index",
+			"blocks writes while the index builds; use CREATE INDEX CONCURRENTLY in a disable-tx migration"})
+	}
+	if m := bulkWrite.FindStringSubmatch(s); m != nil && !created[tableName(m[1]+m[2])] {
+		found = append(found, violation{"bulk-write",
+			"changes every matching row in one transaction; use a backfill, which runs in batches"})
+	}
+
+	m := alterTable.FindStringSubmatch(s)
+	if m == nil || created[tableName(m[1])] {
+		return found
+	}
+	for _, action := range splitActions(m[2]) {
+		if c := addColumn.FindStringSubmatch(action); c != nil && !addConstraint.MatchString(action) {
+			definition := c[1]
+			switch {
+			case strings.Contains(definition, "not null") && !strings.Contains(definition, "default"):
+				found = append(found, violation{"add-column-not-null",
+					"fails on a table with rows; add the column nullable, backfill it, then require it"})
+			case volatile.MatchString(definition):
+				found = append(found, violation{"add-column-volatile-default",
+					"a volatile default, serial or stored generated column rewrites the table; add it without and backfill"})
+			}
+		}
+		switch {
+		case alterType.MatchString(action):
+			found = append(found, violation{"alter-column-type",
+				"rewrites the table under an exclusive lock; add a new column and backfill it instead"})
+		case setNotNull.MatchString(action):
+			found = append(found, violation{"set-not-null",
+				"scans the table under an exclusive lock; add CHECK (column IS NOT NULL) NOT VALID and validate it separately"})
+		case tableRewrite.MatchString(action):
+			found = append(found, violation{"table-rewrite", "rewrites the table under an exclusive lock"})
+		}
+		if c := addConstraint.FindStringSubmatch(action); c != nil {
+			switch c[1] {
+			case "check", "foreign key":
+				if !strings.Contains(action, "not valid") {
+					found = append(found, violation{"add-constraint",
+						"scans the table under an exclusive lock; add it NOT VALID and VALIDATE it in a separate transaction"})
+				}
+			default:
+				if !strings.Contains(action, "using index") {
+					found = append(found, violation{"add-constraint",
+						"builds an index under an exclusive lock; build it CONCURRENTLY and add the constraint USING INDEX"})
+				}
+			}
+		}
+	}
+	return found
+}
+
+func tableName(name string) string {
+	return strings.ReplaceAll(name, `"`, "")
+}
+
+func abbreviate(s string) string {
+	const max = 100
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
+
+// statement is one SQL statement of a migration: its text lowercased with
+// comments and runs of whitespace squeezed out, and the comments before
+// it, where lint:ignore is looked for.
+type statement struct {
+	normalized string
+	comments   []string
+}
+
+var lintIgnore = regexp.MustCompile(`^lint:ignore\s+([\w,-]+)`)
+
+func (s statement) ignores(rule string) bool {
+	for _, c := range s.comments {
+		m := lintIgnore.FindStringSubmatch(strings.TrimSpace(c))
+		if m == nil {
+			continue
+		}
+		for _, r := range strings.Split(m[1], ",") {
+			if r == rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitStatements splits SQL on the semicolons outside of quotes, dollar
+// quotes and comments.
+func splitStatements(sql string) []statement {
+	var (
+		statements []statement
+		current    statement
+		text       strings.Builder
+	)
+	flush := func() {
+		normalized := strings.ToLower(strings.Join(strings.Fields(text.String()), " "))
+		if normalized != "" {
+			current.normalized = normalized
+			statements = append(statements, current)
+			current = statement{}
+		}
+		text.Reset()
+	}
+
+	for i := 0; i < len(sql); {
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			current.comments = append(current.comments, sql[i+2:i+end])
+			text.WriteByte(' ')
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 2
+			}
+			current.comments = append(current.comments, sql[i+2:i+2+end])
+			text.WriteByte(' ')
+			i += end + 4
+		case sql[i] == '\'' || sql[i] == '"':
+			end := closingQuote(sql, i+1, sql[i])
+			text.WriteString(sql[i:end])
+			i = end
+		case sql[i] == '$':
+			if tag := dollarTag.FindString(sql[i:]); tag != "" {
+				end := strings.Index(sql[i+len(tag):], tag)
+				if end < 0 {
+					end = len(sql) - i - len(tag)
+				} else {
+					end += len(tag)
+				}
+				text.WriteString(sql[i : i+len(tag)+end])
+				i += len(tag) + end
+				continue
+			}
+			text.WriteByte(sql[i])
+			i++
+		case sql[i] == ';':
+			flush()
+			i++
+		default:
+			text.WriteByte(sql[i])
+			i++
+		}
+	}
+	flush()
+	return statements
+}
+
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// closingQuote returns the index after the quote closing the one before
+// start; a doubled quote does not close it.
+func closingQuote(sql string, start int, quote byte) int {
+	for i := start; i < len(sql); i++ {
+		if sql[i] != quote {
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(sql)
+}
+
+// splitActions splits the actions of an ALTER TABLE on the commas outside
+// parentheses.
+func splitActions(s string) []string {
+	var actions []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				actions = append(actions, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(actions, strings.TrimSpace(s[start:]))
+}
@@ -0,0 +1,55 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	for _, tc := range []struct {
+		sql           string
+		transactional bool
+		rules         []string
+	}{
+		{"ALTER TABLE transactions ADD COLUMN created_at TIMESTAMPTZ", true, nil},
+		{"ALTER TABLE transactions ADD COLUMN created_at TIMESTAMPTZ NOT NULL", true, []string{"add-column-not-null"}},
+		{"ALTER TABLE transactions ADD COLUMN n INT NOT NULL DEFAULT 0", true, nil},
+		{"ALTER TABLE transactions ADD COLUMN created_at TIMESTAMPTZ DEFAULT clock_timestamp()", true, []string{"add-column-volatile-default"}},
+		{"ALTER TABLE transactions ADD COLUMN seq BIGSERIAL", true, []string{"add-column-volatile-default"}},
+		{"ALTER TABLE transactions ALTER COLUMN transaction_time TYPE TIMESTAMP USING transaction_time::timestamp", true, []string{"alter-column-type"}},
+		{"ALTER TABLE transactions ALTER created_at SET NOT NULL", true, []string{"set-not-null"}},
+		{"ALTER TABLE transactions ALTER COLUMN created_at SET DEFAULT now()", true, nil},
+		{"ALTER TABLE transactions ADD CONSTRAINT c CHECK (value > 0)", true, []string{"add-constraint"}},
+		{"ALTER TABLE transactions ADD CONSTRAINT c CHECK (value > 0) NOT VALID", true, nil},
+		{"ALTER TABLE transactions ADD CONSTRAINT u UNIQUE (principal)", true, []string{"add-constraint"}},
+		{"ALTER TABLE transactions ADD CONSTRAINT u UNIQUE USING INDEX transactions_principal_idx", true, nil},
+		{"ALTER TABLE transactions ADD COLUMN a INT NOT NULL, ALTER COLUMN value TYPE DECIMAL(12, 2)", true, []string{"add-column-not-null", "alter-column-type"}},
+		{"CREATE INDEX transactions_wallet_idx ON transactions (wallet_id)", true, []string{"create-index"}},
+		{"CREATE INDEX CONCURRENTLY transactions_wallet_idx ON transactions (wallet_id)", true, []string{"concurrently-in-transaction"}},
+		{"CREATE INDEX CONCURRENTLY IF NOT EXISTS transactions_wallet_idx ON transactions (wallet_id)", false, nil},
+		{"CREATE TABLE IF NOT EXISTS t (id INT); CREATE INDEX t_idx ON t (id); ALTER TABLE t ADD COLUMN n INT NOT NULL", true, nil},
+		{"UPDATE transactions SET principal = 'x'", true, []string{"bulk-write"}},
+		{"VACUUM FULL transactions", false, []string{"table-rewrite"}},
+		{"-- lint:ignore set-not-null wallets stays small\nALTER TABLE wallets ALTER COLUMN balance SET NOT NULL", true, nil},
+		{"-- lint:ignore create-index\nCREATE INDEX a ON wallets (balance);\nCREATE INDEX b ON wallets (balance)", true, []string{"create-index"}},
+		{"INSERT INTO t VALUES ('; UPDATE t SET a = 1'); SELECT $$;ALTER TABLE t ALTER a SET NOT NULL$$", true, nil},
+	} {
+		var rules []string
+		for _, f := range Lint(Step{Name: "test.sql", Direction: "up", SQL: tc.sql, Transactional: tc.transactional}) {
+			rules = append(rules, f.Rule)
+		}
+		if !reflect.DeepEqual(rules, tc.rules) {
+			t.Errorf("%q: %v, want %v", tc.sql, rules, tc.rules)
+		}
+	}
+}
+
+func TestEmbeddedMigrationsLint(t *testing.T) {
+	findings, err := LintEmbedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		t.Error(f)
+	}
+}
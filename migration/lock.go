@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// lockKey is the advisory lock tern takes around every migration. Holding
+// it across several steps also keeps out sql_service replicas migrating on
+// startup, which wait for it.
+const lockKey = int64(9628173550095224)
+
+const lockPoll = 500 * time.Millisecond
+
+// ErrLocked is returned by Lock when another session holds the migration
+// lock for longer than the caller is willing to wait.
+var ErrLocked = errors.New("another migration is running")
+
+// Lock takes the migration lock for this migrator's session, waiting up to
+// wait for a session that holds it. The lock is held until Unlock or
+// Close.
+func (m Migrator) Lock(ctx context.Context, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		var locked bool
+		if err := m.conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&locked); err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+		if time.Now().Add(lockPoll).After(deadline) {
+			return ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// lock waits for the migration lock for as long as ctx allows.
+func (m Migrator) lock(ctx context.Context) error {
+	_, err := m.conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+	return err
+}
+
+func (m Migrator) Unlock(ctx context.Context) error {
+	_, err := m.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+	return err
+}
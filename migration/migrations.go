@@ -1,8 +1,15 @@
+// Package migration is the database schema of sql_service: numbered tern
+// migrations in data/ and the backfills that follow some of them in
+// backfills/, embedded in the binaries that apply them.
+// sql_service applies them on startup when DB_MIGRATE is set and otherwise
+// refuses to start on an older schema; cmd/migrate applies them as a
+// separate job.
 package migration
 
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 
@@ -12,45 +19,90 @@ import (
 
 const versionTable = "db_version"
 
+// ErrOutdated is wrapped by Check when the database is behind the
+// embedded migrations.
+var ErrOutdated = errors.New("database schema is out of date")
+
 type Migrator struct {
-	migrator *migrate.Migrator
+	conn            *pgx.Conn
+	migrator        *migrate.Migrator
+	backfills       []backfill
+	backfillOptions *BackfillOptions
 }
 
 //go:embed data/*.sql
 var migrationFiles embed.FS
 
-func NewMigrator(dbDNS string) (Migrator, error) {
+//go:embed fixtures/dev.sql
+var devFixture string
 
-	conn, err := pgx.Connect(context.Background(), dbDNS)
+// NewMigrator connects to the database and loads the embedded migrations
+// and backfills. The version and backfill tables are created if they do
+// not exist.
+func NewMigrator(ctx context.Context, connString string) (Migrator, error) {
+	conn, err := pgx.Connect(ctx, connString)
 	if err != nil {
 		return Migrator{}, err
 	}
-	migrator, err := migrate.NewMigratorEx(
-		context.Background(), conn, versionTable,
+	migrator, err := migrate.NewMigratorEx(ctx, conn, versionTable,
 		&migrate.MigratorOptions{
 			DisableTx: false,
 		})
 	if err != nil {
+		conn.Close(ctx)
 		return Migrator{}, err
 	}
 
-	migrationRoot, _ := fs.Sub(migrationFiles, "data")
-
-	err = migrator.LoadMigrations(migrationRoot)
+	backfills, err := load(migrator)
 	if err != nil {
+		conn.Close(ctx)
 		return Migrator{}, err
 	}
 
-	return Migrator{
-		migrator: migrator,
-	}, nil
+	options := defaultBackfillOptions
+	m := Migrator{
+		conn:            conn,
+		migrator:        migrator,
+		backfills:       backfills,
+		backfillOptions: &options,
+	}
+	if err := m.ensureBackfillTableExists(ctx); err != nil {
+		conn.Close(ctx)
+		return Migrator{}, err
+	}
+	return m, nil
+}
+
+func load(migrator *migrate.Migrator) ([]backfill, error) {
+	migrationRoot, err := fs.Sub(migrationFiles, "data")
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.LoadMigrations(migrationRoot); err != nil {
+		return nil, err
+	}
+
+	backfills, err := loadBackfills()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range backfills {
+		if b.version < 1 || int(b.version) > len(migrator.Migrations) {
+			return nil, fmt.Errorf("backfill %s follows migration %d, which does not exist", b.name, b.version)
+		}
+	}
+	return backfills, nil
+}
+
+func (m Migrator) Close(ctx context.Context) error {
+	return m.conn.Close(ctx)
 }
 
 // Info the current migration version and the embedded maximum migration, and a textual
 // representation of the migration state for informational purposes.
-func (m Migrator) Info() (int32, int32, string, error) {
+func (m Migrator) Info(ctx context.Context) (int32, int32, string, error) {
 
-	version, err := m.migrator.GetCurrentVersion(context.Background())
+	version, err := m.migrator.GetCurrentVersion(ctx)
 	if err != nil {
 		return 0, 0, "", err
 	}
@@ -74,14 +126,97 @@ func (m Migrator) Info() (int32, int32, string, error) {
 	return version, last, info, nil
 }
 
+// Version is one embedded migration.
+type Version struct {
+	Sequence int32
+	Name     string
+}
+
+// Status is the version of the database and the migrations embedded in
+// this build.
+type Status struct {
+	Current    int32
+	Migrations []Version
+	Backfills  []BackfillState
+}
+
+func (s Status) Latest() int32 {
+	return int32(len(s.Migrations))
+}
+
+// Pending lists the migrations after the current version.
+func (s Status) Pending() []Version {
+	if s.Current >= s.Latest() {
+		return nil
+	}
+	return s.Migrations[max(s.Current, 0):]
+}
+
+func (m Migrator) Status(ctx context.Context) (Status, error) {
+	version, err := m.migrator.GetCurrentVersion(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	status := Status{Current: version}
+	for _, thisMigration := range m.migrator.Migrations {
+		status.Migrations = append(status.Migrations, Version{Sequence: thisMigration.Sequence, Name: thisMigration.Name})
+	}
+	status.Backfills, err = m.Backfills(ctx)
+	return status, err
+}
+
+// Check fails with ErrOutdated unless every embedded migration has been
+// applied. A database ahead of them passes: a newer release migrated it
+// and this one still runs against the columns it knows.
+func (m Migrator) Check(ctx context.Context) error {
+	version, last, _, err := m.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if version < last {
+		return fmt.Errorf("%w: it is at version %d, the migrations go up to %d", ErrOutdated, version, last)
+	}
+	return nil
+}
+
 // Migrate migrates the DB to the most recent version of the schema.
-func (m Migrator) Migrate() error {
-	err := m.migrator.Migrate(context.Background())
-	return err
+func (m Migrator) Migrate(ctx context.Context) error {
+	return m.MigrateTo(ctx, int32(len(m.migrator.Migrations)))
+}
+
+// MigrateTo migrates to a specific version of the schema, running the
+// backfills on the way up. Use '0' to undo all migrations. It waits for
+// the migration lock and holds it throughout.
+func (m Migrator) MigrateTo(ctx context.Context, ver int32) (err error) {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := m.Unlock(ctx); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	version, err := m.migrator.GetCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	steps, err := m.Plan(version, ver)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if err := m.Apply(ctx, step); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// MigrateTo migrates to a specific version of the schema. Use '0' to undo all migrations.
-func (m Migrator) MigrateTo(ver int32) error {
-	err := m.migrator.MigrateTo(context.Background(), ver)
+// SeedDevData adds the wallets and transactions of fixtures/dev.sql for
+// local development and demos. It never runs in migrations, and seeding
+// twice changes nothing.
+func (m Migrator) SeedDevData(ctx context.Context) error {
+	_, err := m.conn.Exec(ctx, devFixture)
 	return err
 }
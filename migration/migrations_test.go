@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/tern/v2/migrate"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	var migrator migrate.Migrator
+	backfills, err := load(&migrator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrator.Migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for _, m := range migrator.Migrations {
+		if strings.TrimSpace(m.DownSQL) == "" {
+			t.Errorf("%s cannot be rolled back", m.Name)
+		}
+		// A database created before the migrations existed already has
+		// the tables, so applying them to it must not fail.
+		if strings.Contains(m.UpSQL, "CREATE TABLE ") && !strings.Contains(m.UpSQL, "CREATE TABLE IF NOT EXISTS") {
+			t.Errorf("%s creates a table that may exist", m.Name)
+		}
+	}
+
+	for _, b := range backfills {
+		if !strings.Contains(b.sql, "$1") || !strings.Contains(b.sql, "$2") {
+			t.Errorf("backfill %s does not take the last key and the batch size", b.name)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	var migrator migrate.Migrator
+	if _, err := load(&migrator); err != nil {
+		t.Fatal(err)
+	}
+	last := int32(len(migrator.Migrations))
+
+	steps, err := plan(migrator.Migrations, nil, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].Sequence != 2 || steps[1].Sequence != 3 || steps[0].Direction != "up" || steps[0].SQL != migrator.Migrations[1].UpSQL {
+		t.Errorf("1 to 3: %+v", steps)
+	}
+
+	steps, err = plan(migrator.Migrations, nil, last, last-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].Sequence != last || steps[1].Sequence != last-1 || steps[0].Direction != "down" || steps[0].SQL != migrator.Migrations[last-1].DownSQL {
+		t.Errorf("%d to %d: %+v", last, last-2, steps)
+	}
+
+	if _, err := plan(migrator.Migrations, nil, 0, last+1); err == nil {
+		t.Error("planned past the last migration")
+	}
+
+	backfilled := []backfill{{version: 2, name: "002_fill.sql", sql: "SELECT $1, $2"}}
+	for _, tc := range []struct {
+		from, to int32
+		want     []string
+	}{
+		{0, 3, []string{"up 1", "up 2", "backfill 2", "up 3"}},
+		{2, 3, []string{"backfill 2", "up 3"}},
+		{2, 2, []string{"backfill 2"}},
+		{3, 1, []string{"down 3", "down 2"}},
+	} {
+		steps, err := plan(migrator.Migrations, backfilled, tc.from, tc.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range steps {
+			got = append(got, fmt.Sprintf("%s %d", s.Direction, s.Sequence))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d to %d with a backfill after 2: %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+
+	irreversible := append(migrator.Migrations[:1:1], &migrate.Migration{Sequence: 2, Name: "002_irreversible.sql", UpSQL: "SELECT 1"})
+	if _, err := plan(irreversible, nil, 2, 0); err == nil {
+		t.Error("planned to roll back a migration without a down section")
+	}
+}
@@ -0,0 +1,95 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/jackc/tern/v2/migrate"
+)
+
+// Step is one migration applied in one direction, or a backfill.
+type Step struct {
+	Sequence  int32
+	Name      string
+	Direction string // "up", "down" or "backfill"
+	SQL       string
+	// Transactional is false for migrations marked with
+	// "---- tern: disable-tx ----", whose statements run one by one
+	// outside a transaction, as CREATE INDEX CONCURRENTLY has to. They are
+	// not rolled back on failure, so each statement must be safe to run
+	// again.
+	Transactional bool
+}
+
+// disableTx is how tern recognises a non-transactional migration.
+var disableTx = regexp.MustCompile(`(?m)^---- tern: disable-tx ----$`)
+
+// Plan lists the steps that take a database at version from to version
+// to, without touching the database. Going up, the backfills of a version
+// follow its migration; the backfills of from come first, so that one
+// interrupted earlier is finished before anything else, and backfills
+// already done are skipped when the plan is applied. It fails like
+// MigrateTo would: on a version outside the embedded migrations or a step
+// that cannot be rolled back.
+func (m Migrator) Plan(from, to int32) ([]Step, error) {
+	return plan(m.migrator.Migrations, m.backfills, from, to)
+}
+
+func plan(migrations []*migrate.Migration, backfills []backfill, from, to int32) ([]Step, error) {
+	last := int32(len(migrations))
+	if to < 0 || to > last {
+		return nil, migrate.BadVersionError(fmt.Sprintf("destination version %d is outside the valid versions of 0 to %d", to, last))
+	}
+	if from < 0 || from > last {
+		return nil, migrate.BadVersionError(fmt.Sprintf("current version %d is outside the valid versions of 0 to %d", from, last))
+	}
+
+	var steps []Step
+	backfillsOf := func(version int32) {
+		for _, b := range backfills {
+			if b.version == version {
+				steps = append(steps, Step{Sequence: b.version, Name: b.name, Direction: "backfill", SQL: b.sql})
+			}
+		}
+	}
+	if to >= from {
+		backfillsOf(from)
+	}
+	for v := from; v < to; v++ {
+		current := migrations[v]
+		steps = append(steps, Step{Sequence: current.Sequence, Name: current.Name, Direction: "up", SQL: current.UpSQL,
+			Transactional: !disableTx.MatchString(current.UpSQL)})
+		backfillsOf(current.Sequence)
+	}
+	for v := from; v > to; v-- {
+		current := migrations[v-1]
+		if current.DownSQL == "" {
+			return nil, fmt.Errorf("irreversible migration: %d - %s", current.Sequence, current.Name)
+		}
+		steps = append(steps, Step{Sequence: current.Sequence, Name: current.Name, Direction: "down", SQL: current.DownSQL,
+			Transactional: !disableTx.MatchString(current.DownSQL)})
+	}
+	return steps, nil
+}
+
+// Apply runs one step of a plan. A backfill runs the batches it has left;
+// rolling a migration back also forgets the progress of its backfills.
+func (m Migrator) Apply(ctx context.Context, step Step) error {
+	switch step.Direction {
+	case "up":
+		return m.migrator.MigrateTo(ctx, step.Sequence)
+	case "down":
+		if err := m.migrator.MigrateTo(ctx, step.Sequence-1); err != nil {
+			return err
+		}
+		return m.resetBackfills(ctx, step.Sequence)
+	case "backfill":
+		for _, b := range m.backfills {
+			if b.name == step.Name {
+				return m.runBackfill(ctx, b)
+			}
+		}
+	}
+	return fmt.Errorf("unknown step %s %s", step.Direction, step.Name)
+}
@@ -0,0 +1,131 @@
+package authz
+
+import (
+	"context"
+	"slices"
+
+	"common/logging"
+	"sqlapi/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type access int
+
+const (
+	read access = iota
+	write
+	manage
+	operate
+)
+
+// methodAccess classifies every RPC. Methods missing here are denied.
+var methodAccess = map[string]access{
+	pb.SQLService_GetBalance_FullMethodName:        read,
+	pb.SQLService_GetTransactionID_FullMethodName:  read,
+	pb.SQLService_GetAuditLog_FullMethodName:       read,
+	pb.SQLService_Reconcile_FullMethodName:         read,
+	pb.SQLService_GetBatch_FullMethodName:          read,
+	pb.SQLService_GetWallet_FullMethodName:         read,
+	pb.SQLService_ListWallets_FullMethodName:       read,
+	pb.SQLService_ListTransactions_FullMethodName:  read,
+	pb.SQLService_UpdateBalance_FullMethodName:     write,
+	pb.SQLService_CreateTransaction_FullMethodName: write,
+	pb.SQLService_StartBatch_FullMethodName:        write,
+	pb.SQLService_ReportBatchItem_FullMethodName:   write,
+
+	pb.SQLService_ClaimWebhookDeliveries_FullMethodName: write,
+	pb.SQLService_ReportWebhookDelivery_FullMethodName:  write,
+
+	pb.SQLService_CreateWebhook_FullMethodName:         manage,
+	pb.SQLService_ListWebhooks_FullMethodName:          manage,
+	pb.SQLService_DeleteWebhook_FullMethodName:         manage,
+	pb.SQLService_EnableWebhook_FullMethodName:         manage,
+	pb.SQLService_ListWebhookDeliveries_FullMethodName: manage,
+	pb.SQLService_RedeliverWebhook_FullMethodName:      manage,
+	pb.SQLService_CreateBatch_FullMethodName:           manage,
+
+	pb.SQLService_CreateWallet_FullMethodName:                operate,
+	pb.SQLService_SetWalletFrozen_FullMethodName:             operate,
+	pb.SQLService_AdjustBalance_FullMethodName:               operate,
+	pb.SQLService_DecideAdjustment_FullMethodName:            operate,
+	pb.SQLService_ListFailedWebhookDeliveries_FullMethodName: operate,
+}
+
+// Policy lists the caller identities (client certificate common names)
+// allowed to read and to mutate data, and to act on behalf of merchants:
+// manage webhook configuration and submit batches, which only move money
+// once a writer processes them. Writers may also read, which processing
+// needs, but nothing else. Operators are people using walletctl: they may
+// read and manage, create and freeze wallets, adjust balances and replay
+// failed webhook deliveries, but not write on behalf of a service.
+type Policy struct {
+	Readers   []string
+	Writers   []string
+	Managers  []string
+	Operators []string
+}
+
+func (p Policy) allows(identity string, method string) bool {
+	required, ok := methodAccess[method]
+	if !ok {
+		return false
+	}
+	switch required {
+	case read:
+		return slices.Contains(p.Readers, identity) || slices.Contains(p.Writers, identity) ||
+			slices.Contains(p.Operators, identity)
+	case write:
+		return slices.Contains(p.Writers, identity)
+	case manage:
+		return slices.Contains(p.Managers, identity) || slices.Contains(p.Operators, identity)
+	case operate:
+		return slices.Contains(p.Operators, identity)
+	}
+	return false
+}
+
+type callerKey struct{}
+
+// Caller returns the identity of the service that made the current call, or
+// an empty string when the connection is not authenticated.
+func Caller(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// UnaryServerInterceptor identifies the caller by its verified client
+// certificate and rejects RPCs the policy does not grant it.
+func UnaryServerInterceptor(policy Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		identity, ok := peerIdentity(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "client certificate required")
+		}
+
+		if !policy.allows(identity, info.FullMethod) {
+			logging.FromContext(ctx).Warn("RPC denied", "caller", identity, "method", info.FullMethod)
+			return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", identity, info.FullMethod)
+		}
+
+		ctx = context.WithValue(ctx, callerKey{}, identity)
+		ctx = logging.With(ctx, "caller", identity)
+		return handler(ctx, req)
+	}
+}
+
+func peerIdentity(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, true
+}
@@ -0,0 +1,93 @@
+package authz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"sqlapi/pb"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	policy := Policy{
+		Readers:   []string{"api_service"},
+		Writers:   []string{"transaction_service"},
+		Managers:  []string{"api_service"},
+		Operators: []string{"walletctl"},
+	}
+
+	for _, tc := range []struct {
+		identity string
+		method   string
+		want     bool
+	}{
+		{"api_service", pb.SQLService_GetBalance_FullMethodName, true},
+		{"api_service", pb.SQLService_CreateWebhook_FullMethodName, true},
+		{"api_service", pb.SQLService_UpdateBalance_FullMethodName, false},
+		{"api_service", pb.SQLService_AdjustBalance_FullMethodName, false},
+
+		{"transaction_service", pb.SQLService_GetBalance_FullMethodName, true},
+		{"transaction_service", pb.SQLService_UpdateBalance_FullMethodName, true},
+		{"transaction_service", pb.SQLService_CreateTransaction_FullMethodName, true},
+		{"transaction_service", pb.SQLService_ClaimWebhookDeliveries_FullMethodName, true},
+		{"transaction_service", pb.SQLService_CreateBatch_FullMethodName, false},
+		{"transaction_service", pb.SQLService_AdjustBalance_FullMethodName, false},
+		{"transaction_service", pb.SQLService_SetWalletFrozen_FullMethodName, false},
+		{"transaction_service", pb.SQLService_CreateWallet_FullMethodName, false},
+		{"transaction_service", pb.SQLService_DecideAdjustment_FullMethodName, false},
+
+		{"walletctl", pb.SQLService_GetWallet_FullMethodName, true},
+		{"walletctl", pb.SQLService_RedeliverWebhook_FullMethodName, true},
+		{"walletctl", pb.SQLService_AdjustBalance_FullMethodName, true},
+		{"walletctl", pb.SQLService_DecideAdjustment_FullMethodName, true},
+		{"walletctl", pb.SQLService_UpdateBalance_FullMethodName, false},
+		{"walletctl", pb.SQLService_CreateTransaction_FullMethodName, false},
+
+		{"stranger", pb.SQLService_GetBalance_FullMethodName, false},
+		{"transaction_service", "/transaction.SQLService/Unknown", false},
+	} {
+		if got := policy.allows(tc.identity, tc.method); got != tc.want {
+			t.Errorf("%s calling %s: allowed %v, want %v", tc.identity, tc.method, got, tc.want)
+		}
+	}
+}
+
+func TestEveryMethodIsClassified(t *testing.T) {
+	for _, method := range pb.SQLService_ServiceDesc.Methods {
+		name := "/" + pb.SQLService_ServiceDesc.ServiceName + "/" + method.MethodName
+		if _, ok := methodAccess[name]; !ok {
+			t.Errorf("%s has no access class and is denied to everyone", name)
+		}
+	}
+}
+
+func TestPeerIdentity(t *testing.T) {
+	verified := credentials.TLSInfo{State: tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "transaction_service"}}}},
+	}}
+	unverified := credentials.TLSInfo{State: tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "transaction_service"}}},
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		ctx      context.Context
+		identity string
+		ok       bool
+	}{
+		{"verified certificate", peer.NewContext(context.Background(), &peer.Peer{AuthInfo: verified}), "transaction_service", true},
+		{"unverified certificate", peer.NewContext(context.Background(), &peer.Peer{AuthInfo: unverified}), "", false},
+		{"plaintext", peer.NewContext(context.Background(), &peer.Peer{}), "", false},
+		{"no peer", context.Background(), "", false},
+	} {
+		identity, ok := peerIdentity(tc.ctx)
+		if identity != tc.identity || ok != tc.ok {
+			t.Errorf("%s: got %q, %v, want %q, %v", tc.name, identity, ok, tc.identity, tc.ok)
+		}
+	}
+}
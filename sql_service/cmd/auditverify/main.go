@@ -0,0 +1,48 @@
+// Command auditverify walks the audit_log hash chains and exits with code 1
+// if any entry was altered, removed or inserted outside of sql_service, and
+// with code 2 if it could not check them, e.g. because the database stayed
+// unreachable for connectAttempts attempts.
+package main
+
+import (
+	"common/config"
+	"common/logging"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"os"
+	database "sql_service/database"
+	"sql_service/structs"
+)
+
+// connectAttempts bounds how long a scheduled check waits for the
+// database: RETRY_INTERVAL apart, so about 20 seconds by default.
+const connectAttempts = 5
+
+func main() {
+	if err := config.Load(&structs.Config, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New(structs.Config.LogLevel))
+
+	pool, err := database.ConnectAttempts(connectAttempts)
+	if err != nil {
+		slog.Error("Unable to connect to database", "attempts", connectAttempts, "error", err)
+		os.Exit(2)
+	}
+	store := database.NewPostgres(pool)
+
+	verified, err := store.VerifyAuditChain(context.Background())
+	var chainErr *database.ChainError
+	if errors.As(err, &chainErr) {
+		slog.Error("Audit chain is broken", "verified", verified, "entry_id", chainErr.ID, "reason", chainErr.Reason)
+		os.Exit(1)
+	}
+	if err != nil {
+		slog.Error("Failed to verify audit chain", "verified", verified, "error", err)
+		os.Exit(2)
+	}
+
+	slog.Info("Audit chain verified", "entries", verified)
+}
@@ -0,0 +1,84 @@
+// Command partitions maintains the monthly partitions of the transactions
+// table once, as sql_service's worker does on every PARTITION_INTERVAL: it
+// creates the partitions of the coming months and archives the ones past
+// the retention period. It prints a JSON report.
+//
+//	partitions [-months-ahead 3] [-retention MONTHS] [-dir DIR] [-drop]
+//	partitions -list
+//	partitions -archive NAME [-dir DIR] [-drop]
+//
+// Database and default settings come from the environment like
+// sql_service's own. -archive archives one partition that has ended,
+// whatever the retention.
+package main
+
+import (
+	"common/config"
+	"common/logging"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	database "sql_service/database"
+	"sql_service/partitions"
+	"sql_service/structs"
+)
+
+func main() {
+	if err := config.Load(&structs.Config, nil); err != nil {
+		log.Fatal(err)
+	}
+
+	monthsAhead := flag.Int("months-ahead", structs.Config.PartitionAhead, "months after the current one to create partitions for")
+	retention := flag.Int("retention", structs.Config.RetentionMonths, "archive partitions that ended this many months before the current one, 0 archives nothing")
+	dir := flag.String("dir", structs.Config.ArchiveDir, "directory to write archives to")
+	drop := flag.Bool("drop", structs.Config.ArchiveDrop, "drop archived partitions instead of leaving them detached")
+	list := flag.Bool("list", false, "list the partitions and exit")
+	archive := flag.String("archive", "", "archive only the partition with this name")
+	flag.Parse()
+
+	slog.SetDefault(logging.New(structs.Config.LogLevel))
+
+	store := database.NewPostgres(database.Connect())
+	ctx := context.Background()
+
+	if *list {
+		list, err := store.ListPartitions(ctx)
+		if err != nil {
+			logging.Fatal("Failed to list partitions", "error", err)
+		}
+		printJSON(list)
+		return
+	}
+
+	opts := partitions.Options{
+		MonthsAhead:     *monthsAhead,
+		RetentionMonths: *retention,
+		ArchiveDir:      *dir,
+		Drop:            *drop,
+	}
+	if *archive != "" {
+		p, err := partitions.Archive(ctx, store, *archive, opts)
+		if err != nil {
+			logging.Fatal("Failed to archive partition", "partition", *archive, "error", err)
+		}
+		printJSON(p)
+		return
+	}
+
+	report, err := partitions.Run(ctx, store, opts)
+	if err != nil {
+		logging.Fatal("Partition maintenance failed", "error", err)
+	}
+	printJSON(report)
+}
+
+func printJSON(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+// Command reconcile checks wallet balances against their transaction history
+// and lets an operator approve or reject the proposed adjustments.
+//
+//	reconcile [-wallet ID] [-propose]
+//	reconcile -approve ADJUSTMENT_ID -by NAME
+//	reconcile -reject ADJUSTMENT_ID -by NAME
+//
+// Database settings come from the environment like sql_service's own. The
+// check exits with code 1 when any wallet does not match.
+package main
+
+import (
+	"common/config"
+	"common/logging"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	database "sql_service/database"
+	"sql_service/reconcile"
+	"sql_service/structs"
+)
+
+func main() {
+	walletID := flag.Int("wallet", 0, "reconcile only this wallet")
+	propose := flag.Bool("propose", false, "propose an adjustment for every mismatch")
+	approve := flag.String("approve", "", "approve the pending adjustment with this id")
+	reject := flag.String("reject", "", "reject the pending adjustment with this id")
+	by := flag.String("by", "", "operator name recorded with the decision")
+	flag.Parse()
+
+	if err := config.Load(&structs.Config, nil); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New(structs.Config.LogLevel))
+
+	store := database.NewPostgres(database.Connect())
+	ctx := context.Background()
+
+	if *approve != "" || *reject != "" {
+		id, approved := *approve, true
+		if id == "" {
+			id, approved = *reject, false
+		}
+		adjustment, err := store.DecideAdjustment(ctx, id, approved, *by)
+		if err != nil {
+			logging.Fatal("Failed to decide adjustment", "adjustment_id", id, "error", err)
+		}
+		printJSON(adjustment)
+		return
+	}
+
+	report, err := reconcile.Run(ctx, store, reconcile.Options{
+		WalletID: *walletID,
+		Grace:    structs.Config.ReconcileGrace,
+		Propose:  *propose,
+	})
+	if err != nil {
+		logging.Fatal("Reconciliation failed", "error", err)
+	}
+	printJSON(report)
+
+	if len(report.Mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printJSON(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
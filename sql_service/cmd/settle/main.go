@@ -0,0 +1,117 @@
+// Command settle imports bank statement files (CSV or camt.053), matches
+// their entries to wallet transactions and marks the matched transactions
+// as settled. It prints a JSON report with the unmatched items on both sides.
+//
+//	settle [-format csv|camt053] [-amount-tolerance 0.01] [-date-tolerance 72h] [-dry-run] FILE...
+package main
+
+import (
+	"bufio"
+	"common/config"
+	"common/logging"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	database "sql_service/database"
+	"sql_service/settlement"
+	"sql_service/structs"
+	"time"
+)
+
+type fileReport struct {
+	File    string `json:"file"`
+	Account string `json:"account,omitempty"`
+	Settled int    `json:"settled"`
+	settlement.Report
+}
+
+func main() {
+	if err := config.Load(&structs.Config, nil); err != nil {
+		log.Fatal(err)
+	}
+
+	format := flag.String("format", "", "statement format (csv or camt053), detected from the file when empty")
+	amountTolerance := flag.Float64("amount-tolerance", structs.Config.SettleAmountTol, "largest amount difference a match allows")
+	dateTolerance := flag.Duration("date-tolerance", structs.Config.SettleDateTol, "largest booking date difference a match allows")
+	dryRun := flag.Bool("dry-run", false, "report matches without marking transactions as settled")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("usage: settle [flags] FILE...")
+	}
+
+	slog.SetDefault(logging.New(structs.Config.LogLevel))
+	store := database.NewPostgres(database.Connect())
+	ctx := context.Background()
+	tolerance := settlement.Tolerance{Amount: *amountTolerance, Date: *dateTolerance}
+
+	var reports []fileReport
+	for _, name := range flag.Args() {
+		statement, err := parseFile(name, *format)
+		if err != nil {
+			logging.Fatal("Failed to parse statement", "file", name, "error", err)
+		}
+
+		report := fileReport{File: name, Account: statement.Account}
+		if len(statement.Entries) > 0 {
+			from, to := period(statement.Entries)
+			candidates, err := store.UnsettledTransactions(ctx, from.Add(-tolerance.Date), to.Add(tolerance.Date+24*time.Hour))
+			if err != nil {
+				logging.Fatal("Failed to load transactions", "file", name, "error", err)
+			}
+			report.Report = settlement.MatchEntries(statement.Entries, candidates, tolerance)
+		}
+
+		if !*dryRun && len(report.Matched) > 0 {
+			if report.Settled, err = store.MarkSettled(ctx, report.Matched); err != nil {
+				logging.Fatal("Failed to mark transactions as settled", "file", name, "error", err)
+			}
+		}
+
+		slog.Info("Statement reconciled",
+			"file", name,
+			"entries", len(statement.Entries),
+			"matched", len(report.Matched),
+			"settled", report.Settled,
+			"unmatched_entries", len(report.UnmatchedEntries),
+			"unmatched_transactions", len(report.UnmatchedTransactions))
+		reports = append(reports, report)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(reports); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseFile(name, format string) (settlement.Statement, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return settlement.Statement{}, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if format == "" {
+		head, _ := r.Peek(512)
+		format = settlement.DetectFormat(name, head)
+	}
+	return settlement.Parse(format, r)
+}
+
+// period returns the first and last booking dates of the statement.
+func period(entries []settlement.Entry) (from, to time.Time) {
+	from, to = entries[0].BookingDate, entries[0].BookingDate
+	for _, e := range entries[1:] {
+		if e.BookingDate.Before(from) {
+			from = e.BookingDate
+		}
+		if e.BookingDate.After(to) {
+			to = e.BookingDate
+		}
+	}
+	return from, to
+}
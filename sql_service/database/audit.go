@@ -0,0 +1,167 @@
+package sql_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"sql_service/structs"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// genesisHash is the PrevHash of the first audit entry of a chain.
+var genesisHash = strings.Repeat("0", 64)
+
+// Every audit entry links to the previous entry of its wallet, so the
+// entries of different wallets are appended concurrently. Entries written
+// before migration 015 link to the previous entry overall instead and stay
+// verified along that global chain.
+const (
+	chainWallet = "wallet"
+	chainGlobal = "global"
+)
+
+// AuditHash computes the hash of an entry from its content and PrevHash.
+func AuditHash(e structs.AuditEntry) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%.2f|%.2f|%s|%s|%s|%s|%s",
+		e.PrevHash,
+		e.WalletID,
+		e.BeforeBalance,
+		e.AfterBalance,
+		e.CallerService,
+		e.Principal,
+		e.RequestID,
+		e.TransactionID,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)))
+	return hex.EncodeToString(sum[:])
+}
+
+// appendAudit links entry to the end of the chain of its wallet and inserts
+// it within tx, which must hold the lock on the wallet row so that no other
+// entry of the wallet is appended meanwhile.
+func appendAudit(ctx context.Context, tx pgx.Tx, entry structs.AuditEntry) error {
+	err := tx.QueryRow(ctx, `SELECT hash FROM audit_log WHERE wallet_id = $1 ORDER BY id DESC LIMIT 1`,
+		entry.WalletID).Scan(&entry.PrevHash)
+	if err == pgx.ErrNoRows {
+		entry.PrevHash = genesisHash
+	} else if err != nil {
+		return err
+	}
+
+	// Postgres keeps microseconds; hash what will be read back.
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+	entry.Hash = AuditHash(entry)
+
+	insertQuery := `
+		INSERT INTO audit_log (wallet_id, before_balance, after_balance, caller_service, principal,
+			request_id, transaction_id, created_at, prev_hash, hash, chain)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	_, err = tx.Exec(ctx, insertQuery, entry.WalletID, entry.BeforeBalance, entry.AfterBalance,
+		entry.CallerService, entry.Principal, entry.RequestID, entry.TransactionID,
+		entry.CreatedAt, entry.PrevHash, entry.Hash, chainWallet)
+	return err
+}
+
+const auditColumns = `id, wallet_id, before_balance, after_balance, caller_service, principal,
+	request_id, transaction_id, created_at, prev_hash, hash, chain`
+
+func scanAudit(row pgx.Row) (structs.AuditEntry, error) {
+	var e structs.AuditEntry
+	err := row.Scan(&e.ID, &e.WalletID, &e.BeforeBalance, &e.AfterBalance, &e.CallerService,
+		&e.Principal, &e.RequestID, &e.TransactionID, &e.CreatedAt, &e.PrevHash, &e.Hash, &e.Chain)
+	return e, err
+}
+
+// ChainError reports the first audit entry that breaks the hash chain.
+type ChainError struct {
+	ID     int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit entry %d: %s", e.ID, e.Reason)
+}
+
+// chainVerifier checks audit entries, fed in id order, against the hash
+// chains.
+type chainVerifier struct {
+	// global is the hash of the last globally chained entry and wallets
+	// the hash of the last entry of each wallet, whatever its chain.
+	global    string
+	wallets   map[int]string
+	perWallet bool
+	verified  int
+}
+
+func newChainVerifier() *chainVerifier {
+	return &chainVerifier{global: genesisHash, wallets: make(map[int]string)}
+}
+
+func (v *chainVerifier) check(entry structs.AuditEntry) error {
+	prevHash, ok := v.wallets[entry.WalletID]
+	if !ok {
+		prevHash = genesisHash
+	}
+	switch entry.Chain {
+	case chainWallet:
+		v.perWallet = true
+	case chainGlobal:
+		if v.perWallet {
+			return &ChainError{ID: entry.ID, Reason: "is chained globally after entries chained per wallet"}
+		}
+		prevHash = v.global
+	default:
+		return &ChainError{ID: entry.ID, Reason: fmt.Sprintf("unknown chain %q", entry.Chain)}
+	}
+
+	if entry.PrevHash != prevHash {
+		return &ChainError{ID: entry.ID, Reason: "does not link to the previous entry"}
+	}
+	if AuditHash(entry) != entry.Hash {
+		return &ChainError{ID: entry.ID, Reason: "content does not match its hash"}
+	}
+	if entry.Chain == chainGlobal {
+		v.global = entry.Hash
+	}
+	v.wallets[entry.WalletID] = entry.Hash
+	v.verified++
+	return nil
+}
+
+// VerifyAuditChain walks the whole audit log in order, recomputing every
+// hash.
+func (p *Postgres) VerifyAuditChain(ctx context.Context) (verified int, err error) {
+	if p.pool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log ORDER BY id`
+
+	ctx, span := startSpan(ctx, "VerifyAuditChain", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := p.pool.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	chain := newChainVerifier()
+	for rows.Next() {
+		entry, err := scanAudit(rows)
+		if err != nil {
+			return chain.verified, err
+		}
+		if err := chain.check(entry); err != nil {
+			return chain.verified, err
+		}
+	}
+
+	return chain.verified, rows.Err()
+}
@@ -0,0 +1,164 @@
+package sql_service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sql_service/structs"
+)
+
+func TestAuditHash(t *testing.T) {
+	entry := structs.AuditEntry{
+		WalletID:      7,
+		BeforeBalance: 100,
+		AfterBalance:  150.5,
+		CallerService: "transaction_service",
+		Principal:     "merchant",
+		RequestID:     "req-1",
+		TransactionID: "f47cbde3-98d8-47cb-a30b-1046b1f70b75",
+		CreatedAt:     time.Date(2026, 10, 19, 12, 30, 0, 123456000, time.UTC),
+		PrevHash:      genesisHash,
+	}
+
+	// The hash of entries already written must never change.
+	const want = "fd8006c038998d96e34ea03b369c03eb8ae841fa71b3cc1a7e9d6ac8ce89f9bf"
+	if got := AuditHash(entry); got != want {
+		t.Fatalf("AuditHash = %s, want %s", got, want)
+	}
+
+	same := entry
+	same.CreatedAt = entry.CreatedAt.In(time.FixedZone("UTC+3", 3*60*60))
+	same.ID, same.Hash, same.Chain = 42, "ignored", chainWallet
+	if AuditHash(same) != want {
+		t.Error("the hash depends on the time zone, the id, the stored hash or the chain")
+	}
+
+	for name, change := range map[string]func(*structs.AuditEntry){
+		"wallet":         func(e *structs.AuditEntry) { e.WalletID = 8 },
+		"before balance": func(e *structs.AuditEntry) { e.BeforeBalance = 100.01 },
+		"after balance":  func(e *structs.AuditEntry) { e.AfterBalance = 150.49 },
+		"caller":         func(e *structs.AuditEntry) { e.CallerService = "walletctl" },
+		"principal":      func(e *structs.AuditEntry) { e.Principal = "admin" },
+		"request":        func(e *structs.AuditEntry) { e.RequestID = "req-2" },
+		"transaction":    func(e *structs.AuditEntry) { e.TransactionID = "" },
+		"time":           func(e *structs.AuditEntry) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+		"previous hash":  func(e *structs.AuditEntry) { e.PrevHash = want },
+	} {
+		changed := entry
+		change(&changed)
+		if AuditHash(changed) == want {
+			t.Errorf("changing the %s keeps the hash", name)
+		}
+	}
+}
+
+// auditedMemory returns a store with a few balance changes of two wallets,
+// interleaved.
+func auditedMemory(t *testing.T) *Memory {
+	t.Helper()
+	store := NewMemory()
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := store.AddWallet(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, walletID := range []int{1, 2, 1, 1, 2} {
+		if err := store.UpdateBalance(ctx, walletID, float64(10*(i+1)), nil, structs.AuditEntry{CallerService: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	ctx := context.Background()
+	if verified, err := auditedMemory(t).VerifyAuditChain(ctx); err != nil || verified != 5 {
+		t.Fatalf("verified %d entries of an untouched log: %v", verified, err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(audit []structs.AuditEntry) []structs.AuditEntry
+		id     int64
+	}{
+		{"balance changed", func(audit []structs.AuditEntry) []structs.AuditEntry {
+			audit[2].AfterBalance = 1000
+			return audit
+		}, 3},
+		{"hash recomputed after a change", func(audit []structs.AuditEntry) []structs.AuditEntry {
+			audit[2].AfterBalance = 1000
+			audit[2].Hash = AuditHash(audit[2])
+			return audit
+		}, 4},
+		{"entry removed", func(audit []structs.AuditEntry) []structs.AuditEntry {
+			return append(audit[:2:2], audit[3:]...)
+		}, 4},
+		{"entries swapped", func(audit []structs.AuditEntry) []structs.AuditEntry {
+			audit[2], audit[3] = audit[3], audit[2]
+			return audit
+		}, 4},
+		{"chain changed", func(audit []structs.AuditEntry) []structs.AuditEntry {
+			audit[1].Chain = chainGlobal
+			return audit
+		}, 2},
+	}
+	for _, tt := range tests {
+		store := auditedMemory(t)
+		store.audit = tt.tamper(store.audit)
+
+		_, err := store.VerifyAuditChain(ctx)
+		var chainErr *ChainError
+		if !errors.As(err, &chainErr) || chainErr.ID != tt.id {
+			t.Errorf("%s: VerifyAuditChain = %v, want a ChainError at entry %d", tt.name, err, tt.id)
+		}
+	}
+}
+
+// TestVerifyAuditChainAcrossMigration verifies a log whose older entries
+// were chained globally and newer ones per wallet.
+func TestVerifyAuditChainAcrossMigration(t *testing.T) {
+	var audit []structs.AuditEntry
+	last := map[int]string{}
+	add := func(walletID int, chain string) {
+		entry := structs.AuditEntry{ID: int64(len(audit) + 1), WalletID: walletID, AfterBalance: float64(len(audit)), Chain: chain}
+		entry.PrevHash = genesisHash
+		switch {
+		case chain == chainGlobal && len(audit) > 0:
+			entry.PrevHash = audit[len(audit)-1].Hash
+		case chain == chainWallet && last[walletID] != "":
+			entry.PrevHash = last[walletID]
+		}
+		entry.Hash = AuditHash(entry)
+		last[walletID] = entry.Hash
+		audit = append(audit, entry)
+	}
+	add(1, chainGlobal)
+	add(2, chainGlobal)
+	add(1, chainGlobal)
+	add(2, chainWallet)
+	add(3, chainWallet)
+	add(1, chainWallet)
+
+	verify := func(entries []structs.AuditEntry) error {
+		chain := newChainVerifier()
+		for _, entry := range entries {
+			if err := chain.check(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := verify(audit); err != nil {
+		t.Fatal(err)
+	}
+
+	late := structs.AuditEntry{ID: 7, WalletID: 3, Chain: chainGlobal, PrevHash: audit[2].Hash}
+	late.Hash = AuditHash(late)
+	var chainErr *ChainError
+	if err := verify(append(audit, late)); !errors.As(err, &chainErr) || chainErr.ID != 7 {
+		t.Errorf("a globally chained entry after per-wallet ones: %v, want a ChainError at entry 7", err)
+	}
+}
@@ -0,0 +1,331 @@
+package sql_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"sql_service/structs"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// Batch modes. An all_or_nothing batch either applies every item or leaves
+// no balance changed; a best_effort batch applies whatever it can.
+const (
+	BatchAllOrNothing = "all_or_nothing"
+	BatchBestEffort   = "best_effort"
+)
+
+// batchItemResults are the statuses transaction-service may report for an
+// item. revert_failed means the item was applied and could not be undone.
+var batchItemResults = []string{"succeeded", "failed", "skipped", "reverted", "revert_failed"}
+
+var (
+	// ErrInvalidBatch is wrapped by errors about batches that cannot be
+	// created or updated as asked.
+	ErrInvalidBatch = errors.New("invalid batch")
+	// ErrBatchStarted is returned when a batch is started a second time.
+	ErrBatchStarted = errors.New("batch already started")
+)
+
+const batchQuery = `
+	SELECT b.batch_id::text, COALESCE(b.principal, ''), b.mode, b.status, b.total,
+		b.created_at, COALESCE(b.completed_at, 'epoch'),
+		COUNT(*) FILTER (WHERE i.status = 'succeeded'),
+		COUNT(*) FILTER (WHERE i.status = 'pending')
+	FROM batches b JOIN batch_items i ON i.batch_id = b.batch_id
+	WHERE b.batch_id = $1
+	GROUP BY b.batch_id
+`
+
+// CreateBatch stores a batch and its items as pending. Every wallet must
+// exist; the batch is rejected as a whole otherwise.
+func (p *Postgres) CreateBatch(ctx context.Context, principal, mode string, items []structs.BatchItem) (batch structs.Batch, err error) {
+	if p.pool == nil {
+		return batch, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		INSERT INTO batch_items (batch_id, item_index, wallet_id, type, amount, reference, status, updated_at)
+		SELECT $1::uuid, i, w, t, a, NULLIF(r, ''), 'pending', $7
+		FROM unnest($2::int[], $3::int[], $4::text[], $5::float8[], $6::text[]) AS u(i, w, t, a, r)
+	`
+
+	ctx, span := startSpan(ctx, "CreateBatch", query)
+	defer func() { endSpan(span, err) }()
+
+	if err = checkBatch(mode, items); err != nil {
+		return batch, err
+	}
+
+	var indexes, walletIDs []int32
+	var types, references []string
+	var amounts []float64
+	for i, item := range items {
+		indexes = append(indexes, int32(i))
+		walletIDs = append(walletIDs, int32(item.WalletID))
+		types = append(types, item.Type)
+		amounts = append(amounts, item.Amount)
+		references = append(references, item.Reference)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return batch, err
+	}
+	defer tx.Rollback(ctx)
+
+	var missing []int32
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(DISTINCT w ORDER BY w), '{}')
+		FROM unnest($1::int[]) AS w
+		WHERE NOT EXISTS (SELECT 1 FROM wallets WHERE wallet_id = w)
+	`, walletIDs).Scan(&missing)
+	if err != nil {
+		return batch, err
+	}
+	if len(missing) > 0 {
+		return batch, fmt.Errorf("wallets %v do not exist: %w", missing, ErrInvalidBatch)
+	}
+
+	batch = structs.Batch{
+		ID:        uuid.New().String(),
+		Principal: principal,
+		Mode:      mode,
+		Status:    "pending",
+		Total:     len(items),
+		Pending:   len(items),
+		CreatedAt: time.Now(),
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO batches (batch_id, principal, mode, status, total, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)
+	`, batch.ID, principal, mode, batch.Status, batch.Total, batch.CreatedAt)
+	if err != nil {
+		return batch, err
+	}
+
+	_, err = tx.Exec(ctx, query, batch.ID, indexes, walletIDs, types, amounts, references, batch.CreatedAt)
+	if err != nil {
+		return batch, fmt.Errorf("unable to insert batch items: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return batch, err
+	}
+
+	for i, item := range items {
+		item.Index, item.Status = i, "pending"
+		batch.Items = append(batch.Items, item)
+	}
+	return batch, nil
+}
+
+// checkBatch validates the mode and items of a new batch.
+func checkBatch(mode string, items []structs.BatchItem) error {
+	if mode != BatchAllOrNothing && mode != BatchBestEffort {
+		return fmt.Errorf("unknown mode %q: %w", mode, ErrInvalidBatch)
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no items: %w", ErrInvalidBatch)
+	}
+	for i, item := range items {
+		if item.Type != "deposit" && item.Type != "withdraw" {
+			return fmt.Errorf("item %d: unknown type %q: %w", i, item.Type, ErrInvalidBatch)
+		}
+		if item.Amount <= 0 {
+			return fmt.Errorf("item %d: amount must be greater than 0: %w", i, ErrInvalidBatch)
+		}
+	}
+	return nil
+}
+
+// GetBatch returns a batch with its progress, and its items when withItems
+// is set.
+func (p *Postgres) GetBatch(ctx context.Context, batchID string, withItems bool) (batch structs.Batch, err error) {
+	if p.pool == nil {
+		return batch, fmt.Errorf("database pool is not initialized")
+	}
+
+	ctx, span := startSpan(ctx, "GetBatch", batchQuery)
+	defer func() { endSpan(span, err) }()
+
+	id, err := StrToUuid(batchID)
+	if err != nil {
+		return batch, fmt.Errorf("batch %s: %w", batchID, ErrNotFound)
+	}
+
+	tx, err := p.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return batch, err
+	}
+	defer tx.Rollback(ctx)
+
+	batch, err = getBatch(ctx, tx, id, withItems)
+	return batch, err
+}
+
+func getBatch(ctx context.Context, tx pgx.Tx, id uuid.UUID, withItems bool) (batch structs.Batch, err error) {
+	err = tx.QueryRow(ctx, batchQuery, id).Scan(&batch.ID, &batch.Principal, &batch.Mode, &batch.Status,
+		&batch.Total, &batch.CreatedAt, &batch.CompletedAt, &batch.Succeeded, &batch.Pending)
+	if err == pgx.ErrNoRows {
+		return batch, fmt.Errorf("batch %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return batch, err
+	}
+	if batch.CompletedAt.Unix() == 0 {
+		batch.CompletedAt = time.Time{}
+	}
+	batch.Failed = batch.Total - batch.Succeeded - batch.Pending
+
+	if !withItems {
+		return batch, nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT item_index, wallet_id, type, amount, COALESCE(reference, ''), status,
+			COALESCE(transaction_id::text, ''), COALESCE(error, '')
+		FROM batch_items
+		WHERE batch_id = $1
+		ORDER BY item_index
+	`, id)
+	if err != nil {
+		return batch, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item structs.BatchItem
+		err = rows.Scan(&item.Index, &item.WalletID, &item.Type, &item.Amount, &item.Reference,
+			&item.Status, &item.TransactionID, &item.Error)
+		if err != nil {
+			return batch, err
+		}
+		batch.Items = append(batch.Items, item)
+	}
+
+	return batch, rows.Err()
+}
+
+// StartBatch moves a pending batch to processing and returns it with its
+// items. A batch is started once: a redelivered batch message gets
+// ErrBatchStarted.
+func (p *Postgres) StartBatch(ctx context.Context, batchID string) (batch structs.Batch, err error) {
+	if p.pool == nil {
+		return batch, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		UPDATE batches SET status = 'processing', started_at = now()
+		WHERE batch_id = $1 AND status = 'pending'
+	`
+
+	ctx, span := startSpan(ctx, "StartBatch", query)
+	defer func() { endSpan(span, err) }()
+
+	id, err := StrToUuid(batchID)
+	if err != nil {
+		return batch, fmt.Errorf("batch %s: %w", batchID, ErrNotFound)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return batch, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query, id)
+	if err != nil {
+		return batch, err
+	}
+
+	batch, err = getBatch(ctx, tx, id, tag.RowsAffected() == 1)
+	if err != nil {
+		return batch, err
+	}
+	if tag.RowsAffected() == 0 {
+		return batch, fmt.Errorf("batch %s is %s: %w", batchID, batch.Status, ErrBatchStarted)
+	}
+
+	return batch, tx.Commit(ctx)
+}
+
+// ReportBatchItem records the outcome of one item and rolls it up into the
+// batch status: processing while items are pending, then completed when
+// every item succeeded, failed when none changed a balance, and
+// partially_completed otherwise. It returns the new batch status.
+func (p *Postgres) ReportBatchItem(ctx context.Context, batchID string, index int, status, transactionID, errMsg string) (batchStatus string, err error) {
+	if p.pool == nil {
+		return "", fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		UPDATE batches b SET
+			status = CASE
+				WHEN c.pending > 0 THEN 'processing'
+				WHEN c.succeeded = b.total THEN 'completed'
+				WHEN c.applied = 0 THEN 'failed'
+				ELSE 'partially_completed' END,
+			completed_at = CASE WHEN c.pending = 0 THEN now() END
+		FROM (
+			SELECT COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+				COUNT(*) FILTER (WHERE status = 'succeeded') AS succeeded,
+				COUNT(*) FILTER (WHERE status IN ('succeeded', 'revert_failed')) AS applied
+			FROM batch_items WHERE batch_id = $1
+		) c
+		WHERE b.batch_id = $1
+		RETURNING b.status
+	`
+
+	ctx, span := startSpan(ctx, "ReportBatchItem", query)
+	defer func() { endSpan(span, err) }()
+
+	id, err := StrToUuid(batchID)
+	if err != nil {
+		return "", fmt.Errorf("batch %s: %w", batchID, ErrNotFound)
+	}
+	if !slices.Contains(batchItemResults, status) {
+		return "", fmt.Errorf("unknown item status %q: %w", status, ErrInvalidBatch)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `SELECT status FROM batches WHERE batch_id = $1 FOR UPDATE`, id).Scan(&batchStatus)
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("batch %s: %w", batchID, ErrNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	if batchStatus != "processing" {
+		return "", fmt.Errorf("batch %s is %s: %w", batchID, batchStatus, ErrInvalidBatch)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE batch_items
+		SET status = $3, transaction_id = NULLIF($4, '')::uuid, error = NULLIF($5, ''), updated_at = now()
+		WHERE batch_id = $1 AND item_index = $2
+	`, id, index, status, transactionID, errMsg)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", fmt.Errorf("batch %s item %d: %w", batchID, index, ErrNotFound)
+	}
+
+	if err = tx.QueryRow(ctx, query, id).Scan(&batchStatus); err != nil {
+		return "", err
+	}
+
+	return batchStatus, tx.Commit(ctx)
+}
@@ -0,0 +1,622 @@
+package sql_service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"sql_service/structs"
+
+	"github.com/google/uuid"
+)
+
+// Memory is a WalletStore, TransactionStore, BatchStore and AdjustmentStore
+// that keeps everything in memory, for tests of the code above the database. It
+// enforces what the schema does: amounts are rounded to cents and bounded
+// like DECIMAL(10, 2), transaction ids are unique UUIDs of existing wallets,
+// UpdateBalance leaves frozen wallets alone, and every balance change is
+// audited in a hash chain. Unlike Postgres it queues no webhook deliveries
+// and has no partitions.
+type Memory struct {
+	// mu stands in for the wallet row locks of Postgres; one lock is
+	// enough since nothing here blocks.
+	mu           sync.Mutex
+	wallets      map[int]structs.Wallet
+	nextWalletID int
+	transactions map[string]memoryTransaction
+	audit        []structs.AuditEntry
+	adjustments  map[string]memoryAdjustment
+	batches      map[string]structs.Batch
+}
+
+// memoryAdjustment keeps the last audit entry an adjustment accounts for,
+// like balance_adjustments.through_audit_id.
+type memoryAdjustment struct {
+	structs.Adjustment
+	throughAuditID int64
+}
+
+// memoryTransaction keeps the transaction time as Postgres does, so that
+// both return the same time, and created_at, which listings go by.
+type memoryTransaction struct {
+	structs.Transaction
+	time      string
+	createdAt time.Time
+}
+
+func newMemoryTransaction(t structs.Transaction, createdAt time.Time) memoryTransaction {
+	return memoryTransaction{
+		Transaction: t,
+		time:        formatTransactionTime(t.Time),
+		createdAt:   createdAt.UTC().Truncate(time.Microsecond),
+	}
+}
+
+var (
+	_ WalletStore      = (*Memory)(nil)
+	_ TransactionStore = (*Memory)(nil)
+	_ BatchStore       = (*Memory)(nil)
+	_ AdjustmentStore  = (*Memory)(nil)
+)
+
+func NewMemory() *Memory {
+	return &Memory{
+		wallets:      make(map[int]structs.Wallet),
+		nextWalletID: 1,
+		transactions: make(map[string]memoryTransaction),
+		adjustments:  make(map[string]memoryAdjustment),
+		batches:      make(map[string]structs.Batch),
+	}
+}
+
+// AddWallet creates a wallet and returns its id; ids start at 1 like the
+// wallet_id sequence.
+func (m *Memory) AddWallet(balance float64) (int, error) {
+	balance, err := toDecimal(balance)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.addWallet(balance).ID, nil
+}
+
+func (m *Memory) addWallet(balance float64) structs.Wallet {
+	w := structs.Wallet{ID: m.nextWalletID, Balance: balance}
+	m.nextWalletID++
+	m.wallets[w.ID] = w
+	return w
+}
+
+func (m *Memory) GetBalance(ctx context.Context, walletID int) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.wallets[walletID]
+	if !ok {
+		return 0, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	return w.Balance, nil
+}
+
+func (m *Memory) UpdateBalance(ctx context.Context, walletID int, newBalance float64, expected *float64, audit structs.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.wallets[walletID]
+	if !ok {
+		return fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	if !w.FrozenAt.IsZero() {
+		return fmt.Errorf("wallet %d: %w", walletID, ErrWalletFrozen)
+	}
+	if err := checkExpected(walletID, w.Balance, expected); err != nil {
+		return err
+	}
+	after, err := toDecimal(newBalance)
+	if err != nil {
+		return err
+	}
+
+	audit.CreatedAt = time.Now()
+	m.setBalance(w, after, audit)
+	return nil
+}
+
+// setBalance changes the balance of w and appends the audit entry.
+func (m *Memory) setBalance(w structs.Wallet, balance float64, audit structs.AuditEntry) {
+	audit.ID = int64(len(m.audit) + 1)
+	audit.WalletID = w.ID
+	audit.BeforeBalance = w.Balance
+	audit.AfterBalance = balance
+	audit.CreatedAt = audit.CreatedAt.UTC().Truncate(time.Microsecond)
+	audit.PrevHash = genesisHash
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		if m.audit[i].WalletID == w.ID {
+			audit.PrevHash = m.audit[i].Hash
+			break
+		}
+	}
+	audit.Chain = chainWallet
+	audit.Hash = AuditHash(audit)
+
+	w.Balance = balance
+	m.wallets[w.ID] = w
+	m.audit = append(m.audit, audit)
+}
+
+func (m *Memory) GetAuditLog(ctx context.Context, walletID int, limit int) ([]structs.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []structs.AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		if m.audit[i].WalletID == walletID {
+			entries = append(entries, m.audit[i])
+		}
+	}
+	return entries, nil
+}
+
+func (m *Memory) VerifyAuditChain(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chain := newChainVerifier()
+	for _, entry := range m.audit {
+		if err := chain.check(entry); err != nil {
+			return chain.verified, err
+		}
+	}
+	return chain.verified, nil
+}
+
+func (m *Memory) CreateTransaction(ctx context.Context, t structs.Transaction) error {
+	id, err := StrToUuid(t.ID)
+	if err != nil {
+		return fmt.Errorf("unable to insert transaction record: %w: %v", ErrInvalidValue, err)
+	}
+	t.ID = id.String()
+	if t.Amount, err = toDecimal(t.Amount); err != nil {
+		return fmt.Errorf("unable to insert transaction record: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.transactions[t.ID]; ok {
+		return fmt.Errorf("unable to insert transaction record: transaction %s: %w", t.ID, ErrAlreadyExists)
+	}
+	if _, ok := m.wallets[t.WalletID]; !ok {
+		return fmt.Errorf("unable to insert transaction record: wallet %d: %w", t.WalletID, ErrNotFound)
+	}
+
+	m.transactions[t.ID] = newMemoryTransaction(t, t.Time)
+	return nil
+}
+
+func (m *Memory) GetTransaction(ctx context.Context, id string) (structs.Transaction, error) {
+	transactionID, err := StrToUuid(id)
+	if err != nil {
+		return structs.Transaction{}, fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+
+	m.mu.Lock()
+	stored, ok := m.transactions[transactionID.String()]
+	m.mu.Unlock()
+	if !ok {
+		return structs.Transaction{}, fmt.Errorf("transaction %s: %w", id, ErrNotFound)
+	}
+
+	t := stored.Transaction
+	t.Time, err = parseTransactionTime(stored.time)
+	return t, err
+}
+
+func (m *Memory) GetWallet(ctx context.Context, walletID int) (structs.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.wallets[walletID]
+	if !ok {
+		return w, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	return w, nil
+}
+
+func (m *Memory) ListWallets(ctx context.Context, afterID, limit int) ([]structs.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var wallets []structs.Wallet
+	for id := afterID + 1; id < m.nextWalletID && len(wallets) < limit; id++ {
+		if w, ok := m.wallets[id]; ok {
+			wallets = append(wallets, w)
+		}
+	}
+	return wallets, nil
+}
+
+func (m *Memory) CreateWallet(ctx context.Context) (structs.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.addWallet(0), nil
+}
+
+func (m *Memory) SetFrozen(ctx context.Context, walletID int, frozen bool, reason string) (structs.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.wallets[walletID]
+	if !ok {
+		return w, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	switch {
+	case !frozen:
+		w.FrozenAt, w.FrozenReason = time.Time{}, ""
+	case w.FrozenAt.IsZero():
+		w.FrozenAt, w.FrozenReason = time.Now().UTC().Truncate(time.Microsecond), reason
+	default:
+		w.FrozenReason = reason
+	}
+	m.wallets[walletID] = w
+	return w, nil
+}
+
+func (m *Memory) AdjustBalance(ctx context.Context, walletID int, amount float64, reason string, audit structs.AuditEntry) (structs.Adjustment, error) {
+	adjustment, err := newAdjustment(walletID, amount, reason, audit.Principal)
+	if err != nil {
+		return adjustment, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.wallets[walletID]
+	if !ok {
+		return adjustment, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	if err := checkAdjustable(walletID, w.Balance, adjustment.Amount); err != nil {
+		return adjustment, err
+	}
+	balance, err := toDecimal(w.Balance + adjustment.Amount)
+	if err != nil {
+		return adjustment, err
+	}
+
+	audit.TransactionID = adjustment.TransactionID
+	audit.CreatedAt = adjustment.CreatedAt
+	m.setBalance(w, balance, audit)
+	m.adjustments[adjustment.ID] = memoryAdjustment{Adjustment: adjustment, throughAuditID: m.reconciledThrough(walletID)}
+
+	m.transactions[adjustment.TransactionID] = newMemoryTransaction(structs.Transaction{
+		ID:        adjustment.TransactionID,
+		WalletID:  walletID,
+		Amount:    adjustment.Amount,
+		Type:      "adjustment",
+		Status:    "Success",
+		Principal: adjustment.DecidedBy,
+		Time:      adjustment.CreatedAt,
+	}, adjustment.CreatedAt)
+	return adjustment, nil
+}
+
+func (m *Memory) ListTransactions(ctx context.Context, f structs.TransactionFilter) ([]structs.Transaction, string, error) {
+	q, err := newTransactionQuery(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m.mu.Lock()
+	var selected []memoryTransaction
+	for _, t := range m.transactions {
+		if (f.WalletID == 0 || t.WalletID == f.WalletID) &&
+			(f.Type == "" || t.Type == f.Type) &&
+			(f.Status == "" || t.Status == f.Status) &&
+			(q.from.IsZero() || !t.createdAt.Before(q.from)) &&
+			(q.to.IsZero() || t.createdAt.Before(q.to)) &&
+			(q.afterTime.IsZero() || t.createdAt.After(q.afterTime) || t.createdAt.Equal(q.afterTime) && t.ID > q.afterID) {
+			selected = append(selected, t)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(selected, func(i, j int) bool {
+		if !selected[i].createdAt.Equal(selected[j].createdAt) {
+			return selected[i].createdAt.Before(selected[j].createdAt)
+		}
+		return selected[i].ID < selected[j].ID
+	})
+	if len(selected) > f.Limit+1 {
+		selected = selected[:f.Limit+1]
+	}
+
+	transactions := make([]structs.Transaction, len(selected))
+	createdAt := make([]time.Time, len(selected))
+	for i, t := range selected {
+		transactions[i], createdAt[i] = t.Transaction, t.createdAt
+		if transactions[i].Time, err = parseTransactionTime(t.time); err != nil {
+			return nil, "", err
+		}
+	}
+	transactions, next := page(transactions, createdAt, f.Limit)
+	return transactions, next, nil
+}
+
+func (m *Memory) ReconcileWallets(ctx context.Context, walletID int, grace time.Duration) (structs.ReconcileReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := structs.ReconcileReport{CheckedAt: time.Now()}
+	for id := 1; id < m.nextWalletID; id++ {
+		w, ok := m.wallets[id]
+		if !ok || walletID != 0 && id != walletID {
+			continue
+		}
+		if lastChange := m.lastChange(id); !lastChange.IsZero() && report.CheckedAt.Sub(lastChange) < grace {
+			report.Skipped++
+			continue
+		}
+		report.Checked++
+
+		if mismatch := newMismatch(id, w.Balance, m.expectedBalance(id)); mismatch.Difference != 0 {
+			m.describeMismatch(&mismatch)
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+	}
+	return report, nil
+}
+
+// expectedBalance sums the successful transactions of a wallet.
+func (m *Memory) expectedBalance(walletID int) float64 {
+	var sum float64
+	for _, t := range m.transactions {
+		if t.WalletID == walletID && t.Status == "Success" {
+			sum += signedAmount(t.Transaction)
+		}
+	}
+	return roundCents(sum)
+}
+
+func (m *Memory) lastChange(walletID int) time.Time {
+	var last time.Time
+	for _, entry := range m.audit {
+		if entry.WalletID == walletID {
+			last = entry.CreatedAt
+		}
+	}
+	return last
+}
+
+// reconciledThrough is the last audit entry of a wallet an approved
+// adjustment accounts for.
+func (m *Memory) reconciledThrough(walletID int) int64 {
+	var through int64
+	for _, a := range m.adjustments {
+		if a.WalletID == walletID && a.Status == "approved" && a.throughAuditID > through {
+			through = a.throughAuditID
+		}
+	}
+	return through
+}
+
+func (m *Memory) describeMismatch(mismatch *structs.WalletMismatch) {
+	through := m.reconciledThrough(mismatch.WalletID)
+	for _, entry := range m.audit {
+		if entry.WalletID != mismatch.WalletID {
+			continue
+		}
+		mismatch.LastAuditID = entry.ID
+		if t, ok := m.transactions[entry.TransactionID]; entry.ID <= through || ok && t.Status == "Success" {
+			continue
+		}
+		if mismatch.WindowStart.IsZero() {
+			mismatch.WindowStart = entry.CreatedAt
+		}
+		mismatch.WindowEnd = entry.CreatedAt
+		if entry.TransactionID != "" {
+			mismatch.UnrecordedTransactionIDs = append(mismatch.UnrecordedTransactionIDs, entry.TransactionID)
+		}
+	}
+	for _, a := range m.adjustments {
+		if a.WalletID == mismatch.WalletID && a.Status == "pending" {
+			mismatch.AdjustmentID = a.ID
+		}
+	}
+}
+
+func (m *Memory) ProposeAdjustment(ctx context.Context, mismatch structs.WalletMismatch) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	adjustment := memoryAdjustment{
+		Adjustment: structs.Adjustment{
+			ID:        uuid.New().String(),
+			WalletID:  mismatch.WalletID,
+			Amount:    mismatch.Difference,
+			Reason:    proposalReason(mismatch),
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		},
+		throughAuditID: mismatch.LastAuditID,
+	}
+	for _, a := range m.adjustments {
+		if a.WalletID == mismatch.WalletID && a.Status == "pending" {
+			adjustment.ID = a.ID
+		}
+	}
+	m.adjustments[adjustment.ID] = adjustment
+	return adjustment.ID, nil
+}
+
+func (m *Memory) DecideAdjustment(ctx context.Context, adjustmentID string, approve bool, decidedBy string) (structs.Adjustment, error) {
+	id, err := checkDecision(adjustmentID, decidedBy)
+	if err != nil {
+		return structs.Adjustment{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	adjustment, ok := m.adjustments[id.String()]
+	if !ok {
+		return structs.Adjustment{}, fmt.Errorf("adjustment %s: %w", adjustmentID, ErrNotFound)
+	}
+	if err := checkPending(adjustment.Adjustment); err != nil {
+		return adjustment.Adjustment, err
+	}
+
+	decided := adjustment
+	decided.Status = "rejected"
+	decided.DecidedBy = decidedBy
+	decided.DecidedAt = time.Now()
+	if approve {
+		if err := checkProposal(decided.Adjustment, m.wallets[decided.WalletID].Balance, m.expectedBalance(decided.WalletID)); err != nil {
+			return adjustment.Adjustment, err
+		}
+		decided.Status = "approved"
+		decided.TransactionID = uuid.New().String()
+		m.transactions[decided.TransactionID] = newMemoryTransaction(structs.Transaction{
+			ID:        decided.TransactionID,
+			WalletID:  decided.WalletID,
+			Amount:    decided.Amount,
+			Type:      "adjustment",
+			Status:    "Success",
+			Principal: decidedBy,
+			Time:      decided.DecidedAt,
+		}, decided.DecidedAt)
+	}
+	m.adjustments[decided.ID] = decided
+	return decided.Adjustment, nil
+}
+
+func (m *Memory) CreateBatch(ctx context.Context, principal, mode string, items []structs.BatchItem) (structs.Batch, error) {
+	if err := checkBatch(mode, items); err != nil {
+		return structs.Batch{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch := structs.Batch{
+		ID:        uuid.New().String(),
+		Principal: principal,
+		Mode:      mode,
+		Status:    "pending",
+		Total:     len(items),
+		Pending:   len(items),
+		CreatedAt: time.Now(),
+	}
+	for i, item := range items {
+		if _, ok := m.wallets[item.WalletID]; !ok {
+			return structs.Batch{}, fmt.Errorf("wallet %d does not exist: %w", item.WalletID, ErrInvalidBatch)
+		}
+		amount, err := toDecimal(item.Amount)
+		if err != nil {
+			return structs.Batch{}, fmt.Errorf("item %d: %w", i, err)
+		}
+		item.Index, item.Amount, item.Status = i, amount, "pending"
+		item.TransactionID, item.Error = "", ""
+		batch.Items = append(batch.Items, item)
+	}
+	m.batches[batch.ID] = batch
+
+	return copyBatch(batch, true), nil
+}
+
+func (m *Memory) GetBatch(ctx context.Context, batchID string, withItems bool) (structs.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, ok := m.batches[batchID]
+	if !ok {
+		return structs.Batch{}, fmt.Errorf("batch %s: %w", batchID, ErrNotFound)
+	}
+	return copyBatch(batch, withItems), nil
+}
+
+func (m *Memory) StartBatch(ctx context.Context, batchID string) (structs.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, ok := m.batches[batchID]
+	if !ok {
+		return structs.Batch{}, fmt.Errorf("batch %s: %w", batchID, ErrNotFound)
+	}
+	if batch.Status != "pending" {
+		return copyBatch(batch, false), fmt.Errorf("batch %s is %s: %w", batchID, batch.Status, ErrBatchStarted)
+	}
+	batch.Status = "processing"
+	m.batches[batchID] = batch
+	return copyBatch(batch, true), nil
+}
+
+// ReportBatchItem rolls the items up into the batch status the way the
+// Postgres query does.
+func (m *Memory) ReportBatchItem(ctx context.Context, batchID string, index int, status, transactionID, errMsg string) (string, error) {
+	if !slices.Contains(batchItemResults, status) {
+		return "", fmt.Errorf("unknown item status %q: %w", status, ErrInvalidBatch)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, ok := m.batches[batchID]
+	if !ok {
+		return "", fmt.Errorf("batch %s: %w", batchID, ErrNotFound)
+	}
+	if batch.Status != "processing" {
+		return "", fmt.Errorf("batch %s is %s: %w", batchID, batch.Status, ErrInvalidBatch)
+	}
+	if index < 0 || index >= len(batch.Items) {
+		return "", fmt.Errorf("batch %s item %d: %w", batchID, index, ErrNotFound)
+	}
+	item := &batch.Items[index]
+	item.Status, item.TransactionID, item.Error = status, transactionID, errMsg
+
+	applied := 0
+	batch.Succeeded, batch.Pending = 0, 0
+	for _, item := range batch.Items {
+		switch item.Status {
+		case "pending":
+			batch.Pending++
+		case "succeeded":
+			batch.Succeeded++
+			applied++
+		case "revert_failed":
+			applied++
+		}
+	}
+	batch.Failed = batch.Total - batch.Succeeded - batch.Pending
+	switch {
+	case batch.Pending > 0:
+		batch.Status = "processing"
+	case batch.Succeeded == batch.Total:
+		batch.Status = "completed"
+	case applied == 0:
+		batch.Status = "failed"
+	default:
+		batch.Status = "partially_completed"
+	}
+	if batch.Pending == 0 {
+		batch.CompletedAt = time.Now()
+	}
+	m.batches[batchID] = batch
+
+	return batch.Status, nil
+}
+
+// copyBatch returns batch with its own copy of the items, or none.
+func copyBatch(batch structs.Batch, withItems bool) structs.Batch {
+	if withItems {
+		batch.Items = slices.Clone(batch.Items)
+	} else {
+		batch.Items = nil
+	}
+	return batch
+}
@@ -0,0 +1,256 @@
+package sql_service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"sql_service/structs"
+)
+
+func TestMemoryConcurrentUpdatesKeepAuditChain(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	wallets := []int{}
+	for i := 0; i < 3; i++ {
+		id, err := store.AddWallet(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallets = append(wallets, id)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 300; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			walletID := wallets[i%len(wallets)]
+			if err := store.UpdateBalance(ctx, walletID, float64(i), nil, structs.AuditEntry{CallerService: "test"}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if verified, err := store.VerifyAuditChain(ctx); err != nil || verified != 300 {
+		t.Fatalf("verified %d entries: %v", verified, err)
+	}
+
+	// Every entry starts from the balance the previous one of its wallet
+	// left behind.
+	last := map[int]float64{}
+	for _, entry := range store.audit {
+		if entry.BeforeBalance != last[entry.WalletID] {
+			t.Fatalf("entry %d of wallet %d starts at %v, want %v", entry.ID, entry.WalletID, entry.BeforeBalance, last[entry.WalletID])
+		}
+		last[entry.WalletID] = entry.AfterBalance
+	}
+}
+
+func TestMemoryListTransactionsByCreationTime(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	walletID, err := store.AddWallet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two transactions share a creation time at the turn of a month, where
+	// one partition ends and the next begins.
+	turn := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{turn.Add(-time.Hour), turn, turn, turn.Add(time.Hour)}
+	ids := []string{
+		"0b5e8f0c-5f4b-4d0c-9d7e-2f1c3a4b5c6d",
+		"2d7a0b2e-7b6d-4f2e-9f9a-4b3e5c6d7e8f",
+		"1c6f9a1d-6a5c-4e1d-8e8f-3a2d4b5c6d7e",
+		"3e8b1c3f-8c7e-4a3f-8a0b-5c4f6d7e8f9a",
+	}
+	for i, id := range ids {
+		err := store.CreateTransaction(ctx, structs.Transaction{ID: id, WalletID: walletID, Amount: 1, Type: "deposit", Status: "Success", Time: times[i]})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	token := ""
+	for {
+		list, next, err := store.ListTransactions(ctx, structs.TransactionFilter{Limit: 2, PageToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, transaction := range list {
+			got = append(got, transaction.ID)
+		}
+		if token = next; token == "" {
+			break
+		}
+	}
+	want := []string{ids[0], ids[2], ids[1], ids[3]}
+	if len(got) != len(want) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("listed %v, want %v", got, want)
+		}
+	}
+
+	// Bounds are instants, whatever their zone.
+	berlin := time.FixedZone("CET", 3600)
+	list, _, err := store.ListTransactions(ctx, structs.TransactionFilter{Limit: 10, From: turn.In(berlin), To: turn.Add(time.Hour).In(berlin)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Errorf("listed %d transactions created at %s, want 2", len(list), turn)
+	}
+}
+
+// TestMemoryDecideAdjustment checks that approval re-checks the wallet under
+// its lock: an adjustment is only written while the wallet is still off by
+// the proposed amount, and then settles the mismatch.
+func TestMemoryDecideAdjustment(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	walletID, err := store.AddWallet(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	propose := func() (string, structs.WalletMismatch) {
+		t.Helper()
+		report, err := store.ReconcileWallets(ctx, walletID, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Mismatches) != 1 {
+			t.Fatalf("got mismatches %+v, want one", report.Mismatches)
+		}
+		id, err := store.ProposeAdjustment(ctx, report.Mismatches[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id, report.Mismatches[0]
+	}
+
+	id, _ := propose()
+	if err := store.UpdateBalance(ctx, walletID, 120, nil, structs.AuditEntry{TransactionID: "5e7e68f0-30d6-4d4b-8411-7f75e3b63f27"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DecideAdjustment(ctx, id, true, "ops"); !errors.Is(err, ErrBalanceChanged) {
+		t.Fatalf("approving after the wallet changed: %v, want ErrBalanceChanged", err)
+	}
+
+	refreshed, mismatch := propose()
+	if refreshed != id || mismatch.AdjustmentID != id || mismatch.Difference != 120 {
+		t.Fatalf("proposing again gave %s for %+v, want the pending %s refreshed to 120", refreshed, mismatch, id)
+	}
+	adjustment, err := store.DecideAdjustment(ctx, id, true, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adjustment.Status != "approved" || adjustment.Amount != 120 || adjustment.TransactionID == "" || adjustment.DecidedBy != "ops" {
+		t.Errorf("approved %+v", adjustment)
+	}
+	if _, err := store.DecideAdjustment(ctx, id, false, "ops"); !errors.Is(err, ErrAlreadyDecided) {
+		t.Errorf("deciding twice: %v, want ErrAlreadyDecided", err)
+	}
+
+	report, err := store.ReconcileWallets(ctx, walletID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Fatalf("mismatches %+v after the approved adjustment, want none", report.Mismatches)
+	}
+
+	// Only changes after the ones the adjustment accounted for are
+	// reported as unrecorded.
+	if err := store.UpdateBalance(ctx, walletID, 125, nil, structs.AuditEntry{TransactionID: "6f8f79f1-41e7-4e5c-9522-8086f4c74f38"}); err != nil {
+		t.Fatal(err)
+	}
+	_, mismatch = propose()
+	if mismatch.Difference != 5 || len(mismatch.UnrecordedTransactionIDs) != 1 || mismatch.UnrecordedTransactionIDs[0] != "6f8f79f1-41e7-4e5c-9522-8086f4c74f38" {
+		t.Errorf("mismatch %+v, want 5 from the last change only", mismatch)
+	}
+}
+
+func TestMemoryReconcileSkipsRecentChanges(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	for _, balance := range []float64{10, 20} {
+		if _, err := store.AddWallet(balance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.UpdateBalance(ctx, 2, 30, nil, structs.AuditEntry{}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.ReconcileWallets(ctx, 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 1 || report.Skipped != 1 || len(report.Mismatches) != 1 || report.Mismatches[0].WalletID != 1 {
+		t.Errorf("report %+v, want wallet 1 checked and wallet 2 skipped", report)
+	}
+}
+
+func TestMemoryBatchStatus(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	if _, err := store.AddWallet(100); err != nil {
+		t.Fatal(err)
+	}
+	items := []structs.BatchItem{
+		{WalletID: 1, Type: "withdraw", Amount: 10},
+		{WalletID: 1, Type: "deposit", Amount: 10},
+	}
+
+	if _, err := store.CreateBatch(ctx, "merchant", BatchBestEffort, []structs.BatchItem{{WalletID: 2, Type: "deposit", Amount: 1}}); !errors.Is(err, ErrInvalidBatch) {
+		t.Errorf("batch for a missing wallet: %v", err)
+	}
+
+	tests := []struct {
+		results []string
+		want    string
+	}{
+		{[]string{"succeeded", "succeeded"}, "completed"},
+		{[]string{"succeeded", "failed"}, "partially_completed"},
+		{[]string{"revert_failed", "failed"}, "partially_completed"},
+		{[]string{"reverted", "failed"}, "failed"},
+		{[]string{"skipped", "failed"}, "failed"},
+	}
+	for _, tt := range tests {
+		batch, err := store.CreateBatch(ctx, "merchant", BatchAllOrNothing, items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.ReportBatchItem(ctx, batch.ID, 0, "succeeded", "", ""); !errors.Is(err, ErrInvalidBatch) {
+			t.Errorf("report before start: %v", err)
+		}
+		if _, err := store.StartBatch(ctx, batch.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.StartBatch(ctx, batch.ID); !errors.Is(err, ErrBatchStarted) {
+			t.Errorf("second start: %v", err)
+		}
+
+		var status string
+		for i, result := range tt.results {
+			if status, err = store.ReportBatchItem(ctx, batch.ID, i, result, "", ""); err != nil {
+				t.Fatal(err)
+			}
+			if i < len(tt.results)-1 && status != "processing" {
+				t.Errorf("%v: status %s with items pending", tt.results, status)
+			}
+		}
+		if status != tt.want {
+			t.Errorf("%v: status %s, want %s", tt.results, status, tt.want)
+		}
+	}
+}
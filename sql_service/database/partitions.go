@@ -0,0 +1,259 @@
+package sql_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sql_service/structs"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// partitionLockKey serializes partition maintenance between the replicas
+// of sql_service and the partitions command.
+const partitionLockKey = 7351042688124201
+
+// partitionLockTimeout bounds the wait for the lock on transactions that
+// creating and detaching a partition take, so that maintenance gives up
+// rather than queue every query behind it.
+const partitionLockTimeout = "5s"
+
+const partitionColumns = `name, from_ts, to_ts, COALESCE(archived_at, 'epoch'), COALESCE(archive_file, ''), COALESCE(rows, 0)`
+
+func scanPartition(row pgx.Row) (p structs.TransactionPartition, err error) {
+	err = row.Scan(&p.Name, &p.From, &p.To, &p.ArchivedAt, &p.ArchiveFile, &p.Rows)
+	if p.ArchivedAt.Unix() == 0 {
+		p.ArchivedAt = time.Time{}
+	}
+	return p, err
+}
+
+// partitionName names the partition starting at from after its month.
+func partitionName(from time.Time) string {
+	from = from.UTC()
+	return fmt.Sprintf("transactions_p%04d%02d", from.Year(), from.Month())
+}
+
+// nextMonth returns the first UTC month start after t.
+func nextMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ListPartitions returns the partitions of transactions, archived ones
+// included, oldest first.
+func (p *Postgres) ListPartitions(ctx context.Context) (partitions []structs.TransactionPartition, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `SELECT ` + partitionColumns + ` FROM transaction_partitions ORDER BY from_ts`
+	ctx, span := startSpan(ctx, "ListPartitions", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := p.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		partition, err := scanPartition(rows)
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, rows.Err()
+}
+
+// EnsurePartitions creates the monthly partitions of transactions missing
+// up to the end of the month monthsAhead months after the one of now, and
+// returns them. Each is created in a transaction of its own.
+func (p *Postgres) EnsurePartitions(ctx context.Context, now time.Time, monthsAhead int) (created []structs.TransactionPartition, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	ctx, span := startSpan(ctx, "EnsurePartitions", "CREATE TABLE ... PARTITION OF transactions")
+	defer func() { endSpan(span, err) }()
+
+	until := nextMonth(now).AddDate(0, monthsAhead, 0)
+	for {
+		partition, err := p.createNextPartition(ctx, until)
+		if err != nil {
+			return created, err
+		}
+		if partition.Name == "" {
+			return created, nil
+		}
+		created = append(created, partition)
+	}
+}
+
+// createNextPartition creates the partition after the newest one unless
+// that one already reaches until, in which case it returns the zero
+// partition.
+func (p *Postgres) createNextPartition(ctx context.Context, until time.Time) (partition structs.TransactionPartition, err error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return partition, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, partitionLockKey); err != nil {
+		return partition, err
+	}
+	var last time.Time
+	if err = tx.QueryRow(ctx, `SELECT MAX(to_ts) FROM transaction_partitions`).Scan(&last); err != nil {
+		return partition, err
+	}
+	if !last.Before(until) {
+		return structs.TransactionPartition{}, nil
+	}
+
+	partition = structs.TransactionPartition{Name: partitionName(last), From: last, To: nextMonth(last)}
+	if _, err = tx.Exec(ctx, `SET LOCAL lock_timeout = '`+partitionLockTimeout+`'`); err != nil {
+		return partition, err
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s PARTITION OF transactions FOR VALUES FROM ('%s') TO ('%s')`,
+		pgx.Identifier{partition.Name}.Sanitize(), partition.From.UTC().Format(time.RFC3339Nano), partition.To.Format(time.RFC3339Nano)))
+	if err != nil {
+		return partition, fmt.Errorf("unable to create partition %s: %w", partition.Name, err)
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO transaction_partitions (name, from_ts, to_ts) VALUES ($1, $2, $3)`, partition.Name, partition.From, partition.To)
+	if err != nil {
+		return partition, err
+	}
+	return partition, tx.Commit(ctx)
+}
+
+// PartitionStore keeps the monthly partitions of transactions.
+type PartitionStore interface {
+	// ListPartitions returns the partitions, archived ones included,
+	// oldest first.
+	ListPartitions(ctx context.Context) ([]structs.TransactionPartition, error)
+	// EnsurePartitions creates the partitions missing up to monthsAhead
+	// months after the one of now and returns them.
+	EnsurePartitions(ctx context.Context, now time.Time, monthsAhead int) ([]structs.TransactionPartition, error)
+	// ArchivePartition exports a partition that has ended to archive and
+	// detaches it, or drops it when drop is set.
+	ArchivePartition(ctx context.Context, name string, archive TransactionArchive, drop bool) (structs.TransactionPartition, error)
+}
+
+// TransactionArchive receives the rows of a partition being archived.
+type TransactionArchive interface {
+	Write(t structs.ArchivedTransaction) error
+	// Commit makes the rows written durable and returns where they are.
+	Commit() (string, error)
+}
+
+// ArchivePartition exports the rows of a partition that has ended to
+// archive, records the per-wallet totals reconciliation needs in their
+// place and detaches the partition, dropping it too when drop is set. All
+// of it happens in one transaction that keeps the partition from being
+// written meanwhile; if it fails, the partition stays as it was and the
+// archive written so far is left to be overwritten by the next attempt.
+// The ids of archived transactions stay registered in transaction_ids, so
+// they cannot be reused.
+func (p *Postgres) ArchivePartition(ctx context.Context, name string, archive TransactionArchive, drop bool) (partition structs.TransactionPartition, err error) {
+	if p.pool == nil {
+		return partition, fmt.Errorf("database pool is not initialized")
+	}
+
+	ctx, span := startSpan(ctx, "ArchivePartition", "ALTER TABLE transactions DETACH PARTITION ...")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return partition, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, partitionLockKey); err != nil {
+		return partition, err
+	}
+	partition, err = scanPartition(tx.QueryRow(ctx, `SELECT `+partitionColumns+` FROM transaction_partitions WHERE name = $1`, name))
+	if err == pgx.ErrNoRows {
+		return partition, fmt.Errorf("partition %s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return partition, err
+	}
+	if !partition.ArchivedAt.IsZero() {
+		return partition, fmt.Errorf("partition %s: %w: archived at %s", name, ErrAlreadyExists, partition.ArchivedAt)
+	}
+	if partition.To.After(time.Now()) {
+		return partition, fmt.Errorf("%w: partition %s runs until %s and may still be written", ErrInvalidValue, name, partition.To)
+	}
+
+	table := pgx.Identifier{name}.Sanitize()
+	if _, err = tx.Exec(ctx, `LOCK TABLE `+table+` IN SHARE MODE`); err != nil {
+		return partition, err
+	}
+	if partition.Rows, err = exportPartition(ctx, tx, table, archive); err != nil {
+		return partition, fmt.Errorf("unable to export partition %s: %w", name, err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transaction_archive_totals (partition_name, wallet_id, total)
+		SELECT $1, t.wallet_id, SUM(`+signedValue+`)
+		FROM `+table+` t
+		WHERE t.status = 'Success' AND t.wallet_id IS NOT NULL
+		GROUP BY t.wallet_id
+	`, name)
+	if err != nil {
+		return partition, err
+	}
+	if partition.ArchiveFile, err = archive.Commit(); err != nil {
+		return partition, fmt.Errorf("unable to write archive of partition %s: %w", name, err)
+	}
+
+	if _, err = tx.Exec(ctx, `SET LOCAL lock_timeout = '`+partitionLockTimeout+`'`); err != nil {
+		return partition, err
+	}
+	if _, err = tx.Exec(ctx, `ALTER TABLE transactions DETACH PARTITION `+table); err != nil {
+		return partition, fmt.Errorf("unable to detach partition %s: %w", name, err)
+	}
+	if drop {
+		if _, err = tx.Exec(ctx, `DROP TABLE `+table); err != nil {
+			return partition, err
+		}
+	}
+	err = tx.QueryRow(ctx, `
+		UPDATE transaction_partitions SET archived_at = now(), archive_file = $2, rows = $3
+		WHERE name = $1
+		RETURNING archived_at
+	`, name, partition.ArchiveFile, partition.Rows).Scan(&partition.ArchivedAt)
+	if err != nil {
+		return partition, err
+	}
+	return partition, tx.Commit(ctx)
+}
+
+func exportPartition(ctx context.Context, tx pgx.Tx, table string, archive TransactionArchive) (int64, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT transaction_id::text, wallet_id, value::text, type, status, transaction_time, principal,
+			settled_at, settlement_ref, created_at
+		FROM `+table+`
+		ORDER BY created_at, transaction_id
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var n int64
+	for rows.Next() {
+		var t structs.ArchivedTransaction
+		err := rows.Scan(&t.TransactionID, &t.WalletID, &t.Value, &t.Type, &t.Status, &t.TransactionTime,
+			&t.Principal, &t.SettledAt, &t.SettlementRef, &t.CreatedAt)
+		if err != nil {
+			return n, err
+		}
+		if err := archive.Write(t); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,446 @@
+package sql_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sql_service/structs"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Postgres implements every store of the service on one connection pool.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+var (
+	_ WalletStore      = (*Postgres)(nil)
+	_ TransactionStore = (*Postgres)(nil)
+	_ WebhookStore     = (*Postgres)(nil)
+	_ BatchStore       = (*Postgres)(nil)
+	_ AdjustmentStore  = (*Postgres)(nil)
+	_ PartitionStore   = (*Postgres)(nil)
+)
+
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+func (p *Postgres) GetBalance(ctx context.Context, walletID int) (balance float64, err error) {
+	if p.pool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		SELECT balance
+		FROM wallets
+		WHERE wallet_id  = $1
+	`
+
+	ctx, span := startSpan(ctx, "GetBalance", query)
+	defer func() { endSpan(span, err) }()
+
+	err = p.pool.QueryRow(ctx, query, walletID).Scan(&balance)
+	if err == pgx.ErrNoRows {
+		return 0, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func (p *Postgres) UpdateBalance(ctx context.Context, walletID int, newBalance float64, expected *float64, audit structs.AuditEntry) (err error) {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+
+	updateWalletQuery := `
+		UPDATE wallets
+		SET balance = $1
+		WHERE wallet_id = $2
+		RETURNING balance
+	`
+
+	ctx, span := startSpan(ctx, "UpdateBalance", updateWalletQuery)
+	defer func() { endSpan(span, err) }()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var frozen bool
+	err = tx.QueryRow(ctx, `SELECT balance, frozen_at IS NOT NULL FROM wallets WHERE wallet_id = $1 FOR UPDATE`, walletID).Scan(&audit.BeforeBalance, &frozen)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if frozen {
+		return fmt.Errorf("wallet %d: %w", walletID, ErrWalletFrozen)
+	}
+	if err = checkExpected(walletID, audit.BeforeBalance, expected); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, updateWalletQuery, newBalance, walletID).Scan(&audit.AfterBalance)
+	if err != nil {
+		return pgError(err)
+	}
+
+	audit.WalletID = walletID
+	audit.CreatedAt = time.Now()
+	if err = appendAudit(ctx, tx, audit); err != nil {
+		return fmt.Errorf("unable to write audit entry: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetAuditLog(ctx context.Context, walletID int, limit int) (entries []structs.AuditEntry, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log WHERE wallet_id = $1 ORDER BY id DESC LIMIT $2`
+
+	ctx, span := startSpan(ctx, "GetAuditLog", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := p.pool.Query(ctx, query, walletID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// CreateTransaction also queues the webhook deliveries of the transaction
+// in the same database transaction.
+func (p *Postgres) CreateTransaction(ctx context.Context, t structs.Transaction) (err error) {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+
+	insertTransactionQuery := `
+		INSERT INTO transactions (transaction_id, wallet_id, value, type, status, transaction_time, principal, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+	`
+
+	ctx, span := startSpan(ctx, "CreateTransaction", insertTransactionQuery)
+	defer func() { endSpan(span, err) }()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, insertTransactionQuery, t.ID, t.WalletID, t.Amount, t.Type, t.Status, formatTransactionTime(t.Time), t.Principal, t.Time)
+	if err != nil {
+		return fmt.Errorf("unable to insert transaction record: %w", pgError(err))
+	}
+
+	err = enqueueWebhookEvent(ctx, tx, webhookEventData{
+		TransactionID: t.ID,
+		WalletID:      t.WalletID,
+		Amount:        t.Amount,
+		Type:          t.Type,
+		Status:        t.Status,
+		Principal:     t.Principal,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to queue webhook deliveries: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetTransaction(ctx context.Context, id string) (t structs.Transaction, err error) {
+	if p.pool == nil {
+		return t, fmt.Errorf("database pool is not initialized")
+	}
+
+	transactionID, err := StrToUuid(id)
+	if err != nil {
+		return t, fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+
+	query := `
+        SELECT wallet_id, value, type, status, transaction_time, COALESCE(principal, '')
+        FROM transactions
+        WHERE transaction_id = $1
+            AND created_at = (SELECT created_at FROM transaction_ids WHERE transaction_id = $1)
+    `
+
+	ctx, span := startSpan(ctx, "GetTransaction", query)
+	defer func() { endSpan(span, err) }()
+
+	var transactionTime string
+	err = p.pool.QueryRow(ctx, query, transactionID).Scan(&t.WalletID, &t.Amount, &t.Type, &t.Status, &transactionTime, &t.Principal)
+	if err == pgx.ErrNoRows {
+		return t, fmt.Errorf("transaction %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return t, err
+	}
+
+	t.ID = transactionID.String()
+	t.Time, err = parseTransactionTime(transactionTime)
+	if err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+const walletColumns = `wallet_id, balance, COALESCE(frozen_at, 'epoch'), COALESCE(frozen_reason, '')`
+
+func scanWallet(row pgx.Row) (structs.Wallet, error) {
+	var w structs.Wallet
+	err := row.Scan(&w.ID, &w.Balance, &w.FrozenAt, &w.FrozenReason)
+	if w.FrozenAt.Unix() == 0 {
+		w.FrozenAt = time.Time{}
+	}
+	return w, err
+}
+
+func (p *Postgres) GetWallet(ctx context.Context, walletID int) (w structs.Wallet, err error) {
+	if p.pool == nil {
+		return w, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `SELECT ` + walletColumns + ` FROM wallets WHERE wallet_id = $1`
+
+	ctx, span := startSpan(ctx, "GetWallet", query)
+	defer func() { endSpan(span, err) }()
+
+	w, err = scanWallet(p.pool.QueryRow(ctx, query, walletID))
+	if err == pgx.ErrNoRows {
+		return w, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	return w, err
+}
+
+func (p *Postgres) ListWallets(ctx context.Context, afterID, limit int) (wallets []structs.Wallet, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `SELECT ` + walletColumns + ` FROM wallets WHERE wallet_id > $1 ORDER BY wallet_id LIMIT $2`
+
+	ctx, span := startSpan(ctx, "ListWallets", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := p.pool.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+
+	return wallets, rows.Err()
+}
+
+func (p *Postgres) CreateWallet(ctx context.Context) (w structs.Wallet, err error) {
+	if p.pool == nil {
+		return w, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `INSERT INTO wallets (balance) VALUES (0) RETURNING ` + walletColumns
+
+	ctx, span := startSpan(ctx, "CreateWallet", query)
+	defer func() { endSpan(span, err) }()
+
+	return scanWallet(p.pool.QueryRow(ctx, query))
+}
+
+// SetFrozen keeps the time a wallet was first frozen when it is frozen
+// again with another reason.
+func (p *Postgres) SetFrozen(ctx context.Context, walletID int, frozen bool, reason string) (w structs.Wallet, err error) {
+	if p.pool == nil {
+		return w, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		UPDATE wallets
+		SET frozen_at = CASE WHEN $2 THEN COALESCE(frozen_at, now()) END,
+			frozen_reason = CASE WHEN $2 THEN $3 END
+		WHERE wallet_id = $1
+		RETURNING ` + walletColumns
+
+	ctx, span := startSpan(ctx, "SetFrozen", query)
+	defer func() { endSpan(span, err) }()
+
+	w, err = scanWallet(p.pool.QueryRow(ctx, query, walletID, frozen, reason))
+	if err == pgx.ErrNoRows {
+		return w, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	return w, err
+}
+
+// AdjustBalance records the adjustment as approved at once. Its
+// through_audit_id is that of the last approved adjustment: it accounts for
+// no earlier balance change, so reconciliation still reports them.
+func (p *Postgres) AdjustBalance(ctx context.Context, walletID int, amount float64, reason string, audit structs.AuditEntry) (adjustment structs.Adjustment, err error) {
+	if p.pool == nil {
+		return adjustment, fmt.Errorf("database pool is not initialized")
+	}
+
+	updateWalletQuery := `
+		UPDATE wallets
+		SET balance = balance + $1
+		WHERE wallet_id = $2
+		RETURNING balance
+	`
+
+	ctx, span := startSpan(ctx, "AdjustBalance", updateWalletQuery)
+	defer func() { endSpan(span, err) }()
+
+	adjustment, err = newAdjustment(walletID, amount, reason, audit.Principal)
+	if err != nil {
+		return adjustment, err
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return adjustment, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `SELECT balance FROM wallets WHERE wallet_id = $1 FOR UPDATE`, walletID).Scan(&audit.BeforeBalance)
+	if err == pgx.ErrNoRows {
+		return adjustment, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	if err != nil {
+		return adjustment, err
+	}
+	if err = checkAdjustable(walletID, audit.BeforeBalance, adjustment.Amount); err != nil {
+		return adjustment, err
+	}
+
+	err = tx.QueryRow(ctx, updateWalletQuery, adjustment.Amount, walletID).Scan(&audit.AfterBalance)
+	if err != nil {
+		return adjustment, pgError(err)
+	}
+
+	audit.WalletID = walletID
+	audit.TransactionID = adjustment.TransactionID
+	audit.CreatedAt = adjustment.CreatedAt
+	if err = appendAudit(ctx, tx, audit); err != nil {
+		return adjustment, fmt.Errorf("unable to write audit entry: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (transaction_id, wallet_id, value, type, status, transaction_time, principal, created_at)
+		VALUES ($1, $2, $3, 'adjustment', 'Success', $4, $5, $6)
+	`, adjustment.TransactionID, walletID, adjustment.Amount, formatTransactionTime(adjustment.CreatedAt), adjustment.DecidedBy,
+		adjustment.CreatedAt)
+	if err != nil {
+		return adjustment, fmt.Errorf("unable to insert adjustment transaction: %w", pgError(err))
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO balance_adjustments (adjustment_id, wallet_id, amount, reason, status, through_audit_id,
+			transaction_id, created_at, decided_at, decided_by)
+		VALUES ($1, $2, $3, $4, $5,
+			(SELECT COALESCE(MAX(through_audit_id), 0) FROM balance_adjustments WHERE wallet_id = $2 AND status = 'approved'),
+			$6, $7, $7, $8)
+	`, adjustment.ID, walletID, adjustment.Amount, adjustment.Reason, adjustment.Status,
+		adjustment.TransactionID, adjustment.CreatedAt, adjustment.DecidedBy)
+	if err != nil {
+		return adjustment, err
+	}
+
+	err = tx.Commit(ctx)
+	return adjustment, err
+}
+
+func (p *Postgres) ListTransactions(ctx context.Context, f structs.TransactionFilter) (transactions []structs.Transaction, next string, err error) {
+	if p.pool == nil {
+		return nil, "", fmt.Errorf("database pool is not initialized")
+	}
+
+	// The time bounds are always set, infinite when the filter has none,
+	// so that partitions outside them are pruned; the id breaks ties.
+	query := `
+		SELECT transaction_id::text, wallet_id, value, type, status, transaction_time, COALESCE(principal, ''), created_at
+		FROM transactions
+		WHERE ($1 = 0 OR wallet_id = $1)
+			AND ($2 = '' OR type = $2)
+			AND ($3 = '' OR status = $3)
+			AND created_at >= $4 AND created_at < $5
+			AND created_at >= $6 AND (created_at, transaction_id::text) > ($6, $7)
+		ORDER BY created_at, transaction_id::text
+		LIMIT $8
+	`
+
+	ctx, span := startSpan(ctx, "ListTransactions", query)
+	defer func() { endSpan(span, err) }()
+
+	q, err := newTransactionQuery(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := p.pool.Query(ctx, query, f.WalletID, f.Type, f.Status,
+		timeBound(q.from, "-infinity"), timeBound(q.to, "infinity"), timeBound(q.afterTime, "-infinity"), q.afterID, f.Limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var createdAt []time.Time
+	for rows.Next() {
+		var t structs.Transaction
+		var transactionTime string
+		var created time.Time
+		if err := rows.Scan(&t.ID, &t.WalletID, &t.Amount, &t.Type, &t.Status, &transactionTime, &t.Principal, &created); err != nil {
+			return nil, "", err
+		}
+		if t.Time, err = parseTransactionTime(transactionTime); err != nil {
+			return nil, "", err
+		}
+		transactions = append(transactions, t)
+		createdAt = append(createdAt, created)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	transactions, next = page(transactions, createdAt, f.Limit)
+	return transactions, next, nil
+}
@@ -0,0 +1,329 @@
+package sql_service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"sql_service/structs"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// signedValue is the amount a transaction t adds to its wallet's balance.
+// Adjustments carry their own sign.
+const signedValue = `CASE t.type
+			WHEN 'deposit' THEN t.value
+			WHEN 'withdraw' THEN -t.value
+			WHEN 'adjustment' THEN t.value
+			ELSE 0 END`
+
+// expectedBalanceQuery sums the successful transactions of every wallet (or
+// only $1 when it is not 0), the ones of archived partitions from their
+// totals.
+const expectedBalanceQuery = `
+	SELECT w.wallet_id, w.balance,
+		COALESCE(SUM(` + signedValue + `), 0)
+			+ COALESCE((SELECT SUM(z.total) FROM transaction_archive_totals z WHERE z.wallet_id = w.wallet_id), 0),
+		(SELECT MAX(a.created_at) FROM audit_log a WHERE a.wallet_id = w.wallet_id)
+	FROM wallets w
+	LEFT JOIN transactions t ON t.wallet_id = w.wallet_id AND t.status = 'Success'
+	WHERE $1 = 0 OR w.wallet_id = $1
+	GROUP BY w.wallet_id, w.balance
+	ORDER BY w.wallet_id
+`
+
+// unrecordedChangesQuery lists the audit entries of a wallet without a
+// successful transaction, after the last approved adjustment. Entries from
+// before the end of the newest archived partition are left out: their
+// transactions are only in the archive.
+const unrecordedChangesQuery = `
+	SELECT a.id, a.transaction_id, a.created_at
+	FROM audit_log a
+	WHERE a.wallet_id = $1
+		AND a.created_at >= COALESCE((SELECT MAX(to_ts) FROM transaction_partitions WHERE archived_at IS NOT NULL), '-infinity')
+		AND a.id > (SELECT COALESCE(MAX(through_audit_id), 0) FROM balance_adjustments
+			WHERE wallet_id = $1 AND status = 'approved')
+		AND NOT EXISTS (SELECT 1 FROM transactions t
+			WHERE t.transaction_id::text = a.transaction_id AND t.status = 'Success')
+	ORDER BY a.id
+`
+
+// ReconcileWallets compares the balance of every wallet (or only walletID
+// when it is not 0) with the sum of its successful transactions. Wallets
+// changed within grace are skipped: their transaction may still be in flight.
+func (p *Postgres) ReconcileWallets(ctx context.Context, walletID int, grace time.Duration) (report structs.ReconcileReport, err error) {
+	if p.pool == nil {
+		return report, fmt.Errorf("database pool is not initialized")
+	}
+
+	ctx, span := startSpan(ctx, "ReconcileWallets", expectedBalanceQuery)
+	defer func() { endSpan(span, err) }()
+
+	// One snapshot for balances, transactions and the audit log.
+	tx, err := p.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback(ctx)
+
+	report.CheckedAt = time.Now()
+
+	rows, err := tx.Query(ctx, expectedBalanceQuery, walletID)
+	if err != nil {
+		return report, err
+	}
+	var candidates []structs.WalletMismatch
+	for rows.Next() {
+		var m structs.WalletMismatch
+		var lastChange *time.Time
+		if err = rows.Scan(&m.WalletID, &m.Balance, &m.ExpectedBalance, &lastChange); err != nil {
+			rows.Close()
+			return report, err
+		}
+		if lastChange != nil && report.CheckedAt.Sub(*lastChange) < grace {
+			report.Skipped++
+			continue
+		}
+		report.Checked++
+
+		if m = newMismatch(m.WalletID, m.Balance, m.ExpectedBalance); m.Difference != 0 {
+			candidates = append(candidates, m)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return report, err
+	}
+
+	for _, m := range candidates {
+		if err = describeMismatch(ctx, tx, &m); err != nil {
+			return report, err
+		}
+		report.Mismatches = append(report.Mismatches, m)
+	}
+
+	return report, nil
+}
+
+// describeMismatch fills in the window of unrecorded balance changes and the
+// pending adjustment, if one was already proposed.
+func describeMismatch(ctx context.Context, tx pgx.Tx, m *structs.WalletMismatch) error {
+	rows, err := tx.Query(ctx, unrecordedChangesQuery, m.WalletID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var auditID int64
+		var transactionID string
+		var createdAt time.Time
+		if err := rows.Scan(&auditID, &transactionID, &createdAt); err != nil {
+			return err
+		}
+		if m.WindowStart.IsZero() {
+			m.WindowStart = createdAt
+		}
+		m.WindowEnd = createdAt
+		if transactionID != "" {
+			m.UnrecordedTransactionIDs = append(m.UnrecordedTransactionIDs, transactionID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM audit_log WHERE wallet_id = $1`, m.WalletID).Scan(&m.LastAuditID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `SELECT adjustment_id::text FROM balance_adjustments WHERE wallet_id = $1 AND status = 'pending'`,
+		m.WalletID).Scan(&m.AdjustmentID)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+
+	return nil
+}
+
+// ProposeAdjustment records a pending adjustment that would make the
+// transaction history of the wallet add up to its balance. A wallet has at
+// most one pending adjustment; proposing again refreshes it.
+func (p *Postgres) ProposeAdjustment(ctx context.Context, m structs.WalletMismatch) (id string, err error) {
+	if p.pool == nil {
+		return "", fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		INSERT INTO balance_adjustments (adjustment_id, wallet_id, amount, reason, status, through_audit_id, created_at)
+		VALUES ($1, $2, $3, $4, 'pending', $5, $6)
+		ON CONFLICT (wallet_id) WHERE status = 'pending'
+		DO UPDATE SET amount = EXCLUDED.amount, reason = EXCLUDED.reason,
+			through_audit_id = EXCLUDED.through_audit_id, created_at = EXCLUDED.created_at
+		RETURNING adjustment_id::text
+	`
+
+	ctx, span := startSpan(ctx, "ProposeAdjustment", query)
+	defer func() { endSpan(span, err) }()
+
+	err = p.pool.QueryRow(ctx, query, uuid.New(), m.WalletID, m.Difference, proposalReason(m), m.LastAuditID, time.Now()).Scan(&id)
+	return id, err
+}
+
+// DecideAdjustment approves or rejects a pending adjustment. Approval
+// re-checks the wallet and writes the adjustment transaction in the same
+// database transaction, so a wallet that drifted further since the proposal
+// is never "corrected" with a stale amount.
+func (p *Postgres) DecideAdjustment(ctx context.Context, adjustmentID string, approve bool, decidedBy string) (adjustment structs.Adjustment, err error) {
+	if p.pool == nil {
+		return adjustment, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		SELECT adjustment_id::text, wallet_id, amount, reason, status, through_audit_id, created_at
+		FROM balance_adjustments
+		WHERE adjustment_id = $1
+		FOR UPDATE
+	`
+
+	ctx, span := startSpan(ctx, "DecideAdjustment", query)
+	defer func() { endSpan(span, err) }()
+
+	id, err := checkDecision(adjustmentID, decidedBy)
+	if err != nil {
+		return adjustment, err
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return adjustment, err
+	}
+	defer tx.Rollback(ctx)
+
+	var throughAuditID int64
+	err = tx.QueryRow(ctx, query, id).Scan(&adjustment.ID, &adjustment.WalletID, &adjustment.Amount,
+		&adjustment.Reason, &adjustment.Status, &throughAuditID, &adjustment.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return adjustment, fmt.Errorf("adjustment %s: %w", adjustmentID, ErrNotFound)
+	}
+	if err != nil {
+		return adjustment, err
+	}
+	if err = checkPending(adjustment); err != nil {
+		return adjustment, err
+	}
+
+	adjustment.Status = "rejected"
+	adjustment.DecidedBy = decidedBy
+	adjustment.DecidedAt = time.Now()
+
+	if approve {
+		// Lock the wallet so no balance change slips in between the check
+		// and the adjustment transaction.
+		var balance, expected float64
+		if _, err = tx.Exec(ctx, `SELECT 1 FROM wallets WHERE wallet_id = $1 FOR UPDATE`, adjustment.WalletID); err != nil {
+			return adjustment, err
+		}
+		err = tx.QueryRow(ctx, expectedBalanceQuery+` LIMIT 1`, adjustment.WalletID).Scan(&adjustment.WalletID, &balance, &expected, new(*time.Time))
+		if err != nil {
+			return adjustment, err
+		}
+		if err = checkProposal(adjustment, balance, expected); err != nil {
+			return adjustment, err
+		}
+
+		adjustment.Status = "approved"
+		adjustment.TransactionID = uuid.New().String()
+		_, err = tx.Exec(ctx, `
+			INSERT INTO transactions (transaction_id, wallet_id, value, type, status, transaction_time, principal, created_at)
+			VALUES ($1, $2, $3, 'adjustment', 'Success', $4, $5, $6)
+		`, adjustment.TransactionID, adjustment.WalletID, adjustment.Amount,
+			formatTransactionTime(adjustment.DecidedAt), decidedBy, adjustment.DecidedAt)
+		if err != nil {
+			return adjustment, fmt.Errorf("unable to insert adjustment transaction: %v", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE balance_adjustments
+		SET status = $2, transaction_id = NULLIF($3, '')::uuid, decided_at = $4, decided_by = $5
+		WHERE adjustment_id = $1
+	`, id, adjustment.Status, adjustment.TransactionID, adjustment.DecidedAt, decidedBy)
+	if err != nil {
+		return adjustment, err
+	}
+
+	err = tx.Commit(ctx)
+	return adjustment, err
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// signedAmount is what a transaction adds to its wallet's balance, like
+// signedValue.
+func signedAmount(t structs.Transaction) float64 {
+	switch t.Type {
+	case "deposit", "adjustment":
+		return t.Amount
+	case "withdraw":
+		return -t.Amount
+	}
+	return 0
+}
+
+// newMismatch compares the balance of a wallet with what its transactions
+// add up to, to the cent.
+func newMismatch(walletID int, balance, expected float64) structs.WalletMismatch {
+	return structs.WalletMismatch{
+		WalletID:        walletID,
+		Balance:         balance,
+		ExpectedBalance: expected,
+		Difference:      roundCents(balance - expected),
+	}
+}
+
+func proposalReason(m structs.WalletMismatch) string {
+	reason := fmt.Sprintf("reconciliation: balance %.2f, transactions add up to %.2f", m.Balance, m.ExpectedBalance)
+	if !m.WindowStart.IsZero() {
+		reason += fmt.Sprintf(", unrecorded changes %s - %s",
+			m.WindowStart.UTC().Format(time.RFC3339), m.WindowEnd.UTC().Format(time.RFC3339))
+	}
+	return reason
+}
+
+// checkDecision validates the arguments of DecideAdjustment.
+func checkDecision(adjustmentID, decidedBy string) (uuid.UUID, error) {
+	id, err := StrToUuid(adjustmentID)
+	if err != nil {
+		return id, fmt.Errorf("%w: adjustment id: %v", ErrInvalidValue, err)
+	}
+	if decidedBy == "" {
+		return id, fmt.Errorf("%w: decided_by is required", ErrInvalidValue)
+	}
+	return id, nil
+}
+
+// checkPending refuses to decide an adjustment twice.
+func checkPending(adjustment structs.Adjustment) error {
+	if adjustment.Status != "pending" {
+		return fmt.Errorf("adjustment %s: %w: it is %s", adjustment.ID, ErrAlreadyDecided, adjustment.Status)
+	}
+	return nil
+}
+
+// checkProposal refuses to approve an adjustment once the wallet is no
+// longer off by the proposed amount: balance and expected are read with the
+// wallet locked, so approving cannot "correct" it with a stale amount.
+func checkProposal(adjustment structs.Adjustment, balance, expected float64) error {
+	if difference := roundCents(balance - expected); difference != adjustment.Amount {
+		return fmt.Errorf("wallet %d changed since adjustment %s was proposed: %w: difference is now %.2f, proposed %.2f; run reconciliation again",
+			adjustment.WalletID, adjustment.ID, ErrBalanceChanged, difference, adjustment.Amount)
+	}
+	return nil
+}
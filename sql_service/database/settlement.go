@@ -0,0 +1,89 @@
+package sql_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sql_service/settlement"
+)
+
+// UnsettledTransactions returns the successful deposits and withdrawals made
+// between from and to that no statement has settled yet.
+func (p *Postgres) UnsettledTransactions(ctx context.Context, from, to time.Time) (candidates []settlement.Candidate, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		SELECT transaction_id::text, wallet_id, value, type, transaction_time
+		FROM transactions
+		WHERE status = 'Success'
+			AND type IN ('deposit', 'withdraw')
+			AND settled_at IS NULL
+			AND transaction_time::timestamp BETWEEN $1 AND $2
+		ORDER BY transaction_time
+	`
+
+	ctx, span := startSpan(ctx, "UnsettledTransactions", query)
+	defer func() { endSpan(span, err) }()
+
+	// transaction_time is stored as local wall clock time without a zone.
+	rows, err := p.pool.Query(ctx, query, from.Local(), to.Local())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c settlement.Candidate
+		var transactionTime string
+		if err := rows.Scan(&c.TransactionID, &c.WalletID, &c.Amount, &c.Type, &transactionTime); err != nil {
+			return nil, err
+		}
+		if c.Time, err = time.ParseInLocation("2006-01-02 15:04:05", transactionTime, time.Local); err != nil {
+			return nil, fmt.Errorf("transaction %s: %v", c.TransactionID, err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// MarkSettled records the statement entry that settled each matched
+// transaction. Transactions settled in the meantime are left alone; the
+// number actually updated is returned.
+func (p *Postgres) MarkSettled(ctx context.Context, matches []settlement.Match) (settled int, err error) {
+	if p.pool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		UPDATE transactions
+		SET settled_at = $2, settlement_ref = $3
+		WHERE transaction_id = $1 AND settled_at IS NULL
+	`
+
+	ctx, span := startSpan(ctx, "MarkSettled", query)
+	defer func() { endSpan(span, err) }()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, m := range matches {
+		tag, err := tx.Exec(ctx, query, m.Transaction.TransactionID, m.Entry.BookingDate, m.Entry.Reference)
+		if err != nil {
+			return 0, fmt.Errorf("unable to settle transaction %s: %v", m.Transaction.TransactionID, err)
+		}
+		settled += int(tag.RowsAffected())
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return settled, nil
+}
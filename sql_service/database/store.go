@@ -0,0 +1,285 @@
+package sql_service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"sql_service/structs"
+	"sqlapi/amount"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+)
+
+var (
+	// ErrAlreadyExists is wrapped by errors about a row whose key is taken.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalidValue is wrapped by errors about values a column cannot
+	// hold: malformed UUIDs and amounts outside DECIMAL(10, 2).
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrBalanceChanged is wrapped by errors about a conditional update of
+	// a wallet whose balance is no longer the expected one.
+	ErrBalanceChanged = errors.New("balance changed")
+	// ErrWalletFrozen is wrapped by errors about balance changes of a
+	// frozen wallet.
+	ErrWalletFrozen = errors.New("wallet is frozen")
+	// ErrAlreadyDecided is wrapped by errors about deciding an adjustment
+	// that is no longer pending.
+	ErrAlreadyDecided = errors.New("adjustment already decided")
+)
+
+// WalletStore keeps wallet balances and their audit trail.
+type WalletStore interface {
+	// GetBalance returns the balance of a wallet; ErrNotFound if it does
+	// not exist.
+	GetBalance(ctx context.Context, walletID int) (float64, error)
+	// UpdateBalance sets the balance of a wallet and appends an audit entry
+	// with the previous and new balance, atomically and with the wallet
+	// locked against concurrent updates. When expected is not nil the
+	// balance is only set if it still equals *expected, ErrBalanceChanged
+	// otherwise. audit carries who asked for the change (caller, principal,
+	// request and transaction ids).
+	UpdateBalance(ctx context.Context, walletID int, newBalance float64, expected *float64, audit structs.AuditEntry) error
+	// GetAuditLog returns the newest limit entries of a wallet, newest
+	// first.
+	GetAuditLog(ctx context.Context, walletID int, limit int) ([]structs.AuditEntry, error)
+	// GetWallet returns a wallet; ErrNotFound if it does not exist.
+	GetWallet(ctx context.Context, walletID int) (structs.Wallet, error)
+	// ListWallets returns up to limit wallets with an id above afterID, in
+	// id order.
+	ListWallets(ctx context.Context, afterID, limit int) ([]structs.Wallet, error)
+	// CreateWallet creates an empty wallet.
+	CreateWallet(ctx context.Context) (structs.Wallet, error)
+	// SetFrozen freezes a wallet for reason or unfreezes it. UpdateBalance
+	// of a frozen wallet fails with ErrWalletFrozen.
+	SetFrozen(ctx context.Context, walletID int, frozen bool, reason string) (structs.Wallet, error)
+	// AdjustBalance adds amount to the balance of a wallet, frozen or not,
+	// as an approved adjustment for reason by audit.Principal. The
+	// adjustment, its transaction and the audit entry are written
+	// atomically. It fails with ErrInvalidValue without a reason or when
+	// the balance would drop below zero.
+	AdjustBalance(ctx context.Context, walletID int, amount float64, reason string, audit structs.AuditEntry) (structs.Adjustment, error)
+	// VerifyAuditChain recomputes the hash of every audit entry in order.
+	// It returns the number of verified entries and a *ChainError at the
+	// first one that was modified, removed or inserted out of band.
+	VerifyAuditChain(ctx context.Context) (int, error)
+}
+
+// TransactionStore records deposits and withdrawals.
+type TransactionStore interface {
+	// CreateTransaction records t. It fails with ErrAlreadyExists when the
+	// id is taken and ErrNotFound when the wallet does not exist.
+	CreateTransaction(ctx context.Context, t structs.Transaction) error
+	// GetTransaction returns a transaction; ErrNotFound if it does not
+	// exist.
+	GetTransaction(ctx context.Context, id string) (structs.Transaction, error)
+	// ListTransactions returns a page of the transactions f selects, in
+	// time order, and the token of the next page; the token is empty on
+	// the last page.
+	ListTransactions(ctx context.Context, f structs.TransactionFilter) ([]structs.Transaction, string, error)
+}
+
+// WebhookStore keeps the webhooks of wallets and the outbox of their
+// deliveries.
+type WebhookStore interface {
+	// CreateWebhook registers url for the events of a wallet and generates
+	// the secret deliveries are signed with.
+	CreateWebhook(ctx context.Context, walletID int, url string, principal string) (structs.Webhook, error)
+	ListWebhooks(ctx context.Context, walletID int) ([]structs.Webhook, error)
+	// DeleteWebhook removes a webhook together with its deliveries.
+	DeleteWebhook(ctx context.Context, walletID int, webhookID string) error
+	// EnableWebhook turns a webhook disabled for failing back on.
+	EnableWebhook(ctx context.Context, walletID int, webhookID string) (structs.Webhook, error)
+	// ListWebhookDeliveries returns the latest deliveries of a webhook,
+	// newest first.
+	ListWebhookDeliveries(ctx context.Context, walletID int, webhookID string, limit int) ([]structs.WebhookDelivery, error)
+	// ListFailedWebhookDeliveries returns the latest deliveries that ran
+	// out of attempts, of a wallet or of every wallet when walletID is 0.
+	ListFailedWebhookDeliveries(ctx context.Context, walletID int, limit int) ([]structs.WebhookDelivery, error)
+	// RedeliverWebhook queues a delivery again with a fresh attempt budget.
+	RedeliverWebhook(ctx context.Context, walletID int, webhookID, deliveryID string) (structs.WebhookDelivery, error)
+	// ClaimWebhookDeliveries reserves up to limit due deliveries for lease.
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]structs.WebhookDelivery, error)
+	// ReportWebhookDelivery logs an attempt and schedules the delivery
+	// according to policy. It tells whether the attempt disabled the
+	// webhook.
+	ReportWebhookDelivery(ctx context.Context, attempt structs.WebhookAttempt, policy WebhookRetryPolicy) (bool, error)
+}
+
+// BatchStore keeps batches of payments and the progress of their items.
+type BatchStore interface {
+	// CreateBatch stores a batch and its items as pending; ErrNotFound if
+	// a wallet does not exist.
+	CreateBatch(ctx context.Context, principal, mode string, items []structs.BatchItem) (structs.Batch, error)
+	// GetBatch returns a batch, with its items when withItems is set.
+	GetBatch(ctx context.Context, batchID string, withItems bool) (structs.Batch, error)
+	// StartBatch moves a pending batch to processing and returns it with
+	// its items; ErrBatchStarted if it was started before.
+	StartBatch(ctx context.Context, batchID string) (structs.Batch, error)
+	// ReportBatchItem records the outcome of an item and returns the new
+	// status of the batch.
+	ReportBatchItem(ctx context.Context, batchID string, index int, status, transactionID, errMsg string) (string, error)
+}
+
+// AdjustmentStore reconciles balances with their transactions and keeps the
+// adjustments proposed to correct them.
+type AdjustmentStore interface {
+	// ReconcileWallets compares the balance of every wallet, or only of
+	// walletID when it is not 0, with its successful transactions,
+	// skipping wallets changed within grace.
+	ReconcileWallets(ctx context.Context, walletID int, grace time.Duration) (structs.ReconcileReport, error)
+	// ProposeAdjustment records a pending adjustment of the difference of
+	// m; a wallet has at most one.
+	ProposeAdjustment(ctx context.Context, m structs.WalletMismatch) (string, error)
+	// DecideAdjustment approves or rejects a pending adjustment. Approval
+	// writes its transaction only if the wallet is still off by the
+	// proposed amount.
+	DecideAdjustment(ctx context.Context, adjustmentID string, approve bool, decidedBy string) (structs.Adjustment, error)
+}
+
+// transactionTimeLayout is how transaction_time is stored: local wall clock
+// time with hundredths of a second and no zone.
+const transactionTimeLayout = "2006-01-02 15:04:05.00"
+
+func formatTransactionTime(t time.Time) string {
+	return t.Format(transactionTimeLayout)
+}
+
+func parseTransactionTime(s string) (time.Time, error) {
+	// Parsing accepts the fraction without naming it in the layout, which
+	// also reads the rows written before it was stored.
+	return time.Parse("2006-01-02 15:04:05", s)
+}
+
+// toDecimal rounds v the way Postgres stores it in a DECIMAL(10, 2) column
+// and fails like Postgres when it does not fit.
+func toDecimal(v float64) (float64, error) {
+	rounded := math.Round(v*100) / 100
+	if math.IsNaN(v) || math.Abs(rounded) > amount.Max {
+		return 0, fmt.Errorf("%w: numeric field overflow", ErrInvalidValue)
+	}
+	return rounded, nil
+}
+
+// checkExpected compares balances to the cent, like the DECIMAL(10, 2)
+// column holds them.
+func checkExpected(walletID int, balance float64, expected *float64) error {
+	if expected != nil && math.Round(balance*100) != math.Round(*expected*100) {
+		return fmt.Errorf("wallet %d: %w: balance is %.2f, expected %.2f", walletID, ErrBalanceChanged, balance, *expected)
+	}
+	return nil
+}
+
+// newAdjustment checks a manual adjustment and fills in its ids and
+// times.
+func newAdjustment(walletID int, amount float64, reason, operator string) (structs.Adjustment, error) {
+	amount, err := toDecimal(amount)
+	if err != nil {
+		return structs.Adjustment{}, err
+	}
+	if amount == 0 {
+		return structs.Adjustment{}, fmt.Errorf("%w: the adjustment amount is zero", ErrInvalidValue)
+	}
+	if strings.TrimSpace(reason) == "" || operator == "" {
+		return structs.Adjustment{}, fmt.Errorf("%w: an adjustment needs a reason and an operator", ErrInvalidValue)
+	}
+
+	now := time.Now()
+	return structs.Adjustment{
+		ID:            uuid.New().String(),
+		WalletID:      walletID,
+		Amount:        amount,
+		Reason:        reason,
+		Status:        "approved",
+		TransactionID: uuid.New().String(),
+		CreatedAt:     now,
+		DecidedAt:     now,
+		DecidedBy:     operator,
+	}, nil
+}
+
+// checkAdjustable refuses to take a balance below zero, which no
+// withdrawal can do either.
+func checkAdjustable(walletID int, balance, amount float64) error {
+	if math.Round((balance+amount)*100) < 0 {
+		return fmt.Errorf("wallet %d: %w: adjusting %.2f by %.2f leaves a negative balance", walletID, ErrInvalidValue, balance, amount)
+	}
+	return nil
+}
+
+// transactionQuery is a TransactionFilter in terms of the stored columns.
+// Transactions are listed by created_at, which the table is partitioned
+// by, so a listing bounded in time only reads the partitions it covers.
+type transactionQuery struct {
+	from, to  time.Time
+	afterTime time.Time
+	afterID   string
+}
+
+func newTransactionQuery(f structs.TransactionFilter) (q transactionQuery, err error) {
+	q.from, q.to = f.From, f.To
+	if f.PageToken != "" {
+		q.afterTime, q.afterID, err = decodePageToken(f.PageToken)
+	}
+	return q, err
+}
+
+// timeBound formats t for a timestamptz parameter; the zero time is
+// unbounded in the direction of infinity.
+func timeBound(t time.Time, infinity string) string {
+	if t.IsZero() {
+		return infinity
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// A page token holds the creation time and the id of the last transaction
+// of a page.
+func encodePageToken(createdAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+func decodePageToken(token string) (createdAt time.Time, id string, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		if createdAtText, id, ok := strings.Cut(string(data), "|"); ok {
+			if createdAt, err = time.Parse(time.RFC3339Nano, createdAtText); err == nil {
+				return createdAt, id, nil
+			}
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("%w: malformed page token", ErrInvalidValue)
+}
+
+// page cuts transactions, fetched one past limit, to limit and returns the
+// token of the next page when there is one. createdAt are the creation
+// times of the transactions.
+func page(transactions []structs.Transaction, createdAt []time.Time, limit int) ([]structs.Transaction, string) {
+	if len(transactions) <= limit {
+		return transactions, ""
+	}
+	last := limit - 1
+	return transactions[:limit], encodePageToken(createdAt[last], transactions[last].ID)
+}
+
+// pgError wraps the store's error for the Postgres errors callers act on.
+func pgError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, pgErr.Message)
+	case "23503": // foreign_key_violation
+		return fmt.Errorf("%w: %s", ErrNotFound, pgErr.Detail)
+	case "22003", "22P02": // numeric_value_out_of_range, invalid_text_representation
+		return fmt.Errorf("%w: %s", ErrInvalidValue, pgErr.Message)
+	}
+	return err
+}
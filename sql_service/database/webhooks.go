@@ -0,0 +1,467 @@
+package sql_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"sql_service/structs"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrNotFound is wrapped by errors about rows that do not exist or belong
+// to another wallet.
+var ErrNotFound = errors.New("not found")
+
+// Webhook event types.
+const (
+	EventTransactionCompleted = "transaction.completed"
+	EventTransactionFailed    = "transaction.failed"
+)
+
+// WebhookRetryPolicy decides what happens after a failed delivery attempt.
+type WebhookRetryPolicy struct {
+	MaxAttempts  int
+	Backoff      time.Duration
+	BackoffMax   time.Duration
+	DisableAfter int
+}
+
+// delay returns the wait before the attempt after the given failed one:
+// Backoff doubled per previous failure, capped at BackoffMax.
+func (p WebhookRetryPolicy) delay(attempts int) time.Duration {
+	d := float64(p.Backoff) * math.Pow(2, float64(attempts-1))
+	if d > float64(p.BackoffMax) {
+		return p.BackoffMax
+	}
+	return time.Duration(d)
+}
+
+const webhookColumns = `webhook_id::text, wallet_id, url, enabled, consecutive_failures,
+	COALESCE(disabled_reason, ''), COALESCE(principal, ''), created_at`
+
+func scanWebhook(row pgx.Row) (structs.Webhook, error) {
+	var w structs.Webhook
+	err := row.Scan(&w.ID, &w.WalletID, &w.URL, &w.Enabled, &w.ConsecutiveFailures,
+		&w.DisabledReason, &w.Principal, &w.CreatedAt)
+	return w, err
+}
+
+const deliveryColumns = `d.delivery_id::text, d.webhook_id::text, d.event_id::text, d.event_type, d.payload,
+	d.status, d.attempts, d.next_attempt_at, COALESCE(d.last_attempt_at, 'epoch'),
+	COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.created_at`
+
+func scanDelivery(row pgx.Row, extra ...any) (structs.WebhookDelivery, error) {
+	var d structs.WebhookDelivery
+	dest := []any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload,
+		&d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.CreatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if d.LastAttemptAt.Unix() == 0 {
+		d.LastAttemptAt = time.Time{}
+	}
+	return d, err
+}
+
+// CreateWebhook registers url for the events of walletID and generates the
+// secret deliveries are signed with.
+func (p *Postgres) CreateWebhook(ctx context.Context, walletID int, url string, principal string) (webhook structs.Webhook, err error) {
+	if p.pool == nil {
+		return webhook, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		INSERT INTO webhooks (webhook_id, wallet_id, url, secret, enabled, consecutive_failures, principal, created_at)
+		SELECT $1, wallet_id, $3, $4, true, 0, NULLIF($5, ''), $6 FROM wallets WHERE wallet_id = $2
+		RETURNING ` + webhookColumns
+
+	ctx, span := startSpan(ctx, "CreateWebhook", query)
+	defer func() { endSpan(span, err) }()
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return webhook, err
+	}
+
+	webhook, err = scanWebhook(p.pool.QueryRow(ctx, query, uuid.New(), walletID, url,
+		"whsec_"+hex.EncodeToString(secret), principal, time.Now()))
+	if err == pgx.ErrNoRows {
+		return webhook, fmt.Errorf("wallet %d: %w", walletID, ErrNotFound)
+	}
+	if err != nil {
+		return webhook, err
+	}
+	webhook.Secret = "whsec_" + hex.EncodeToString(secret)
+
+	return webhook, nil
+}
+
+func (p *Postgres) ListWebhooks(ctx context.Context, walletID int) (webhooks []structs.Webhook, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE wallet_id = $1 ORDER BY created_at`
+
+	ctx, span := startSpan(ctx, "ListWebhooks", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := p.pool.Query(ctx, query, walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook together with its deliveries.
+func (p *Postgres) DeleteWebhook(ctx context.Context, walletID int, webhookID string) (err error) {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `DELETE FROM webhooks WHERE webhook_id = $1 AND wallet_id = $2`
+
+	ctx, span := startSpan(ctx, "DeleteWebhook", query)
+	defer func() { endSpan(span, err) }()
+
+	id, err := StrToUuid(webhookID)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", webhookID, ErrNotFound)
+	}
+
+	tag, err := p.pool.Exec(ctx, query, id, walletID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook %s: %w", webhookID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// EnableWebhook turns a webhook back on after it was disabled for failing.
+// Deliveries still pending are sent again.
+func (p *Postgres) EnableWebhook(ctx context.Context, walletID int, webhookID string) (webhook structs.Webhook, err error) {
+	if p.pool == nil {
+		return webhook, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		UPDATE webhooks SET enabled = true, consecutive_failures = 0, disabled_reason = NULL
+		WHERE webhook_id = $1 AND wallet_id = $2
+		RETURNING ` + webhookColumns
+
+	ctx, span := startSpan(ctx, "EnableWebhook", query)
+	defer func() { endSpan(span, err) }()
+
+	id, err := StrToUuid(webhookID)
+	if err != nil {
+		return webhook, fmt.Errorf("webhook %s: %w", webhookID, ErrNotFound)
+	}
+
+	webhook, err = scanWebhook(p.pool.QueryRow(ctx, query, id, walletID))
+	if err == pgx.ErrNoRows {
+		return webhook, fmt.Errorf("webhook %s: %w", webhookID, ErrNotFound)
+	}
+
+	return webhook, err
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a webhook, newest
+// first.
+func (p *Postgres) ListWebhookDeliveries(ctx context.Context, walletID int, webhookID string, limit int) (deliveries []structs.WebhookDelivery, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries d JOIN webhooks w ON w.webhook_id = d.webhook_id
+		WHERE d.webhook_id = $1 AND w.wallet_id = $2
+		ORDER BY d.created_at DESC
+		LIMIT $3
+	`
+
+	ctx, span := startSpan(ctx, "ListWebhookDeliveries", query)
+	defer func() { endSpan(span, err) }()
+
+	id, err := StrToUuid(webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("webhook %s: %w", webhookID, ErrNotFound)
+	}
+
+	rows, err := p.pool.Query(ctx, query, id, walletID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ListFailedWebhookDeliveries returns the latest deliveries that ran out of
+// attempts, newest first, of walletID or of every wallet when it is 0.
+func (p *Postgres) ListFailedWebhookDeliveries(ctx context.Context, walletID int, limit int) (deliveries []structs.WebhookDelivery, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		SELECT ` + deliveryColumns + `, w.wallet_id
+		FROM webhook_deliveries d JOIN webhooks w ON w.webhook_id = d.webhook_id
+		WHERE d.status = 'failed' AND ($1 = 0 OR w.wallet_id = $1)
+		ORDER BY d.created_at DESC
+		LIMIT $2
+	`
+
+	ctx, span := startSpan(ctx, "ListFailedWebhookDeliveries", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := p.pool.Query(ctx, query, walletID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var wallet int
+		delivery, err := scanDelivery(rows, &wallet)
+		if err != nil {
+			return nil, err
+		}
+		delivery.WalletID = wallet
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RedeliverWebhook queues a delivery again right away with a fresh attempt
+// budget, whatever its current status.
+func (p *Postgres) RedeliverWebhook(ctx context.Context, walletID int, webhookID, deliveryID string) (delivery structs.WebhookDelivery, err error) {
+	if p.pool == nil {
+		return delivery, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		UPDATE webhook_deliveries d SET status = 'pending', attempts = 0, next_attempt_at = now()
+		FROM webhooks w
+		WHERE d.delivery_id = $1 AND d.webhook_id = $2 AND w.webhook_id = d.webhook_id AND w.wallet_id = $3
+		RETURNING ` + deliveryColumns
+
+	ctx, span := startSpan(ctx, "RedeliverWebhook", query)
+	defer func() { endSpan(span, err) }()
+
+	webhookUUID, err := StrToUuid(webhookID)
+	if err != nil {
+		return delivery, fmt.Errorf("webhook %s: %w", webhookID, ErrNotFound)
+	}
+	deliveryUUID, err := StrToUuid(deliveryID)
+	if err != nil {
+		return delivery, fmt.Errorf("delivery %s: %w", deliveryID, ErrNotFound)
+	}
+
+	delivery, err = scanDelivery(p.pool.QueryRow(ctx, query, deliveryUUID, webhookUUID, walletID))
+	if err == pgx.ErrNoRows {
+		return delivery, fmt.Errorf("delivery %s: %w", deliveryID, ErrNotFound)
+	}
+
+	return delivery, err
+}
+
+// ClaimWebhookDeliveries reserves up to limit due deliveries of enabled
+// webhooks for lease. A worker that dies mid-delivery loses the lease and
+// the delivery is claimed again.
+func (p *Postgres) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (deliveries []structs.WebhookDelivery, err error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `
+		WITH due AS (
+			SELECT d.delivery_id
+			FROM webhook_deliveries d JOIN webhooks w ON w.webhook_id = d.webhook_id
+			WHERE d.status IN ('pending', 'in_progress') AND d.next_attempt_at <= now() AND w.enabled
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET status = 'in_progress', next_attempt_at = now() + make_interval(secs => $2)
+		FROM due, webhooks w
+		WHERE d.delivery_id = due.delivery_id AND w.webhook_id = d.webhook_id
+		RETURNING ` + deliveryColumns + `, w.url, w.secret`
+
+	ctx, span := startSpan(ctx, "ClaimWebhookDeliveries", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := p.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url, secret string
+		delivery, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		delivery.URL, delivery.Secret = url, secret
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ReportWebhookDelivery logs an attempt and schedules the delivery: done on
+// success, retried with backoff on failure until policy.MaxAttempts. The
+// webhook is disabled after policy.DisableAfter consecutive failures; the
+// returned flag tells whether this attempt disabled it.
+func (p *Postgres) ReportWebhookDelivery(ctx context.Context, attempt structs.WebhookAttempt, policy WebhookRetryPolicy) (disabled bool, err error) {
+	if p.pool == nil {
+		return false, fmt.Errorf("database pool is not initialized")
+	}
+
+	query := `SELECT webhook_id, attempts FROM webhook_deliveries WHERE delivery_id = $1 FOR UPDATE`
+
+	ctx, span := startSpan(ctx, "ReportWebhookDelivery", query)
+	defer func() { endSpan(span, err) }()
+
+	deliveryID, err := StrToUuid(attempt.DeliveryID)
+	if err != nil {
+		return false, fmt.Errorf("delivery %s: %w", attempt.DeliveryID, ErrNotFound)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var webhookID uuid.UUID
+	var attempts int
+	err = tx.QueryRow(ctx, query, deliveryID).Scan(&webhookID, &attempts)
+	if err == pgx.ErrNoRows {
+		return false, fmt.Errorf("delivery %s: %w", attempt.DeliveryID, ErrNotFound)
+	}
+	if err != nil {
+		return false, err
+	}
+	attempts++
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_attempts (delivery_id, attempt, success, status_code, error, duration_ms, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''), $6, now())
+	`, deliveryID, attempts, attempt.Success, attempt.StatusCode, attempt.Error, attempt.Duration.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+
+	status, next := "succeeded", time.Now()
+	if !attempt.Success {
+		status, next = "pending", next.Add(policy.delay(attempts))
+		if attempts >= policy.MaxAttempts {
+			status = "failed"
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_attempt_at = now(),
+			last_status_code = NULLIF($5, 0), last_error = NULLIF($6, '')
+		WHERE delivery_id = $1
+	`, deliveryID, status, attempts, next, attempt.StatusCode, attempt.Error)
+	if err != nil {
+		return false, err
+	}
+
+	if attempt.Success {
+		_, err = tx.Exec(ctx, `UPDATE webhooks SET consecutive_failures = 0 WHERE webhook_id = $1`, webhookID)
+	} else {
+		err = tx.QueryRow(ctx, `
+			UPDATE webhooks
+			SET consecutive_failures = consecutive_failures + 1,
+				enabled = enabled AND consecutive_failures + 1 < $2,
+				disabled_reason = CASE WHEN enabled AND consecutive_failures + 1 >= $2
+					THEN $3 ELSE disabled_reason END
+			WHERE webhook_id = $1
+			RETURNING consecutive_failures = $2
+		`, webhookID, policy.DisableAfter,
+			fmt.Sprintf("disabled after %d consecutive failed deliveries", policy.DisableAfter)).Scan(&disabled)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return disabled, tx.Commit(ctx)
+}
+
+// webhookEvent is the JSON body POSTed to webhook endpoints.
+type webhookEvent struct {
+	ID        string           `json:"id"`
+	Type      string           `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      webhookEventData `json:"data"`
+}
+
+type webhookEventData struct {
+	TransactionID string  `json:"transaction_id"`
+	WalletID      int     `json:"wallet_id"`
+	Amount        float64 `json:"amount"`
+	Type          string  `json:"type"`
+	Status        string  `json:"status"`
+	Principal     string  `json:"principal,omitempty"`
+}
+
+// enqueueWebhookEvent queues a delivery of the transaction event to every
+// enabled webhook of the wallet, within the transaction that records it.
+func enqueueWebhookEvent(ctx context.Context, tx pgx.Tx, data webhookEventData) error {
+	event := webhookEvent{
+		ID:        uuid.New().String(),
+		Type:      EventTransactionFailed,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	if data.Status == "Success" {
+		event.Type = EventTransactionCompleted
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (delivery_id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		SELECT gen_random_uuid(), webhook_id, $2, $3, $4, 'pending', 0, now(), now()
+		FROM webhooks
+		WHERE wallet_id = $1 AND enabled
+	`, data.WalletID, event.ID, event.Type, string(payload))
+	return err
+}
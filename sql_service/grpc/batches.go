@@ -0,0 +1,113 @@
+package sql_service
+
+import (
+	"common/logging"
+	"context"
+	"errors"
+	db "sql_service/database"
+	"sql_service/structs"
+	api "sqlapi/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) CreateBatch(ctx context.Context, req *api.CreateBatchRequest) (*api.Batch, error) {
+	items := make([]structs.BatchItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, structs.BatchItem{
+			WalletID:  int(item.WalletId),
+			Type:      item.Type,
+			Amount:    item.Amount,
+			Reference: item.Reference,
+		})
+	}
+
+	batch, err := s.Batches.CreateBatch(ctx, req.Principal, req.Mode, items)
+	if err != nil {
+		return nil, batchError(ctx, "Failed to create batch", err)
+	}
+	logging.FromContext(ctx).Info("Batch created", "batch_id", batch.ID, "mode", batch.Mode, "items", batch.Total, "principal", req.Principal)
+
+	return batchToProto(batch), nil
+}
+
+func (s *Server) GetBatch(ctx context.Context, req *api.BatchRef) (*api.Batch, error) {
+	batch, err := s.Batches.GetBatch(logging.With(ctx, "batch_id", req.BatchId), req.BatchId, true)
+	if err != nil {
+		return nil, batchError(ctx, "Failed to get batch", err)
+	}
+	return batchToProto(batch), nil
+}
+
+func (s *Server) StartBatch(ctx context.Context, req *api.BatchRef) (*api.Batch, error) {
+	ctx = logging.With(ctx, "batch_id", req.BatchId)
+
+	batch, err := s.Batches.StartBatch(ctx, req.BatchId)
+	if err != nil {
+		return nil, batchError(ctx, "Failed to start batch", err)
+	}
+	logging.FromContext(ctx).Info("Batch started", "items", batch.Total)
+
+	return batchToProto(batch), nil
+}
+
+func (s *Server) ReportBatchItem(ctx context.Context, req *api.BatchItemResult) (*api.Empty, error) {
+	ctx = logging.With(ctx, "batch_id", req.BatchId, "item", req.Index)
+
+	batchStatus, err := s.Batches.ReportBatchItem(ctx, req.BatchId, int(req.Index), req.Status, req.TransactionId, req.Error)
+	if err != nil {
+		return nil, batchError(ctx, "Failed to record batch item", err)
+	}
+	if batchStatus != "processing" {
+		logging.FromContext(ctx).Info("Batch finished", "status", batchStatus)
+	}
+
+	return &api.Empty{}, nil
+}
+
+// batchError logs err and maps the database errors callers act on to gRPC
+// codes.
+func batchError(ctx context.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, db.ErrInvalidBatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, db.ErrBatchStarted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	logging.FromContext(ctx).Error(msg, "error", err)
+	return err
+}
+
+func batchToProto(b structs.Batch) *api.Batch {
+	batch := &api.Batch{
+		BatchId:   b.ID,
+		Principal: b.Principal,
+		Mode:      b.Mode,
+		Status:    b.Status,
+		Total:     int32(b.Total),
+		Succeeded: int32(b.Succeeded),
+		Failed:    int32(b.Failed),
+		Pending:   int32(b.Pending),
+		CreatedAt: timestamppb.New(b.CreatedAt),
+	}
+	if !b.CompletedAt.IsZero() {
+		batch.CompletedAt = timestamppb.New(b.CompletedAt)
+	}
+	for _, item := range b.Items {
+		batch.Items = append(batch.Items, &api.BatchItem{
+			Index:         int32(item.Index),
+			WalletId:      int32(item.WalletID),
+			Type:          item.Type,
+			Amount:        item.Amount,
+			Reference:     item.Reference,
+			Status:        item.Status,
+			TransactionId: item.TransactionID,
+			Error:         item.Error,
+		})
+	}
+	return batch
+}
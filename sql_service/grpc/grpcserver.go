@@ -1,63 +1,78 @@
 package sql_service
 
 import (
+	"common/logging"
 	"context"
-	"log"
+	"errors"
+	"sql_service/authz"
 	db "sql_service/database"
-	api "sql_service/grpc/proto"
+	"sql_service/reconcile"
+	"sql_service/structs"
+	api "sqlapi/pb"
 	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-type Server struct{}
+// Server implements the SQLService gRPC API. Everything it keeps goes
+// through the stores so that the handlers can be tested without Postgres.
+type Server struct {
+	api.UnimplementedSQLServiceServer
+	Wallets      db.WalletStore
+	Transactions db.TransactionStore
+	Webhooks     db.WebhookStore
+	Batches      db.BatchStore
+	Adjustments  db.AdjustmentStore
+}
 
 func (s *Server) GetTransactionID(ctx context.Context, req *api.TransactionId) (*api.Transaction, error) {
 	transaction_id := req.TransactionId
-	transaction, err := db.GetTransactionID(transaction_id)
-	if err != nil {
-		log.Printf("Error getting transaction from the database: %v", err)
-		return nil, err
-	}
-
+	ctx = logging.With(ctx, "transaction_id", transaction_id)
+	transaction, err := s.Transactions.GetTransaction(ctx, transaction_id)
 	if err != nil {
-		return nil, err
+		return nil, storeError(ctx, "Error getting transaction from the database", err)
 	}
 
 	response := &api.Transaction{
-		TransactionId: transaction.TransactionId,
-		WalletId:      int32(transaction.WalletId),
+		TransactionId: transaction.ID,
+		WalletId:      int32(transaction.WalletID),
 		Amount:        transaction.Amount,
 		Type:          transaction.Type,
-		RequestTime:   transaction.RequestTime,
+		RequestTime:   timestamppb.New(transaction.Time),
 		Status:        transaction.Status,
+		Principal:     transaction.Principal,
 	}
-	// log.Printf("Transaction Details:\nTransaction Id: %s\nWallet ID: %d\nAmount: %.2f\nTransaction Type: %s\nTransaction Status: %s\n------------------------", transaction.TransactionId, int32(transaction.WalletId), transaction.Amount, transaction.Type, transaction.RequestTime, transaction.Status)
 
 	return response, nil
 }
 
 func (s *Server) GetBalance(ctx context.Context, req *api.WalletIdRequest) (*api.BalanceResponse, error) {
-	balance, err := db.GetBalanceByIDwallet(int(req.WalletId))
+	ctx = logging.With(ctx, "wallet_id", req.WalletId)
+	balance, err := s.Wallets.GetBalance(ctx, int(req.WalletId))
 	if err != nil {
-		return nil, err
+		return nil, storeError(ctx, "Error getting balance from the database", err)
 	}
-	// fmt.Printf("Balance Wallet ID - %d: %.2f \n", req.WalletId, balance)
 	return &api.BalanceResponse{Balance: balance}, nil
 }
 
 func (s *Server) CreateTransaction(ctx context.Context, req *api.Transaction) (*api.Empty, error) {
-	TransactionId := req.TransactionId
-	walletID := int(req.WalletId)
-	amount := req.Amount
-	typeTx := req.Type
-	statusTx := req.Status
-	transactionTime := time.Now()
-	formattedTransactionTime := transactionTime.Format("2006-01-02 15:04:05.00")
-
-	// log.Printf("\nNew Transaction. Details:\nTransaction Id: %s\nWallet ID: %d\nAmount: %.2f\nTransaction Type: %s\nTransaction Status: %s\nTransaction Time: %s\n------------------------", TransactionId, walletID, amount, typeTx, statusTx, formattedTransactionTime)
+	transaction := structs.Transaction{
+		ID:        req.TransactionId,
+		WalletID:  int(req.WalletId),
+		Amount:    req.Amount,
+		Type:      req.Type,
+		Status:    req.Status,
+		Principal: req.Principal,
+		Time:      time.Now(),
+	}
+	ctx = logging.With(ctx, "wallet_id", transaction.WalletID, "transaction_id", transaction.ID)
 
-	if err := db.NewTransaction(TransactionId, walletID, amount, typeTx, statusTx, formattedTransactionTime); err != nil {
-		return nil, err
+	if err := s.Transactions.CreateTransaction(ctx, transaction); err != nil {
+		return nil, storeError(ctx, "Failed to create transaction", err)
 	}
+	logging.FromContext(ctx).Info("Transaction created", "type", transaction.Type, "status", transaction.Status, "amount", transaction.Amount, "principal", transaction.Principal)
 
 	return &api.Empty{}, nil
 }
@@ -65,12 +80,128 @@ func (s *Server) CreateTransaction(ctx context.Context, req *api.Transaction) (*
 func (s *Server) UpdateBalance(ctx context.Context, req *api.UpdateBalanceRequest) (*api.Empty, error) {
 	walletID := int(req.WalletId)
 	newBalance := req.NewBalance
+	ctx = logging.With(ctx, "wallet_id", walletID, "transaction_id", req.TransactionId)
+
+	audit := structs.AuditEntry{
+		CallerService: authz.Caller(ctx),
+		Principal:     req.Principal,
+		RequestID:     logging.RequestID(ctx),
+		TransactionID: req.TransactionId,
+	}
+	if audit.CallerService == "" {
+		audit.CallerService = "unauthenticated"
+	}
 
-	if err := db.UpdateBalance(walletID, newBalance); err != nil {
-		log.Printf("Failed to update balance: %v", err)
-		return nil, err
+	if err := s.Wallets.UpdateBalance(ctx, walletID, newBalance, req.ExpectedBalance, audit); err != nil {
+		return nil, storeError(ctx, "Failed to update balance", err)
 	}
-	// fmt.Printf("UpdateBalance Wallet ID - %d: %.2f \n", req.WalletId, newBalance)
+	logging.FromContext(ctx).Info("Balance updated", "new_balance", newBalance)
 
 	return &api.Empty{}, nil
 }
+
+func (s *Server) GetAuditLog(ctx context.Context, req *api.AuditLogRequest) (*api.AuditLogResponse, error) {
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	entries, err := s.Wallets.GetAuditLog(ctx, int(req.WalletId), limit)
+	if err != nil {
+		return nil, storeError(ctx, "Failed to get audit log", err)
+	}
+
+	response := &api.AuditLogResponse{}
+	for _, e := range entries {
+		response.Entries = append(response.Entries, &api.AuditEntry{
+			Id:            e.ID,
+			WalletId:      int32(e.WalletID),
+			BeforeBalance: e.BeforeBalance,
+			AfterBalance:  e.AfterBalance,
+			CallerService: e.CallerService,
+			Principal:     e.Principal,
+			RequestId:     e.RequestID,
+			TransactionId: e.TransactionID,
+			CreatedAt:     timestamppb.New(e.CreatedAt),
+			PrevHash:      e.PrevHash,
+			Hash:          e.Hash,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *Server) Reconcile(ctx context.Context, req *api.ReconcileRequest) (*api.ReconcileReport, error) {
+	report, err := reconcile.Run(ctx, s.Adjustments, reconcile.Options{
+		WalletID: int(req.WalletId),
+		Grace:    structs.Config.ReconcileGrace,
+	})
+	if err != nil {
+		return nil, storeError(ctx, "Failed to reconcile balances", err)
+	}
+
+	response := &api.ReconcileReport{
+		Checked:   int32(report.Checked),
+		Skipped:   int32(report.Skipped),
+		CheckedAt: timestamppb.New(report.CheckedAt),
+	}
+	for _, m := range report.Mismatches {
+		mismatch := &api.WalletMismatch{
+			WalletId:                 int32(m.WalletID),
+			Balance:                  m.Balance,
+			ExpectedBalance:          m.ExpectedBalance,
+			Difference:               m.Difference,
+			UnrecordedTransactionIds: m.UnrecordedTransactionIDs,
+			AdjustmentId:             m.AdjustmentID,
+		}
+		if !m.WindowStart.IsZero() {
+			mismatch.WindowStart = timestamppb.New(m.WindowStart)
+			mismatch.WindowEnd = timestamppb.New(m.WindowEnd)
+		}
+		response.Mismatches = append(response.Mismatches, mismatch)
+	}
+
+	return response, nil
+}
+
+func (s *Server) DecideAdjustment(ctx context.Context, req *api.AdjustmentDecision) (*api.Adjustment, error) {
+	ctx = logging.With(ctx, "adjustment_id", req.AdjustmentId)
+
+	adjustment, err := s.Adjustments.DecideAdjustment(ctx, req.AdjustmentId, req.Approve, req.DecidedBy)
+	if err != nil {
+		return nil, storeError(ctx, "Failed to decide adjustment", err)
+	}
+
+	logging.FromContext(ctx).Info("Adjustment decided", "status", adjustment.Status, "decided_by", adjustment.DecidedBy)
+
+	return &api.Adjustment{
+		AdjustmentId:  adjustment.ID,
+		WalletId:      int32(adjustment.WalletID),
+		Amount:        adjustment.Amount,
+		Reason:        adjustment.Reason,
+		Status:        adjustment.Status,
+		TransactionId: adjustment.TransactionID,
+		CreatedAt:     timestamppb.New(adjustment.CreatedAt),
+		DecidedAt:     timestamppb.New(adjustment.DecidedAt),
+		DecidedBy:     adjustment.DecidedBy,
+	}, nil
+}
+
+// storeError logs err and maps the store errors callers act on to gRPC
+// codes.
+func storeError(ctx context.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, db.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, db.ErrInvalidValue):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, db.ErrBalanceChanged):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, db.ErrWalletFrozen), errors.Is(err, db.ErrAlreadyDecided):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	logging.FromContext(ctx).Error(msg, "error", err)
+	return err
+}
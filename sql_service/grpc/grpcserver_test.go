@@ -0,0 +1,434 @@
+package sql_service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db "sql_service/database"
+	"sql_service/structs"
+	api "sqlapi/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const transactionID = "f47cbde3-98d8-47cb-a30b-1046b1f70b75"
+
+func newServer(t *testing.T, balances ...float64) *Server {
+	t.Helper()
+
+	store := db.NewMemory()
+	for _, balance := range balances {
+		if _, err := store.AddWallet(balance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Server{Wallets: store, Transactions: store, Adjustments: store}
+}
+
+func TestUpdateBalanceIsAudited(t *testing.T) {
+	s := newServer(t, 100)
+	ctx := context.Background()
+
+	for _, balance := range []float64{150.004, 20} {
+		_, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 1, NewBalance: balance, TransactionId: transactionID, Principal: "merchant"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response, err := s.GetBalance(ctx, &api.WalletIdRequest{WalletId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Balance != 20 {
+		t.Errorf("balance %v, want 20", response.Balance)
+	}
+
+	log, err := s.GetAuditLog(ctx, &api.AuditLogRequest{WalletId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(log.Entries))
+	}
+	newest, oldest := log.Entries[0], log.Entries[1]
+	if oldest.BeforeBalance != 100 || oldest.AfterBalance != 150 || newest.BeforeBalance != 150 || newest.AfterBalance != 20 {
+		t.Errorf("audit balances %v→%v, %v→%v; want 100→150, 150→20",
+			oldest.BeforeBalance, oldest.AfterBalance, newest.BeforeBalance, newest.AfterBalance)
+	}
+	if newest.PrevHash != oldest.Hash {
+		t.Errorf("newest entry links to %s, want %s", newest.PrevHash, oldest.Hash)
+	}
+	if newest.CallerService != "unauthenticated" || newest.Principal != "merchant" || newest.TransactionId != transactionID {
+		t.Errorf("audit entry %+v does not record who asked", newest)
+	}
+}
+
+func TestCreateTransaction(t *testing.T) {
+	s := newServer(t, 100)
+	ctx := context.Background()
+
+	before := time.Now()
+	_, err := s.CreateTransaction(ctx, &api.Transaction{TransactionId: transactionID, WalletId: 1, Amount: 12.345, Type: "deposit", Status: "Success"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := s.GetTransactionID(ctx, &api.TransactionId{TransactionId: transactionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.WalletId != 1 || transaction.Amount != 12.35 || transaction.Type != "deposit" || transaction.Status != "Success" {
+		t.Errorf("got %+v", transaction)
+	}
+	// The time is stored as wall clock time to the hundredth of a second.
+	if got := transaction.RequestTime.AsTime(); got.Format(time.DateTime) < before.Format(time.DateTime) {
+		t.Errorf("request time %v is before the transaction was created at %v", got, before)
+	}
+}
+
+// failingAuditLog is a wallet store whose audit log cannot be read.
+type failingAuditLog struct {
+	db.WalletStore
+	err error
+}
+
+func (f failingAuditLog) GetAuditLog(context.Context, int, int) ([]structs.AuditEntry, error) {
+	return nil, f.err
+}
+
+func TestStoreErrorCodes(t *testing.T) {
+	s := newServer(t, 100)
+	ctx := context.Background()
+
+	if _, err := s.CreateTransaction(ctx, &api.Transaction{TransactionId: transactionID, WalletId: 1, Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"balance of unknown wallet", func() error {
+			_, err := s.GetBalance(ctx, &api.WalletIdRequest{WalletId: 2})
+			return err
+		}, codes.NotFound},
+		{"update of unknown wallet", func() error {
+			_, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 2, NewBalance: 1})
+			return err
+		}, codes.NotFound},
+		{"balance out of range", func() error {
+			_, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 1, NewBalance: 1e8})
+			return err
+		}, codes.InvalidArgument},
+		{"transaction of unknown wallet", func() error {
+			_, err := s.CreateTransaction(ctx, &api.Transaction{TransactionId: "5e7e68f0-30d6-4d4b-8411-7f75e3b63f27", WalletId: 2, Amount: 10})
+			return err
+		}, codes.NotFound},
+		{"duplicate transaction", func() error {
+			_, err := s.CreateTransaction(ctx, &api.Transaction{TransactionId: transactionID, WalletId: 1, Amount: 10})
+			return err
+		}, codes.AlreadyExists},
+		{"malformed transaction id", func() error {
+			_, err := s.CreateTransaction(ctx, &api.Transaction{TransactionId: "42", WalletId: 1, Amount: 10})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown transaction", func() error {
+			_, err := s.GetTransactionID(ctx, &api.TransactionId{TransactionId: "5e7e68f0-30d6-4d4b-8411-7f75e3b63f27"})
+			return err
+		}, codes.NotFound},
+		{"unknown adjustment", func() error {
+			_, err := s.DecideAdjustment(ctx, &api.AdjustmentDecision{AdjustmentId: "5e7e68f0-30d6-4d4b-8411-7f75e3b63f27", DecidedBy: "ops"})
+			return err
+		}, codes.NotFound},
+		{"malformed adjustment id", func() error {
+			_, err := s.DecideAdjustment(ctx, &api.AdjustmentDecision{AdjustmentId: "42", DecidedBy: "ops"})
+			return err
+		}, codes.InvalidArgument},
+		{"decision without operator", func() error {
+			_, err := s.DecideAdjustment(ctx, &api.AdjustmentDecision{AdjustmentId: "5e7e68f0-30d6-4d4b-8411-7f75e3b63f27"})
+			return err
+		}, codes.InvalidArgument},
+		{"audit log of unknown wallet", func() error {
+			failing := &Server{Wallets: failingAuditLog{s.Wallets, db.ErrNotFound}}
+			_, err := failing.GetAuditLog(ctx, &api.AuditLogRequest{WalletId: 2})
+			return err
+		}, codes.NotFound},
+	}
+	for _, tt := range tests {
+		if got := status.Code(tt.call()); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// A refused update leaves the wallet and its audit trail alone.
+	log, err := s.GetAuditLog(ctx, &api.AuditLogRequest{WalletId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Entries) != 0 {
+		t.Errorf("got %d audit entries after refused updates, want 0", len(log.Entries))
+	}
+}
+
+func TestUpdateBalanceWithExpectedBalance(t *testing.T) {
+	s := newServer(t, 100)
+	ctx := context.Background()
+
+	stale := 90.0
+	_, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 1, NewBalance: 80, ExpectedBalance: &stale})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("update with a stale balance: got %v, want Aborted", err)
+	}
+
+	current := 100.0
+	if _, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 1, NewBalance: 110, ExpectedBalance: &current}); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := s.GetAuditLog(ctx, &api.AuditLogRequest{WalletId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Entries) != 1 || log.Entries[0].AfterBalance != 110 {
+		t.Errorf("audit log %v, want only the update from 100 to 110", log.Entries)
+	}
+}
+
+func TestFrozenWalletRefusesUpdates(t *testing.T) {
+	s := newServer(t, 100)
+	ctx := context.Background()
+
+	if _, err := s.SetWalletFrozen(ctx, &api.SetWalletFrozenRequest{WalletId: 1, Frozen: true}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("freeze without a reason: got %v, want InvalidArgument", err)
+	}
+	wallet, err := s.SetWalletFrozen(ctx, &api.SetWalletFrozenRequest{WalletId: 1, Frozen: true, Reason: "chargeback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Frozen || wallet.FrozenReason != "chargeback" || wallet.FrozenAt == nil {
+		t.Errorf("got %+v, want a frozen wallet", wallet)
+	}
+
+	_, err = s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 1, NewBalance: 50})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("update of a frozen wallet: got %v, want FailedPrecondition", err)
+	}
+
+	if _, err := s.SetWalletFrozen(ctx, &api.SetWalletFrozenRequest{WalletId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 1, NewBalance: 50}); err != nil {
+		t.Errorf("update after unfreezing: %v", err)
+	}
+}
+
+func TestAdjustBalance(t *testing.T) {
+	s := newServer(t, 100)
+	ctx := context.Background()
+
+	for _, req := range []*api.AdjustBalanceRequest{
+		{WalletId: 1, Amount: 5, Operator: "alice"},
+		{WalletId: 1, Amount: 5, Reason: "refund"},
+		{WalletId: 1, Amount: -100.01, Reason: "refund", Operator: "alice"},
+	} {
+		if _, err := s.AdjustBalance(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%+v: got %v, want InvalidArgument", req, err)
+		}
+	}
+
+	// Adjustments apply to frozen wallets too.
+	if _, err := s.SetWalletFrozen(ctx, &api.SetWalletFrozenRequest{WalletId: 1, Frozen: true, Reason: "review"}); err != nil {
+		t.Fatal(err)
+	}
+	adjustment, err := s.AdjustBalance(ctx, &api.AdjustBalanceRequest{WalletId: 1, Amount: -20.5, Reason: "duplicate deposit", Operator: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adjustment.Status != "approved" || adjustment.DecidedBy != "alice" || adjustment.Amount != -20.5 {
+		t.Errorf("got %+v", adjustment)
+	}
+
+	wallet, err := s.GetWallet(ctx, &api.WalletIdRequest{WalletId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Balance != 79.5 {
+		t.Errorf("balance %v, want 79.5", wallet.Balance)
+	}
+
+	transaction, err := s.GetTransactionID(ctx, &api.TransactionId{TransactionId: adjustment.TransactionId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Type != "adjustment" || transaction.Amount != -20.5 || transaction.Principal != "alice" {
+		t.Errorf("recorded %+v", transaction)
+	}
+
+	log, err := s.GetAuditLog(ctx, &api.AuditLogRequest{WalletId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Entries) != 1 || log.Entries[0].AfterBalance != 79.5 || log.Entries[0].Principal != "alice" || log.Entries[0].TransactionId != adjustment.TransactionId {
+		t.Errorf("audit log %v, want the adjustment by alice", log.Entries)
+	}
+}
+
+func TestListTransactions(t *testing.T) {
+	s := newServer(t, 100, 100)
+	ctx := context.Background()
+
+	ids := []string{
+		"0b5e8f0c-5f4b-4d0c-9d7e-2f1c3a4b5c6d",
+		"1c6f9a1d-6a5c-4e1d-8e8f-3a2d4b5c6d7e",
+		"2d7a0b2e-7b6d-4f2e-9f9a-4b3e5c6d7e8f",
+		"3e8b1c3f-8c7e-4a3f-8a0b-5c4f6d7e8f9a",
+		"4f9c2d4a-9d8f-4b4a-9b1c-6d5a7e8f9a0b",
+	}
+	for i, id := range ids {
+		transaction := &api.Transaction{TransactionId: id, WalletId: 1, Amount: float64(i + 1), Type: "deposit", Status: "Success"}
+		if i == 4 {
+			transaction.WalletId, transaction.Type = 2, "withdraw"
+		}
+		if _, err := s.CreateTransaction(ctx, transaction); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	token := ""
+	for pages := 0; ; pages++ {
+		list, err := s.ListTransactions(ctx, &api.ListTransactionsRequest{WalletId: 1, Limit: 3, PageToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, transaction := range list.Transactions {
+			got = append(got, transaction.TransactionId)
+		}
+		if token = list.NextPageToken; token == "" {
+			break
+		}
+		if pages > 1 {
+			t.Fatal("too many pages")
+		}
+	}
+	if len(got) != 4 {
+		t.Fatalf("listed %v, want the 4 transactions of wallet 1", got)
+	}
+	seen := make(map[string]bool)
+	for _, id := range got {
+		if seen[id] {
+			t.Errorf("%s listed twice", id)
+		}
+		seen[id] = true
+	}
+
+	list, err := s.ListTransactions(ctx, &api.ListTransactionsRequest{Type: "withdraw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Transactions) != 1 || list.Transactions[0].TransactionId != ids[4] {
+		t.Errorf("withdrawals %v, want only %s", list.Transactions, ids[4])
+	}
+
+	list, err = s.ListTransactions(ctx, &api.ListTransactionsRequest{To: timestamppb.New(time.Now().Add(-time.Hour))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Transactions) != 0 {
+		t.Errorf("listed %d transactions before they were made", len(list.Transactions))
+	}
+
+	if _, err := s.ListTransactions(ctx, &api.ListTransactionsRequest{PageToken: "!"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("malformed page token: got %v, want InvalidArgument", err)
+	}
+}
+
+// TestReconcile checks the mismatch arithmetic: deposits add, withdrawals
+// subtract, failed transactions and balance changes without a transaction
+// count for nothing, and the difference is rounded to the cent.
+func TestReconcile(t *testing.T) {
+	s := newServer(t, 0, 0)
+	ctx := context.Background()
+
+	change := func(walletID int32, balance float64, transactionID, typ, status string, amount float64) {
+		t.Helper()
+		if _, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: walletID, NewBalance: balance, TransactionId: transactionID}); err != nil {
+			t.Fatal(err)
+		}
+		if typ == "" {
+			return
+		}
+		_, err := s.CreateTransaction(ctx, &api.Transaction{TransactionId: transactionID, WalletId: walletID, Amount: amount, Type: typ, Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	change(1, 50.1, "0d4e7a9c-1b2f-4c3d-8e5f-6a7b8c9d0e1f", "deposit", "Success", 50.1)
+	change(1, 40, "1e5f8b0d-2c3a-4d4e-9f6a-7b8c9d0e1f2a", "withdraw", "Success", 10.1)
+	change(1, 40, "2f6a9c1e-3d4b-4e5f-8a7b-8c9d0e1f2a3b", "withdraw", "Failed", 99)
+	change(1, 40.3, "3a7b0d2f-4e5c-4f6a-9b8c-9d0e1f2a3b4c", "", "", 0)
+	change(2, 20.2, "4b8c1e3a-5f6d-4a7b-8c9d-0e1f2a3b4c5d", "deposit", "Success", 20.2)
+
+	report, err := s.Reconcile(ctx, &api.ReconcileRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 2 || report.Skipped != 0 || len(report.Mismatches) != 1 {
+		t.Fatalf("checked %d, skipped %d, mismatches %v; want 2, 0 and wallet 1", report.Checked, report.Skipped, report.Mismatches)
+	}
+	m := report.Mismatches[0]
+	if m.WalletId != 1 || m.Balance != 40.3 || m.ExpectedBalance != 40 || m.Difference != 0.3 {
+		t.Errorf("mismatch %v, want wallet 1 with balance 40.30, expected 40.00 and difference 0.30", m)
+	}
+	if len(m.UnrecordedTransactionIds) != 2 || m.UnrecordedTransactionIds[1] != "3a7b0d2f-4e5c-4f6a-9b8c-9d0e1f2a3b4c" ||
+		m.WindowStart == nil || m.WindowEnd.AsTime().Before(m.WindowStart.AsTime()) {
+		t.Errorf("unrecorded changes %v from %v to %v, want the failed withdrawal and the change without a transaction",
+			m.UnrecordedTransactionIds, m.WindowStart, m.WindowEnd)
+	}
+
+	report, err = s.Reconcile(ctx, &api.ReconcileRequest{WalletId: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 1 || len(report.Mismatches) != 0 {
+		t.Errorf("reconciling wallet 2 checked %d and found %v, want 1 and no mismatch", report.Checked, report.Mismatches)
+	}
+}
+
+func TestDecideAdjustmentErrors(t *testing.T) {
+	s := newServer(t, 100)
+	store := s.Adjustments.(*db.Memory)
+	ctx := context.Background()
+
+	report, err := store.ReconcileWallets(ctx, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.ProposeAdjustment(ctx, report.Mismatches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The wallet drifts further after the proposal.
+	if _, err := s.UpdateBalance(ctx, &api.UpdateBalanceRequest{WalletId: 1, NewBalance: 120}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.DecideAdjustment(ctx, &api.AdjustmentDecision{AdjustmentId: id, Approve: true, DecidedBy: "ops"})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("approving a stale adjustment: %v, want Aborted", err)
+	}
+
+	adjustment, err := s.DecideAdjustment(ctx, &api.AdjustmentDecision{AdjustmentId: id, DecidedBy: "ops"})
+	if err != nil || adjustment.Status != "rejected" || adjustment.TransactionId != "" {
+		t.Fatalf("rejecting: %v, %v", adjustment, err)
+	}
+	_, err = s.DecideAdjustment(ctx, &api.AdjustmentDecision{AdjustmentId: id, Approve: true, DecidedBy: "ops"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("deciding twice: %v, want FailedPrecondition", err)
+	}
+}
@@ -0,0 +1,171 @@
+package sql_service
+
+import (
+	"common/logging"
+	"context"
+	"sql_service/authz"
+	"sql_service/structs"
+	api "sqlapi/pb"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) GetWallet(ctx context.Context, req *api.WalletIdRequest) (*api.Wallet, error) {
+	ctx = logging.With(ctx, "wallet_id", req.WalletId)
+
+	wallet, err := s.Wallets.GetWallet(ctx, int(req.WalletId))
+	if err != nil {
+		return nil, storeError(ctx, "Failed to get wallet", err)
+	}
+	return walletToProto(wallet), nil
+}
+
+func (s *Server) ListWallets(ctx context.Context, req *api.ListWalletsRequest) (*api.WalletList, error) {
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	wallets, err := s.Wallets.ListWallets(ctx, int(req.AfterWalletId), limit)
+	if err != nil {
+		return nil, storeError(ctx, "Failed to list wallets", err)
+	}
+
+	response := &api.WalletList{}
+	for _, wallet := range wallets {
+		response.Wallets = append(response.Wallets, walletToProto(wallet))
+	}
+	return response, nil
+}
+
+func (s *Server) CreateWallet(ctx context.Context, req *api.Empty) (*api.Wallet, error) {
+	wallet, err := s.Wallets.CreateWallet(ctx)
+	if err != nil {
+		return nil, storeError(ctx, "Failed to create wallet", err)
+	}
+	logging.FromContext(ctx).Info("Wallet created", "wallet_id", wallet.ID, "caller", authz.Caller(ctx))
+
+	return walletToProto(wallet), nil
+}
+
+func (s *Server) SetWalletFrozen(ctx context.Context, req *api.SetWalletFrozenRequest) (*api.Wallet, error) {
+	ctx = logging.With(ctx, "wallet_id", req.WalletId)
+
+	if req.Frozen && strings.TrimSpace(req.Reason) == "" {
+		return nil, status.Error(codes.InvalidArgument, "freezing a wallet needs a reason")
+	}
+
+	wallet, err := s.Wallets.SetFrozen(ctx, int(req.WalletId), req.Frozen, req.Reason)
+	if err != nil {
+		return nil, storeError(ctx, "Failed to freeze wallet", err)
+	}
+	logging.FromContext(ctx).Info("Wallet freeze changed", "frozen", req.Frozen, "reason", req.Reason, "caller", authz.Caller(ctx))
+
+	return walletToProto(wallet), nil
+}
+
+func (s *Server) AdjustBalance(ctx context.Context, req *api.AdjustBalanceRequest) (*api.Adjustment, error) {
+	ctx = logging.With(ctx, "wallet_id", req.WalletId)
+
+	audit := structs.AuditEntry{
+		CallerService: authz.Caller(ctx),
+		Principal:     req.Operator,
+		RequestID:     logging.RequestID(ctx),
+	}
+	if audit.CallerService == "" {
+		audit.CallerService = "unauthenticated"
+	}
+
+	adjustment, err := s.Wallets.AdjustBalance(ctx, int(req.WalletId), req.Amount, req.Reason, audit)
+	if err != nil {
+		return nil, storeError(ctx, "Failed to adjust balance", err)
+	}
+	logging.FromContext(ctx).Info("Balance adjusted", "adjustment_id", adjustment.ID, "amount", adjustment.Amount,
+		"reason", adjustment.Reason, "operator", req.Operator)
+
+	return &api.Adjustment{
+		AdjustmentId:  adjustment.ID,
+		WalletId:      int32(adjustment.WalletID),
+		Amount:        adjustment.Amount,
+		Reason:        adjustment.Reason,
+		Status:        adjustment.Status,
+		TransactionId: adjustment.TransactionID,
+		CreatedAt:     timestamppb.New(adjustment.CreatedAt),
+		DecidedAt:     timestamppb.New(adjustment.DecidedAt),
+		DecidedBy:     adjustment.DecidedBy,
+	}, nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, req *api.ListTransactionsRequest) (*api.TransactionList, error) {
+	filter := structs.TransactionFilter{
+		WalletID:  int(req.WalletId),
+		Type:      req.Type,
+		Status:    req.Status,
+		Limit:     int(req.Limit),
+		PageToken: req.PageToken,
+	}
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+	if req.From != nil {
+		filter.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		filter.To = req.To.AsTime()
+	}
+
+	transactions, next, err := s.Transactions.ListTransactions(ctx, filter)
+	if err != nil {
+		return nil, storeError(ctx, "Failed to list transactions", err)
+	}
+
+	response := &api.TransactionList{NextPageToken: next}
+	for _, t := range transactions {
+		response.Transactions = append(response.Transactions, &api.Transaction{
+			TransactionId: t.ID,
+			WalletId:      int32(t.WalletID),
+			Amount:        t.Amount,
+			Type:          t.Type,
+			RequestTime:   timestamppb.New(t.Time),
+			Status:        t.Status,
+			Principal:     t.Principal,
+		})
+	}
+	return response, nil
+}
+
+func (s *Server) ListFailedWebhookDeliveries(ctx context.Context, req *api.FailedWebhookDeliveriesRequest) (*api.WebhookDeliveryList, error) {
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	deliveries, err := s.Webhooks.ListFailedWebhookDeliveries(ctx, int(req.WalletId), limit)
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to list failed webhook deliveries", err)
+	}
+
+	response := &api.WebhookDeliveryList{}
+	for _, delivery := range deliveries {
+		d := deliveryToProto(delivery)
+		d.WalletId = int32(delivery.WalletID)
+		response.Deliveries = append(response.Deliveries, d)
+	}
+	return response, nil
+}
+
+func walletToProto(w structs.Wallet) *api.Wallet {
+	wallet := &api.Wallet{
+		WalletId:     int32(w.ID),
+		Balance:      w.Balance,
+		Frozen:       !w.FrozenAt.IsZero(),
+		FrozenReason: w.FrozenReason,
+	}
+	if wallet.Frozen {
+		wallet.FrozenAt = timestamppb.New(w.FrozenAt)
+	}
+	return wallet
+}
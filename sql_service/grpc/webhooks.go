@@ -0,0 +1,192 @@
+package sql_service
+
+import (
+	"common/logging"
+	"context"
+	"errors"
+	"net/url"
+	db "sql_service/database"
+	"sql_service/structs"
+	api "sqlapi/pb"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) CreateWebhook(ctx context.Context, req *api.CreateWebhookRequest) (*api.Webhook, error) {
+	ctx = logging.With(ctx, "wallet_id", req.WalletId)
+
+	// Deliveries carry the signing secret's signature and wallet data, so
+	// they only go out over TLS; which addresses they may reach is up to
+	// the delivering worker.
+	u, err := url.Parse(req.Url)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "%q is not an https URL", req.Url)
+	}
+
+	webhook, err := s.Webhooks.CreateWebhook(ctx, int(req.WalletId), req.Url, req.Principal)
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to create webhook", err)
+	}
+	logging.FromContext(ctx).Info("Webhook created", "webhook_id", webhook.ID, "principal", req.Principal)
+
+	response := webhookToProto(webhook)
+	response.Secret = webhook.Secret
+	return response, nil
+}
+
+func (s *Server) ListWebhooks(ctx context.Context, req *api.WalletIdRequest) (*api.WebhookList, error) {
+	webhooks, err := s.Webhooks.ListWebhooks(ctx, int(req.WalletId))
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to list webhooks", err)
+	}
+
+	response := &api.WebhookList{}
+	for _, webhook := range webhooks {
+		response.Webhooks = append(response.Webhooks, webhookToProto(webhook))
+	}
+	return response, nil
+}
+
+func (s *Server) DeleteWebhook(ctx context.Context, req *api.WebhookRef) (*api.Empty, error) {
+	ctx = logging.With(ctx, "wallet_id", req.WalletId, "webhook_id", req.WebhookId)
+
+	if err := s.Webhooks.DeleteWebhook(ctx, int(req.WalletId), req.WebhookId); err != nil {
+		return nil, webhookError(ctx, "Failed to delete webhook", err)
+	}
+	logging.FromContext(ctx).Info("Webhook deleted")
+
+	return &api.Empty{}, nil
+}
+
+func (s *Server) EnableWebhook(ctx context.Context, req *api.WebhookRef) (*api.Webhook, error) {
+	ctx = logging.With(ctx, "wallet_id", req.WalletId, "webhook_id", req.WebhookId)
+
+	webhook, err := s.Webhooks.EnableWebhook(ctx, int(req.WalletId), req.WebhookId)
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to enable webhook", err)
+	}
+	logging.FromContext(ctx).Info("Webhook enabled")
+
+	return webhookToProto(webhook), nil
+}
+
+func (s *Server) ListWebhookDeliveries(ctx context.Context, req *api.WebhookDeliveriesRequest) (*api.WebhookDeliveryList, error) {
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	deliveries, err := s.Webhooks.ListWebhookDeliveries(ctx, int(req.WalletId), req.WebhookId, limit)
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to list webhook deliveries", err)
+	}
+
+	response := &api.WebhookDeliveryList{}
+	for _, delivery := range deliveries {
+		response.Deliveries = append(response.Deliveries, deliveryToProto(delivery))
+	}
+	return response, nil
+}
+
+func (s *Server) RedeliverWebhook(ctx context.Context, req *api.RedeliverRequest) (*api.WebhookDelivery, error) {
+	ctx = logging.With(ctx, "wallet_id", req.WalletId, "webhook_id", req.WebhookId, "delivery_id", req.DeliveryId)
+
+	delivery, err := s.Webhooks.RedeliverWebhook(ctx, int(req.WalletId), req.WebhookId, req.DeliveryId)
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to redeliver webhook", err)
+	}
+	logging.FromContext(ctx).Info("Webhook delivery queued again")
+
+	return deliveryToProto(delivery), nil
+}
+
+func (s *Server) ClaimWebhookDeliveries(ctx context.Context, req *api.ClaimWebhookDeliveriesRequest) (*api.WebhookDeliveryList, error) {
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	deliveries, err := s.Webhooks.ClaimWebhookDeliveries(ctx, limit, structs.Config.WebhookLease)
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to claim webhook deliveries", err)
+	}
+
+	response := &api.WebhookDeliveryList{}
+	for _, delivery := range deliveries {
+		d := deliveryToProto(delivery)
+		d.Url, d.Secret = delivery.URL, delivery.Secret
+		response.Deliveries = append(response.Deliveries, d)
+	}
+	return response, nil
+}
+
+func (s *Server) ReportWebhookDelivery(ctx context.Context, req *api.WebhookAttempt) (*api.Empty, error) {
+	ctx = logging.With(ctx, "delivery_id", req.DeliveryId)
+
+	disabled, err := s.Webhooks.ReportWebhookDelivery(ctx, structs.WebhookAttempt{
+		DeliveryID: req.DeliveryId,
+		Success:    req.Success,
+		StatusCode: int(req.StatusCode),
+		Error:      req.Error,
+		Duration:   time.Duration(req.DurationMs) * time.Millisecond,
+	}, db.WebhookRetryPolicy{
+		MaxAttempts:  structs.Config.WebhookMaxAttempts,
+		Backoff:      structs.Config.WebhookBackoff,
+		BackoffMax:   structs.Config.WebhookBackoffMax,
+		DisableAfter: structs.Config.WebhookDisableAt,
+	})
+	if err != nil {
+		return nil, webhookError(ctx, "Failed to record webhook delivery", err)
+	}
+	if disabled {
+		logging.FromContext(ctx).Warn("Webhook disabled after repeated failures")
+	}
+
+	return &api.Empty{}, nil
+}
+
+// webhookError logs err and turns missing rows into NotFound so callers can
+// tell them from failures.
+func webhookError(ctx context.Context, msg string, err error) error {
+	if errors.Is(err, db.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	logging.FromContext(ctx).Error(msg, "error", err)
+	return err
+}
+
+func webhookToProto(w structs.Webhook) *api.Webhook {
+	return &api.Webhook{
+		WebhookId:           w.ID,
+		WalletId:            int32(w.WalletID),
+		Url:                 w.URL,
+		Enabled:             w.Enabled,
+		ConsecutiveFailures: int32(w.ConsecutiveFailures),
+		DisabledReason:      w.DisabledReason,
+		Principal:           w.Principal,
+		CreatedAt:           timestamppb.New(w.CreatedAt),
+	}
+}
+
+func deliveryToProto(d structs.WebhookDelivery) *api.WebhookDelivery {
+	delivery := &api.WebhookDelivery{
+		DeliveryId:     d.ID,
+		WebhookId:      d.WebhookID,
+		EventId:        d.EventID,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       int32(d.Attempts),
+		NextAttemptAt:  timestamppb.New(d.NextAttemptAt),
+		LastStatusCode: int32(d.LastStatusCode),
+		LastError:      d.LastError,
+		CreatedAt:      timestamppb.New(d.CreatedAt),
+	}
+	if !d.LastAttemptAt.IsZero() {
+		delivery.LastAttemptAt = timestamppb.New(d.LastAttemptAt)
+	}
+	return delivery
+}
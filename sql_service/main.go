@@ -1,41 +1,126 @@
 package main
 
 import (
+	"common/config"
+	"common/logging"
+	"common/tlsconfig"
+	"common/tracing"
+	"context"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"sql_service/authz"
 	database "sql_service/database"
 	sql_service "sql_service/grpc"
-	api "sql_service/grpc/proto"
+	"sql_service/partitions"
+	"sql_service/reconcile"
+	"sql_service/structs"
+	api "sqlapi/pb"
 
-	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
 	InitConfig()
-	database.InitDB() // +migration
-	ListenerGrpcServer()
+	slog.SetDefault(logging.New(structs.Config.LogLevel))
+	config.Print(&structs.Config)
+
+	shutdownTracing, err := tracing.Init(context.Background(), "sql_service", structs.Config.TracingExporter, structs.Config.OTLPEndpoint)
+	if err != nil {
+		logging.Fatal("Failed to init tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	store := database.NewPostgres(database.InitDB())
+
+	go ListenerMetrics()
+	if structs.Config.ReconcileInterval > 0 {
+		go reconcile.Worker(context.Background(), store, structs.Config.ReconcileInterval, reconcile.Options{
+			Grace:   structs.Config.ReconcileGrace,
+			Propose: structs.Config.ReconcilePropose,
+		})
+	}
+
+	if structs.Config.PartitionInterval > 0 {
+		go partitions.Worker(context.Background(), store, structs.Config.PartitionInterval, PartitionOptions())
+	}
+
+	ListenerGrpcServer(&sql_service.Server{
+		Wallets:      store,
+		Transactions: store,
+		Webhooks:     store,
+		Batches:      store,
+		Adjustments:  store,
+	})
 }
 
 func InitConfig() {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err := config.Load(&structs.Config, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func PartitionOptions() partitions.Options {
+	return partitions.Options{
+		MonthsAhead:     structs.Config.PartitionAhead,
+		RetentionMonths: structs.Config.RetentionMonths,
+		ArchiveDir:      structs.Config.ArchiveDir,
+		Drop:            structs.Config.ArchiveDrop,
+	}
+}
+
+func ListenerMetrics() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if err := http.ListenAndServe(structs.Config.MetricsAddress, mux); err != nil {
+		logging.Fatal("Metrics server failed", "error", err)
 	}
 }
 
-func ListenerGrpcServer() {
-	server := grpc.NewServer()
-	sqlService := &sql_service.Server{}
+func ListenerGrpcServer(sqlService *sql_service.Server) {
+	interceptors := []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor()}
+	options := []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}
+
+	tlsFiles := tlsconfig.Files{
+		CertFile: structs.Config.TLSCertFile,
+		KeyFile:  structs.Config.TLSKeyFile,
+		CAFile:   structs.Config.TLSCAFile,
+	}
+	plaintext, err := tlsFiles.Plaintext(structs.Config.InsecureDev)
+	if err != nil {
+		logging.Fatal("Refusing to serve gRPC", "error", err)
+	}
+	if !plaintext {
+		reloader, err := tlsconfig.NewReloader(context.Background(), tlsFiles, structs.Config.TLSReloadInterval)
+		if err != nil {
+			logging.Fatal("Failed to load TLS certificates", "error", err)
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
+		interceptors = append(interceptors, authz.UnaryServerInterceptor(authz.Policy{
+			Readers:   structs.Config.GRPCReaders,
+			Writers:   structs.Config.GRPCWriters,
+			Managers:  structs.Config.GRPCManagers,
+			Operators: structs.Config.GRPCOperators,
+		}))
+	} else {
+		slog.Warn("INSECURE_DEV is set: the gRPC server accepts unauthenticated plaintext connections")
+	}
+
+	options = append(options, grpc.ChainUnaryInterceptor(interceptors...))
+	server := grpc.NewServer(options...)
 
 	api.RegisterSQLServiceServer(server, sqlService)
 
-	listener, err := net.Listen("tcp", (os.Getenv("SQL_SERVICE_ADDRESS")))
+	listener, err := net.Listen("tcp", structs.Config.ListenAddress)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		logging.Fatal("Failed to listen", "error", err)
 	}
 	if err := server.Serve(listener); err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+		logging.Fatal("Failed to serve", "error", err)
 	}
 }
@@ -0,0 +1,69 @@
+package partitions
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"sql_service/structs"
+)
+
+// archiveFile writes the rows of one partition as gzipped newline
+// delimited JSON. It writes to a temporary file next to the final one and
+// only renames it into place on Commit, so a file under the final name is
+// always complete.
+type archiveFile struct {
+	path     string
+	file     *os.File
+	buffered *bufio.Writer
+	gzip     *gzip.Writer
+	encoder  *json.Encoder
+}
+
+// newArchiveFile creates the archive of partition in dir, which is
+// created if needed.
+func newArchiveFile(dir, partition string) (*archiveFile, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, partition+".ndjson.gz")
+	file, err := os.CreateTemp(dir, "."+partition+"-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	a := &archiveFile{path: path, file: file, buffered: bufio.NewWriter(file)}
+	a.gzip = gzip.NewWriter(a.buffered)
+	a.encoder = json.NewEncoder(a.gzip)
+	return a, nil
+}
+
+func (a *archiveFile) Write(t structs.ArchivedTransaction) error {
+	return a.encoder.Encode(t)
+}
+
+func (a *archiveFile) Commit() (string, error) {
+	if err := a.gzip.Close(); err != nil {
+		return "", err
+	}
+	if err := a.buffered.Flush(); err != nil {
+		return "", err
+	}
+	if err := a.file.Sync(); err != nil {
+		return "", err
+	}
+	if err := a.file.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(a.file.Name(), a.path); err != nil {
+		return "", err
+	}
+	return a.path, nil
+}
+
+// Close removes the temporary file unless the archive was committed.
+func (a *archiveFile) Close() {
+	a.file.Close()
+	os.Remove(a.file.Name())
+}
@@ -0,0 +1,112 @@
+package partitions
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sql_service/structs"
+)
+
+func TestArchiveFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	walletID := 1
+	rows := []structs.ArchivedTransaction{
+		{TransactionID: "0b5e8f0c-5f4b-4d0c-9d7e-2f1c3a4b5c6d", WalletID: &walletID, Value: "100.00", Type: "deposit",
+			Status: "Success", TransactionTime: "2024-01-05 10:00:00.00", CreatedAt: time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)},
+		{TransactionID: "1c6f9a1d-6a5c-4e1d-8e8f-3a2d4b5c6d7e", Value: "0.10", Type: "withdraw",
+			Status: "Failed", TransactionTime: "2024-01-31 23:59:59.99", CreatedAt: time.Date(2024, 1, 31, 23, 59, 59, 990000000, time.UTC)},
+	}
+
+	a, err := newArchiveFile(dir, "transactions_p202401")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	for _, row := range rows {
+		if err := a.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "transactions_p202401.ndjson.gz")); !os.IsNotExist(err) {
+		t.Fatalf("archive is in place before it is committed: %v", err)
+	}
+	path, err := a.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "transactions_p202401.ndjson.gz" {
+		t.Fatalf("archive directory holds %v, want only the archive", entries)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []structs.ArchivedTransaction
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var row structs.ArchivedTransaction
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("line %d: %v", len(got)+1, err)
+		}
+		got = append(got, row)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(rows) {
+		t.Fatalf("read %d rows, want %d", len(got), len(rows))
+	}
+	if *got[0].WalletID != 1 || got[1].WalletID != nil || got[1].Value != "0.10" || !got[1].CreatedAt.Equal(rows[1].CreatedAt) {
+		t.Errorf("read %+v, want %+v", got, rows)
+	}
+}
+
+func TestAbandonedArchiveLeavesNothing(t *testing.T) {
+	dir := t.TempDir()
+	a, err := newArchiveFile(dir, "transactions_p202401")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Write(structs.ArchivedTransaction{TransactionID: "0b5e8f0c-5f4b-4d0c-9d7e-2f1c3a4b5c6d"}); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("an abandoned archive left %v behind", entries)
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	for months, want := range map[int]time.Time{
+		1:  time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		3:  time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		12: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if got := RetentionCutoff(now, months); !got.Equal(want) {
+			t.Errorf("%d months: cutoff %s, want %s", months, got, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package partitions keeps the monthly partitions of the transactions
+// table: it creates the coming months' partitions ahead of time and
+// archives the partitions past the retention period, exporting their rows
+// to files before detaching them.
+package partitions
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"common/logging"
+	database "sql_service/database"
+	"sql_service/structs"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	created = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sql_transaction_partitions_created_total",
+		Help: "Transaction partitions created ahead of time.",
+	})
+
+	archived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sql_transaction_partitions_archived_total",
+		Help: "Transaction partitions exported and detached.",
+	})
+
+	lastRun = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sql_transaction_partitions_last_run_timestamp_seconds",
+		Help: "Unix time of the last successful maintenance run.",
+	})
+
+	runs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sql_transaction_partitions_runs_total",
+		Help: "Partition maintenance runs by result (ok, error).",
+	}, []string{"result"})
+)
+
+// Options control a maintenance run.
+type Options struct {
+	// MonthsAhead is how many months after the current one get their
+	// partition in advance.
+	MonthsAhead int
+	// RetentionMonths archives the partitions that ended more than this
+	// many months before the current one began; 0 archives nothing.
+	RetentionMonths int
+	// ArchiveDir is where archives are written.
+	ArchiveDir string
+	// Drop drops archived partitions instead of leaving them detached.
+	Drop bool
+}
+
+// Run creates the missing partitions of store and archives the expired ones
+// once.
+func Run(ctx context.Context, store database.PartitionStore, opts Options) (report structs.PartitionReport, err error) {
+	defer func() {
+		if err != nil {
+			runs.WithLabelValues("error").Inc()
+			return
+		}
+		runs.WithLabelValues("ok").Inc()
+		lastRun.SetToCurrentTime()
+	}()
+
+	now := time.Now()
+	report.Created, err = store.EnsurePartitions(ctx, now, opts.MonthsAhead)
+	created.Add(float64(len(report.Created)))
+	for _, p := range report.Created {
+		logging.FromContext(ctx).Info("Partition created", "partition", p.Name, "from", p.From, "to", p.To)
+	}
+	if err != nil || opts.RetentionMonths <= 0 {
+		return report, err
+	}
+
+	partitions, err := store.ListPartitions(ctx)
+	if err != nil {
+		return report, err
+	}
+	cutoff := RetentionCutoff(now, opts.RetentionMonths)
+	for _, p := range partitions {
+		if !p.ArchivedAt.IsZero() || p.To.After(cutoff) {
+			continue
+		}
+		p, err := Archive(ctx, store, p.Name, opts)
+		if err != nil {
+			return report, err
+		}
+		report.Archived = append(report.Archived, p)
+	}
+	return report, nil
+}
+
+// RetentionCutoff is the end a partition must not be after to be archived
+// with a retention of months: the start of the month that many months
+// before the one of now.
+func RetentionCutoff(now time.Time, months int) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()-time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Archive exports the partition name to a file in opts.ArchiveDir and
+// detaches it.
+func Archive(ctx context.Context, store database.PartitionStore, name string, opts Options) (structs.TransactionPartition, error) {
+	file, err := newArchiveFile(opts.ArchiveDir, name)
+	if err != nil {
+		return structs.TransactionPartition{}, err
+	}
+	defer file.Close()
+
+	p, err := store.ArchivePartition(ctx, name, file, opts.Drop)
+	if err != nil {
+		return p, err
+	}
+	archived.Inc()
+	logging.FromContext(ctx).Info("Partition archived",
+		"partition", p.Name,
+		"rows", p.Rows,
+		"archive_file", p.ArchiveFile,
+		"dropped", opts.Drop)
+	return p, nil
+}
+
+// Worker runs maintenance every interval until ctx is done, starting right
+// away so that a new deployment has its partitions before they are needed.
+func Worker(ctx context.Context, store database.PartitionStore, interval time.Duration, opts Options) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := Run(ctx, store, opts)
+		if err != nil {
+			slog.Error("Partition maintenance failed", "error", err)
+		} else {
+			slog.Info("Partition maintenance finished",
+				"created", len(report.Created),
+				"archived", len(report.Archived))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package reconcile checks wallet balances against their transaction
+// history, publishes the result as metrics and optionally proposes
+// correcting adjustments for an operator to approve.
+package reconcile
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"common/logging"
+	database "sql_service/database"
+	"sql_service/structs"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	mismatchedWallets = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sql_reconcile_mismatched_wallets",
+		Help: "Wallets whose balance did not match their transactions in the last full run.",
+	})
+
+	walletDifference = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sql_reconcile_wallet_difference",
+		Help: "Balance minus the sum of successful transactions, per mismatched wallet.",
+	}, []string{"wallet_id"})
+
+	lastRun = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sql_reconcile_last_run_timestamp_seconds",
+		Help: "Unix time of the last successful full run.",
+	})
+
+	runs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sql_reconcile_runs_total",
+		Help: "Reconciliation runs by result (ok, mismatch, error).",
+	}, []string{"result"})
+)
+
+// Options control a reconciliation run.
+type Options struct {
+	// WalletID limits the run to one wallet; 0 checks all of them.
+	WalletID int
+	// Grace skips wallets changed more recently than this.
+	Grace time.Duration
+	// Propose records a pending adjustment for every mismatch.
+	Propose bool
+}
+
+// Run reconciles the wallets of store once. Full runs (WalletID 0) also
+// update metrics.
+func Run(ctx context.Context, store database.AdjustmentStore, opts Options) (structs.ReconcileReport, error) {
+	report, err := store.ReconcileWallets(ctx, opts.WalletID, opts.Grace)
+	if err != nil {
+		runs.WithLabelValues("error").Inc()
+		return report, err
+	}
+
+	for i, m := range report.Mismatches {
+		logging.FromContext(ctx).Warn("Balance does not match transactions",
+			"wallet_id", m.WalletID,
+			"balance", m.Balance,
+			"expected_balance", m.ExpectedBalance,
+			"difference", m.Difference,
+			"window_start", m.WindowStart,
+			"window_end", m.WindowEnd,
+			"unrecorded_transaction_ids", m.UnrecordedTransactionIDs,
+		)
+		if opts.Propose {
+			id, err := store.ProposeAdjustment(ctx, m)
+			if err != nil {
+				runs.WithLabelValues("error").Inc()
+				return report, err
+			}
+			report.Mismatches[i].AdjustmentID = id
+			logging.FromContext(ctx).Info("Adjustment proposed", "wallet_id", m.WalletID, "adjustment_id", id)
+		}
+	}
+
+	if len(report.Mismatches) > 0 {
+		runs.WithLabelValues("mismatch").Inc()
+	} else {
+		runs.WithLabelValues("ok").Inc()
+	}
+
+	if opts.WalletID == 0 {
+		mismatchedWallets.Set(float64(len(report.Mismatches)))
+		walletDifference.Reset()
+		for _, m := range report.Mismatches {
+			walletDifference.WithLabelValues(strconv.Itoa(m.WalletID)).Set(m.Difference)
+		}
+		lastRun.Set(float64(report.CheckedAt.Unix()))
+	}
+
+	return report, nil
+}
+
+// Worker runs a full reconciliation every interval until ctx is done.
+func Worker(ctx context.Context, store database.AdjustmentStore, interval time.Duration, opts Options) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := Run(ctx, store, opts)
+		if err != nil {
+			slog.Error("Reconciliation failed", "error", err)
+		} else {
+			slog.Info("Reconciliation finished",
+				"checked", report.Checked,
+				"skipped", report.Skipped,
+				"mismatches", len(report.Mismatches))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
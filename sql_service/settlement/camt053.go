@@ -0,0 +1,183 @@
+package settlement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The subset of ISO 20022 camt.053 (BankToCustomerStatement) needed for
+// settlement. Element names are the same in versions .001.02 to .001.10;
+// namespaces are ignored.
+type camtDocument struct {
+	XMLName    xml.Name        `xml:"Document"`
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	Other   string      `xml:"Acct>Id>Othr>Id"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtEntry struct {
+	EntryRef            string          `xml:"NtryRef"`
+	Amount              camtAmount      `xml:"Amt"`
+	CreditDebit         string          `xml:"CdtDbtInd"`
+	Status              camtStatus      `xml:"Sts"`
+	BookingDate         camtDate        `xml:"BookgDt"`
+	ValueDate           camtDate        `xml:"ValDt"`
+	AccountServicerRef  string          `xml:"AcctSvcrRef"`
+	Details             []camtTxDetails `xml:"NtryDtls>TxDtls"`
+	AdditionalEntryInfo string          `xml:"AddtlNtryInf"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtStatus is plain text up to .001.06 and <Cd> from .001.08 on.
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtTxDetails struct {
+	EndToEndID      string     `xml:"Refs>EndToEndId"`
+	Amount          camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	Unstructured    []string   `xml:"RmtInf>Ustrd"`
+	CreditorRef     string     `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AdditionalTxInf string     `xml:"AddtlTxInf"`
+}
+
+// ParseCamt053 reads the booked entries of every statement in a camt.053
+// document. An entry that batches several transactions with their own
+// amounts becomes one Entry per transaction.
+func ParseCamt053(r io.Reader) (Statement, error) {
+	var document camtDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return Statement{}, fmt.Errorf("decoding camt.053: %w", err)
+	}
+	if len(document.Statements) == 0 {
+		return Statement{}, fmt.Errorf("camt.053 document has no statements")
+	}
+
+	var statement Statement
+	number := 0
+	for _, stmt := range document.Statements {
+		if statement.Account == "" {
+			statement.Account = firstNonEmpty(stmt.IBAN, stmt.Other)
+		}
+
+		for _, ntry := range stmt.Entries {
+			number++
+			status := strings.TrimSpace(firstNonEmpty(ntry.Status.Code, ntry.Status.Text))
+			if status != "" && status != "BOOK" {
+				continue
+			}
+
+			entries, err := camtEntries(ntry, number)
+			if err != nil {
+				return Statement{}, err
+			}
+			statement.Entries = append(statement.Entries, entries...)
+		}
+	}
+
+	return statement, nil
+}
+
+func camtEntries(ntry camtEntry, number int) ([]Entry, error) {
+	base := Entry{Line: number, Currency: ntry.Amount.Currency}
+
+	switch ntry.CreditDebit {
+	case "CRDT":
+		base.Direction = Credit
+	case "DBIT":
+		base.Direction = Debit
+	default:
+		return nil, fmt.Errorf("entry %d: unknown CdtDbtInd %q", number, ntry.CreditDebit)
+	}
+
+	var err error
+	date := firstNonEmpty(ntry.BookingDate.Date, ntry.BookingDate.DateTime, ntry.ValueDate.Date, ntry.ValueDate.DateTime)
+	if base.BookingDate, err = parseDate(date); err != nil {
+		return nil, fmt.Errorf("entry %d: %w", number, err)
+	}
+
+	fallbackRef := firstNonEmpty(ntry.AccountServicerRef, ntry.EntryRef)
+
+	// Split batched entries only when every transaction carries its amount.
+	split := len(ntry.Details) > 1
+	for _, d := range ntry.Details {
+		split = split && d.Amount.Value != ""
+	}
+	if split {
+		entries := make([]Entry, 0, len(ntry.Details))
+		for _, d := range ntry.Details {
+			entry := base
+			if entry.Amount, err = camtAmountValue(d.Amount, number); err != nil {
+				return nil, err
+			}
+			if d.Amount.Currency != "" {
+				entry.Currency = d.Amount.Currency
+			}
+			entry.Reference, entry.Description = camtReference(d, fallbackRef)
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	}
+
+	if base.Amount, err = camtAmountValue(ntry.Amount, number); err != nil {
+		return nil, err
+	}
+	details := camtTxDetails{}
+	if len(ntry.Details) > 0 {
+		details = ntry.Details[0]
+	}
+	base.Reference, base.Description = camtReference(details, fallbackRef)
+	if base.Description == "" {
+		base.Description = strings.TrimSpace(ntry.AdditionalEntryInfo)
+	}
+
+	return []Entry{base}, nil
+}
+
+// camtReference prefers the end-to-end id set by the payer, then the
+// structured creditor reference, then the free-text remittance information.
+func camtReference(d camtTxDetails, fallback string) (reference, description string) {
+	parts := append([]string{}, d.Unstructured...)
+	description = strings.TrimSpace(strings.Join(append(parts, d.AdditionalTxInf), " "))
+
+	endToEnd := strings.TrimSpace(d.EndToEndID)
+	if strings.EqualFold(endToEnd, "NOTPROVIDED") {
+		endToEnd = ""
+	}
+	reference = firstNonEmpty(endToEnd, strings.TrimSpace(d.CreditorRef), description, fallback)
+	return reference, description
+}
+
+func camtAmountValue(amount camtAmount, number int) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount.Value), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("entry %d: %q is not an amount", number, amount.Value)
+	}
+	return value, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
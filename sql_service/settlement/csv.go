@@ -0,0 +1,127 @@
+package settlement
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumns maps the header names banks commonly use to Entry fields.
+var csvColumns = map[string]string{
+	"date":          "date",
+	"booking_date":  "date",
+	"booking date":  "date",
+	"amount":        "amount",
+	"currency":      "currency",
+	"reference":     "reference",
+	"ref":           "reference",
+	"end_to_end_id": "reference",
+	"description":   "description",
+	"details":       "description",
+	"remittance":    "description",
+	"direction":     "direction",
+	"credit_debit":  "direction",
+}
+
+// ParseCSV reads a statement with a header row. Columns are found by name
+// (date, amount and reference are required); the separator may be ',' or
+// ';'. Without a direction column the sign of the amount decides it.
+func ParseCSV(r io.Reader) (Statement, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(buffered.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return Statement{}, err
+	}
+	firstLine, _, _ := strings.Cut(string(header), "\n")
+
+	reader := csv.NewReader(buffered)
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+
+	names, err := reader.Read()
+	if err != nil {
+		return Statement{}, fmt.Errorf("reading header: %w", err)
+	}
+	index := make(map[string]int)
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[name]; ok {
+			index[field] = i
+		}
+	}
+	for _, required := range []string{"date", "amount", "reference"} {
+		if _, ok := index[required]; !ok {
+			return Statement{}, fmt.Errorf("header has no %s column", required)
+		}
+	}
+
+	var statement Statement
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Statement{}, err
+		}
+
+		column := func(field string) string {
+			if i, ok := index[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		entry := Entry{
+			Line:        line,
+			Reference:   column("reference"),
+			Currency:    column("currency"),
+			Description: column("description"),
+		}
+		if entry.BookingDate, err = parseDate(column("date")); err != nil {
+			return Statement{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.Amount, err = parseAmount(column("amount"), reader.Comma); err != nil {
+			return Statement{}, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		entry.Direction = Credit
+		switch strings.ToLower(column("direction")) {
+		case "":
+			if entry.Amount < 0 {
+				entry.Direction = Debit
+			}
+		case "credit", "crdt", "cr", "c":
+		case "debit", "dbit", "dr", "d":
+			entry.Direction = Debit
+		default:
+			return Statement{}, fmt.Errorf("line %d: unknown direction %q", line, column("direction"))
+		}
+		if entry.Amount < 0 {
+			entry.Amount = -entry.Amount
+		}
+
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	return statement, nil
+}
+
+// parseAmount accepts "1234.56" and, in ';'-separated files, "1.234,56".
+func parseAmount(raw string, comma rune) (float64, error) {
+	cleaned := strings.NewReplacer(" ", "", "\u00a0", "").Replace(raw)
+	if comma == ';' && strings.Contains(cleaned, ",") {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.ReplaceAll(cleaned, ",", ".")
+	}
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an amount", raw)
+	}
+	return amount, nil
+}
@@ -0,0 +1,170 @@
+package settlement
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Candidate is a successful, not yet settled wallet transaction.
+type Candidate struct {
+	TransactionID string    `json:"transaction_id"`
+	WalletID      int       `json:"wallet_id"`
+	Amount        float64   `json:"amount"`
+	Type          string    `json:"type"`
+	Time          time.Time `json:"time"`
+}
+
+// Tolerance bounds how far a statement entry may be from a transaction and
+// still match it.
+type Tolerance struct {
+	Amount float64
+	Date   time.Duration
+}
+
+// Match methods.
+const (
+	ByReference  = "reference"
+	ByAmountDate = "amount_date"
+)
+
+type Match struct {
+	Entry       Entry     `json:"entry"`
+	Transaction Candidate `json:"transaction"`
+	By          string    `json:"by"`
+}
+
+type UnmatchedEntry struct {
+	Entry  Entry  `json:"entry"`
+	Reason string `json:"reason"`
+}
+
+type Report struct {
+	Matched               []Match          `json:"matched"`
+	UnmatchedEntries      []UnmatchedEntry `json:"unmatched_entries"`
+	UnmatchedTransactions []Candidate      `json:"unmatched_transactions"`
+}
+
+var uuidPattern = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// MatchEntries pairs statement entries with transactions, each at most
+// once. An entry whose reference or description names a transaction id is
+// matched to that transaction only; the others are matched to the unique
+// closest transaction of the same direction within the tolerance.
+func MatchEntries(entries []Entry, candidates []Candidate, tolerance Tolerance) Report {
+	var report Report
+
+	byID := make(map[string]int, len(candidates))
+	for i, c := range candidates {
+		byID[strings.ToLower(c.TransactionID)] = i
+	}
+	used := make([]bool, len(candidates))
+	var rest []Entry
+
+	for _, entry := range entries {
+		i, found := -1, false
+		for _, id := range uuidPattern.FindAllString(entry.Reference+" "+entry.Description, -1) {
+			if i, found = byID[strings.ToLower(id)]; found {
+				break
+			}
+		}
+		if !found {
+			rest = append(rest, entry)
+			continue
+		}
+
+		candidate := candidates[i]
+		switch {
+		case used[i]:
+			report.UnmatchedEntries = append(report.UnmatchedEntries, UnmatchedEntry{entry,
+				fmt.Sprintf("transaction %s is already matched to another entry", candidate.TransactionID)})
+		case candidate.Type != entry.Direction.TransactionType():
+			report.UnmatchedEntries = append(report.UnmatchedEntries, UnmatchedEntry{entry,
+				fmt.Sprintf("transaction %s is a %s, entry is a %s", candidate.TransactionID, candidate.Type, entry.Direction)})
+		case math.Abs(candidate.Amount-entry.Amount) > tolerance.Amount:
+			report.UnmatchedEntries = append(report.UnmatchedEntries, UnmatchedEntry{entry,
+				fmt.Sprintf("transaction %s amount %.2f differs from %.2f", candidate.TransactionID, candidate.Amount, entry.Amount)})
+		default:
+			used[i] = true
+			report.Matched = append(report.Matched, Match{Entry: entry, Transaction: candidate, By: ByReference})
+		}
+	}
+
+	for _, entry := range rest {
+		best, ambiguous := -1, false
+		for i, c := range candidates {
+			if used[i] || !withinTolerance(entry, c, tolerance) {
+				continue
+			}
+			if best == -1 {
+				best = i
+				continue
+			}
+			switch compareDistance(entry, c, candidates[best]) {
+			case -1:
+				best, ambiguous = i, false
+			case 0:
+				ambiguous = true
+			}
+		}
+
+		switch {
+		case best == -1:
+			report.UnmatchedEntries = append(report.UnmatchedEntries, UnmatchedEntry{entry,
+				"no transaction with this reference, amount and date"})
+		case ambiguous:
+			report.UnmatchedEntries = append(report.UnmatchedEntries, UnmatchedEntry{entry,
+				"several transactions match amount and date equally well"})
+		default:
+			used[best] = true
+			report.Matched = append(report.Matched, Match{Entry: entry, Transaction: candidates[best], By: ByAmountDate})
+		}
+	}
+
+	for i, c := range candidates {
+		if !used[i] {
+			report.UnmatchedTransactions = append(report.UnmatchedTransactions, c)
+		}
+	}
+
+	return report
+}
+
+func withinTolerance(entry Entry, c Candidate, tolerance Tolerance) bool {
+	return c.Type == entry.Direction.TransactionType() &&
+		math.Abs(c.Amount-entry.Amount) <= tolerance.Amount &&
+		dateDistance(entry, c) <= tolerance.Date
+}
+
+// dateDistance compares calendar days: statements carry booking dates only.
+func dateDistance(entry Entry, c Candidate) time.Duration {
+	entryDay := time.Date(entry.BookingDate.Year(), entry.BookingDate.Month(), entry.BookingDate.Day(), 0, 0, 0, 0, time.UTC)
+	txDay := time.Date(c.Time.Year(), c.Time.Month(), c.Time.Day(), 0, 0, 0, 0, time.UTC)
+	d := entryDay.Sub(txDay)
+	if d < 0 {
+		d = -d
+	}
+	return d
+}
+
+// compareDistance orders a and b by how close they are to entry: amount
+// first, then date.
+func compareDistance(entry Entry, a, b Candidate) int {
+	amountA, amountB := math.Abs(a.Amount-entry.Amount), math.Abs(b.Amount-entry.Amount)
+	if amountA != amountB {
+		if amountA < amountB {
+			return -1
+		}
+		return 1
+	}
+	dateA, dateB := dateDistance(entry, a), dateDistance(entry, b)
+	switch {
+	case dateA < dateB:
+		return -1
+	case dateA > dateB:
+		return 1
+	}
+	return 0
+}
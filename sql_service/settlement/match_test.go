@@ -0,0 +1,174 @@
+package settlement
+
+import (
+	"testing"
+	"time"
+)
+
+var seedTransactions = []Candidate{
+	{TransactionID: "f47cbde3-98d8-47cb-a30b-1046b1f70b75", WalletID: 1, Amount: 100, Type: "deposit", Time: date("2023-11-06").Add(12 * time.Hour)},
+	{TransactionID: "5e7e68f0-30d6-4d4b-8411-7f75e3b63f27", WalletID: 2, Amount: 200, Type: "deposit", Time: date("2023-11-06").Add(12 * time.Hour)},
+	{TransactionID: "f4c94427-d2a9-49ac-bb5a-e7a7d2db6d4d", WalletID: 3, Amount: 75, Type: "deposit", Time: date("2023-11-06").Add(12 * time.Hour)},
+	{TransactionID: "0d7f1c4e-58a4-4d4e-9b53-0c5cf1b0a7d2", WalletID: 3, Amount: 50, Type: "withdraw", Time: date("2023-11-06").Add(23 * time.Hour)},
+	{TransactionID: "9a1e0a39-5a5d-4f0b-8c66-2ef4c0f0d0a1", WalletID: 1, Amount: 30, Type: "deposit", Time: date("2023-11-01")},
+}
+
+var defaultTolerance = Tolerance{Amount: 0.01, Date: 72 * time.Hour}
+
+func TestMatchCamt053Fixture(t *testing.T) {
+	statement := parseFixture(t, "camt053.xml")
+
+	report := MatchEntries(statement.Entries, seedTransactions, defaultTolerance)
+
+	matched := map[string]string{}
+	for _, m := range report.Matched {
+		matched[m.Transaction.TransactionID] = m.By
+	}
+	want := map[string]string{
+		"f47cbde3-98d8-47cb-a30b-1046b1f70b75": ByReference,
+		"5e7e68f0-30d6-4d4b-8411-7f75e3b63f27": ByAmountDate,
+		"f4c94427-d2a9-49ac-bb5a-e7a7d2db6d4d": ByReference,
+		"0d7f1c4e-58a4-4d4e-9b53-0c5cf1b0a7d2": ByAmountDate,
+	}
+	if len(matched) != len(want) {
+		t.Fatalf("matched %v, want %v", matched, want)
+	}
+	for id, by := range want {
+		if matched[id] != by {
+			t.Errorf("transaction %s matched by %q, want %q", id, matched[id], by)
+		}
+	}
+
+	if len(report.UnmatchedEntries) != 0 {
+		t.Errorf("unexpected unmatched entries %+v", report.UnmatchedEntries)
+	}
+	if len(report.UnmatchedTransactions) != 1 || report.UnmatchedTransactions[0].WalletID != 1 || report.UnmatchedTransactions[0].Amount != 30 {
+		t.Errorf("unmatched transactions = %+v", report.UnmatchedTransactions)
+	}
+}
+
+func TestMatchCSVFixtureUnmatchedEntry(t *testing.T) {
+	statement := parseFixture(t, "statement.csv")
+
+	report := MatchEntries(statement.Entries, seedTransactions, defaultTolerance)
+
+	if len(report.Matched) != 3 {
+		t.Errorf("matched %d entries, want 3: %+v", len(report.Matched), report.Matched)
+	}
+	if len(report.UnmatchedEntries) != 1 || report.UnmatchedEntries[0].Entry.Reference != "UNKNOWN-1" {
+		t.Fatalf("unmatched entries = %+v", report.UnmatchedEntries)
+	}
+}
+
+func TestMatchEntries(t *testing.T) {
+	deposit := func(id string, amount float64, day string) Candidate {
+		return Candidate{TransactionID: id, Amount: amount, Type: "deposit", Time: date(day)}
+	}
+	credit := func(ref string, amount float64, day string) Entry {
+		return Entry{Reference: ref, Amount: amount, Direction: Credit, BookingDate: date(day)}
+	}
+	const idA = "11111111-1111-4111-8111-111111111111"
+	const idB = "22222222-2222-4222-8222-222222222222"
+
+	tests := []struct {
+		name       string
+		entries    []Entry
+		candidates []Candidate
+		tolerance  Tolerance
+		matched    []string
+		reasons    []string
+	}{
+		{
+			name:       "reference wins over a closer amount",
+			entries:    []Entry{credit("payment "+idB, 10, "2023-11-06")},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-06"), deposit(idB, 10.01, "2023-11-06")},
+			tolerance:  defaultTolerance,
+			matched:    []string{idB},
+		},
+		{
+			name:       "reference with wrong amount is not matched elsewhere",
+			entries:    []Entry{credit(idB, 10, "2023-11-06")},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-06"), deposit(idB, 12, "2023-11-06")},
+			tolerance:  defaultTolerance,
+			reasons:    []string{"transaction " + idB + " amount 12.00 differs from 10.00"},
+		},
+		{
+			name:       "amount tolerance",
+			entries:    []Entry{credit("x", 9.5, "2023-11-06")},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-06")},
+			tolerance:  Tolerance{Amount: 0.5, Date: 0},
+			matched:    []string{idA},
+		},
+		{
+			name:       "date tolerance",
+			entries:    []Entry{credit("x", 10, "2023-11-10")},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-06")},
+			tolerance:  defaultTolerance,
+			reasons:    []string{"no transaction with this reference, amount and date"},
+		},
+		{
+			name:       "closest date wins",
+			entries:    []Entry{credit("x", 10, "2023-11-07")},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-05"), deposit(idB, 10, "2023-11-06")},
+			tolerance:  defaultTolerance,
+			matched:    []string{idB},
+		},
+		{
+			name:       "ties are ambiguous",
+			entries:    []Entry{credit("x", 10, "2023-11-06")},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-05"), deposit(idB, 10, "2023-11-07")},
+			tolerance:  defaultTolerance,
+			reasons:    []string{"several transactions match amount and date equally well"},
+		},
+		{
+			name:       "direction must agree",
+			entries:    []Entry{{Reference: "x", Amount: 10, Direction: Debit, BookingDate: date("2023-11-06")}},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-06")},
+			tolerance:  defaultTolerance,
+			reasons:    []string{"no transaction with this reference, amount and date"},
+		},
+		{
+			name:       "each transaction matches once",
+			entries:    []Entry{credit("x", 10, "2023-11-06"), credit("y", 10, "2023-11-06")},
+			candidates: []Candidate{deposit(idA, 10, "2023-11-06")},
+			tolerance:  defaultTolerance,
+			matched:    []string{idA},
+			reasons:    []string{"no transaction with this reference, amount and date"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := MatchEntries(tt.entries, tt.candidates, tt.tolerance)
+
+			var matched, reasons []string
+			for _, m := range report.Matched {
+				matched = append(matched, m.Transaction.TransactionID)
+			}
+			for _, u := range report.UnmatchedEntries {
+				reasons = append(reasons, u.Reason)
+			}
+			if !equal(matched, tt.matched) {
+				t.Errorf("matched %v, want %v", matched, tt.matched)
+			}
+			if !equal(reasons, tt.reasons) {
+				t.Errorf("unmatched reasons %q, want %q", reasons, tt.reasons)
+			}
+			if got := len(report.Matched) + len(report.UnmatchedTransactions); got != len(tt.candidates) {
+				t.Errorf("%d transactions reported, want %d", got, len(tt.candidates))
+			}
+		})
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+// Package settlement reads bank statement files and matches their entries
+// against wallet transactions. Parsing and matching do not touch the
+// database, so both can be exercised from the fixtures in testdata.
+package settlement
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Direction tells whether money arrived at or left the bank account.
+type Direction string
+
+const (
+	Credit Direction = "credit"
+	Debit  Direction = "debit"
+)
+
+// TransactionType is the wallet transaction type a direction settles.
+func (d Direction) TransactionType() string {
+	if d == Debit {
+		return "withdraw"
+	}
+	return "deposit"
+}
+
+// Entry is one booked movement on a statement. Amount is always positive.
+type Entry struct {
+	Line        int       `json:"line"`
+	Reference   string    `json:"reference"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency,omitempty"`
+	Direction   Direction `json:"direction"`
+	BookingDate time.Time `json:"booking_date"`
+	Description string    `json:"description,omitempty"`
+}
+
+type Statement struct {
+	Account string  `json:"account,omitempty"`
+	Entries []Entry `json:"entries"`
+}
+
+// Format names accepted by Parse.
+const (
+	FormatCSV     = "csv"
+	FormatCamt053 = "camt053"
+)
+
+// DetectFormat guesses the format from the file name, then from the content.
+func DetectFormat(name string, head []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".txt":
+		return FormatCSV
+	case ".xml":
+		return FormatCamt053
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(head), []byte("<")) {
+		return FormatCamt053
+	}
+	return FormatCSV
+}
+
+// Parse reads a statement in the given format.
+func Parse(format string, r io.Reader) (Statement, error) {
+	switch format {
+	case FormatCSV:
+		return ParseCSV(r)
+	case FormatCamt053:
+		return ParseCamt053(r)
+	}
+	return Statement{}, fmt.Errorf("unknown statement format %q", format)
+}
+
+var dateLayouts = []string{"2006-01-02", "02.01.2006", time.RFC3339, "2006-01-02T15:04:05"}
+
+func parseDate(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date", raw)
+}
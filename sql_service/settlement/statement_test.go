@@ -0,0 +1,136 @@
+package settlement
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name string) Statement {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	statement, err := Parse(DetectFormat(name, nil), f)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+	return statement
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseCSV(t *testing.T) {
+	statement := parseFixture(t, "statement.csv")
+
+	want := []Entry{
+		{Line: 2, Reference: "f47cbde3-98d8-47cb-a30b-1046b1f70b75", Amount: 100, Currency: "EUR", Direction: Credit, BookingDate: date("2023-11-06"), Description: "Top-up wallet 1"},
+		{Line: 3, Reference: "NOTPROVIDED", Amount: 200, Currency: "EUR", Direction: Credit, BookingDate: date("2023-11-06"), Description: "Top-up wallet 2"},
+		{Line: 4, Reference: "PAYOUT-7", Amount: 50, Currency: "EUR", Direction: Debit, BookingDate: date("2023-11-07"), Description: "Payout, wallet 3"},
+		{Line: 5, Reference: "UNKNOWN-1", Amount: 42, Currency: "EUR", Direction: Credit, BookingDate: date("2023-11-08"), Description: "Unknown payer"},
+	}
+	assertEntries(t, statement.Entries, want)
+}
+
+func TestParseCSVSemicolon(t *testing.T) {
+	statement := parseFixture(t, "statement_semicolon.csv")
+
+	want := []Entry{
+		{Line: 2, Reference: "REF-1", Amount: 1234.56, Currency: "EUR", Direction: Credit, BookingDate: date("2023-11-06")},
+		{Line: 3, Reference: "REF-2", Amount: 10, Currency: "EUR", Direction: Debit, BookingDate: date("2023-11-07")},
+	}
+	assertEntries(t, statement.Entries, want)
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "statement_bad_amount.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	_, err = ParseCSV(f)
+	if err == nil || err.Error() != `line 2: "ten" is not an amount` {
+		t.Fatalf("got error %v", err)
+	}
+}
+
+func TestParseCamt053(t *testing.T) {
+	statement := parseFixture(t, "camt053.xml")
+
+	if statement.Account != "DE89370400440532013000" {
+		t.Errorf("account = %q", statement.Account)
+	}
+	want := []Entry{
+		{Line: 1, Reference: "f47cbde3-98d8-47cb-a30b-1046b1f70b75", Amount: 100, Currency: "EUR", Direction: Credit, BookingDate: date("2023-11-06"), Description: "Top-up wallet 1"},
+		{Line: 2, Reference: "Top-up wallet 2", Amount: 200, Currency: "EUR", Direction: Credit, BookingDate: date("2023-11-06"), Description: "Top-up wallet 2"},
+		{Line: 2, Reference: "f4c94427-d2a9-49ac-bb5a-e7a7d2db6d4d", Amount: 75, Currency: "EUR", Direction: Credit, BookingDate: date("2023-11-06")},
+		{Line: 3, Reference: "BANK-0003", Amount: 50, Currency: "EUR", Direction: Debit, BookingDate: date("2023-11-07"), Description: "Payout wallet 3"},
+	}
+	assertEntries(t, statement.Entries, want)
+}
+
+func TestParseCamt053V08(t *testing.T) {
+	statement := parseFixture(t, "camt053_v08.xml")
+
+	if statement.Account != "40817810099910004312" {
+		t.Errorf("account = %q", statement.Account)
+	}
+	if len(statement.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1 (INFO entries are skipped)", len(statement.Entries))
+	}
+	entry := statement.Entries[0]
+	if entry.Reference != "RF18539007547034" || entry.Amount != 1500.50 || entry.Currency != "RUB" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if !entry.BookingDate.Equal(time.Date(2023, 11, 6, 7, 15, 0, 0, time.UTC)) {
+		t.Errorf("booking date = %s", entry.BookingDate)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"statement.csv", "", FormatCSV},
+		{"STATEMENT.XML", "", FormatCamt053},
+		{"upload", "  <?xml version=\"1.0\"?>", FormatCamt053},
+		{"upload", "date,amount,reference", FormatCSV},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.name, []byte(tt.head)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %s, want %s", tt.name, tt.head, got, tt.want)
+		}
+	}
+}
+
+func assertEntries(t *testing.T, got, want []Entry) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].BookingDate.Equal(want[i].BookingDate) {
+			t.Errorf("entry %d: booking date %s, want %s", i, got[i].BookingDate, want[i].BookingDate)
+		}
+		g, w := got[i], want[i]
+		g.BookingDate, w.BookingDate = time.Time{}, time.Time{}
+		if g != w {
+			t.Errorf("entry %d:\n got %+v\nwant %+v", i, g, w)
+		}
+	}
+}
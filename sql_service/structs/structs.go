@@ -4,29 +4,241 @@ import "time"
 
 var Config Configuration
 
+// Configuration is filled by config.Load from the environment, .env and flags.
 type Configuration struct {
-	LogLevel           string
-	ListenAddress      string
-	RabbitMQURL        string
-	DBConnectionString string
+	LogLevel           string        `env:"LOG_LEVEL" flag:"log-level" default:"info" validate:"oneof=debug info warn error"`
+	ListenAddress      string        `env:"SQL_SERVICE_ADDRESS" flag:"listen-address" default:":50051" validate:"hostport"`
+	RabbitMQURL        string        `env:"RABBITMQ_ADDRESS" validate:"url"`
+	DBConnectionString string        `env:"POSTGRES_STRING,POSTRGRES_STRING" flag:"postgres" required:"true" secret:"true"`
+	DBMaxConns         int           `env:"DB_MAX_CONNS" default:"10" validate:"positive"`
+	DBMigrate          bool          `env:"DB_MIGRATE" flag:"migrate" default:"false" usage:"apply the database migrations on startup"`
+	DBSeedDevData      bool          `env:"DB_SEED_DEV_DATA" flag:"seed-dev-data" default:"false" usage:"add the development wallets on startup"`
+	RetryInterval      time.Duration `env:"RETRY_INTERVAL" default:"5s" validate:"positive"`
+	TLSCertFile        string        `env:"TLS_CERT_FILE" flag:"tls-cert"`
+	TLSKeyFile         string        `env:"TLS_KEY_FILE" flag:"tls-key" secret:"true"`
+	TLSCAFile          string        `env:"TLS_CA_FILE" flag:"tls-ca"`
+	TLSReloadInterval  time.Duration `env:"TLS_RELOAD_INTERVAL" default:"30s" validate:"positive"`
+	InsecureDev        bool          `env:"INSECURE_DEV" default:"false" usage:"allow plaintext gRPC without certificates, for development only"`
+	GRPCReaders        []string      `env:"GRPC_READERS" default:"api_service,transaction_service" usage:"identities allowed to call read-only RPCs"`
+	GRPCWriters        []string      `env:"GRPC_WRITERS" default:"transaction_service" usage:"identities allowed to call mutating RPCs"`
+	GRPCManagers       []string      `env:"GRPC_MANAGERS" default:"api_service" usage:"identities allowed to manage webhooks and submit batches on behalf of merchants"`
+	GRPCOperators      []string      `env:"GRPC_OPERATORS" default:"walletctl" usage:"identities allowed to freeze wallets, adjust balances and replay webhook deliveries"`
+	TracingExporter    string        `env:"TRACING_EXPORTER" default:"none" validate:"oneof=none stdout otlp"`
+	MetricsAddress     string        `env:"METRICS_ADDRESS" flag:"metrics-address" default:":2112" validate:"hostport"`
+	ReconcileInterval  time.Duration `env:"RECONCILE_INTERVAL" default:"10m" usage:"how often to reconcile balances, 0 disables the worker"`
+	ReconcileGrace     time.Duration `env:"RECONCILE_GRACE" default:"1m" usage:"skip wallets changed more recently than this"`
+	ReconcilePropose   bool          `env:"RECONCILE_PROPOSE_ADJUSTMENTS" default:"false" usage:"propose adjustments for mismatches"`
+	SettleAmountTol    float64       `env:"SETTLEMENT_AMOUNT_TOLERANCE" default:"0.01" usage:"largest amount difference a statement match allows"`
+	SettleDateTol      time.Duration `env:"SETTLEMENT_DATE_TOLERANCE" default:"72h" usage:"largest booking date difference a statement match allows"`
+	WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" default:"10" validate:"positive" usage:"attempts before a delivery is given up"`
+	WebhookBackoff     time.Duration `env:"WEBHOOK_BACKOFF" default:"10s" validate:"positive" usage:"delay before the first retry, doubled after every failure"`
+	WebhookBackoffMax  time.Duration `env:"WEBHOOK_BACKOFF_MAX" default:"1h" validate:"positive"`
+	WebhookDisableAt   int           `env:"WEBHOOK_DISABLE_AFTER" default:"20" validate:"positive" usage:"consecutive failed attempts that disable a webhook"`
+	WebhookLease       time.Duration `env:"WEBHOOK_LEASE" default:"1m" validate:"positive" usage:"how long a claimed delivery is reserved for a worker"`
+	OTLPEndpoint       string        `env:"OTLP_ENDPOINT" default:"localhost:4317" validate:"hostport"`
+	PartitionInterval  time.Duration `env:"PARTITION_INTERVAL" default:"1h" usage:"how often to create upcoming transaction partitions and archive old ones, 0 disables the worker"`
+	PartitionAhead     int           `env:"PARTITION_MONTHS_AHEAD" default:"3" validate:"positive" usage:"months of transaction partitions created in advance"`
+	RetentionMonths    int           `env:"TRANSACTION_RETENTION_MONTHS" default:"0" usage:"archive transaction partitions that ended this many months ago, 0 keeps them all"`
+	ArchiveDir         string        `env:"ARCHIVE_DIR" default:"archive" usage:"directory archived transaction partitions are exported to"`
+	ArchiveDrop        bool          `env:"ARCHIVE_DROP" default:"false" usage:"drop archived partitions instead of leaving them detached"`
 }
 
+// Transaction is one deposit, withdrawal or adjustment of a wallet.
 type Transaction struct {
-	ID              int
-	WalletID        int
-	Amount          float64
-	Type            string
-	Status          string
-	RequestTime     time.Time
-	TransactionTime time.Time
+	ID        string
+	WalletID  int
+	Amount    float64
+	Type      string
+	Status    string
+	Principal string
+	Time      time.Time
 }
 
 type Wallet struct {
 	ID      int
 	Balance float64
+	// FrozenAt is zero unless the wallet is frozen: payments to and from
+	// it fail until an operator unfreezes it.
+	FrozenAt     time.Time
+	FrozenReason string
+}
+
+// TransactionFilter selects transactions to list. Zero fields do not
+// filter; From is inclusive and To exclusive.
+type TransactionFilter struct {
+	WalletID int
+	Type     string
+	Status   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	// PageToken continues the listing after the last transaction of the
+	// previous page.
+	PageToken string
 }
 
 type DepositRequest struct {
 	WalletID int     `json:"wallet_id"`
 	Amount   float64 `json:"amount"`
 }
+
+// AuditEntry is one immutable row of the balance audit trail. Hash covers
+// every other field and PrevHash, chaining all entries together.
+type AuditEntry struct {
+	ID            int64
+	WalletID      int
+	BeforeBalance float64
+	AfterBalance  float64
+	CallerService string
+	Principal     string
+	RequestID     string
+	TransactionID string
+	CreatedAt     time.Time
+	PrevHash      string
+	Hash          string
+	// Chain is "wallet" for entries linked to the previous entry of their
+	// wallet and "global" for older ones linked to the previous entry
+	// overall.
+	Chain string
+}
+
+// WalletMismatch is a wallet whose balance differs from the sum of its
+// successful transactions. The window spans the balance changes that have
+// no successful transaction recorded for them.
+type WalletMismatch struct {
+	WalletID                 int
+	Balance                  float64
+	ExpectedBalance          float64
+	Difference               float64
+	WindowStart              time.Time
+	WindowEnd                time.Time
+	UnrecordedTransactionIDs []string
+	LastAuditID              int64
+	AdjustmentID             string
+}
+
+type ReconcileReport struct {
+	Checked    int
+	Skipped    int
+	Mismatches []WalletMismatch
+	CheckedAt  time.Time
+}
+
+// Adjustment is a correcting transaction proposed by reconciliation. It is
+// only written to transactions once an operator approves it.
+type Adjustment struct {
+	ID            string
+	WalletID      int
+	Amount        float64
+	Reason        string
+	Status        string
+	TransactionID string
+	CreatedAt     time.Time
+	DecidedAt     time.Time
+	DecidedBy     string
+}
+
+// Webhook is an endpoint a merchant registered to be notified about the
+// transactions of one wallet.
+type Webhook struct {
+	ID                  string
+	WalletID            int
+	URL                 string
+	Secret              string
+	Enabled             bool
+	ConsecutiveFailures int
+	DisabledReason      string
+	Principal           string
+	CreatedAt           time.Time
+}
+
+// WebhookDelivery is one event queued for one webhook, with the outcome of
+// its latest attempt.
+type WebhookDelivery struct {
+	ID             string
+	WebhookID      string
+	EventID        string
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	URL            string
+	Secret         string
+	// WalletID is only filled in by ListFailedWebhookDeliveries.
+	WalletID int
+}
+
+// WebhookAttempt is the outcome of one POST to a webhook endpoint.
+type WebhookAttempt struct {
+	DeliveryID string
+	Success    bool
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// Batch is a set of deposits and withdrawals submitted in one request and
+// processed by transaction-service item by item.
+type Batch struct {
+	ID          string
+	Principal   string
+	Mode        string
+	Status      string
+	Total       int
+	Succeeded   int
+	Failed      int
+	Pending     int
+	CreatedAt   time.Time
+	CompletedAt time.Time
+	Items       []BatchItem
+}
+
+// BatchItem is one deposit or withdrawal of a batch and its outcome.
+type BatchItem struct {
+	Index         int
+	WalletID      int
+	Type          string
+	Amount        float64
+	Reference     string
+	Status        string
+	TransactionID string
+	Error         string
+}
+
+// TransactionPartition is one monthly partition of the transactions table,
+// covering created_at from From up to To. Archived partitions have been
+// exported to ArchiveFile and detached.
+type TransactionPartition struct {
+	Name        string
+	From        time.Time
+	To          time.Time
+	ArchivedAt  time.Time
+	ArchiveFile string
+	Rows        int64
+}
+
+// ArchivedTransaction is one row of an archived partition with every
+// column as stored; value keeps its decimal text.
+type ArchivedTransaction struct {
+	TransactionID   string     `json:"transaction_id"`
+	WalletID        *int       `json:"wallet_id"`
+	Value           string     `json:"value"`
+	Type            string     `json:"type"`
+	Status          string     `json:"status"`
+	TransactionTime string     `json:"transaction_time"`
+	Principal       *string    `json:"principal"`
+	SettledAt       *time.Time `json:"settled_at"`
+	SettlementRef   *string    `json:"settlement_ref"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// PartitionReport is what one partition maintenance run did.
+type PartitionReport struct {
+	Created  []TransactionPartition
+	Archived []TransactionPartition
+}
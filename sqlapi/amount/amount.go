@@ -0,0 +1,7 @@
+// Package amount holds the bounds of the amounts and balances sql-service
+// stores, so that every service checks them alike.
+package amount
+
+// Max is the largest amount or balance that fits the DECIMAL(10, 2)
+// columns of sql-service: eight digits before the point.
+const Max = 99999999.99
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sqlapi/pb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// command is one "group name" subcommand.
+type command struct {
+	group, name string
+	// args describes the positional arguments in the usage line.
+	args    string
+	summary string
+	// dial is set for the commands that call sql-service.
+	dial bool
+	run  func(c *cli, args []string) error
+}
+
+func (cmd command) path() string {
+	if cmd.name == "" {
+		return cmd.group
+	}
+	return cmd.group + " " + cmd.name
+}
+
+// commands is filled in by init because completion refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"wallet", "show", "WALLET", "show a wallet", true, walletShow},
+		{"wallet", "list", "[-after WALLET] [-limit N] [-all]", "list wallets in id order", true, walletList},
+		{"wallet", "create", "", "create an empty wallet", true, walletCreate},
+		{"wallet", "freeze", "WALLET -reason TEXT", "stop payments to and from a wallet", true, walletFreeze},
+		{"wallet", "unfreeze", "WALLET", "let a frozen wallet pay again", true, walletUnfreeze},
+		{"tx", "get", "TRANSACTION", "show a transaction", true, txGet},
+		{"tx", "list", "[filters] [-limit N] [-page-token T]", "list one page of transactions", true, txList},
+		{"tx", "export", "[filters] [-format csv|jsonl]", "write every matching transaction", true, txExport},
+		{"balance", "adjust", "WALLET AMOUNT -reason TEXT [-operator NAME]", "add AMOUNT (negative to take money out) as an audited adjustment", true, balanceAdjust},
+		{"dlq", "list", "[-wallet WALLET] [-limit N]", "list webhook deliveries that ran out of attempts", true, dlqList},
+		{"dlq", "replay", "WALLET WEBHOOK DELIVERY | -all [-wallet WALLET]", "queue failed webhook deliveries again", true, dlqReplay},
+		{"reconcile", "run", "[-wallet WALLET]", "check balances against the transactions; exits 1 on mismatches", true, reconcileRun},
+		{"reconcile", "approve", "ADJUSTMENT [-by NAME]", "approve a proposed adjustment", true, reconcileDecide(true)},
+		{"reconcile", "reject", "ADJUSTMENT [-by NAME]", "reject a proposed adjustment", true, reconcileDecide(false)},
+		{"completion", "", "bash|zsh|fish", "print a shell completion script", false, completion},
+	}
+}
+
+// lookup finds the command args start with and returns the rest.
+func lookup(args []string) (command, []string, bool) {
+	if len(args) == 0 {
+		return command{}, nil, false
+	}
+	for _, cmd := range commands {
+		if cmd.group != args[0] {
+			continue
+		}
+		if cmd.name == "" {
+			return cmd, args[1:], true
+		}
+		if len(args) > 1 && cmd.name == args[1] {
+			return cmd, args[2:], true
+		}
+	}
+	return command{}, nil, false
+}
+
+// usageError is a mistake in the command line.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func usagef(format string, a ...any) error {
+	return usageError{fmt.Errorf(format, a...)}
+}
+
+// errMismatch makes walletctl exit with 1 without printing an error.
+var errMismatch = errors.New("balances do not match")
+
+// flags returns a flag set with -o, which may also follow the command.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.errOut)
+	fs.StringVar(&c.output, "o", c.output, "output format: table or json")
+	return fs
+}
+
+// parse parses flags and exactly n positional arguments, or any number
+// when n is negative, in any order. Negative numbers are arguments, not
+// flags.
+func (c *cli) parse(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{err}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+		for len(args) > 0 && isNumber(args[0]) {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+	}
+	if n >= 0 && len(positional) != n {
+		return nil, usagef("want %d arguments, got %d", n, len(positional))
+	}
+	if c.output != "table" && c.output != "json" {
+		return nil, usagef("unknown output format %q", c.output)
+	}
+	return positional, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func walletID(s string) (int32, error) {
+	id, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || id < 1 {
+		return 0, usagef("%q is not a wallet id", s)
+	}
+	return int32(id), nil
+}
+
+func walletShow(c *cli, args []string) error {
+	positional, err := c.parse(c.flags("wallet show"), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := walletID(positional[0])
+	if err != nil {
+		return err
+	}
+
+	wallet, err := c.client.GetWallet(c.ctx, &pb.WalletIdRequest{WalletId: id})
+	if err != nil {
+		return err
+	}
+	return c.print(wallet, walletsTable(wallet))
+}
+
+func walletList(c *cli, args []string) error {
+	fs := c.flags("wallet list")
+	after := fs.Int("after", 0, "list wallets with an id above this")
+	limit := fs.Int("limit", 100, "wallets per page, at most 1000")
+	all := fs.Bool("all", false, "list every wallet, page after page")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	list := &pb.WalletList{}
+	req := &pb.ListWalletsRequest{AfterWalletId: int32(*after), Limit: int32(*limit)}
+	for {
+		page, err := c.client.ListWallets(c.ctx, req)
+		if err != nil {
+			return err
+		}
+		list.Wallets = append(list.Wallets, page.Wallets...)
+		if !*all || len(page.Wallets) == 0 {
+			break
+		}
+		req.AfterWalletId = page.Wallets[len(page.Wallets)-1].WalletId
+	}
+	return c.print(list, walletsTable(list.Wallets...))
+}
+
+func walletCreate(c *cli, args []string) error {
+	if _, err := c.parse(c.flags("wallet create"), args, 0); err != nil {
+		return err
+	}
+
+	wallet, err := c.client.CreateWallet(c.ctx, &pb.Empty{})
+	if err != nil {
+		return err
+	}
+	return c.print(wallet, walletsTable(wallet))
+}
+
+func walletFreeze(c *cli, args []string) error {
+	fs := c.flags("wallet freeze")
+	reason := fs.String("reason", "", "why the wallet is frozen (required)")
+	positional, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := walletID(positional[0])
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return usagef("-reason is required")
+	}
+
+	wallet, err := c.client.SetWalletFrozen(c.ctx, &pb.SetWalletFrozenRequest{WalletId: id, Frozen: true, Reason: *reason})
+	if err != nil {
+		return err
+	}
+	return c.print(wallet, walletsTable(wallet))
+}
+
+func walletUnfreeze(c *cli, args []string) error {
+	positional, err := c.parse(c.flags("wallet unfreeze"), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := walletID(positional[0])
+	if err != nil {
+		return err
+	}
+
+	wallet, err := c.client.SetWalletFrozen(c.ctx, &pb.SetWalletFrozenRequest{WalletId: id})
+	if err != nil {
+		return err
+	}
+	return c.print(wallet, walletsTable(wallet))
+}
+
+func txGet(c *cli, args []string) error {
+	positional, err := c.parse(c.flags("tx get"), args, 1)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := c.client.GetTransactionID(c.ctx, &pb.TransactionId{TransactionId: positional[0]})
+	if err != nil {
+		return err
+	}
+	return c.print(transaction, transactionsTable(transaction))
+}
+
+// transactionFilter adds the flags tx list and tx export share.
+func transactionFilter(fs *flag.FlagSet) func() (*pb.ListTransactionsRequest, error) {
+	wallet := fs.Int("wallet", 0, "only this wallet")
+	kind := fs.String("type", "", "only this type: deposit, withdraw or adjustment")
+	state := fs.String("status", "", "only this status: Success or error")
+	from := fs.String("from", "", "from this time on (RFC 3339 or YYYY-MM-DD)")
+	to := fs.String("to", "", "before this time (RFC 3339 or YYYY-MM-DD)")
+
+	return func() (*pb.ListTransactionsRequest, error) {
+		req := &pb.ListTransactionsRequest{WalletId: int32(*wallet), Type: *kind, Status: *state}
+		var err error
+		if req.From, err = parseTime("-from", *from); err != nil {
+			return nil, err
+		}
+		if req.To, err = parseTime("-to", *to); err != nil {
+			return nil, err
+		}
+		return req, nil
+	}
+}
+
+func parseTime(name, value string) (*timestamppb.Timestamp, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return timestamppb.New(t), nil
+		}
+	}
+	return nil, usagef("%s %q is neither RFC 3339 nor YYYY-MM-DD", name, value)
+}
+
+func txList(c *cli, args []string) error {
+	fs := c.flags("tx list")
+	filter := transactionFilter(fs)
+	limit := fs.Int("limit", 100, "transactions per page, at most 1000")
+	token := fs.String("page-token", "", "next_page_token of the previous page")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	req, err := filter()
+	if err != nil {
+		return err
+	}
+	req.Limit, req.PageToken = int32(*limit), *token
+
+	list, err := c.client.ListTransactions(c.ctx, req)
+	if err != nil {
+		return err
+	}
+	if err := c.print(list, transactionsTable(list.Transactions...)); err != nil {
+		return err
+	}
+	if c.output == "table" && list.NextPageToken != "" {
+		fmt.Fprintf(c.errOut, "next page: -page-token %s\n", list.NextPageToken)
+	}
+	return nil
+}
+
+func txExport(c *cli, args []string) error {
+	fs := c.flags("tx export")
+	filter := transactionFilter(fs)
+	format := fs.String("format", "csv", "csv or jsonl")
+	pageSize := fs.Int("page-size", 500, "transactions fetched per call, at most 1000")
+	file := fs.String("file", "", "write to this file instead of stdout")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	req, err := filter()
+	if err != nil {
+		return err
+	}
+	req.Limit = int32(*pageSize)
+
+	var w io.Writer = c.out
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	e, err := newExporter(w, *format)
+	if err != nil {
+		return err
+	}
+	n, err := exportTransactions(c, req, e)
+	if err != nil {
+		return err
+	}
+	if *file != "" {
+		fmt.Fprintf(c.errOut, "exported %d transactions to %s\n", n, *file)
+	}
+	return nil
+}
+
+// exportTransactions writes the pages of req to e until the last one and
+// returns how many transactions it wrote.
+func exportTransactions(c *cli, req *pb.ListTransactionsRequest, e exporter) (int, error) {
+	n := 0
+	for {
+		page, err := c.client.ListTransactions(c.ctx, req)
+		if err != nil {
+			return n, err
+		}
+		for _, transaction := range page.Transactions {
+			if err := e.write(transaction); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if page.NextPageToken == "" {
+			return n, e.flush()
+		}
+		req.PageToken = page.NextPageToken
+	}
+}
+
+func balanceAdjust(c *cli, args []string) error {
+	fs := c.flags("balance adjust")
+	reason := fs.String("reason", "", "why the balance is adjusted (required)")
+	operator := fs.String("operator", os.Getenv("USER"), "who adjusts it, recorded in the audit log")
+	positional, err := c.parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	id, err := walletID(positional[0])
+	if err != nil {
+		return err
+	}
+	amount, err := strconv.ParseFloat(positional[1], 64)
+	if err != nil || amount == 0 {
+		return usagef("%q is not a non-zero amount", positional[1])
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return usagef("-reason is required")
+	}
+	if *operator == "" {
+		return usagef("-operator is required when $USER is not set")
+	}
+
+	adjustment, err := c.client.AdjustBalance(c.ctx, &pb.AdjustBalanceRequest{
+		WalletId: id,
+		Amount:   amount,
+		Reason:   *reason,
+		Operator: *operator,
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(adjustment, adjustmentTable(adjustment))
+}
+
+func dlqList(c *cli, args []string) error {
+	fs := c.flags("dlq list")
+	wallet := fs.Int("wallet", 0, "only this wallet")
+	limit := fs.Int("limit", 100, "at most this many deliveries, newest first")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	list, err := c.client.ListFailedWebhookDeliveries(c.ctx, &pb.FailedWebhookDeliveriesRequest{
+		WalletId: int32(*wallet),
+		Limit:    int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(list, deliveriesTable(list.Deliveries...))
+}
+
+func dlqReplay(c *cli, args []string) error {
+	fs := c.flags("dlq replay")
+	all := fs.Bool("all", false, "replay every failed delivery")
+	wallet := fs.Int("wallet", 0, "with -all, only the deliveries of this wallet")
+	limit := fs.Int("limit", 1000, "with -all, at most this many deliveries")
+	positional, err := c.parse(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	if *all && len(positional) != 0 || !*all && len(positional) != 3 {
+		return usagef("give WALLET WEBHOOK DELIVERY or -all")
+	}
+
+	var refs []*pb.RedeliverRequest
+	if *all {
+		list, err := c.client.ListFailedWebhookDeliveries(c.ctx, &pb.FailedWebhookDeliveriesRequest{
+			WalletId: int32(*wallet),
+			Limit:    int32(*limit),
+		})
+		if err != nil {
+			return err
+		}
+		for _, d := range list.Deliveries {
+			refs = append(refs, &pb.RedeliverRequest{WalletId: d.WalletId, WebhookId: d.WebhookId, DeliveryId: d.DeliveryId})
+		}
+	} else {
+		id, err := walletID(positional[0])
+		if err != nil {
+			return err
+		}
+		refs = append(refs, &pb.RedeliverRequest{WalletId: id, WebhookId: positional[1], DeliveryId: positional[2]})
+	}
+
+	// One delivery that cannot be replayed does not stop the others.
+	replayed := &pb.WebhookDeliveryList{}
+	for _, ref := range refs {
+		delivery, err := c.client.RedeliverWebhook(c.ctx, ref)
+		if err != nil {
+			fmt.Fprintf(c.errOut, "walletctl: delivery %s: %s\n", ref.DeliveryId, describe(err))
+			continue
+		}
+		delivery.WalletId = ref.WalletId
+		replayed.Deliveries = append(replayed.Deliveries, delivery)
+	}
+	if err := c.print(replayed, deliveriesTable(replayed.Deliveries...)); err != nil {
+		return err
+	}
+	if failed := len(refs) - len(replayed.Deliveries); failed > 0 {
+		return fmt.Errorf("%d of %d deliveries were not replayed", failed, len(refs))
+	}
+	return nil
+}
+
+func reconcileRun(c *cli, args []string) error {
+	fs := c.flags("reconcile run")
+	wallet := fs.Int("wallet", 0, "only this wallet")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	report, err := c.client.Reconcile(c.ctx, &pb.ReconcileRequest{WalletId: int32(*wallet)})
+	if err != nil {
+		return err
+	}
+	if err := c.print(report, reconcileTable(report)); err != nil {
+		return err
+	}
+	if c.output == "table" {
+		fmt.Fprintf(c.out, "checked %d wallets, skipped %d, %d do not match\n", report.Checked, report.Skipped, len(report.Mismatches))
+	}
+	if len(report.Mismatches) > 0 {
+		return errMismatch
+	}
+	return nil
+}
+
+func reconcileDecide(approve bool) func(c *cli, args []string) error {
+	name := "reconcile reject"
+	if approve {
+		name = "reconcile approve"
+	}
+	return func(c *cli, args []string) error {
+		fs := c.flags(name)
+		by := fs.String("by", os.Getenv("USER"), "operator name recorded with the decision")
+		positional, err := c.parse(fs, args, 1)
+		if err != nil {
+			return err
+		}
+		if *by == "" {
+			return usagef("-by is required when $USER is not set")
+		}
+
+		adjustment, err := c.client.DecideAdjustment(c.ctx, &pb.AdjustmentDecision{
+			AdjustmentId: positional[0],
+			Approve:      approve,
+			DecidedBy:    *by,
+		})
+		if err != nil {
+			return err
+		}
+		return c.print(adjustment, adjustmentTable(adjustment))
+	}
+}
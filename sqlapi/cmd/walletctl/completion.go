@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+// completion prints a script that completes the commands and their names.
+//
+//	source <(walletctl completion bash)
+//	source <(walletctl completion zsh)     # after compinit
+//	walletctl completion fish > ~/.config/fish/completions/walletctl.fish
+func completion(c *cli, args []string) error {
+	positional, err := c.parse(c.flags("completion"), args, 1)
+	if err != nil {
+		return err
+	}
+	script, ok := completionScripts[positional[0]]
+	if !ok {
+		return usagef("unknown shell %q", positional[0])
+	}
+	return script.Execute(c.out, completionData())
+}
+
+type completionGroup struct {
+	Name  string
+	Names string
+}
+
+// completionData lists the groups in order, each with its command names.
+func completionData() []completionGroup {
+	var groups []completionGroup
+	for _, cmd := range commands {
+		if len(groups) == 0 || groups[len(groups)-1].Name != cmd.group {
+			groups = append(groups, completionGroup{Name: cmd.group})
+		}
+		g := &groups[len(groups)-1]
+		if cmd.name != "" {
+			g.Names = strings.TrimSpace(g.Names + " " + cmd.name)
+		}
+	}
+	// completion takes a shell instead of a command name.
+	for i := range groups {
+		if groups[i].Name == "completion" {
+			groups[i].Names = "bash zsh fish"
+		}
+	}
+	return groups
+}
+
+// completionFuncs list the groups, and the global flags whose values the
+// scripts skip.
+var completionFuncs = template.FuncMap{
+	"groups": func(groups []completionGroup) string {
+		var names []string
+		for _, g := range groups {
+			names = append(names, g.Name)
+		}
+		return strings.Join(names, " ")
+	},
+	"valueFlags": func(sep string) string {
+		return strings.Join([]string{"-addr", "-server-name", "-cert", "-key", "-ca", "-timeout", "-o"}, sep)
+	},
+}
+
+var completionScripts = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Funcs(completionFuncs).Parse(`# bash completion for walletctl
+_walletctl() {
+    local cur=${COMP_WORDS[COMP_CWORD]} i group=""
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case ${COMP_WORDS[i]} in
+            {{valueFlags "|"}}) ((i++)) ;;
+            -*) ;;
+            *) group=${COMP_WORDS[i]}; break ;;
+        esac
+    done
+    if [[ -z $group ]]; then
+        COMPREPLY=($(compgen -W "{{groups .}}" -- "$cur"))
+        return
+    fi
+    if ((COMP_CWORD == i + 1)); then
+        case $group in
+{{- range .}}
+            {{.Name}}) COMPREPLY=($(compgen -W "{{.Names}}" -- "$cur")) ;;
+{{- end}}
+        esac
+    fi
+}
+complete -o default -F _walletctl walletctl
+`)),
+	"zsh": template.Must(template.New("zsh").Funcs(completionFuncs).Parse(`#compdef walletctl
+# zsh completion for walletctl
+_walletctl() {
+    local -a args
+    local i
+    for ((i = 2; i < CURRENT; i++)); do
+        case ${words[i]} in
+            {{valueFlags "|"}}) ((i++)) ;;
+            -*) ;;
+            *) args+=(${words[i]}) ;;
+        esac
+    done
+    if (( ${#args} == 0 )); then
+        compadd -- {{groups .}}
+        return
+    fi
+    if (( ${#args} == 1 )); then
+        case ${args[1]} in
+{{- range .}}
+            {{.Name}}) compadd -- {{.Names}} ;;
+{{- end}}
+        esac
+        return
+    fi
+    _files
+}
+compdef _walletctl walletctl
+`)),
+	"fish": template.Must(template.New("fish").Funcs(completionFuncs).Parse(`# fish completion for walletctl
+complete -c walletctl -n __fish_use_subcommand -f -a "{{groups .}}"
+{{- range .}}
+complete -c walletctl -n "__fish_seen_subcommand_from {{.Name}}; and not __fish_seen_subcommand_from {{.Names}}" -f -a "{{.Names}}"
+{{- end}}
+`)),
+}
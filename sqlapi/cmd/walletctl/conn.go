@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// transportCredentials returns mTLS credentials, or plaintext ones with a
+// warning when no certificate is given and insecureDev allows it, like the
+// services in development.
+func transportCredentials(certFile, keyFile, caFile, serverName string, insecureDev bool, warn io.Writer) (grpc.DialOption, error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		if !insecureDev {
+			return nil, errors.New("TLS is not configured: give -cert, -key and -ca, or -insecure to connect over plaintext in development")
+		}
+		fmt.Fprintln(warn, "walletctl: connecting to sql-service over plaintext")
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, errors.New("-cert, -key and -ca must be given together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("%s has no PEM certificates", caFile)
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	})), nil
+}
+
+// describe turns a gRPC error into "Code: message".
+func describe(err error) string {
+	if s, ok := status.FromError(err); ok {
+		return fmt.Sprintf("%s: %s", s.Code(), s.Message())
+	}
+	return err.Error()
+}
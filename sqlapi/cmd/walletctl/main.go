@@ -0,0 +1,134 @@
+// Command walletctl lets operators inspect and fix wallets through
+// sql-service's gRPC API instead of psql.
+//
+//	walletctl [global flags] wallet show|list|create|freeze|unfreeze ...
+//	walletctl [global flags] tx get|list|export ...
+//	walletctl [global flags] balance adjust WALLET AMOUNT -reason TEXT
+//	walletctl [global flags] dlq list|replay ...
+//	walletctl [global flags] reconcile run|approve|reject ...
+//	walletctl completion bash|zsh|fish
+//
+// The connection settings default to the environment variables the
+// services use: SQL_SERVICE_ADDRESS, SQL_SERVICE_NAME, TLS_CERT_FILE,
+// TLS_KEY_FILE and TLS_CA_FILE. sql-service only lets the certificate
+// identities in its GRPC_OPERATORS change wallets; certs/gen.sh makes one
+// for walletctl.
+//
+// Results are printed as a table, or with -o json as the protobuf JSON of
+// the response. Balance adjustments need a reason and are recorded in the
+// audit log under the operator's name, -operator or $USER.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"sqlapi/pb"
+	"sqlapi/sqlclient"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("walletctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", env("SQL_SERVICE_ADDRESS", "localhost:50051"), "sql-service address")
+	serverName := fs.String("server-name", env("SQL_SERVICE_NAME", "sql_service"), "name sql-service's certificate is checked against")
+	certFile := fs.String("cert", os.Getenv("TLS_CERT_FILE"), "client certificate")
+	keyFile := fs.String("key", os.Getenv("TLS_KEY_FILE"), "client certificate key")
+	caFile := fs.String("ca", os.Getenv("TLS_CA_FILE"), "CA that signed sql-service's certificate")
+	insecureDev := fs.Bool("insecure", os.Getenv("INSECURE_DEV") == "true", "connect over plaintext without -cert, -key and -ca, for development only")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of one call")
+	c := &cli{out: stdout, errOut: stderr, output: "table"}
+	fs.StringVar(&c.output, "o", c.output, "output format: table or json")
+	fs.Usage = func() { usage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cmd, rest, ok := lookup(fs.Args())
+	if !ok {
+		usage(fs)
+		return 2
+	}
+
+	if cmd.dial {
+		creds, err := transportCredentials(*certFile, *keyFile, *caFile, *serverName, *insecureDev, stderr)
+		if err != nil {
+			fmt.Fprintln(stderr, "walletctl:", err)
+			return 1
+		}
+		client, err := sqlclient.Dial(*addr, sqlclient.Options{
+			Timeout:      *timeout,
+			Retries:      2,
+			RetryBackoff: 200 * time.Millisecond,
+			DialOptions:  []grpc.DialOption{creds},
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, "walletctl:", err)
+			return 1
+		}
+		defer client.Close()
+		c.client = client
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	c.ctx = ctx
+
+	err := cmd.run(c, rest)
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "walletctl %s: %v\nusage: walletctl %s %s %s\n", cmd.path(), usageErr.err, cmd.group, cmd.name, cmd.args)
+		return 2
+	case errors.Is(err, errMismatch):
+		return 1
+	}
+	fmt.Fprintln(stderr, "walletctl:", describe(err))
+	return 1
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: walletctl [global flags] COMMAND [flags] [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.path(), cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nGlobal flags:")
+	fs.PrintDefaults()
+}
+
+func env(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// cli is what the commands share: the client, where to print and how.
+type cli struct {
+	ctx    context.Context
+	client pb.SQLServiceClient
+	out    io.Writer
+	errOut io.Writer
+	output string
+}
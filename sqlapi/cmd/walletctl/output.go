@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sqlapi/pb"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// table is a header row and the rows under it.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// print writes m as JSON or t as a table, depending on -o.
+func (c *cli) print(m proto.Message, t table) error {
+	if c.output == "json" {
+		data, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", EmitUnpopulated: true}.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.out, string(data))
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.header, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func walletsTable(wallets ...*pb.Wallet) table {
+	t := table{header: []string{"WALLET", "BALANCE", "FROZEN", "FROZEN AT", "REASON"}}
+	for _, w := range wallets {
+		frozen := "no"
+		if w.Frozen {
+			frozen = "yes"
+		}
+		t.rows = append(t.rows, []string{itoa(w.WalletId), money(w.Balance), frozen, timestamp(w.FrozenAt), w.FrozenReason})
+	}
+	return t
+}
+
+func transactionsTable(transactions ...*pb.Transaction) table {
+	t := table{header: []string{"TRANSACTION", "WALLET", "TYPE", "AMOUNT", "STATUS", "TIME", "PRINCIPAL"}}
+	for _, tx := range transactions {
+		t.rows = append(t.rows, transactionRow(tx))
+	}
+	return t
+}
+
+func transactionRow(tx *pb.Transaction) []string {
+	return []string{tx.TransactionId, itoa(tx.WalletId), tx.Type, money(tx.Amount), tx.Status, timestamp(tx.RequestTime), tx.Principal}
+}
+
+func adjustmentTable(a *pb.Adjustment) table {
+	return table{
+		header: []string{"ADJUSTMENT", "WALLET", "AMOUNT", "STATUS", "TRANSACTION", "DECIDED BY", "REASON"},
+		rows:   [][]string{{a.AdjustmentId, itoa(a.WalletId), money(a.Amount), a.Status, a.TransactionId, a.DecidedBy, a.Reason}},
+	}
+}
+
+func deliveriesTable(deliveries ...*pb.WebhookDelivery) table {
+	t := table{header: []string{"WALLET", "WEBHOOK", "DELIVERY", "EVENT", "STATUS", "ATTEMPTS", "LAST ATTEMPT", "LAST ERROR"}}
+	for _, d := range deliveries {
+		lastError := d.LastError
+		if lastError == "" && d.LastStatusCode != 0 {
+			lastError = "HTTP " + itoa(d.LastStatusCode)
+		}
+		t.rows = append(t.rows, []string{itoa(d.WalletId), d.WebhookId, d.DeliveryId, d.EventType, d.Status,
+			itoa(d.Attempts), timestamp(d.LastAttemptAt), lastError})
+	}
+	return t
+}
+
+func reconcileTable(r *pb.ReconcileReport) table {
+	t := table{header: []string{"WALLET", "BALANCE", "EXPECTED", "DIFFERENCE", "UNRECORDED", "ADJUSTMENT"}}
+	for _, m := range r.Mismatches {
+		t.rows = append(t.rows, []string{itoa(m.WalletId), money(m.Balance), money(m.ExpectedBalance), money(m.Difference),
+			strings.Join(m.UnrecordedTransactionIds, ","), m.AdjustmentId})
+	}
+	return t
+}
+
+func itoa(n int32) string {
+	return strconv.Itoa(int(n))
+}
+
+func money(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func timestamp(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().Format(time.RFC3339)
+}
+
+// exporter writes transactions one at a time.
+type exporter interface {
+	write(*pb.Transaction) error
+	flush() error
+}
+
+func newExporter(w io.Writer, format string) (exporter, error) {
+	switch format {
+	case "csv":
+		e := &csvExporter{w: csv.NewWriter(w)}
+		return e, e.w.Write([]string{"transaction_id", "wallet_id", "type", "amount", "status", "time", "principal"})
+	case "jsonl":
+		return jsonlExporter{w}, nil
+	}
+	return nil, usagef("unknown export format %q", format)
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) write(tx *pb.Transaction) error {
+	return e.w.Write(transactionRow(tx))
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlExporter struct {
+	w io.Writer
+}
+
+func (e jsonlExporter) write(tx *pb.Transaction) error {
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(e.w, string(data))
+	return err
+}
+
+func (e jsonlExporter) flush() error {
+	return nil
+}
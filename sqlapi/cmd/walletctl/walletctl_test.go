@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"sqlapi/pb"
+
+	"google.golang.org/grpc"
+)
+
+// fakeSQL answers the calls walletctl makes and records the requests.
+type fakeSQL struct {
+	pb.SQLServiceClient
+	adjustments  []*pb.AdjustBalanceRequest
+	pages        map[string]*pb.TransactionList
+	transactions []*pb.ListTransactionsRequest
+	redelivered  []*pb.RedeliverRequest
+	failed       []*pb.WebhookDelivery
+}
+
+func (f *fakeSQL) AdjustBalance(ctx context.Context, in *pb.AdjustBalanceRequest, opts ...grpc.CallOption) (*pb.Adjustment, error) {
+	f.adjustments = append(f.adjustments, in)
+	return &pb.Adjustment{WalletId: in.WalletId, Amount: in.Amount, Reason: in.Reason, Status: "approved", DecidedBy: in.Operator}, nil
+}
+
+func (f *fakeSQL) ListTransactions(ctx context.Context, in *pb.ListTransactionsRequest, opts ...grpc.CallOption) (*pb.TransactionList, error) {
+	f.transactions = append(f.transactions, &pb.ListTransactionsRequest{WalletId: in.WalletId, PageToken: in.PageToken, Limit: in.Limit})
+	return f.pages[in.PageToken], nil
+}
+
+func (f *fakeSQL) ListFailedWebhookDeliveries(ctx context.Context, in *pb.FailedWebhookDeliveriesRequest, opts ...grpc.CallOption) (*pb.WebhookDeliveryList, error) {
+	return &pb.WebhookDeliveryList{Deliveries: f.failed}, nil
+}
+
+func (f *fakeSQL) RedeliverWebhook(ctx context.Context, in *pb.RedeliverRequest, opts ...grpc.CallOption) (*pb.WebhookDelivery, error) {
+	f.redelivered = append(f.redelivered, in)
+	if in.DeliveryId == "gone" {
+		return nil, errors.New("not found")
+	}
+	return &pb.WebhookDelivery{WebhookId: in.WebhookId, DeliveryId: in.DeliveryId, Status: "pending"}, nil
+}
+
+// call runs a command line against client and returns what it printed.
+func call(t *testing.T, client pb.SQLServiceClient, args ...string) (string, error) {
+	t.Helper()
+
+	cmd, rest, ok := lookup(args)
+	if !ok {
+		t.Fatalf("no command %v", args)
+	}
+	var out, errOut bytes.Buffer
+	c := &cli{ctx: context.Background(), client: client, out: &out, errOut: &errOut, output: "table"}
+	err := cmd.run(c, rest)
+	return out.String(), err
+}
+
+func TestBalanceAdjust(t *testing.T) {
+	client := &fakeSQL{}
+
+	// The amount may be negative and the flags may come after it.
+	out, err := call(t, client, "balance", "adjust", "7", "-20.5", "-reason", "duplicate deposit", "-operator", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.adjustments) != 1 {
+		t.Fatalf("made %d adjustments, want 1", len(client.adjustments))
+	}
+	if got := client.adjustments[0]; got.WalletId != 7 || got.Amount != -20.5 || got.Reason != "duplicate deposit" || got.Operator != "alice" {
+		t.Errorf("sent %+v", got)
+	}
+	if !strings.Contains(out, "-20.50") || !strings.Contains(out, "approved") {
+		t.Errorf("printed %q", out)
+	}
+
+	for _, args := range [][]string{
+		{"balance", "adjust", "7", "10", "-operator", "alice"},
+		{"balance", "adjust", "7", "0", "-reason", "x", "-operator", "alice"},
+		{"balance", "adjust", "wallet", "10", "-reason", "x", "-operator", "alice"},
+		{"balance", "adjust", "7", "-reason", "x", "-operator", "alice"},
+	} {
+		var usageErr usageError
+		if _, err := call(t, client, args...); !errors.As(err, &usageErr) {
+			t.Errorf("%v: got %v, want a usage error", args, err)
+		}
+	}
+	if len(client.adjustments) != 1 {
+		t.Errorf("invalid command lines made %d more adjustments", len(client.adjustments)-1)
+	}
+}
+
+func TestTxExportFollowsPages(t *testing.T) {
+	client := &fakeSQL{pages: map[string]*pb.TransactionList{
+		"": {Transactions: []*pb.Transaction{
+			{TransactionId: "a", WalletId: 1, Type: "deposit", Amount: 10, Status: "Success"},
+			{TransactionId: "b", WalletId: 1, Type: "withdraw", Amount: 2.5, Status: "error"},
+		}, NextPageToken: "next"},
+		"next": {Transactions: []*pb.Transaction{
+			{TransactionId: "c", WalletId: 1, Type: "adjustment", Amount: -1, Status: "Success", Principal: "alice"},
+		}},
+	}}
+
+	out, err := call(t, client, "tx", "export", "-wallet", "1", "-page-size", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `transaction_id,wallet_id,type,amount,status,time,principal
+a,1,deposit,10.00,Success,,
+b,1,withdraw,2.50,error,,
+c,1,adjustment,-1.00,Success,,alice
+`
+	if out != want {
+		t.Errorf("exported\n%s\nwant\n%s", out, want)
+	}
+	if len(client.transactions) != 2 || client.transactions[1].PageToken != "next" || client.transactions[1].WalletId != 1 || client.transactions[1].Limit != 2 {
+		t.Errorf("requested %v", client.transactions)
+	}
+
+	client.transactions = nil
+	out, err = call(t, client, "tx", "export", "-format", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 3 || !strings.Contains(lines[2], `"principal":"alice"`) && !strings.Contains(lines[2], `"principal": "alice"`) {
+		t.Errorf("exported %q", out)
+	}
+}
+
+func TestDLQReplayAll(t *testing.T) {
+	client := &fakeSQL{failed: []*pb.WebhookDelivery{
+		{WalletId: 1, WebhookId: "w1", DeliveryId: "d1", Status: "failed"},
+		{WalletId: 2, WebhookId: "w2", DeliveryId: "gone", Status: "failed"},
+		{WalletId: 2, WebhookId: "w2", DeliveryId: "d3", Status: "failed"},
+	}}
+
+	out, err := call(t, client, "dlq", "replay", "-all")
+	if err == nil || !strings.Contains(err.Error(), "1 of 3") {
+		t.Errorf("got %v, want one delivery not replayed", err)
+	}
+	if len(client.redelivered) != 3 || client.redelivered[2].WalletId != 2 || client.redelivered[2].DeliveryId != "d3" {
+		t.Errorf("redelivered %v", client.redelivered)
+	}
+	if !strings.Contains(out, "d1") || !strings.Contains(out, "d3") || strings.Contains(out, "gone") {
+		t.Errorf("printed %q", out)
+	}
+
+	var usageErr usageError
+	if _, err := call(t, client, "dlq", "replay", "1", "w1"); !errors.As(err, &usageErr) {
+		t.Errorf("replay without a delivery: got %v, want a usage error", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		out, err := call(t, nil, "completion", shell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, word := range []string{"wallet", "freeze", "export", "adjust", "replay", "reconcile"} {
+			if !strings.Contains(out, word) {
+				t.Errorf("%s completion does not offer %s", shell, word)
+			}
+		}
+	}
+	if _, err := call(t, nil, "completion", "tcsh"); err == nil {
+		t.Error("tcsh completion printed")
+	}
+}
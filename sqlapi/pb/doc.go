@@ -0,0 +1,7 @@
+// Package pb is the Go code generated from proto/grpc/transaction_sql.proto,
+// the gRPC API of sql-service. Do not edit the .pb.go files: change the
+// proto and run go generate (buf and the protoc-gen-go and
+// protoc-gen-go-grpc plugins must be on PATH).
+package pb
+
+//go:generate sh -c "cd .. && buf lint && buf generate"
@@ -0,0 +1,1036 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: grpc/transaction_sql.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SQLService_GetBalance_FullMethodName                  = "/grpc.SQLService/GetBalance"
+	SQLService_UpdateBalance_FullMethodName               = "/grpc.SQLService/UpdateBalance"
+	SQLService_CreateTransaction_FullMethodName           = "/grpc.SQLService/CreateTransaction"
+	SQLService_GetTransactionID_FullMethodName            = "/grpc.SQLService/GetTransactionID"
+	SQLService_GetAuditLog_FullMethodName                 = "/grpc.SQLService/GetAuditLog"
+	SQLService_Reconcile_FullMethodName                   = "/grpc.SQLService/Reconcile"
+	SQLService_DecideAdjustment_FullMethodName            = "/grpc.SQLService/DecideAdjustment"
+	SQLService_CreateWebhook_FullMethodName               = "/grpc.SQLService/CreateWebhook"
+	SQLService_ListWebhooks_FullMethodName                = "/grpc.SQLService/ListWebhooks"
+	SQLService_DeleteWebhook_FullMethodName               = "/grpc.SQLService/DeleteWebhook"
+	SQLService_EnableWebhook_FullMethodName               = "/grpc.SQLService/EnableWebhook"
+	SQLService_ListWebhookDeliveries_FullMethodName       = "/grpc.SQLService/ListWebhookDeliveries"
+	SQLService_RedeliverWebhook_FullMethodName            = "/grpc.SQLService/RedeliverWebhook"
+	SQLService_ClaimWebhookDeliveries_FullMethodName      = "/grpc.SQLService/ClaimWebhookDeliveries"
+	SQLService_ReportWebhookDelivery_FullMethodName       = "/grpc.SQLService/ReportWebhookDelivery"
+	SQLService_CreateBatch_FullMethodName                 = "/grpc.SQLService/CreateBatch"
+	SQLService_GetBatch_FullMethodName                    = "/grpc.SQLService/GetBatch"
+	SQLService_StartBatch_FullMethodName                  = "/grpc.SQLService/StartBatch"
+	SQLService_ReportBatchItem_FullMethodName             = "/grpc.SQLService/ReportBatchItem"
+	SQLService_GetWallet_FullMethodName                   = "/grpc.SQLService/GetWallet"
+	SQLService_ListWallets_FullMethodName                 = "/grpc.SQLService/ListWallets"
+	SQLService_CreateWallet_FullMethodName                = "/grpc.SQLService/CreateWallet"
+	SQLService_SetWalletFrozen_FullMethodName             = "/grpc.SQLService/SetWalletFrozen"
+	SQLService_AdjustBalance_FullMethodName               = "/grpc.SQLService/AdjustBalance"
+	SQLService_ListTransactions_FullMethodName            = "/grpc.SQLService/ListTransactions"
+	SQLService_ListFailedWebhookDeliveries_FullMethodName = "/grpc.SQLService/ListFailedWebhookDeliveries"
+)
+
+// SQLServiceClient is the client API for SQLService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SQLServiceClient interface {
+	GetBalance(ctx context.Context, in *WalletIdRequest, opts ...grpc.CallOption) (*BalanceResponse, error)
+	UpdateBalance(ctx context.Context, in *UpdateBalanceRequest, opts ...grpc.CallOption) (*Empty, error)
+	CreateTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Empty, error)
+	GetTransactionID(ctx context.Context, in *TransactionId, opts ...grpc.CallOption) (*Transaction, error)
+	GetAuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogResponse, error)
+	Reconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*ReconcileReport, error)
+	DecideAdjustment(ctx context.Context, in *AdjustmentDecision, opts ...grpc.CallOption) (*Adjustment, error)
+	CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*Webhook, error)
+	ListWebhooks(ctx context.Context, in *WalletIdRequest, opts ...grpc.CallOption) (*WebhookList, error)
+	DeleteWebhook(ctx context.Context, in *WebhookRef, opts ...grpc.CallOption) (*Empty, error)
+	EnableWebhook(ctx context.Context, in *WebhookRef, opts ...grpc.CallOption) (*Webhook, error)
+	ListWebhookDeliveries(ctx context.Context, in *WebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveryList, error)
+	RedeliverWebhook(ctx context.Context, in *RedeliverRequest, opts ...grpc.CallOption) (*WebhookDelivery, error)
+	ClaimWebhookDeliveries(ctx context.Context, in *ClaimWebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveryList, error)
+	ReportWebhookDelivery(ctx context.Context, in *WebhookAttempt, opts ...grpc.CallOption) (*Empty, error)
+	CreateBatch(ctx context.Context, in *CreateBatchRequest, opts ...grpc.CallOption) (*Batch, error)
+	GetBatch(ctx context.Context, in *BatchRef, opts ...grpc.CallOption) (*Batch, error)
+	StartBatch(ctx context.Context, in *BatchRef, opts ...grpc.CallOption) (*Batch, error)
+	ReportBatchItem(ctx context.Context, in *BatchItemResult, opts ...grpc.CallOption) (*Empty, error)
+	// Operator RPCs, used by walletctl.
+	GetWallet(ctx context.Context, in *WalletIdRequest, opts ...grpc.CallOption) (*Wallet, error)
+	ListWallets(ctx context.Context, in *ListWalletsRequest, opts ...grpc.CallOption) (*WalletList, error)
+	CreateWallet(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Wallet, error)
+	SetWalletFrozen(ctx context.Context, in *SetWalletFrozenRequest, opts ...grpc.CallOption) (*Wallet, error)
+	AdjustBalance(ctx context.Context, in *AdjustBalanceRequest, opts ...grpc.CallOption) (*Adjustment, error)
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*TransactionList, error)
+	ListFailedWebhookDeliveries(ctx context.Context, in *FailedWebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveryList, error)
+}
+
+type sQLServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSQLServiceClient(cc grpc.ClientConnInterface) SQLServiceClient {
+	return &sQLServiceClient{cc}
+}
+
+func (c *sQLServiceClient) GetBalance(ctx context.Context, in *WalletIdRequest, opts ...grpc.CallOption) (*BalanceResponse, error) {
+	out := new(BalanceResponse)
+	err := c.cc.Invoke(ctx, SQLService_GetBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) UpdateBalance(ctx context.Context, in *UpdateBalanceRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, SQLService_UpdateBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) CreateTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, SQLService_CreateTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) GetTransactionID(ctx context.Context, in *TransactionId, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, SQLService_GetTransactionID_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) GetAuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogResponse, error) {
+	out := new(AuditLogResponse)
+	err := c.cc.Invoke(ctx, SQLService_GetAuditLog_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) Reconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*ReconcileReport, error) {
+	out := new(ReconcileReport)
+	err := c.cc.Invoke(ctx, SQLService_Reconcile_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) DecideAdjustment(ctx context.Context, in *AdjustmentDecision, opts ...grpc.CallOption) (*Adjustment, error) {
+	out := new(Adjustment)
+	err := c.cc.Invoke(ctx, SQLService_DecideAdjustment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*Webhook, error) {
+	out := new(Webhook)
+	err := c.cc.Invoke(ctx, SQLService_CreateWebhook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ListWebhooks(ctx context.Context, in *WalletIdRequest, opts ...grpc.CallOption) (*WebhookList, error) {
+	out := new(WebhookList)
+	err := c.cc.Invoke(ctx, SQLService_ListWebhooks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) DeleteWebhook(ctx context.Context, in *WebhookRef, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, SQLService_DeleteWebhook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) EnableWebhook(ctx context.Context, in *WebhookRef, opts ...grpc.CallOption) (*Webhook, error) {
+	out := new(Webhook)
+	err := c.cc.Invoke(ctx, SQLService_EnableWebhook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ListWebhookDeliveries(ctx context.Context, in *WebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveryList, error) {
+	out := new(WebhookDeliveryList)
+	err := c.cc.Invoke(ctx, SQLService_ListWebhookDeliveries_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) RedeliverWebhook(ctx context.Context, in *RedeliverRequest, opts ...grpc.CallOption) (*WebhookDelivery, error) {
+	out := new(WebhookDelivery)
+	err := c.cc.Invoke(ctx, SQLService_RedeliverWebhook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ClaimWebhookDeliveries(ctx context.Context, in *ClaimWebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveryList, error) {
+	out := new(WebhookDeliveryList)
+	err := c.cc.Invoke(ctx, SQLService_ClaimWebhookDeliveries_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ReportWebhookDelivery(ctx context.Context, in *WebhookAttempt, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, SQLService_ReportWebhookDelivery_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) CreateBatch(ctx context.Context, in *CreateBatchRequest, opts ...grpc.CallOption) (*Batch, error) {
+	out := new(Batch)
+	err := c.cc.Invoke(ctx, SQLService_CreateBatch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) GetBatch(ctx context.Context, in *BatchRef, opts ...grpc.CallOption) (*Batch, error) {
+	out := new(Batch)
+	err := c.cc.Invoke(ctx, SQLService_GetBatch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) StartBatch(ctx context.Context, in *BatchRef, opts ...grpc.CallOption) (*Batch, error) {
+	out := new(Batch)
+	err := c.cc.Invoke(ctx, SQLService_StartBatch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ReportBatchItem(ctx context.Context, in *BatchItemResult, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, SQLService_ReportBatchItem_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) GetWallet(ctx context.Context, in *WalletIdRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, SQLService_GetWallet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ListWallets(ctx context.Context, in *ListWalletsRequest, opts ...grpc.CallOption) (*WalletList, error) {
+	out := new(WalletList)
+	err := c.cc.Invoke(ctx, SQLService_ListWallets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) CreateWallet(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, SQLService_CreateWallet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) SetWalletFrozen(ctx context.Context, in *SetWalletFrozenRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, SQLService_SetWalletFrozen_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) AdjustBalance(ctx context.Context, in *AdjustBalanceRequest, opts ...grpc.CallOption) (*Adjustment, error) {
+	out := new(Adjustment)
+	err := c.cc.Invoke(ctx, SQLService_AdjustBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*TransactionList, error) {
+	out := new(TransactionList)
+	err := c.cc.Invoke(ctx, SQLService_ListTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sQLServiceClient) ListFailedWebhookDeliveries(ctx context.Context, in *FailedWebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveryList, error) {
+	out := new(WebhookDeliveryList)
+	err := c.cc.Invoke(ctx, SQLService_ListFailedWebhookDeliveries_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SQLServiceServer is the server API for SQLService service.
+// All implementations must embed UnimplementedSQLServiceServer
+// for forward compatibility
+type SQLServiceServer interface {
+	GetBalance(context.Context, *WalletIdRequest) (*BalanceResponse, error)
+	UpdateBalance(context.Context, *UpdateBalanceRequest) (*Empty, error)
+	CreateTransaction(context.Context, *Transaction) (*Empty, error)
+	GetTransactionID(context.Context, *TransactionId) (*Transaction, error)
+	GetAuditLog(context.Context, *AuditLogRequest) (*AuditLogResponse, error)
+	Reconcile(context.Context, *ReconcileRequest) (*ReconcileReport, error)
+	DecideAdjustment(context.Context, *AdjustmentDecision) (*Adjustment, error)
+	CreateWebhook(context.Context, *CreateWebhookRequest) (*Webhook, error)
+	ListWebhooks(context.Context, *WalletIdRequest) (*WebhookList, error)
+	DeleteWebhook(context.Context, *WebhookRef) (*Empty, error)
+	EnableWebhook(context.Context, *WebhookRef) (*Webhook, error)
+	ListWebhookDeliveries(context.Context, *WebhookDeliveriesRequest) (*WebhookDeliveryList, error)
+	RedeliverWebhook(context.Context, *RedeliverRequest) (*WebhookDelivery, error)
+	ClaimWebhookDeliveries(context.Context, *ClaimWebhookDeliveriesRequest) (*WebhookDeliveryList, error)
+	ReportWebhookDelivery(context.Context, *WebhookAttempt) (*Empty, error)
+	CreateBatch(context.Context, *CreateBatchRequest) (*Batch, error)
+	GetBatch(context.Context, *BatchRef) (*Batch, error)
+	StartBatch(context.Context, *BatchRef) (*Batch, error)
+	ReportBatchItem(context.Context, *BatchItemResult) (*Empty, error)
+	// Operator RPCs, used by walletctl.
+	GetWallet(context.Context, *WalletIdRequest) (*Wallet, error)
+	ListWallets(context.Context, *ListWalletsRequest) (*WalletList, error)
+	CreateWallet(context.Context, *Empty) (*Wallet, error)
+	SetWalletFrozen(context.Context, *SetWalletFrozenRequest) (*Wallet, error)
+	AdjustBalance(context.Context, *AdjustBalanceRequest) (*Adjustment, error)
+	ListTransactions(context.Context, *ListTransactionsRequest) (*TransactionList, error)
+	ListFailedWebhookDeliveries(context.Context, *FailedWebhookDeliveriesRequest) (*WebhookDeliveryList, error)
+	mustEmbedUnimplementedSQLServiceServer()
+}
+
+// UnimplementedSQLServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSQLServiceServer struct {
+}
+
+func (UnimplementedSQLServiceServer) GetBalance(context.Context, *WalletIdRequest) (*BalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedSQLServiceServer) UpdateBalance(context.Context, *UpdateBalanceRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateBalance not implemented")
+}
+func (UnimplementedSQLServiceServer) CreateTransaction(context.Context, *Transaction) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTransaction not implemented")
+}
+func (UnimplementedSQLServiceServer) GetTransactionID(context.Context, *TransactionId) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionID not implemented")
+}
+func (UnimplementedSQLServiceServer) GetAuditLog(context.Context, *AuditLogRequest) (*AuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAuditLog not implemented")
+}
+func (UnimplementedSQLServiceServer) Reconcile(context.Context, *ReconcileRequest) (*ReconcileReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reconcile not implemented")
+}
+func (UnimplementedSQLServiceServer) DecideAdjustment(context.Context, *AdjustmentDecision) (*Adjustment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DecideAdjustment not implemented")
+}
+func (UnimplementedSQLServiceServer) CreateWebhook(context.Context, *CreateWebhookRequest) (*Webhook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWebhook not implemented")
+}
+func (UnimplementedSQLServiceServer) ListWebhooks(context.Context, *WalletIdRequest) (*WebhookList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhooks not implemented")
+}
+func (UnimplementedSQLServiceServer) DeleteWebhook(context.Context, *WebhookRef) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebhook not implemented")
+}
+func (UnimplementedSQLServiceServer) EnableWebhook(context.Context, *WebhookRef) (*Webhook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableWebhook not implemented")
+}
+func (UnimplementedSQLServiceServer) ListWebhookDeliveries(context.Context, *WebhookDeliveriesRequest) (*WebhookDeliveryList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhookDeliveries not implemented")
+}
+func (UnimplementedSQLServiceServer) RedeliverWebhook(context.Context, *RedeliverRequest) (*WebhookDelivery, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RedeliverWebhook not implemented")
+}
+func (UnimplementedSQLServiceServer) ClaimWebhookDeliveries(context.Context, *ClaimWebhookDeliveriesRequest) (*WebhookDeliveryList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClaimWebhookDeliveries not implemented")
+}
+func (UnimplementedSQLServiceServer) ReportWebhookDelivery(context.Context, *WebhookAttempt) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportWebhookDelivery not implemented")
+}
+func (UnimplementedSQLServiceServer) CreateBatch(context.Context, *CreateBatchRequest) (*Batch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBatch not implemented")
+}
+func (UnimplementedSQLServiceServer) GetBatch(context.Context, *BatchRef) (*Batch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBatch not implemented")
+}
+func (UnimplementedSQLServiceServer) StartBatch(context.Context, *BatchRef) (*Batch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartBatch not implemented")
+}
+func (UnimplementedSQLServiceServer) ReportBatchItem(context.Context, *BatchItemResult) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportBatchItem not implemented")
+}
+func (UnimplementedSQLServiceServer) GetWallet(context.Context, *WalletIdRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWallet not implemented")
+}
+func (UnimplementedSQLServiceServer) ListWallets(context.Context, *ListWalletsRequest) (*WalletList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWallets not implemented")
+}
+func (UnimplementedSQLServiceServer) CreateWallet(context.Context, *Empty) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWallet not implemented")
+}
+func (UnimplementedSQLServiceServer) SetWalletFrozen(context.Context, *SetWalletFrozenRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetWalletFrozen not implemented")
+}
+func (UnimplementedSQLServiceServer) AdjustBalance(context.Context, *AdjustBalanceRequest) (*Adjustment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdjustBalance not implemented")
+}
+func (UnimplementedSQLServiceServer) ListTransactions(context.Context, *ListTransactionsRequest) (*TransactionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedSQLServiceServer) ListFailedWebhookDeliveries(context.Context, *FailedWebhookDeliveriesRequest) (*WebhookDeliveryList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFailedWebhookDeliveries not implemented")
+}
+func (UnimplementedSQLServiceServer) mustEmbedUnimplementedSQLServiceServer() {}
+
+// UnsafeSQLServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SQLServiceServer will
+// result in compilation errors.
+type UnsafeSQLServiceServer interface {
+	mustEmbedUnimplementedSQLServiceServer()
+}
+
+func RegisterSQLServiceServer(s grpc.ServiceRegistrar, srv SQLServiceServer) {
+	s.RegisterService(&SQLService_ServiceDesc, srv)
+}
+
+func _SQLService_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WalletIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).GetBalance(ctx, req.(*WalletIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_UpdateBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).UpdateBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_UpdateBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).UpdateBalance(ctx, req.(*UpdateBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_CreateTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).CreateTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_CreateTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).CreateTransaction(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_GetTransactionID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).GetTransactionID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_GetTransactionID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).GetTransactionID(ctx, req.(*TransactionId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_GetAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).GetAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_GetAuditLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).GetAuditLog(ctx, req.(*AuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_Reconcile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).Reconcile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_Reconcile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).Reconcile(ctx, req.(*ReconcileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_DecideAdjustment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdjustmentDecision)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).DecideAdjustment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_DecideAdjustment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).DecideAdjustment(ctx, req.(*AdjustmentDecision))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_CreateWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).CreateWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_CreateWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).CreateWebhook(ctx, req.(*CreateWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ListWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WalletIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ListWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ListWebhooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ListWebhooks(ctx, req.(*WalletIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_DeleteWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).DeleteWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_DeleteWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).DeleteWebhook(ctx, req.(*WebhookRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_EnableWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).EnableWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_EnableWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).EnableWebhook(ctx, req.(*WebhookRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ListWebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ListWebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ListWebhookDeliveries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ListWebhookDeliveries(ctx, req.(*WebhookDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_RedeliverWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RedeliverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).RedeliverWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_RedeliverWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).RedeliverWebhook(ctx, req.(*RedeliverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ClaimWebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClaimWebhookDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ClaimWebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ClaimWebhookDeliveries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ClaimWebhookDeliveries(ctx, req.(*ClaimWebhookDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ReportWebhookDelivery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookAttempt)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ReportWebhookDelivery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ReportWebhookDelivery_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ReportWebhookDelivery(ctx, req.(*WebhookAttempt))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_CreateBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).CreateBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_CreateBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).CreateBatch(ctx, req.(*CreateBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_GetBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).GetBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_GetBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).GetBatch(ctx, req.(*BatchRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_StartBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).StartBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_StartBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).StartBatch(ctx, req.(*BatchRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ReportBatchItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchItemResult)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ReportBatchItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ReportBatchItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ReportBatchItem(ctx, req.(*BatchItemResult))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_GetWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WalletIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).GetWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_GetWallet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).GetWallet(ctx, req.(*WalletIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ListWallets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWalletsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ListWallets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ListWallets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ListWallets(ctx, req.(*ListWalletsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_CreateWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).CreateWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_CreateWallet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).CreateWallet(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_SetWalletFrozen_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetWalletFrozenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).SetWalletFrozen(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_SetWalletFrozen_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).SetWalletFrozen(ctx, req.(*SetWalletFrozenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_AdjustBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdjustBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).AdjustBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_AdjustBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).AdjustBalance(ctx, req.(*AdjustBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SQLService_ListFailedWebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FailedWebhookDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SQLServiceServer).ListFailedWebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SQLService_ListFailedWebhookDeliveries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SQLServiceServer).ListFailedWebhookDeliveries(ctx, req.(*FailedWebhookDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SQLService_ServiceDesc is the grpc.ServiceDesc for SQLService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SQLService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc.SQLService",
+	HandlerType: (*SQLServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBalance",
+			Handler:    _SQLService_GetBalance_Handler,
+		},
+		{
+			MethodName: "UpdateBalance",
+			Handler:    _SQLService_UpdateBalance_Handler,
+		},
+		{
+			MethodName: "CreateTransaction",
+			Handler:    _SQLService_CreateTransaction_Handler,
+		},
+		{
+			MethodName: "GetTransactionID",
+			Handler:    _SQLService_GetTransactionID_Handler,
+		},
+		{
+			MethodName: "GetAuditLog",
+			Handler:    _SQLService_GetAuditLog_Handler,
+		},
+		{
+			MethodName: "Reconcile",
+			Handler:    _SQLService_Reconcile_Handler,
+		},
+		{
+			MethodName: "DecideAdjustment",
+			Handler:    _SQLService_DecideAdjustment_Handler,
+		},
+		{
+			MethodName: "CreateWebhook",
+			Handler:    _SQLService_CreateWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhooks",
+			Handler:    _SQLService_ListWebhooks_Handler,
+		},
+		{
+			MethodName: "DeleteWebhook",
+			Handler:    _SQLService_DeleteWebhook_Handler,
+		},
+		{
+			MethodName: "EnableWebhook",
+			Handler:    _SQLService_EnableWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhookDeliveries",
+			Handler:    _SQLService_ListWebhookDeliveries_Handler,
+		},
+		{
+			MethodName: "RedeliverWebhook",
+			Handler:    _SQLService_RedeliverWebhook_Handler,
+		},
+		{
+			MethodName: "ClaimWebhookDeliveries",
+			Handler:    _SQLService_ClaimWebhookDeliveries_Handler,
+		},
+		{
+			MethodName: "ReportWebhookDelivery",
+			Handler:    _SQLService_ReportWebhookDelivery_Handler,
+		},
+		{
+			MethodName: "CreateBatch",
+			Handler:    _SQLService_CreateBatch_Handler,
+		},
+		{
+			MethodName: "GetBatch",
+			Handler:    _SQLService_GetBatch_Handler,
+		},
+		{
+			MethodName: "StartBatch",
+			Handler:    _SQLService_StartBatch_Handler,
+		},
+		{
+			MethodName: "ReportBatchItem",
+			Handler:    _SQLService_ReportBatchItem_Handler,
+		},
+		{
+			MethodName: "GetWallet",
+			Handler:    _SQLService_GetWallet_Handler,
+		},
+		{
+			MethodName: "ListWallets",
+			Handler:    _SQLService_ListWallets_Handler,
+		},
+		{
+			MethodName: "CreateWallet",
+			Handler:    _SQLService_CreateWallet_Handler,
+		},
+		{
+			MethodName: "SetWalletFrozen",
+			Handler:    _SQLService_SetWalletFrozen_Handler,
+		},
+		{
+			MethodName: "AdjustBalance",
+			Handler:    _SQLService_AdjustBalance_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _SQLService_ListTransactions_Handler,
+		},
+		{
+			MethodName: "ListFailedWebhookDeliveries",
+			Handler:    _SQLService_ListFailedWebhookDeliveries_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "grpc/transaction_sql.proto",
+}
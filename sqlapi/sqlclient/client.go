@@ -0,0 +1,116 @@
+// Package sqlclient is the sql-service client shared by api-service,
+// transaction-service and walletctl. It holds one connection and gives
+// every call a deadline; read-only calls are retried when sql-service is
+// unavailable.
+package sqlclient
+
+import (
+	"context"
+	"time"
+
+	"sqlapi/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options configure Dial. The zero value means no default deadline and no
+// retries.
+type Options struct {
+	// Timeout bounds each call, retries included, unless the context
+	// already has an earlier deadline.
+	Timeout time.Duration
+	// Retries is how many more times a read-only call is tried after
+	// codes.Unavailable. Writes are never retried: sql-service may have
+	// applied them before the connection broke.
+	Retries int
+	// RetryBackoff is the wait before the first retry; it doubles after
+	// each one.
+	RetryBackoff time.Duration
+	// Interceptors run inside the deadline and retry handling, once per
+	// attempt.
+	Interceptors []grpc.UnaryClientInterceptor
+	// DialOptions are passed to grpc.Dial, e.g. transport credentials and
+	// stats handlers.
+	DialOptions []grpc.DialOption
+}
+
+// readOnly lists the methods that are safe to retry.
+var readOnly = map[string]bool{
+	pb.SQLService_GetBalance_FullMethodName:            true,
+	pb.SQLService_GetTransactionID_FullMethodName:      true,
+	pb.SQLService_GetAuditLog_FullMethodName:           true,
+	pb.SQLService_ListWebhooks_FullMethodName:          true,
+	pb.SQLService_ListWebhookDeliveries_FullMethodName: true,
+	pb.SQLService_GetBatch_FullMethodName:              true,
+	pb.SQLService_GetWallet_FullMethodName:             true,
+	pb.SQLService_ListWallets_FullMethodName:           true,
+	pb.SQLService_ListTransactions_FullMethodName:      true,
+
+	pb.SQLService_ListFailedWebhookDeliveries_FullMethodName: true,
+}
+
+// Client is a typed sql-service client; the generated methods are promoted
+// from pb.SQLServiceClient.
+type Client struct {
+	pb.SQLServiceClient
+	conn *grpc.ClientConn
+}
+
+// Dial connects to sql-service at address. Like grpc.Dial it does not wait
+// for the connection to be up.
+func Dial(address string, opts Options) (*Client, error) {
+	interceptors := append([]grpc.UnaryClientInterceptor{
+		deadline(opts.Timeout),
+		retry(opts.Retries, opts.RetryBackoff),
+	}, opts.Interceptors...)
+
+	dialOptions := append([]grpc.DialOption{grpc.WithChainUnaryInterceptor(interceptors...)}, opts.DialOptions...)
+	conn, err := grpc.Dial(address, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+// New wraps an existing connection without adding interceptors.
+func New(conn *grpc.ClientConn) *Client {
+	return &Client{SQLServiceClient: pb.NewSQLServiceClient(conn), conn: conn}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func deadline(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func retry(retries int, backoff time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if !readOnly[method] {
+			return err
+		}
+
+		wait := backoff
+		for attempt := 0; attempt < retries && status.Code(err) == codes.Unavailable; attempt++ {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+			wait *= 2
+			err = invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return err
+	}
+}
@@ -0,0 +1,119 @@
+package sqlclient
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sqlapi/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// flakyServer fails the first failures calls with Unavailable.
+type flakyServer struct {
+	pb.UnimplementedSQLServiceServer
+	failures int32
+	calls    atomic.Int32
+	deadline chan time.Duration
+}
+
+func (s *flakyServer) GetBalance(ctx context.Context, req *pb.WalletIdRequest) (*pb.BalanceResponse, error) {
+	if s.calls.Add(1) <= s.failures {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	if deadline, ok := ctx.Deadline(); ok && s.deadline != nil {
+		s.deadline <- time.Until(deadline)
+	}
+	return &pb.BalanceResponse{Balance: 100}, nil
+}
+
+func (s *flakyServer) UpdateBalance(ctx context.Context, req *pb.UpdateBalanceRequest) (*pb.Empty, error) {
+	if s.calls.Add(1) <= s.failures {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &pb.Empty{}, nil
+}
+
+func dial(t *testing.T, server *flakyServer, opts Options) *Client {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterSQLServiceServer(s, server)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	opts.DialOptions = append(opts.DialOptions,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+	)
+	client, err := Dial("bufnet", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRetriesReadOnlyCalls(t *testing.T) {
+	server := &flakyServer{failures: 2}
+	client := dial(t, server, Options{Retries: 2, RetryBackoff: time.Millisecond})
+
+	response, err := client.GetBalance(context.Background(), &pb.WalletIdRequest{WalletId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Balance != 100 || server.calls.Load() != 3 {
+		t.Errorf("balance %v after %d calls, want 100 after 3", response.Balance, server.calls.Load())
+	}
+}
+
+func TestGivesUpAfterRetries(t *testing.T) {
+	server := &flakyServer{failures: 5}
+	client := dial(t, server, Options{Retries: 2, RetryBackoff: time.Millisecond})
+
+	_, err := client.GetBalance(context.Background(), &pb.WalletIdRequest{WalletId: 1})
+	if status.Code(err) != codes.Unavailable || server.calls.Load() != 3 {
+		t.Errorf("got %v after %d calls, want Unavailable after 3", err, server.calls.Load())
+	}
+}
+
+func TestDoesNotRetryWrites(t *testing.T) {
+	server := &flakyServer{failures: 1}
+	client := dial(t, server, Options{Retries: 2, RetryBackoff: time.Millisecond})
+
+	_, err := client.UpdateBalance(context.Background(), &pb.UpdateBalanceRequest{WalletId: 1, NewBalance: 5})
+	if status.Code(err) != codes.Unavailable || server.calls.Load() != 1 {
+		t.Errorf("got %v after %d calls, want Unavailable after 1", err, server.calls.Load())
+	}
+}
+
+func TestAppliesTimeout(t *testing.T) {
+	server := &flakyServer{deadline: make(chan time.Duration, 1)}
+	client := dial(t, server, Options{Timeout: time.Minute})
+
+	if _, err := client.GetBalance(context.Background(), &pb.WalletIdRequest{WalletId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if left := <-server.deadline; left <= 0 || left > time.Minute {
+		t.Errorf("server saw %v until the deadline, want at most 1m", left)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.GetBalance(ctx, &pb.WalletIdRequest{WalletId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if left := <-server.deadline; left > time.Second {
+		t.Errorf("server saw %v until the deadline, want the caller's 1s", left)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "time"
+
+// Config is the transaction-service configuration, see config.Load for the tag syntax.
+type Config struct {
+	LogLevel          string        `env:"LOG_LEVEL" flag:"log-level" default:"info" validate:"oneof=debug info warn error"`
+	SQLServiceAddress string        `env:"SQL_SERVICE_ADDRESS" flag:"sql-service-address" required:"true" validate:"hostport"`
+	SQLServiceTimeout time.Duration `env:"SQL_SERVICE_TIMEOUT" default:"5s" validate:"positive"`
+	SQLServiceRetries int           `env:"SQL_SERVICE_RETRIES" default:"2" usage:"retries of read-only sql-service calls when it is unavailable"`
+	SQLServiceBackoff time.Duration `env:"SQL_SERVICE_RETRY_BACKOFF" default:"100ms" validate:"positive"`
+	RabbitMQAddress   string        `env:"RABBITMQ_ADDRESS" flag:"rabbitmq-address" required:"true" validate:"url"`
+	SQLServiceName    string        `env:"SQL_SERVICE_SERVER_NAME" usage:"expected name in the sql-service certificate, defaults to the address host"`
+	TLSCertFile       string        `env:"TLS_CERT_FILE" flag:"tls-cert"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE" flag:"tls-key" secret:"true"`
+	TLSCAFile         string        `env:"TLS_CA_FILE" flag:"tls-ca"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" default:"30s" validate:"positive"`
+	InsecureDev       bool          `env:"INSECURE_DEV" default:"false" usage:"allow plaintext gRPC without certificates, for development only"`
+	RetryInterval     time.Duration `env:"RETRY_INTERVAL" default:"5s" validate:"positive"`
+	Workers           int           `env:"WORKERS" flag:"workers" default:"1" validate:"positive" usage:"consumers per queue"`
+	WebhookDelivery   bool          `env:"WEBHOOK_DELIVERY" default:"true" usage:"run the webhook delivery worker"`
+	WebhookPoll       time.Duration `env:"WEBHOOK_POLL_INTERVAL" default:"2s" validate:"positive"`
+	WebhookBatchSize  int           `env:"WEBHOOK_BATCH_SIZE" default:"10" validate:"positive"`
+	WebhookTimeout    time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s" validate:"positive" usage:"timeout of one POST to a webhook endpoint"`
+	TracingExporter   string        `env:"TRACING_EXPORTER" default:"none" validate:"oneof=none stdout otlp"`
+	OTLPEndpoint      string        `env:"OTLP_ENDPOINT" default:"localhost:4317" validate:"hostport"`
+}
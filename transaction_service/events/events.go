@@ -0,0 +1,119 @@
+// Package events publishes wallet activity to the wallet_events fanout
+// exchange once transaction_service has processed a request. api_service
+// relays the events to dashboards.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"common/logging"
+	"common/tracing"
+	pb "sqlapi/pb"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+// Exchange is the fanout exchange wallet events are published to.
+const Exchange = "wallet_events"
+
+// Event types.
+const (
+	TransactionCompleted = "transaction.completed"
+	TransactionFailed    = "transaction.failed"
+	BalanceChanged       = "balance.changed"
+)
+
+// Event is the message body. ID is unique across all publishers so
+// subscribers can resume after it.
+type Event struct {
+	ID              string    `json:"id"`
+	Type            string    `json:"type"`
+	WalletID        int       `json:"wallet_id"`
+	TransactionID   string    `json:"transaction_id,omitempty"`
+	TransactionType string    `json:"transaction_type,omitempty"`
+	Amount          float64   `json:"amount,omitempty"`
+	Status          string    `json:"status,omitempty"`
+	Balance         *float64  `json:"balance,omitempty"`
+	Time            time.Time `json:"time"`
+}
+
+// Publisher sends events over one channel. A nil Publisher drops them.
+type Publisher struct {
+	mu sync.Mutex // amqp channels are not safe for concurrent publishing
+	ch *amqp.Channel
+}
+
+// NewPublisher opens a channel on conn and declares the exchange.
+func NewPublisher(conn *amqp.Connection) (*Publisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.ExchangeDeclare(Exchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		ch.Close()
+		return nil, err
+	}
+	return &Publisher{ch: ch}, nil
+}
+
+func (p *Publisher) Close() error {
+	return p.ch.Close()
+}
+
+// Publish sends e, filling in its ID and time. Failures are logged only:
+// the event stream is best effort and never fails a transaction.
+func (p *Publisher) Publish(ctx context.Context, e Event) {
+	if p == nil {
+		return
+	}
+	e.ID = uuid.New().String()
+	e.Time = time.Now().UTC()
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to encode wallet event", "error", err)
+		return
+	}
+
+	p.mu.Lock()
+	err = p.ch.Publish(Exchange, "", false, false, amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   e.ID,
+		Headers:     tracing.AMQPHeaders(ctx),
+		Body:        body,
+	})
+	p.mu.Unlock()
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to publish wallet event", "type", e.Type, "error", err)
+	}
+}
+
+// Transaction publishes the outcome of a recorded transaction.
+func (p *Publisher) Transaction(ctx context.Context, t *pb.Transaction) {
+	eventType := TransactionFailed
+	if t.Status == "Success" {
+		eventType = TransactionCompleted
+	}
+	p.Publish(ctx, Event{
+		Type:            eventType,
+		WalletID:        int(t.WalletId),
+		TransactionID:   t.TransactionId,
+		TransactionType: t.Type,
+		Amount:          t.Amount,
+		Status:          t.Status,
+	})
+}
+
+// Balance publishes the new balance of a wallet.
+func (p *Publisher) Balance(ctx context.Context, walletID int, transactionID string, balance float64) {
+	p.Publish(ctx, Event{
+		Type:          BalanceChanged,
+		WalletID:      walletID,
+		TransactionID: transactionID,
+		Balance:       &balance,
+	})
+}
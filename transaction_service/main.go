@@ -2,309 +2,185 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log"
+	"log/slog"
+	"net"
 	"os"
 	"time"
 
-	pb "transaction_service/grpc/proto"
+	"common/config"
+	"common/logging"
+	"common/tlsconfig"
+	"common/tracing"
+	"sqlapi/sqlclient"
+	"transaction_service/events"
+	"transaction_service/processor"
+	"transaction_service/webhook"
 
-	"github.com/golang/protobuf/ptypes/timestamp"
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
-type WithdrawRequest struct {
-	WalletID int     `json:"wallet_id"`
-	Amount   float64 `json:"amount"`
-}
-
-type DepositRequest struct {
-	WalletID int     `json:"wallet_id"`
-	Amount   float64 `json:"amount"`
-}
+const serviceName = "transaction_service"
 
 func main() {
-	err := godotenv.Load(".env")
+	var cfg Config
+	if err := config.Load(&cfg, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New(cfg.LogLevel))
+	config.Print(&cfg)
+
+	shutdownTracing, err := tracing.Init(context.Background(), serviceName, cfg.TracingExporter, cfg.OTLPEndpoint)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		logging.Fatal("Failed to init tracing", "error", err)
 	}
+	defer shutdownTracing(context.Background())
 
-	sqlServiceConnString := os.Getenv("SQL_SERVICE_ADDRESS")
+	transportCredentials, err := sqlServiceCredentials(cfg)
+	if err != nil {
+		logging.Fatal("Failed to load TLS certificates", "error", err)
+	}
 
 	// gRPC Connect to sql-service
-	var connSQL *grpc.ClientConn
-	var sqlServiceClient pb.SQLServiceClient
-
-	connectSQLService := func() {
-		for {
-			connSQL, err = grpc.Dial(sqlServiceConnString, grpc.WithInsecure())
-			if err != nil {
-				log.Printf("Failed to connect to SQL service: %v. Retrying...", err)
-				time.Sleep(5 * time.Second)
-				continue
-			}
-			sqlServiceClient = pb.NewSQLServiceClient(connSQL)
-			log.Println("Connected to SQL service")
-			break
+	var sqlServiceClient *sqlclient.Client
+	for {
+		sqlServiceClient, err = sqlclient.Dial(cfg.SQLServiceAddress, sqlclient.Options{
+			Timeout:      cfg.SQLServiceTimeout,
+			Retries:      cfg.SQLServiceRetries,
+			RetryBackoff: cfg.SQLServiceBackoff,
+			Interceptors: []grpc.UnaryClientInterceptor{logging.UnaryClientInterceptor()},
+			DialOptions:  []grpc.DialOption{transportCredentials, grpc.WithStatsHandler(otelgrpc.NewClientHandler())},
+		})
+		if err != nil {
+			slog.Warn("Failed to connect to SQL service. Retrying...", "error", err)
+			time.Sleep(cfg.RetryInterval)
+			continue
 		}
+		slog.Info("Connected to SQL service")
+		break
 	}
-
-	connectSQLService()
-	defer connSQL.Close()
+	defer sqlServiceClient.Close()
 
 	// RabbitMQ Connect
 	for {
-		rabbitConnString := os.Getenv("RABBITMQ_ADDRESS")
-		log.Printf("RABBIT: %s\n", rabbitConnString)
-		conn, err := amqp.Dial(rabbitConnString)
+		conn, err := amqp.Dial(cfg.RabbitMQAddress)
 		if err != nil {
-			log.Printf("Failed to connect to RabbitMQ: %v. Retrying...", err)
-			time.Sleep(5 * time.Second)
+			slog.Warn("Failed to connect to RabbitMQ. Retrying...", "error", err)
+			time.Sleep(cfg.RetryInterval)
 			continue
 		}
 		defer conn.Close()
 
 		ch, err := conn.Channel()
 		if err != nil {
-			log.Printf("Failed to open a channel: %v. Retrying...", err)
-			time.Sleep(5 * time.Second)
+			slog.Warn("Failed to open a channel. Retrying...", "error", err)
+			time.Sleep(cfg.RetryInterval)
 			continue
 		}
 		defer ch.Close()
 
-		qDeposit, err := ch.QueueDeclare(
-			"deposit_requests",
-			false,
-			false,
-			false,
-			false,
-			nil,
-		)
+		publisher, err := events.NewPublisher(conn)
 		if err != nil {
-			log.Fatal("Failed to declare a queue for deposit requests")
-			return
+			logging.Fatal("Failed to declare the wallet events exchange", "error", err)
 		}
+		defer publisher.Close()
 
-		qWithdraw, err := ch.QueueDeclare(
-			"withdraw_requests",
-			false,
-			false,
-			false,
-			false,
-			nil,
-		)
-		if err != nil {
-			log.Fatal("Failed to declare a queue for withdraw requests")
-			return
+		p := processor.New(sqlServiceClient, publisher)
+		consumers := []*processor.Consumer{
+			{Processor: p, Type: processor.TypeDeposit, Queue: "deposit_requests", Timeout: cfg.SQLServiceTimeout},
+			{Processor: p, Type: processor.TypeWithdraw, Queue: "withdraw_requests", Timeout: cfg.SQLServiceTimeout},
 		}
-
-		messagesDeposit, err := ch.Consume(
-			qDeposit.Name,
-			"",
-			true,
-			false,
-			false,
-			false,
-			nil,
-		)
-		if err != nil {
-			log.Fatal("Failed to register a consumer for deposit requests")
-			return
+		for _, consumer := range consumers {
+			messages, err := consume(ch, consumer.Queue)
+			if err != nil {
+				logging.Fatal("Failed to consume "+consumer.Type+" requests", "error", err)
+			}
+			for i := 0; i < cfg.Workers; i++ {
+				go consumer.Run(messages)
+			}
 		}
 
-		messagesWithdraw, err := ch.Consume(
-			qWithdraw.Name,
-			"",
-			true,
-			false,
-			false,
-			false,
-			nil,
-		)
+		batches := &processor.BatchConsumer{Processor: p, Client: sqlServiceClient, Queue: "batch_requests", Timeout: cfg.SQLServiceTimeout}
+		messagesBatch, err := consume(ch, batches.Queue)
 		if err != nil {
-			log.Fatal("Failed to register a consumer for withdraw requests")
-			return
+			logging.Fatal("Failed to consume batch requests", "error", err)
+		}
+		for i := 0; i < cfg.Workers; i++ {
+			go batches.Run(messagesBatch)
 		}
 
-		// Withdraw
-		go func() {
-			for msg := range messagesWithdraw {
-				var withdrawRequest WithdrawRequest
-				err := json.Unmarshal(msg.Body, &withdrawRequest)
-				if err != nil {
-					log.Println("Failed withdraw request:", err)
-					continue
-				}
-
-				if withdrawRequest.Amount <= 0 {
-					log.Println("Error: Withdraw amount must be greater than 0.")
-					continue
-				}
-
-				balanceRequest := &pb.WalletIdRequest{WalletId: int32(withdrawRequest.WalletID)}
-				balanceResponse, err := sqlServiceClient.GetBalance(context.Background(), balanceRequest)
-				if err != nil {
-					log.Printf("Failed to get balance: %v", err)
-					continue
-				}
-
-				currentBalance := balanceResponse.Balance
-				amountToWithdraw := float64(withdrawRequest.Amount)
-
-				if currentBalance < amountToWithdraw {
-					log.Println("Error: Insufficient balance for the withdrawal.")
-
-					newTransaction := &pb.Transaction{
-						TransactionId: uuid.New().String(),
-						WalletId:      int32(withdrawRequest.WalletID),
-						Amount:        amountToWithdraw,
-						Type:          "withdraw",
-						RequestTime:   &timestamp.Timestamp{},
-						Status:        "error",
-					}
-
-					if _, err := sqlServiceClient.CreateTransaction(context.Background(), newTransaction); err != nil {
-						log.Printf("Failed to create an error withdraw transaction: %v", err)
-					} else {
-						log.Println("New error withdraw transaction created successfully")
-					}
-
-					continue
-				}
-
-				newBalance := currentBalance - amountToWithdraw
-
-				// Update balance
-				updateBalanceRequest := &pb.UpdateBalanceRequest{
-					WalletId:   int32(withdrawRequest.WalletID),
-					NewBalance: newBalance,
-				}
-
-				if _, err := sqlServiceClient.UpdateBalance(context.Background(), updateBalanceRequest); err != nil {
-					log.Printf("Failed to update balance: %v", err)
-					continue
-				}
-
-				// Successful withdrawal transaction
-				newTransaction := &pb.Transaction{
-					TransactionId: uuid.New().String(),
-					WalletId:      int32(withdrawRequest.WalletID),
-					Amount:        amountToWithdraw,
-					Type:          "withdraw",
-					RequestTime:   &timestamp.Timestamp{},
-					Status:        "Success",
-				}
-
-				if _, err := sqlServiceClient.CreateTransaction(context.Background(), newTransaction); err != nil {
-					log.Printf("Failed to create a new withdraw transaction: %v", err)
-				} else {
-					log.Println("New withdraw transaction created successfully")
-				}
+		if cfg.WebhookDelivery {
+			worker := &webhook.Worker{
+				Queue:        sqlServiceClient,
+				HTTP:         webhook.NewHTTPClient(cfg.WebhookTimeout),
+				PollInterval: cfg.WebhookPoll,
+				BatchSize:    cfg.WebhookBatchSize,
+				QueueTimeout: cfg.SQLServiceTimeout,
 			}
-		}()
-
-		// Deposit
-		go func() {
-			for msg := range messagesDeposit {
-				var depositRequest DepositRequest
-				err := json.Unmarshal(msg.Body, &depositRequest)
-				if err != nil {
-					log.Println("Failed to unmarshal deposit request:", err)
-					continue
-				}
-
-				if depositRequest.Amount < 0 {
-					log.Println("Error: Deposit amount cannot be negative.")
-					continue
-				}
-
-				if depositRequest.Amount > 1000000000 {
-					log.Println("Error: Deposit amount exceeds the limit of 1,000,000,000.")
-
-					// Transaction "error"
-					newTransaction := &pb.Transaction{
-						TransactionId: uuid.New().String(),
-						WalletId:      int32(depositRequest.WalletID),
-						Amount:        float64(depositRequest.Amount),
-						Type:          "deposit",
-						RequestTime:   &timestamp.Timestamp{},
-						Status:        "error",
-					}
-
-					if _, err := sqlServiceClient.CreateTransaction(context.Background(), newTransaction); err != nil {
-						log.Printf("Failed to create an error deposit transaction: %v", err)
-					} else {
-						log.Println("New error deposit transaction created successfully")
-					}
-					continue
-				}
-
-				balanceRequest := &pb.WalletIdRequest{WalletId: int32(depositRequest.WalletID)}
-				balanceResponse, err := sqlServiceClient.GetBalance(context.Background(), balanceRequest)
-				if err != nil {
-					log.Printf("Failed to get balance: %v", err)
-					continue
-				}
-
-				currentBalance := balanceResponse.Balance
-				amountToDeposit := float64(depositRequest.Amount)
-				newBalance := currentBalance + amountToDeposit
-
-				if newBalance > 1000000000 {
-					log.Println("Error: Deposit would exceed the balance limit of 1,000,000,000.")
-
-					// Transaction "error"
-					newTransaction := &pb.Transaction{
-						TransactionId: uuid.New().String(),
-						WalletId:      int32(depositRequest.WalletID),
-						Amount:        amountToDeposit,
-						Type:          "deposit",
-						RequestTime:   &timestamp.Timestamp{},
-						Status:        "error",
-					}
-
-					if _, err := sqlServiceClient.CreateTransaction(context.Background(), newTransaction); err != nil {
-						log.Printf("Failed to create an error deposit transaction: %v", err)
-					} else {
-						log.Println("New error deposit transaction created successfully")
-					}
+			go worker.Run(context.Background())
+		}
 
-					continue
-				}
+		slog.Info("Transaction service is running")
+		select {}
+	}
+}
 
-				// Update balance
-				updateBalanceRequest := &pb.UpdateBalanceRequest{
-					WalletId:   int32(depositRequest.WalletID),
-					NewBalance: newBalance,
-				}
+// consume declares queue and registers a consumer on it.
+func consume(ch *amqp.Channel, queue string) (<-chan amqp.Delivery, error) {
+	q, err := ch.QueueDeclare(
+		queue,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
 
-				if _, err := sqlServiceClient.UpdateBalance(context.Background(), updateBalanceRequest); err != nil {
-					log.Printf("Failed to update balance: %v", err)
-					continue
-				}
+	return ch.Consume(
+		q.Name,
+		"",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+}
 
-				// Successful deposit
-				newTransaction := &pb.Transaction{
-					TransactionId: uuid.New().String(),
-					WalletId:      int32(depositRequest.WalletID),
-					Amount:        amountToDeposit,
-					Type:          "deposit",
-					RequestTime:   &timestamp.Timestamp{},
-					Status:        "Success",
-				}
+// sqlServiceCredentials returns mTLS transport credentials for the sql-service
+// connection, or plaintext when no certificates are configured and
+// INSECURE_DEV allows it.
+func sqlServiceCredentials(cfg Config) (grpc.DialOption, error) {
+	files := tlsconfig.Files{
+		CertFile: cfg.TLSCertFile,
+		KeyFile:  cfg.TLSKeyFile,
+		CAFile:   cfg.TLSCAFile,
+	}
+	plaintext, err := files.Plaintext(cfg.InsecureDev)
+	if err != nil {
+		return nil, err
+	}
+	if plaintext {
+		slog.Warn("INSECURE_DEV is set: connecting to sql-service over plaintext")
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
 
-				if _, err := sqlServiceClient.CreateTransaction(context.Background(), newTransaction); err != nil {
-					log.Printf("Failed to create a new deposit transaction: %v", err)
-				} else {
-					log.Println("New deposit transaction created successfully")
-				}
-			}
-		}()
+	reloader, err := tlsconfig.NewReloader(context.Background(), files, cfg.TLSReloadInterval)
+	if err != nil {
+		return nil, err
+	}
 
-		log.Println("Transaction service is running")
-		select {}
+	serverName := cfg.SQLServiceName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(cfg.SQLServiceAddress)
 	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(reloader.ClientConfig(serverName))), nil
 }
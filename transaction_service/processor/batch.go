@@ -0,0 +1,255 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"common/logging"
+	pb "sqlapi/pb"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Batch modes.
+const (
+	ModeAllOrNothing = "all_or_nothing"
+	ModeBestEffort   = "best_effort"
+)
+
+// BatchService is the part of the sql-service client batch processing
+// uses.
+type BatchService interface {
+	GetBalance(ctx context.Context, in *pb.WalletIdRequest, opts ...grpc.CallOption) (*pb.BalanceResponse, error)
+	StartBatch(ctx context.Context, in *pb.BatchRef, opts ...grpc.CallOption) (*pb.Batch, error)
+	ReportBatchItem(ctx context.Context, in *pb.BatchItemResult, opts ...grpc.CallOption) (*pb.Empty, error)
+}
+
+// BatchRequest is published by api-service once a batch is stored in
+// sql-service.
+type BatchRequest struct {
+	BatchID string `json:"batch_id"`
+}
+
+// BatchConsumer runs the batches announced on Queue through a processor.
+type BatchConsumer struct {
+	Processor *Processor
+	Client    BatchService
+	Queue     string
+	// Timeout bounds the sql-service calls of a single item, not the whole
+	// batch.
+	Timeout time.Duration
+}
+
+// Run processes deliveries until the channel is closed.
+func (c *BatchConsumer) Run(deliveries <-chan amqp.Delivery) {
+	for msg := range deliveries {
+		c.Handle(msg)
+	}
+}
+
+// Handle processes the batch announced by one delivery.
+func (c *BatchConsumer) Handle(msg amqp.Delivery) {
+	ctx, span := StartConsumerSpan(msg, c.Queue)
+	defer span.End()
+
+	var request BatchRequest
+	if err := json.Unmarshal(msg.Body, &request); err != nil {
+		logging.FromContext(ctx).Error("Failed to unmarshal batch request", "error", err)
+		return
+	}
+	c.Process(ctx, request.BatchID)
+}
+
+// Process starts the batch in sql-service and runs its items, in order,
+// through the deposit and withdraw pipeline, reporting each outcome to
+// sql-service, which rolls them up into the batch status. A batch that was
+// started before is skipped.
+func (c *BatchConsumer) Process(ctx context.Context, batchID string) {
+	ctx = logging.With(ctx, "batch_id", batchID)
+	logger := logging.FromContext(ctx)
+
+	startCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+	batch, err := c.Client.StartBatch(startCtx, &pb.BatchRef{BatchId: batchID})
+	cancel()
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Warn("Skipping batch", "error", err)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to start batch", "error", err)
+		return
+	}
+	logger.Info("Processing batch", "mode", batch.Mode, "items", batch.Total)
+
+	bp := &batchProcessor{client: c.Client, processor: c.Processor, batch: batch, timeout: c.Timeout}
+	if batch.Mode == ModeAllOrNothing {
+		bp.allOrNothing(ctx)
+	} else {
+		bp.bestEffort(ctx)
+	}
+}
+
+// batchProcessor runs the items of one batch.
+type batchProcessor struct {
+	client    BatchService
+	processor *Processor
+	batch     *pb.Batch
+	// timeout bounds the sql-service calls of a single item.
+	timeout time.Duration
+}
+
+func (p *batchProcessor) bestEffort(ctx context.Context) {
+	for _, item := range p.batch.Items {
+		transactionID, applied, err := p.apply(ctx, item.WalletId, item.Type, item.Amount)
+		if applied {
+			p.report(ctx, item, "succeeded", transactionID, errorText(err))
+			continue
+		}
+		if !errors.Is(err, ErrRejected) {
+			transactionID = ""
+		}
+		p.report(ctx, item, "failed", transactionID, err.Error())
+	}
+}
+
+// allOrNothing checks the whole batch against the current balances before
+// touching any of them. An item that still fails while the batch runs (the
+// balance moved in between) has the items applied before it reverted with
+// compensating transactions.
+func (p *batchProcessor) allOrNothing(ctx context.Context) {
+	if failed, reason := p.check(ctx); failed != nil {
+		for _, item := range p.batch.Items {
+			if item != failed {
+				p.report(ctx, item, "skipped", "", fmt.Sprintf("batch rejected: item %d: %s", failed.Index, reason))
+			}
+		}
+		p.report(ctx, failed, "failed", "", reason)
+		return
+	}
+
+	type appliedItem struct {
+		item          *pb.BatchItem
+		transactionID string
+	}
+	var done []appliedItem
+
+	for i, item := range p.batch.Items {
+		transactionID, applied, err := p.apply(ctx, item.WalletId, item.Type, item.Amount)
+		if applied {
+			p.report(ctx, item, "succeeded", transactionID, errorText(err))
+			done = append(done, appliedItem{item, transactionID})
+			continue
+		}
+
+		// Undo in reverse order, then skip the rest. The failed item is
+		// reported last so the batch only finishes once everything is in.
+		cause := fmt.Sprintf("item %d failed: %v", item.Index, err)
+		for j := len(done) - 1; j >= 0; j-- {
+			reverse := TypeWithdraw
+			if done[j].item.Type == TypeWithdraw {
+				reverse = TypeDeposit
+			}
+			reversalID, reverted, revertErr := p.apply(ctx, done[j].item.WalletId, reverse, done[j].item.Amount)
+			if reverted {
+				p.report(ctx, done[j].item, "reverted", done[j].transactionID, fmt.Sprintf("%s; reverted by %s", cause, reversalID))
+			} else {
+				logging.FromContext(ctx).Error("Failed to revert batch item", "item", done[j].item.Index, "error", revertErr)
+				p.report(ctx, done[j].item, "revert_failed", done[j].transactionID, fmt.Sprintf("%s; revert failed: %v", cause, revertErr))
+			}
+		}
+		for _, rest := range p.batch.Items[i+1:] {
+			p.report(ctx, rest, "skipped", "", cause)
+		}
+		if !errors.Is(err, ErrRejected) {
+			transactionID = ""
+		}
+		p.report(ctx, item, "failed", transactionID, err.Error())
+		return
+	}
+}
+
+// check replays the batch on the current balances with the rules of the
+// pipeline and returns the first item that would fail.
+func (p *batchProcessor) check(ctx context.Context) (*pb.BatchItem, string) {
+	balances := make(map[int32]float64)
+	for _, item := range p.batch.Items {
+		balance, ok := balances[item.WalletId]
+		if !ok {
+			callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+			response, err := p.client.GetBalance(callCtx, &pb.WalletIdRequest{WalletId: item.WalletId})
+			cancel()
+			if err != nil {
+				return item, fmt.Sprintf("failed to get balance: %v", err)
+			}
+			balance = response.Balance
+		}
+
+		h, ok := p.processor.Handler(item.Type)
+		if !ok {
+			return item, fmt.Sprintf("unknown transaction type %q", item.Type)
+		}
+		if err := h.Validate(item.Amount); err != nil {
+			return item, reasonText(err)
+		}
+		balance, err := h.Apply(balance, item.Amount)
+		if err != nil {
+			return item, reasonText(err)
+		}
+		balances[item.WalletId] = balance
+	}
+	return nil, ""
+}
+
+// apply runs one deposit or withdraw through the pipeline on behalf of the
+// batch principal.
+func (p *batchProcessor) apply(ctx context.Context, walletID int32, typ string, amount float64) (transactionID string, applied bool, err error) {
+	transactionID = uuid.New().String()
+	ctx = logging.With(ctx, "wallet_id", walletID, "transaction_id", transactionID, "principal", p.batch.Principal)
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	outcome := p.processor.Process(ctx, Request{
+		Type:          typ,
+		TransactionID: transactionID,
+		WalletID:      int(walletID),
+		Amount:        amount,
+		Principal:     p.batch.Principal,
+	})
+	return transactionID, outcome.Applied, outcome.Err
+}
+
+func (p *batchProcessor) report(ctx context.Context, item *pb.BatchItem, itemStatus, transactionID, errMsg string) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	_, err := p.client.ReportBatchItem(ctx, &pb.BatchItemResult{
+		BatchId:       p.batch.BatchId,
+		Index:         item.Index,
+		Status:        itemStatus,
+		TransactionId: transactionID,
+		Error:         errMsg,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to report batch item", "item", item.Index, "status", itemStatus, "error", err)
+	}
+}
+
+// reasonText is the reason of a rejection without the "rejected: " prefix.
+func reasonText(err error) string {
+	return strings.TrimPrefix(err.Error(), ErrRejected.Error()+": ")
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
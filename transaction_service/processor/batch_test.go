@@ -0,0 +1,241 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	db "sql_service/database"
+	sqlservice "sql_service/grpc"
+	pb "sqlapi/pb"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memorySQL is a sql-service client whose server keeps everything in a
+// database.Memory. afterUpdate, when set, runs after every successful
+// UpdateBalance with the number of updates so far, so a test can change
+// balances while a batch runs.
+type memorySQL struct {
+	pb.SQLServiceClient
+	updates     int
+	afterUpdate func(n int)
+}
+
+func (m *memorySQL) UpdateBalance(ctx context.Context, in *pb.UpdateBalanceRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	response, err := m.SQLServiceClient.UpdateBalance(ctx, in, opts...)
+	if err == nil {
+		m.updates++
+		if m.afterUpdate != nil {
+			m.afterUpdate(m.updates)
+		}
+	}
+	return response, err
+}
+
+// newMemorySQL serves sql-service over an in-process connection and funds
+// one wallet per balance with a recorded deposit, so that reconciliation
+// starts clean.
+func newMemorySQL(t *testing.T, balances ...float64) (*memorySQL, *db.Memory, *Processor) {
+	t.Helper()
+
+	store := db.NewMemory()
+	server := grpc.NewServer()
+	pb.RegisterSQLServiceServer(server, &sqlservice.Server{Wallets: store, Transactions: store, Batches: store, Adjustments: store})
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client := &memorySQL{SQLServiceClient: pb.NewSQLServiceClient(conn)}
+	p := New(client, &fakePublisher{})
+	for _, balance := range balances {
+		walletID, err := store.AddWallet(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance > 0 {
+			deposit(t, p, walletID, balance)
+		}
+	}
+	client.updates = 0
+	return client, store, p
+}
+
+func deposit(t *testing.T, p *Processor, walletID int, amount float64) {
+	t.Helper()
+	outcome := p.Process(context.Background(), Request{Type: TypeDeposit, TransactionID: uuid.NewString(), WalletID: walletID, Amount: amount})
+	if outcome.Err != nil {
+		t.Fatal(outcome.Err)
+	}
+}
+
+// runBatch stores a batch, processes it through a BatchConsumer and
+// returns it as sql-service reports it afterwards.
+func runBatch(t *testing.T, client *memorySQL, p *Processor, mode string, items ...*pb.BatchItem) *pb.Batch {
+	t.Helper()
+	ctx := context.Background()
+
+	batch, err := client.CreateBatch(ctx, &pb.CreateBatchRequest{Principal: "merchant", Mode: mode, Items: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(BatchRequest{BatchID: batch.BatchId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer := &BatchConsumer{Processor: p, Client: client, Queue: "batch_requests", Timeout: time.Second}
+	consumer.Handle(amqp.Delivery{Body: body})
+
+	batch, err = client.GetBatch(ctx, &pb.BatchRef{BatchId: batch.BatchId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+func item(walletID int32, typ string, amount float64) *pb.BatchItem {
+	return &pb.BatchItem{WalletId: walletID, Type: typ, Amount: amount}
+}
+
+// checkBatch compares the batch and item statuses and the wallet balances
+// with the expected ones, and checks that every balance still matches its
+// recorded transactions: a batch may neither lose nor mint money.
+func checkBatch(t *testing.T, store *db.Memory, batch *pb.Batch, wantStatus string, wantItems []string, wantBalances ...float64) {
+	t.Helper()
+	ctx := context.Background()
+
+	if batch.Status != wantStatus {
+		t.Errorf("batch status %s, want %s", batch.Status, wantStatus)
+	}
+	for i, it := range batch.Items {
+		if it.Status != wantItems[i] {
+			t.Errorf("item %d: status %s (%s), want %s", i, it.Status, it.Error, wantItems[i])
+		}
+		if it.Status == "succeeded" || it.Status == "reverted" || it.Status == "revert_failed" {
+			transaction, err := store.GetTransaction(ctx, it.TransactionId)
+			if err != nil || transaction.Status != StatusSuccess || transaction.Principal != "merchant" {
+				t.Errorf("item %d: transaction %+v, %v", i, transaction, err)
+			}
+		}
+	}
+	for i, want := range wantBalances {
+		if balance, err := store.GetBalance(ctx, i+1); err != nil || balance != want {
+			t.Errorf("wallet %d: balance %v, %v, want %v", i+1, balance, err, want)
+		}
+	}
+
+	report, err := store.ReconcileWallets(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mismatch := range report.Mismatches {
+		t.Errorf("wallet %d: balance %v, transactions add up to %v", mismatch.WalletID, mismatch.Balance, mismatch.ExpectedBalance)
+	}
+	if checked, err := store.VerifyAuditChain(ctx); err != nil {
+		t.Errorf("audit chain broken after %d entries: %v", checked, err)
+	}
+}
+
+func TestBatchAllOrNothing(t *testing.T) {
+	client, store, p := newMemorySQL(t, 100, 50)
+	batch := runBatch(t, client, p, ModeAllOrNothing,
+		item(1, TypeWithdraw, 30), item(2, TypeDeposit, 30), item(2, TypeWithdraw, 60))
+
+	checkBatch(t, store, batch, "completed", []string{"succeeded", "succeeded", "succeeded"}, 70, 20)
+}
+
+// TestBatchAllOrNothingPreCheck checks that a batch that cannot be applied
+// on the current balances leaves every balance alone. Each item fits the
+// balance on its own; together they overdraw wallet 1.
+func TestBatchAllOrNothingPreCheck(t *testing.T) {
+	client, store, p := newMemorySQL(t, 100, 0)
+	batch := runBatch(t, client, p, ModeAllOrNothing,
+		item(2, TypeDeposit, 10), item(1, TypeWithdraw, 60), item(1, TypeWithdraw, 60))
+
+	checkBatch(t, store, batch, "failed", []string{"skipped", "skipped", "failed"}, 100, 0)
+	if client.updates != 0 {
+		t.Errorf("%d balance updates for a rejected batch", client.updates)
+	}
+}
+
+// TestBatchAllOrNothingCompensates changes a balance after the pre-check so
+// that the last item fails, and checks that the items applied before it
+// are undone.
+func TestBatchAllOrNothingCompensates(t *testing.T) {
+	client, store, p := newMemorySQL(t, 100, 50)
+	client.afterUpdate = func(n int) {
+		if n == 2 {
+			// Another request takes 40 from wallet 2 between the second
+			// and the third item.
+			outcome := p.Process(context.Background(), Request{Type: TypeWithdraw, TransactionID: uuid.NewString(), WalletID: 2, Amount: 40})
+			if outcome.Err != nil {
+				t.Error(outcome.Err)
+			}
+		}
+	}
+	batch := runBatch(t, client, p, ModeAllOrNothing,
+		item(1, TypeWithdraw, 30), item(2, TypeDeposit, 30), item(2, TypeWithdraw, 60))
+
+	checkBatch(t, store, batch, "failed", []string{"reverted", "reverted", "failed"}, 100, 10)
+}
+
+// TestBatchAllOrNothingRevertFails freezes both wallets after the second
+// item: the third is rejected, neither applied item can be undone, and the
+// batch is partially completed.
+func TestBatchAllOrNothingRevertFails(t *testing.T) {
+	client, store, p := newMemorySQL(t, 100, 50)
+	client.afterUpdate = func(n int) {
+		if n == 2 {
+			if _, err := store.SetFrozen(context.Background(), 2, true, "test"); err != nil {
+				t.Error(err)
+			}
+			if _, err := store.SetFrozen(context.Background(), 1, true, "test"); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	batch := runBatch(t, client, p, ModeAllOrNothing,
+		item(1, TypeWithdraw, 30), item(2, TypeDeposit, 30), item(2, TypeWithdraw, 10))
+
+	checkBatch(t, store, batch, "partially_completed", []string{"revert_failed", "revert_failed", "failed"}, 70, 80)
+}
+
+func TestBatchBestEffort(t *testing.T) {
+	client, store, p := newMemorySQL(t, 100, 0)
+	batch := runBatch(t, client, p, ModeBestEffort,
+		item(1, TypeWithdraw, 60), item(1, TypeWithdraw, 60), item(2, TypeDeposit, 60))
+
+	checkBatch(t, store, batch, "partially_completed", []string{"succeeded", "failed", "succeeded"}, 40, 60)
+	if batch.Items[1].TransactionId == "" {
+		t.Error("the rejected item has no transaction id")
+	}
+	if transaction, err := store.GetTransaction(context.Background(), batch.Items[1].TransactionId); err != nil || transaction.Status != StatusError {
+		t.Errorf("rejected item: transaction %+v, %v", transaction, err)
+	}
+}
+
+// TestBatchRedelivered checks that a batch message delivered twice is only
+// processed once.
+func TestBatchRedelivered(t *testing.T) {
+	client, store, p := newMemorySQL(t, 100)
+	batch := runBatch(t, client, p, ModeBestEffort, item(1, TypeWithdraw, 10))
+
+	consumer := &BatchConsumer{Processor: p, Client: client, Queue: "batch_requests", Timeout: time.Second}
+	consumer.Process(context.Background(), batch.BatchId)
+
+	checkBatch(t, store, batch, "completed", []string{"succeeded"}, 90)
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"common/logging"
+	"common/tracing"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("transaction_service/processor")
+
+// Message is the body api-service publishes to the queue of a transaction
+// type.
+type Message struct {
+	// TransactionID is assigned by api-service; messages published before
+	// it did so get a new one.
+	TransactionID string  `json:"transaction_id"`
+	WalletID      int     `json:"wallet_id"`
+	Amount        float64 `json:"amount"`
+	Principal     string  `json:"principal"`
+}
+
+// Consumer feeds the requests of one queue to a processor.
+type Consumer struct {
+	Processor *Processor
+	// Type is the transaction type of every message in Queue.
+	Type  string
+	Queue string
+	// Timeout bounds the processing of one message.
+	Timeout time.Duration
+}
+
+// Run processes deliveries until the channel is closed.
+func (c *Consumer) Run(deliveries <-chan amqp.Delivery) {
+	for msg := range deliveries {
+		c.Handle(msg)
+	}
+}
+
+// Handle processes one delivery under the transaction id api-service gave
+// it.
+func (c *Consumer) Handle(msg amqp.Delivery) Outcome {
+	ctx, span := StartConsumerSpan(msg, c.Queue)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var message Message
+	if err := json.Unmarshal(msg.Body, &message); err != nil {
+		logging.FromContext(ctx).Error("Failed to unmarshal "+c.Type+" request", "error", err)
+		return Outcome{Err: err}
+	}
+
+	if message.TransactionID == "" {
+		message.TransactionID = uuid.New().String()
+	}
+	req := Request{
+		Type:          c.Type,
+		TransactionID: message.TransactionID,
+		WalletID:      message.WalletID,
+		Amount:        message.Amount,
+		Principal:     message.Principal,
+	}
+	ctx = logging.With(ctx, "wallet_id", req.WalletID, "transaction_id", req.TransactionID, "principal", req.Principal)
+	return c.Processor.Process(ctx, req)
+}
+
+// StartConsumerSpan continues the trace carried in the message headers and
+// tags the context logger with the request id set by api-service.
+func StartConsumerSpan(msg amqp.Delivery, queue string) (context.Context, trace.Span) {
+	ctx := tracing.FromAMQPHeaders(context.Background(), msg.Headers)
+	return tracer.Start(ctx, queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.source.name", queue),
+		),
+	)
+}
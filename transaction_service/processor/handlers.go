@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"fmt"
+
+	"sqlapi/amount"
+)
+
+// Transaction types handled by default.
+const (
+	TypeDeposit  = "deposit"
+	TypeWithdraw = "withdraw"
+)
+
+// BalanceLimit caps deposits and wallet balances at the largest balance
+// sql-service can store.
+const BalanceLimit = amount.Max
+
+// Handler holds the rules of one transaction type. Errors that wrap
+// ErrRejected are recorded as error transactions; other errors mean the
+// request is invalid and it is dropped.
+type Handler interface {
+	// Validate checks the amount before the balance is read.
+	Validate(amount float64) error
+	// Apply returns the balance after the transaction.
+	Apply(balance, amount float64) (float64, error)
+}
+
+// Deposit adds the amount to the balance, which may not exceed Limit.
+type Deposit struct {
+	Limit float64
+}
+
+func (d Deposit) Validate(amount float64) error {
+	if amount < 0 {
+		return fmt.Errorf("deposit amount cannot be negative")
+	}
+	if amount > d.Limit {
+		return fmt.Errorf("%w: deposit amount exceeds the limit", ErrRejected)
+	}
+	return nil
+}
+
+func (d Deposit) Apply(balance, amount float64) (float64, error) {
+	if balance+amount > d.Limit {
+		return 0, fmt.Errorf("%w: deposit would exceed the balance limit", ErrRejected)
+	}
+	return balance + amount, nil
+}
+
+// Withdraw takes the amount from the balance, which may not go negative.
+type Withdraw struct{}
+
+func (Withdraw) Validate(amount float64) error {
+	if amount <= 0 {
+		return fmt.Errorf("withdraw amount must be greater than 0")
+	}
+	return nil
+}
+
+func (Withdraw) Apply(balance, amount float64) (float64, error) {
+	if balance < amount {
+		return 0, fmt.Errorf("%w: insufficient balance", ErrRejected)
+	}
+	return balance - amount, nil
+}
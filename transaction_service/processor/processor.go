@@ -0,0 +1,209 @@
+// Package processor applies deposits, withdrawals and any other registered
+// transaction type to wallet balances through sql-service. The rules of each
+// type live in a Handler; Processor runs the steps they share: read the
+// balance, update it, record the transaction and publish the events.
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"common/logging"
+	pb "sqlapi/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Transaction statuses recorded in sql-service.
+const (
+	StatusSuccess = "Success"
+	StatusError   = "error"
+)
+
+// ErrRejected wraps the reason a request was turned down by a business
+// rule. The attempt is recorded as an error transaction.
+var ErrRejected = errors.New("rejected")
+
+// SQLService is the part of the sql-service client the processor uses.
+type SQLService interface {
+	GetBalance(ctx context.Context, in *pb.WalletIdRequest, opts ...grpc.CallOption) (*pb.BalanceResponse, error)
+	UpdateBalance(ctx context.Context, in *pb.UpdateBalanceRequest, opts ...grpc.CallOption) (*pb.Empty, error)
+	CreateTransaction(ctx context.Context, in *pb.Transaction, opts ...grpc.CallOption) (*pb.Empty, error)
+}
+
+// Publisher is told about every balance change and recorded transaction;
+// *events.Publisher is the one used in production.
+type Publisher interface {
+	Balance(ctx context.Context, walletID int, transactionID string, balance float64)
+	Transaction(ctx context.Context, t *pb.Transaction)
+}
+
+// Request asks for one transaction of a wallet.
+type Request struct {
+	Type          string
+	TransactionID string
+	WalletID      int
+	Amount        float64
+	Principal     string
+}
+
+// Outcome is what became of a request.
+type Outcome struct {
+	TransactionID string
+	// Status is the status of the recorded transaction; empty when none was
+	// recorded.
+	Status string
+	// Applied reports whether the balance was changed. It is also set with
+	// an error when the transaction could not be recorded afterwards.
+	Applied bool
+	Balance float64
+	// Err wraps ErrRejected when a rule turned the request down.
+	Err error
+}
+
+// Processor runs requests through the handler registered for their type.
+// It is safe for concurrent use once the handlers are registered.
+type Processor struct {
+	client    SQLService
+	publisher Publisher
+	handlers  map[string]Handler
+	// ConflictRetries is how many more times a request is tried when the
+	// balance changed between reading and updating it.
+	ConflictRetries int
+}
+
+// DefaultConflictRetries is the ConflictRetries of New.
+const DefaultConflictRetries = 10
+
+// New returns a processor with the deposit and withdraw handlers
+// registered.
+func New(client SQLService, publisher Publisher) *Processor {
+	p := &Processor{client: client, publisher: publisher, handlers: make(map[string]Handler), ConflictRetries: DefaultConflictRetries}
+	p.Register(TypeDeposit, Deposit{Limit: BalanceLimit})
+	p.Register(TypeWithdraw, Withdraw{})
+	return p
+}
+
+// Register makes h handle the requests of type typ, replacing the handler
+// registered before. It must not be called while requests are processed.
+func (p *Processor) Register(typ string, h Handler) {
+	p.handlers[typ] = h
+}
+
+// Handler returns the handler registered for typ.
+func (p *Processor) Handler(typ string) (Handler, bool) {
+	h, ok := p.handlers[typ]
+	return h, ok
+}
+
+// Process runs req and logs the outcome.
+func (p *Processor) Process(ctx context.Context, req Request) Outcome {
+	logger := logging.FromContext(ctx)
+	outcome := Outcome{TransactionID: req.TransactionID}
+
+	h, ok := p.handlers[req.Type]
+	if !ok {
+		outcome.Err = fmt.Errorf("unknown transaction type %q", req.Type)
+		logger.Warn("Error: Unknown transaction type.", "type", req.Type)
+		return outcome
+	}
+
+	if err := h.Validate(req.Amount); err != nil {
+		return p.reject(ctx, req, outcome, err)
+	}
+
+	// The update only goes through if the balance is still the one the
+	// handler saw; otherwise another request changed it in between and
+	// this one starts over from the new balance.
+	var newBalance float64
+	for attempt := 0; ; attempt++ {
+		balanceResponse, err := p.client.GetBalance(ctx, &pb.WalletIdRequest{WalletId: int32(req.WalletID)})
+		if err != nil {
+			logger.Error("Failed to get balance", "error", err)
+			outcome.Err = err
+			return outcome
+		}
+		balance := balanceResponse.Balance
+
+		newBalance, err = h.Apply(balance, req.Amount)
+		if err != nil {
+			return p.reject(ctx, req, outcome, err)
+		}
+
+		_, err = p.client.UpdateBalance(ctx, &pb.UpdateBalanceRequest{
+			WalletId:        int32(req.WalletID),
+			NewBalance:      newBalance,
+			TransactionId:   req.TransactionID,
+			Principal:       req.Principal,
+			ExpectedBalance: &balance,
+		})
+		if status.Code(err) == codes.Aborted && attempt < p.ConflictRetries {
+			logger.Debug("Balance changed concurrently, retrying", "attempt", attempt+1)
+			continue
+		}
+		if status.Code(err) == codes.FailedPrecondition {
+			// The wallet is frozen.
+			return p.reject(ctx, req, outcome, fmt.Errorf("%w: %s", ErrRejected, status.Convert(err).Message()))
+		}
+		if err != nil {
+			logger.Error("Failed to update balance", "error", err)
+			outcome.Err = err
+			return outcome
+		}
+		break
+	}
+	outcome.Applied = true
+	outcome.Balance = newBalance
+	p.publisher.Balance(ctx, req.WalletID, req.TransactionID, newBalance)
+
+	if err := p.record(ctx, req, StatusSuccess); err != nil {
+		outcome.Err = err
+		return outcome
+	}
+	outcome.Status = StatusSuccess
+
+	return outcome
+}
+
+// reject records a request turned down by a rule as an error transaction.
+// Requests that are invalid rather than rejected are not recorded.
+func (p *Processor) reject(ctx context.Context, req Request, outcome Outcome, reason error) Outcome {
+	logging.FromContext(ctx).Warn("Error: "+reason.Error(), "type", req.Type, "amount", req.Amount)
+	outcome.Err = reason
+	if !errors.Is(reason, ErrRejected) {
+		return outcome
+	}
+
+	if err := p.record(ctx, req, StatusError); err != nil {
+		outcome.Err = err
+		return outcome
+	}
+	outcome.Status = StatusError
+	return outcome
+}
+
+func (p *Processor) record(ctx context.Context, req Request, transactionStatus string) error {
+	logger := logging.FromContext(ctx)
+
+	transaction := &pb.Transaction{
+		TransactionId: req.TransactionID,
+		WalletId:      int32(req.WalletID),
+		Amount:        req.Amount,
+		Type:          req.Type,
+		RequestTime:   &timestamppb.Timestamp{},
+		Status:        transactionStatus,
+		Principal:     req.Principal,
+	}
+	if _, err := p.client.CreateTransaction(ctx, transaction); err != nil {
+		logger.Error("Failed to create a "+req.Type+" transaction", "status", transactionStatus, "error", err)
+		return err
+	}
+	logger.Info("New "+req.Type+" transaction created successfully", "status", transactionStatus)
+	p.publisher.Transaction(ctx, transaction)
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "sqlapi/pb"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSQL keeps balances in a map and records the transactions.
+type fakeSQL struct {
+	balances     map[int32]float64
+	transactions []*pb.Transaction
+	updateErr    error
+}
+
+func (f *fakeSQL) GetBalance(ctx context.Context, in *pb.WalletIdRequest, opts ...grpc.CallOption) (*pb.BalanceResponse, error) {
+	balance, ok := f.balances[in.WalletId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "wallet %d: not found", in.WalletId)
+	}
+	return &pb.BalanceResponse{Balance: balance}, nil
+}
+
+func (f *fakeSQL) UpdateBalance(ctx context.Context, in *pb.UpdateBalanceRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	f.balances[in.WalletId] = in.NewBalance
+	return &pb.Empty{}, nil
+}
+
+func (f *fakeSQL) CreateTransaction(ctx context.Context, in *pb.Transaction, opts ...grpc.CallOption) (*pb.Empty, error) {
+	f.transactions = append(f.transactions, in)
+	return &pb.Empty{}, nil
+}
+
+// fakePublisher records the published events as strings.
+type fakePublisher struct {
+	events []string
+}
+
+func (f *fakePublisher) Balance(ctx context.Context, walletID int, transactionID string, balance float64) {
+	f.events = append(f.events, fmt.Sprintf("balance %d %.2f", walletID, balance))
+}
+
+func (f *fakePublisher) Transaction(ctx context.Context, t *pb.Transaction) {
+	f.events = append(f.events, fmt.Sprintf("transaction %s %s", t.Type, t.Status))
+}
+
+func newProcessor(balances map[int32]float64) (*Processor, *fakeSQL, *fakePublisher) {
+	client := &fakeSQL{balances: balances}
+	publisher := &fakePublisher{}
+	return New(client, publisher), client, publisher
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         Request
+		balance     float64
+		wantStatus  string
+		wantApplied bool
+		wantBalance float64
+		rejected    bool
+	}{
+		{"deposit", Request{Type: TypeDeposit, Amount: 50}, 100, StatusSuccess, true, 150, false},
+		{"withdraw", Request{Type: TypeWithdraw, Amount: 40}, 100, StatusSuccess, true, 60, false},
+		{"whole balance", Request{Type: TypeWithdraw, Amount: 100}, 100, StatusSuccess, true, 0, false},
+		{"insufficient balance", Request{Type: TypeWithdraw, Amount: 101}, 100, StatusError, false, 100, true},
+		{"deposit over the limit", Request{Type: TypeDeposit, Amount: BalanceLimit + 1}, 0, StatusError, false, 0, true},
+		{"balance over the limit", Request{Type: TypeDeposit, Amount: BalanceLimit}, 1, StatusError, false, 1, true},
+		{"negative deposit", Request{Type: TypeDeposit, Amount: -1}, 100, "", false, 100, false},
+		{"zero withdraw", Request{Type: TypeWithdraw, Amount: 0}, 100, "", false, 100, false},
+		{"unknown type", Request{Type: "refund", Amount: 1}, 100, "", false, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, client, _ := newProcessor(map[int32]float64{1: tt.balance})
+			tt.req.WalletID = 1
+			tt.req.TransactionID = "f47cbde3-98d8-47cb-a30b-1046b1f70b75"
+
+			outcome := p.Process(context.Background(), tt.req)
+
+			if outcome.Status != tt.wantStatus || outcome.Applied != tt.wantApplied {
+				t.Errorf("got status %q applied %v, want %q %v", outcome.Status, outcome.Applied, tt.wantStatus, tt.wantApplied)
+			}
+			if errors.Is(outcome.Err, ErrRejected) != tt.rejected || (outcome.Err == nil) != tt.wantApplied {
+				t.Errorf("unexpected error %v", outcome.Err)
+			}
+			if got := client.balances[1]; got != tt.wantBalance {
+				t.Errorf("balance %v, want %v", got, tt.wantBalance)
+			}
+			if tt.wantStatus == "" && len(client.transactions) != 0 {
+				t.Errorf("recorded %+v for an invalid request", client.transactions)
+			}
+			if tt.wantStatus != "" && (len(client.transactions) != 1 || client.transactions[0].Status != tt.wantStatus || client.transactions[0].TransactionId != tt.req.TransactionID) {
+				t.Errorf("recorded %+v, want one %s transaction", client.transactions, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProcessPublishesAfterEachStep(t *testing.T) {
+	p, _, publisher := newProcessor(map[int32]float64{1: 100})
+
+	p.Process(context.Background(), Request{Type: TypeDeposit, WalletID: 1, Amount: 5})
+	p.Process(context.Background(), Request{Type: TypeWithdraw, WalletID: 1, Amount: 500})
+
+	want := []string{"balance 1 105.00", "transaction deposit Success", "transaction withdraw error"}
+	if fmt.Sprint(publisher.events) != fmt.Sprint(want) {
+		t.Errorf("published %v, want %v", publisher.events, want)
+	}
+}
+
+func TestProcessFailedUpdateRecordsNothing(t *testing.T) {
+	p, client, publisher := newProcessor(map[int32]float64{1: 100})
+	client.updateErr = status.Error(codes.Unavailable, "down")
+
+	outcome := p.Process(context.Background(), Request{Type: TypeDeposit, WalletID: 1, Amount: 5})
+
+	if outcome.Applied || status.Code(outcome.Err) != codes.Unavailable {
+		t.Errorf("got applied %v error %v, want the update error", outcome.Applied, outcome.Err)
+	}
+	if len(client.transactions) != 0 || len(publisher.events) != 0 {
+		t.Errorf("recorded %v and published %v after a failed update", client.transactions, publisher.events)
+	}
+}
+
+func TestProcessFrozenWalletIsRejected(t *testing.T) {
+	p, client, _ := newProcessor(map[int32]float64{1: 100})
+	client.updateErr = status.Error(codes.FailedPrecondition, "wallet 1: wallet is frozen")
+
+	outcome := p.Process(context.Background(), Request{Type: TypeWithdraw, WalletID: 1, Amount: 5})
+
+	if outcome.Applied || !errors.Is(outcome.Err, ErrRejected) || outcome.Status != StatusError {
+		t.Errorf("got applied %v status %q error %v, want a rejection", outcome.Applied, outcome.Status, outcome.Err)
+	}
+	if len(client.transactions) != 1 || client.transactions[0].Status != StatusError {
+		t.Errorf("recorded %+v, want one error transaction", client.transactions)
+	}
+}
+
+// fee takes a fixed charge on top of the amount.
+type fee struct{ charge float64 }
+
+func (f fee) Validate(amount float64) error { return nil }
+
+func (f fee) Apply(balance, amount float64) (float64, error) {
+	if balance < amount+f.charge {
+		return 0, fmt.Errorf("%w: insufficient balance for the fee", ErrRejected)
+	}
+	return balance - amount - f.charge, nil
+}
+
+func TestRegisteredHandler(t *testing.T) {
+	p, client, _ := newProcessor(map[int32]float64{1: 100})
+	p.Register("fee", fee{charge: 1})
+
+	outcome := p.Process(context.Background(), Request{Type: "fee", WalletID: 1, Amount: 10})
+
+	if outcome.Err != nil || client.balances[1] != 89 {
+		t.Errorf("got %v and balance %v, want balance 89", outcome.Err, client.balances[1])
+	}
+	if len(client.transactions) != 1 || client.transactions[0].Type != "fee" {
+		t.Errorf("recorded %+v, want one fee transaction", client.transactions)
+	}
+}
+
+func TestConsumerHandle(t *testing.T) {
+	p, client, _ := newProcessor(map[int32]float64{2: 10})
+	consumer := &Consumer{Processor: p, Type: TypeWithdraw, Queue: "withdraw_requests", Timeout: time.Second}
+
+	outcome := consumer.Handle(amqp.Delivery{Body: []byte(`{"wallet_id": 2, "amount": 4, "principal": "merchant"}`)})
+	if outcome.Err != nil || client.balances[2] != 6 {
+		t.Fatalf("got %v and balance %v, want balance 6", outcome.Err, client.balances[2])
+	}
+	if recorded := client.transactions[0]; recorded.Principal != "merchant" || recorded.TransactionId != outcome.TransactionID || outcome.TransactionID == "" {
+		t.Errorf("recorded %+v for outcome %+v", recorded, outcome)
+	}
+
+	const transactionID = "7d2b1f8e-3c4a-4e59-9a61-0f5d8c2b7e41"
+	outcome = consumer.Handle(amqp.Delivery{Body: []byte(`{"transaction_id": "` + transactionID + `", "wallet_id": 2, "amount": 1}`)})
+	if outcome.TransactionID != transactionID || client.transactions[1].TransactionId != transactionID {
+		t.Errorf("message transaction id %s recorded as %s", transactionID, client.transactions[1].TransactionId)
+	}
+
+	if outcome := consumer.Handle(amqp.Delivery{Body: []byte(`{`)}); outcome.Err == nil {
+		t.Error("malformed message accepted")
+	}
+}
+
+// TestBalanceLimitFitsStore checks BalanceLimit against what sql-service
+// stores: a balance at the limit is kept, and a deposit past it is
+// rejected and still recorded as an error transaction.
+func TestBalanceLimitFitsStore(t *testing.T) {
+	_, store, p := newMemorySQL(t, BalanceLimit-10)
+	ctx := context.Background()
+
+	if outcome := p.Process(ctx, Request{Type: TypeDeposit, TransactionID: uuid.NewString(), WalletID: 1, Amount: 10}); !outcome.Applied || outcome.Err != nil {
+		t.Fatalf("deposit up to the limit: %+v", outcome)
+	}
+	outcome := p.Process(ctx, Request{Type: TypeDeposit, TransactionID: uuid.NewString(), WalletID: 1, Amount: 0.01})
+	if outcome.Applied || outcome.Status != StatusError || !errors.Is(outcome.Err, ErrRejected) {
+		t.Errorf("deposit past the limit: %+v", outcome)
+	}
+	if balance, err := store.GetBalance(ctx, 1); err != nil || balance != BalanceLimit {
+		t.Errorf("balance %v, %v, want %v", balance, err, BalanceLimit)
+	}
+}
@@ -0,0 +1,333 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "sqlapi/pb"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ledger is a concurrency-safe sql-service stand-in that watches every
+// balance update for the invariants the pipeline must keep.
+type ledger struct {
+	mu           sync.Mutex
+	initial      map[int32]float64
+	balances     map[int32]float64
+	transactions []*pb.Transaction
+	updates      int
+	violations   []string
+	// ignoreExpected makes UpdateBalance unconditional, like sql-service
+	// before expected_balance: the last writer wins.
+	ignoreExpected bool
+	// afterRead runs after every GetBalance, outside the lock, to let other
+	// requests in between reading and updating a balance.
+	afterRead func()
+}
+
+func newLedger(balances map[int32]float64) *ledger {
+	l := &ledger{initial: balances, balances: make(map[int32]float64), afterRead: runtime.Gosched}
+	for id, balance := range balances {
+		l.balances[id] = balance
+	}
+	return l
+}
+
+func (l *ledger) GetBalance(ctx context.Context, in *pb.WalletIdRequest, opts ...grpc.CallOption) (*pb.BalanceResponse, error) {
+	l.mu.Lock()
+	balance, ok := l.balances[in.WalletId]
+	l.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "wallet %d: not found", in.WalletId)
+	}
+	if l.afterRead != nil {
+		l.afterRead()
+	}
+	return &pb.BalanceResponse{Balance: balance}, nil
+}
+
+func (l *ledger) UpdateBalance(ctx context.Context, in *pb.UpdateBalanceRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.balances[in.WalletId]
+	if in.ExpectedBalance != nil && cents(current) != cents(*in.ExpectedBalance) {
+		if !l.ignoreExpected {
+			return nil, status.Errorf(codes.Aborted, "wallet %d: balance changed", in.WalletId)
+		}
+		l.violations = append(l.violations, fmt.Sprintf("lost update: wallet %d was %.2f when %s wrote %.2f computed from %.2f",
+			in.WalletId, current, in.TransactionId, in.NewBalance, *in.ExpectedBalance))
+	}
+	if in.NewBalance < 0 {
+		l.violations = append(l.violations, fmt.Sprintf("negative balance: wallet %d set to %.2f by %s", in.WalletId, in.NewBalance, in.TransactionId))
+	}
+	l.balances[in.WalletId] = in.NewBalance
+	l.updates++
+	return &pb.Empty{}, nil
+}
+
+func (l *ledger) CreateTransaction(ctx context.Context, in *pb.Transaction, opts ...grpc.CallOption) (*pb.Empty, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.transactions = append(l.transactions, in)
+	return &pb.Empty{}, nil
+}
+
+// check returns the invariants that do not hold once all requests are done:
+// the ones seen during the updates, every balance equal to its initial
+// balance plus its successful transactions, and one successful transaction
+// per balance update.
+func (l *ledger) check() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	violations := append([]string(nil), l.violations...)
+
+	expected := make(map[int32]float64)
+	for id, balance := range l.initial {
+		expected[id] = balance
+	}
+	succeeded := 0
+	for _, t := range l.transactions {
+		if t.Status != StatusSuccess {
+			continue
+		}
+		succeeded++
+		switch t.Type {
+		case TypeDeposit:
+			expected[t.WalletId] += t.Amount
+		case TypeWithdraw:
+			expected[t.WalletId] -= t.Amount
+		}
+	}
+	for id, balance := range l.balances {
+		if cents(balance) != cents(expected[id]) {
+			violations = append(violations, fmt.Sprintf("drift: wallet %d holds %.2f, its transactions add up to %.2f", id, balance, expected[id]))
+		}
+	}
+	if succeeded != l.updates {
+		violations = append(violations, fmt.Sprintf("%d balance updates but %d successful transactions", l.updates, succeeded))
+	}
+	return violations
+}
+
+func cents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+// op is one step of a stress run. A transfer is a withdrawal from Wallet
+// followed, if it went through, by a deposit to To.
+type op struct {
+	Kind   string
+	Wallet int32
+	To     int32
+	Amount float64
+}
+
+func (o op) String() string {
+	if o.Kind == "transfer" {
+		return fmt.Sprintf("transfer %.2f %d→%d", o.Amount, o.Wallet, o.To)
+	}
+	return fmt.Sprintf("%s %.2f %d", o.Kind, o.Amount, o.Wallet)
+}
+
+// stressWallets start with these balances; few wallets keep contention high.
+var stressWallets = map[int32]float64{1: 100, 2: 50, 3: 0}
+
+func randomOps(rng *rand.Rand, n int) []op {
+	kinds := []string{TypeDeposit, TypeWithdraw, "transfer"}
+	ops := make([]op, n)
+	for i := range ops {
+		ops[i] = op{
+			Kind:   kinds[rng.Intn(len(kinds))],
+			Wallet: int32(rng.Intn(len(stressWallets)) + 1),
+			To:     int32(rng.Intn(len(stressWallets)) + 1),
+			Amount: float64(rng.Intn(5000)+1) / 100,
+		}
+	}
+	return ops
+}
+
+// runOps runs ops on workers goroutines against a fresh ledger and returns
+// the broken invariants.
+func runOps(ops []op, workers int, configure func(*ledger, *Processor)) []string {
+	l := newLedger(stressWallets)
+	p := New(l, nopPublisher{})
+	if configure != nil {
+		configure(l, p)
+	}
+
+	queue := make(chan op)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range queue {
+				runOp(p, o)
+			}
+		}()
+	}
+	for _, o := range ops {
+		queue <- o
+	}
+	close(queue)
+	wg.Wait()
+
+	return l.check()
+}
+
+func runOp(p *Processor, o op) {
+	ctx := context.Background()
+	request := func(typ string, walletID int32) Outcome {
+		return p.Process(ctx, Request{Type: typ, TransactionID: uuid.New().String(), WalletID: int(walletID), Amount: o.Amount})
+	}
+
+	switch o.Kind {
+	case "transfer":
+		if request(TypeWithdraw, o.Wallet).Applied {
+			request(TypeDeposit, o.To)
+		}
+	default:
+		request(o.Kind, o.Wallet)
+	}
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Balance(context.Context, int, string, float64) {}
+func (nopPublisher) Transaction(context.Context, *pb.Transaction)  {}
+
+// minimize drops ops as long as the rest still fails, first in large
+// chunks, then one by one. Concurrency makes a failure depend on timing,
+// so fails should try a candidate more than once.
+func minimize(ops []op, fails func([]op) bool) []op {
+	for size := len(ops) / 2; size >= 1; size /= 2 {
+		for start := 0; start+size <= len(ops); {
+			candidate := append(append([]op(nil), ops[:start]...), ops[start+size:]...)
+			if fails(candidate) {
+				ops = candidate
+				continue
+			}
+			start += size
+		}
+	}
+	return ops
+}
+
+func TestStressInvariants(t *testing.T) {
+	n := 5000
+	if testing.Short() {
+		n = 500
+	}
+	seed := time.Now().UnixNano()
+	ops := randomOps(rand.New(rand.NewSource(seed)), n)
+
+	violations := runOps(ops, 32, nil)
+	if len(violations) == 0 {
+		return
+	}
+
+	fails := func(candidate []op) bool {
+		for attempt := 0; attempt < 20; attempt++ {
+			if len(runOps(candidate, 32, nil)) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	minimal := minimize(ops, fails)
+	t.Errorf("seed %d: %d of %d ops break invariants:\n  %s\nminimal failing sequence (%d ops):\n  %s",
+		seed, len(violations), n, strings.Join(firstN(violations, 10), "\n  "), len(minimal), opsString(minimal))
+}
+
+// TestReadUpdateRace shows the lost update the read-then-update flow allows
+// when sql-service applies updates unconditionally, and that the
+// expected_balance check prevents it: two deposits both read the balance
+// before either writes.
+func TestReadUpdateRace(t *testing.T) {
+	deposits := []op{{Kind: TypeDeposit, Wallet: 1, Amount: 10}, {Kind: TypeDeposit, Wallet: 1, Amount: 20}}
+
+	bothRead := func(l *ledger, _ *Processor) {
+		var reads sync.WaitGroup
+		reads.Add(len(deposits))
+		var mu sync.Mutex
+		first := len(deposits)
+		l.afterRead = func() {
+			mu.Lock()
+			waiting := first > 0
+			first--
+			mu.Unlock()
+			if waiting {
+				reads.Done()
+				reads.Wait()
+			}
+		}
+	}
+
+	unconditional := runOps(deposits, 2, func(l *ledger, p *Processor) {
+		bothRead(l, p)
+		l.ignoreExpected = true
+	})
+	if !hasPrefix(unconditional, "lost update") || !hasPrefix(unconditional, "drift") {
+		t.Errorf("unconditional updates: got %q, want a lost update and drift", unconditional)
+	}
+
+	if conditional := runOps(deposits, 2, bothRead); len(conditional) > 0 {
+		t.Errorf("conditional updates: %q", conditional)
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	ops := randomOps(rand.New(rand.NewSource(1)), 200)
+	culprit := op{Kind: TypeWithdraw, Wallet: 3, Amount: 1}
+	ops = append(ops[:120], append([]op{culprit}, ops[120:]...)...)
+
+	minimal := minimize(ops, func(candidate []op) bool {
+		for _, o := range candidate {
+			if o == culprit {
+				return true
+			}
+		}
+		return false
+	})
+	if len(minimal) != 1 || minimal[0] != culprit {
+		t.Errorf("minimized to %s, want %s", opsString(minimal), culprit)
+	}
+}
+
+func hasPrefix(violations []string, prefix string) bool {
+	for _, v := range violations {
+		if strings.HasPrefix(v, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstN(s []string, n int) []string {
+	if len(s) > n {
+		return append(s[:n:n], fmt.Sprintf("… and %d more", len(s)-n))
+	}
+	return s
+}
+
+func opsString(ops []op) string {
+	parts := make([]string, len(ops))
+	for i, o := range ops {
+		parts[i] = o.String()
+	}
+	return strings.Join(parts, "\n  ")
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrDestinationNotAllowed is returned when a webhook URL resolves to an
+// address inside the service network.
+var ErrDestinationNotAllowed = errors.New("destination not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range, which is as private as
+// the RFC 1918 ones.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress reports whether a webhook may be delivered to addr:
+// loopback, private, link-local (cloud metadata included), unspecified and
+// multicast addresses are refused.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !(addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr))
+}
+
+// newHTTPClient returns a client that only connects to the addresses
+// allowed accepts. The check runs on the address actually dialled, after
+// DNS resolution, so a public name pointing inside is refused too.
+func newHTTPClient(timeout time.Duration, allowed func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowed(addrPort.Addr()) {
+				return ErrDestinationNotAllowed
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// A proxy would be dialled instead of the endpoint and escape
+			// the check.
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     time.Minute,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// failureClass describes why a request failed without the details of the
+// dial error, which would tell a merchant reading the delivery log about
+// the network the worker runs in.
+func failureClass(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrDestinationNotAllowed):
+		return ErrDestinationNotAllowed.Error()
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "host not found"
+	case errors.As(err, &certErr) || errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr):
+		return "TLS certificate not trusted"
+	}
+	return "connection failed"
+}
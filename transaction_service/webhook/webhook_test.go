@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	pb "sqlapi/pb"
+
+	"google.golang.org/grpc"
+)
+
+const secret = "whsec_test"
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign(secret, now, body)
+
+	if err := Verify(secret, header, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := Verify(secret, header, []byte(`{"id":"2"}`), 5*time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered body: got %v", err)
+	}
+	if err := Verify("whsec_other", header, body, 5*time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("wrong secret: got %v", err)
+	}
+	if err := Verify(secret, header, body, 5*time.Minute, now.Add(10*time.Minute)); !errors.Is(err, ErrExpiredSignature) {
+		t.Errorf("replayed request: got %v", err)
+	}
+	if err := Verify(secret, "v1=abc", body, 5*time.Minute, now); err == nil {
+		t.Error("header without timestamp accepted")
+	}
+}
+
+// fakeQueue hands out its deliveries once and records the reports.
+type fakeQueue struct {
+	mu         sync.Mutex
+	deliveries []*pb.WebhookDelivery
+	reports    []*pb.WebhookAttempt
+}
+
+func (q *fakeQueue) ClaimWebhookDeliveries(ctx context.Context, in *pb.ClaimWebhookDeliveriesRequest, opts ...grpc.CallOption) (*pb.WebhookDeliveryList, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	batch := &pb.WebhookDeliveryList{Deliveries: q.deliveries}
+	q.deliveries = nil
+	return batch, nil
+}
+
+func (q *fakeQueue) ReportWebhookDelivery(ctx context.Context, in *pb.WebhookAttempt, opts ...grpc.CallOption) (*pb.Empty, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reports = append(q.reports, in)
+	return &pb.Empty{}, nil
+}
+
+func newWorker(queue Queue) *Worker {
+	return &Worker{
+		Queue:        queue,
+		HTTP:         newHTTPClient(200*time.Millisecond, func(netip.Addr) bool { return true }),
+		PollInterval: 10 * time.Millisecond,
+		BatchSize:    10,
+		QueueTimeout: time.Second,
+	}
+}
+
+func TestWorkerDeliversSignedEvent(t *testing.T) {
+	payload := `{"id":"e1","type":"transaction.completed","data":{"wallet_id":1}}`
+	received := make(chan *http.Request, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		if string(body) != payload {
+			t.Errorf("body = %s", body)
+		}
+		received <- r
+	}))
+	defer server.Close()
+
+	queue := &fakeQueue{deliveries: []*pb.WebhookDelivery{{
+		DeliveryId: "d1", WebhookId: "w1", EventId: "e1", EventType: "transaction.completed",
+		Payload: payload, Url: server.URL, Secret: secret,
+	}}}
+
+	n, err := newWorker(queue).Poll(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Poll = %d, %v", n, err)
+	}
+
+	r := <-received
+	if r.Header.Get(EventIDHeader) != "e1" || r.Header.Get(EventTypeHeader) != "transaction.completed" || r.Header.Get(DeliveryHeader) != "d1" {
+		t.Errorf("unexpected headers %v", r.Header)
+	}
+	if len(queue.reports) != 1 || !queue.reports[0].Success || queue.reports[0].StatusCode != http.StatusOK {
+		t.Fatalf("reports = %v", queue.reports)
+	}
+}
+
+func TestWorkerReportsFailures(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, failing.URL, http.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+
+	queue := &fakeQueue{deliveries: []*pb.WebhookDelivery{
+		{DeliveryId: "500", Url: failing.URL, Secret: secret},
+		{DeliveryId: "302", Url: redirecting.URL, Secret: secret},
+		{DeliveryId: "timeout", Url: slow.URL, Secret: secret},
+	}}
+
+	if _, err := newWorker(queue).Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int32{"500": 500, "302": 302, "timeout": 0}
+	if len(queue.reports) != len(want) {
+		t.Fatalf("got %d reports, want %d", len(queue.reports), len(want))
+	}
+	for _, report := range queue.reports {
+		if report.Success || report.Error == "" || report.StatusCode != want[report.DeliveryId] {
+			t.Errorf("delivery %s reported %v", report.DeliveryId, report)
+		}
+	}
+}
+
+func TestWorkerRefusesInternalDestinations(t *testing.T) {
+	reached := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer internal.Close()
+
+	queue := &fakeQueue{deliveries: []*pb.WebhookDelivery{{DeliveryId: "d1", Url: internal.URL, Secret: secret}}}
+	worker := newWorker(queue)
+	worker.HTTP = NewHTTPClient(200 * time.Millisecond)
+	if _, err := worker.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if reached {
+		t.Error("the worker connected to a loopback address")
+	}
+	if len(queue.reports) != 1 || queue.reports[0].Success || queue.reports[0].Error != ErrDestinationNotAllowed.Error() {
+		t.Fatalf("reports = %v", queue.reports)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.5":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"100.64.0.1":           false,
+		"::ffff:10.0.0.1":      false,
+		"224.0.0.1":            false,
+	} {
+		if got := publicAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("%s: public %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestFailureClassHidesDetails(t *testing.T) {
+	closed := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	url := closed.URL
+	closed.Close()
+
+	_, err := newHTTPClient(200*time.Millisecond, func(netip.Addr) bool { return true }).Post(url, "application/json", nil)
+	if err == nil {
+		t.Fatal("posting to a closed port succeeded")
+	}
+	if got := failureClass(err); got != "connection failed" {
+		t.Errorf("closed port reported as %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"common/logging"
+	pb "sqlapi/pb"
+
+	"google.golang.org/grpc"
+)
+
+// Queue is the part of the sql-service client the worker uses.
+type Queue interface {
+	ClaimWebhookDeliveries(ctx context.Context, in *pb.ClaimWebhookDeliveriesRequest, opts ...grpc.CallOption) (*pb.WebhookDeliveryList, error)
+	ReportWebhookDelivery(ctx context.Context, in *pb.WebhookAttempt, opts ...grpc.CallOption) (*pb.Empty, error)
+}
+
+// Worker polls the delivery queue and POSTs due events.
+type Worker struct {
+	Queue Queue
+	// HTTP sends the requests; NewHTTPClient is used when nil.
+	HTTP         *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+	// QueueTimeout bounds every call to the queue.
+	QueueTimeout time.Duration
+}
+
+// NewHTTPClient returns a client that does not follow redirects, so an
+// endpoint must answer with 2xx itself for a delivery to count, and that
+// refuses to connect to addresses inside the service network: merchants
+// choose the URLs.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return newHTTPClient(timeout, publicAddress)
+}
+
+// Run delivers until ctx is done. It only sleeps when the queue is empty.
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		n, err := w.Poll(ctx)
+		if err != nil {
+			slog.Warn("Failed to claim webhook deliveries", "error", err)
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// Poll claims one batch, delivers it concurrently and reports every
+// outcome. It returns the number of deliveries claimed.
+func (w *Worker) Poll(ctx context.Context) (int, error) {
+	claimCtx, cancel := context.WithTimeout(ctx, w.QueueTimeout)
+	batch, err := w.Queue.ClaimWebhookDeliveries(claimCtx, &pb.ClaimWebhookDeliveriesRequest{Limit: int32(w.BatchSize)})
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range batch.Deliveries {
+		wg.Add(1)
+		go func(delivery *pb.WebhookDelivery) {
+			defer wg.Done()
+
+			ctx := logging.With(ctx, "delivery_id", delivery.DeliveryId, "webhook_id", delivery.WebhookId, "event_type", delivery.EventType)
+			attempt, cause := w.deliver(ctx, delivery)
+			if attempt.Success {
+				logging.FromContext(ctx).Info("Webhook delivered", "status_code", attempt.StatusCode, "attempt", delivery.Attempts+1)
+			} else {
+				logging.FromContext(ctx).Warn("Webhook delivery failed", "status_code", attempt.StatusCode, "error", cause, "attempt", delivery.Attempts+1)
+			}
+
+			reportCtx, cancel := context.WithTimeout(ctx, w.QueueTimeout)
+			defer cancel()
+			if _, err := w.Queue.ReportWebhookDelivery(reportCtx, attempt); err != nil {
+				// The lease runs out and the delivery is attempted again.
+				logging.FromContext(ctx).Error("Failed to report webhook delivery", "error", err)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(batch.Deliveries), nil
+}
+
+// deliver POSTs one delivery. The attempt reported to the queue, and shown
+// to the merchant, only carries the class of a failure; the error itself is
+// returned for the log.
+func (w *Worker) deliver(ctx context.Context, delivery *pb.WebhookDelivery) (attempt *pb.WebhookAttempt, cause error) {
+	attempt = &pb.WebhookAttempt{DeliveryId: delivery.DeliveryId}
+	started := time.Now()
+	defer func() { attempt.DurationMs = time.Since(started).Milliseconds() }()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = "invalid URL"
+		return attempt, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wallet-webhooks/1")
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, started, body))
+	req.Header.Set(EventIDHeader, delivery.EventId)
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.DeliveryId)
+
+	client := w.HTTP
+	if client == nil {
+		client = NewHTTPClient(10 * time.Second)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		attempt.Error = failureClass(err)
+		return attempt, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = int32(resp.StatusCode)
+	attempt.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !attempt.Success {
+		attempt.Error = fmt.Sprintf("endpoint answered %s", resp.Status)
+		return attempt, errors.New(attempt.Error)
+	}
+	return attempt, nil
+}